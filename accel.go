@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+/* ------------------------------- Accelerators ------------------------------ */
+
+// dnnTargets maps FACE_DNN_TARGET to an OpenCV backend and target. The
+// accelerated ones need an OpenCV built with CUDA or OpenCL.
+var dnnTargets = map[string]struct {
+	backend gocv.NetBackendType
+	target  gocv.NetTargetType
+}{
+	"cpu":         {gocv.NetBackendDefault, gocv.NetTargetCPU},
+	"cuda":        {gocv.NetBackendCUDA, gocv.NetTargetCUDA},
+	"cuda_fp16":   {gocv.NetBackendCUDA, gocv.NetTargetCUDAFP16},
+	"opencl":      {gocv.NetBackendOpenCV, gocv.NetTargetFP32},
+	"opencl_fp16": {gocv.NetBackendOpenCV, gocv.NetTargetFP16},
+}
+
+// parseDNNTarget returns the backend and target of a FACE_DNN_TARGET name.
+func parseDNNTarget(name string) (gocv.NetBackendType, gocv.NetTargetType, error) {
+	t, ok := dnnTargets[name]
+	if !ok {
+		names := make([]string, 0, len(dnnTargets))
+		for n := range dnnTargets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return 0, 0, fmt.Errorf("unknown target %q (want one of %s)", name, strings.Join(names, ", "))
+	}
+	return t.backend, t.target, nil
+}
+
+// errAcceleratorOOM is a Forward that failed for lack of device memory,
+// typically a CUDA target on a shared GPU. Retrying the same batch won't
+// help, so forwardRetry returns it at once.
+var errAcceleratorOOM = errors.New("accelerator out of memory")
+
+// isAcceleratorOOM guesses from OpenCV's exception message whether a
+// failure is an out-of-memory one. OpenCV has no dedicated error code, so
+// this is best-effort: CUDA reports "out of memory" and OpenCL
+// CL_MEM_OBJECT_ALLOCATION_FAILURE or CL_OUT_OF_RESOURCES.
+func isAcceleratorOOM(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"out of memory", "cudaerrormemoryallocation", "cl_mem_object_allocation_failure", "cl_out_of_resources", "insufficient memory"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverOOM adapts to an out-of-memory failure of a batch of n tiles, and
+// reports whether the batch is worth retrying: tiles are first inferred in
+// batches half as large, then, once a single image doesn't fit either, the
+// net is moved to the CPU for good.
+func (d *DNNDetector) recoverOOM(n int, cause error) bool {
+	if d.stats != nil {
+		d.stats.ObserveAcceleratorOOM()
+	}
+	if n > 1 {
+		d.maxBatch = n / 2
+		log.Printf("[detector] %v, inferring at most %d tiles per batch", cause, d.maxBatch)
+		return true
+	}
+	if d.netCfg.Target == gocv.NetTargetCPU {
+		return false
+	}
+	from := d.netCfg.Target
+	if err := d.fallbackToCPU(); err != nil {
+		log.Printf("[detector] %v, and falling back to the CPU failed: %v", cause, err)
+		return false
+	}
+	log.Printf("[detector] %v, falling back from %s to the CPU for the next frames", cause, from)
+	if d.stats != nil {
+		d.stats.SetAcceleratorFallback(from.String())
+	}
+	return true
+}
+
+// fallbackToCPU reloads the net on the CPU target. A fresh net is safer
+// than retargeting one whose device buffers failed to allocate; the current
+// net is kept if the reload fails. Reinit and SwapModel keep the CPU.
+func (d *DNNDetector) fallbackToCPU() error {
+	cfg := d.netCfg
+	cfg.Backend, cfg.Target = gocv.NetBackendDefault, gocv.NetTargetCPU
+	net, err := d.load(cfg)
+	if err != nil {
+		return err
+	}
+	d.net.Close()
+	d.net, d.netCfg = net, cfg
+	meta := *d.meta // snapshots already published keep the old one
+	meta.Backend, meta.Target = cfg.Backend.String(), cfg.Target.String()
+	d.meta = &meta
+	return nil
+}
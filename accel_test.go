@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// batchOOMNet runs out of device memory on batches of more than max images.
+type batchOOMNet struct {
+	*fakeNet
+	max, batch int
+	oom        bool
+}
+
+func (n *batchOOMNet) SetInput(blob gocv.Mat, name string) {
+	n.fakeNet.SetInput(blob, name)
+	n.batch = blob.Size()[0]
+}
+
+func (n *batchOOMNet) Forward(name string) gocv.Mat {
+	if n.oom = n.batch > n.max; n.oom {
+		return gocv.NewMat()
+	}
+	return n.fakeNet.Forward(name)
+}
+
+func (n *batchOOMNet) LastError() error {
+	if n.oom {
+		return errors.New("OpenCV(4.9.0) cuda4dnn: CUDA error: out of memory")
+	}
+	return n.fakeNet.LastError()
+}
+
+func TestAcceleratorOOMFallback(t *testing.T) {
+	face := [][4]float32{{0.1, 0.1, 0.4, 0.4}}
+	gpu := &batchOOMNet{fakeNet: &fakeNet{faces: face}, max: 0} // nothing fits
+	cpu := &fakeNet{faces: face}
+	var targets []gocv.NetTargetType
+	load := func(cfg DetectorConfig) (inferenceNet, error) {
+		targets = append(targets, cfg.Target)
+		if cfg.Target == gocv.NetTargetCPU {
+			return cpu, nil
+		}
+		return gpu, nil
+	}
+	cfg := DetectorConfig{Backend: gocv.NetBackendCUDA, Target: gocv.NetTargetCUDA}
+	d, err := newInferenceDetector(cfg, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.cap = fakeSource{w: 100, h: 100}
+	d.stats = NewStats()
+
+	// The OOM frame is inferred again on the CPU, which the next ones keep.
+	for i := range 3 {
+		if _, faces, _, _, err := d.Detect(); err != nil || len(faces) != 1 {
+			t.Fatalf("frame %d: %d faces, %v", i, len(faces), err)
+		}
+	}
+	if len(targets) != 2 || targets[1] != gocv.NetTargetCPU || !gpu.closed || cpu.forwards() != 3 {
+		t.Errorf("loads %v, GPU net closed %v, %d CPU inferences; want one CPU reload serving every frame", targets, gpu.closed, cpu.forwards())
+	}
+	st := d.stats.Report()
+	if st.AcceleratorOOM != 1 || st.AcceleratorFallback != gocv.NetTargetCUDA.String() {
+		t.Errorf("stats: %d OOM, fallback %q", st.AcceleratorOOM, st.AcceleratorFallback)
+	}
+	if m := d.Meta(); m.Target != gocv.NetTargetCPU.String() || m.Backend != gocv.NetBackendDefault.String() {
+		t.Errorf("meta reports %s/%s, want the CPU", m.Backend, m.Target)
+	}
+}
+
+func TestAcceleratorOOMBatch(t *testing.T) {
+	// 2x2 tiles: a batch of 4 doesn't fit, 2 do, and the GPU is kept.
+	gpu := &batchOOMNet{fakeNet: &fakeNet{faces: [][4]float32{{0.1, 0.1, 0.4, 0.4}}}, max: 2}
+	loads := 0
+	load := func(DetectorConfig) (inferenceNet, error) { loads++; return gpu, nil }
+	cfg := DetectorConfig{Target: gocv.NetTargetCUDA, Tiling: &Tiling{Cols: 2, Rows: 2, Merge: 0.5}}
+	d, err := newInferenceDetector(cfg, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.cap = fakeSource{w: 200, h: 200}
+	d.stats = NewStats()
+
+	if _, faces, _, _, err := d.Detect(); err != nil || len(faces) == 0 {
+		t.Fatalf("%d faces, %v", len(faces), err)
+	}
+	if d.maxBatch != 2 || loads != 1 || d.stats.Report().AcceleratorFallback != "" {
+		t.Errorf("batch %d, %d loads, fallback %q: want batches of 2 on the GPU", d.maxBatch, loads, d.stats.Report().AcceleratorFallback)
+	}
+
+	// Already on the CPU: nothing left to try.
+	cpu := &batchOOMNet{fakeNet: &fakeNet{}, max: 0}
+	d, err = newInferenceDetector(DetectorConfig{}, func(DetectorConfig) (inferenceNet, error) { return cpu, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.cap = fakeSource{w: 100, h: 100}
+	if _, _, _, _, err := d.Detect(); !errors.Is(err, ErrInference) {
+		t.Errorf("OOM on the CPU: %v, want ErrInference", err)
+	}
+}
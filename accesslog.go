@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+/* ------------------------------- Access log -------------------------------- */
+
+// accessLog logs HTTP requests. Errors (4xx/5xx) are always logged;
+// successful requests are sampled (1 in sample, 0 = none) and never logged
+// for the skipped paths, so frequent polling doesn't flood the log.
+type accessLog struct {
+	sample int
+	skip   map[string]bool
+	n      atomic.Uint64 // successful requests eligible for sampling
+}
+
+func newAccessLog(sample int, skip []string) *accessLog {
+	a := &accessLog{sample: sample, skip: make(map[string]bool, len(skip))}
+	for _, p := range skip {
+		a.skip[p] = true
+	}
+	return a
+}
+
+// shouldLog decides for one completed request. The first eligible success
+// is logged, then every sample-th one.
+func (a *accessLog) shouldLog(path string, status int) bool {
+	if status >= 400 {
+		return true
+	}
+	if a.skip[path] || a.sample <= 0 {
+		return false
+	}
+	return (a.n.Add(1)-1)%uint64(a.sample) == 0
+}
+
+func (a *accessLog) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t0 := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		if a.shouldLog(r.URL.Path, sw.status) {
+			log.Printf("[http] method=%s path=%s status=%d bytes=%d duration=%s remote=%s",
+				r.Method, r.URL.Path, sw.status, sw.bytes, time.Since(t0), r.RemoteAddr)
+		}
+	})
+}
+
+// statusWriter records the status and size of a response. It keeps the
+// streaming endpoints working by forwarding Flush.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code >= 200 { // 1xx (e.g. 103 Early Hints) precede the real status
+		w.status, w.wroteHeader = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
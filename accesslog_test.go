@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(prev)
+
+	h := newAccessLog(10, []string{"/healthz"}).middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/boom":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	serve := func(path string, n int) {
+		for range n {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+	}
+	logged := func(path, status string) int {
+		return strings.Count(buf.String(), "path="+path+" status="+status+" ")
+	}
+
+	serve("/faces", 25)
+	serve("/healthz", 25)
+	serve("/missing", 7)
+	serve("/boom", 3)
+	tests := []struct {
+		path, status string
+		want         int
+	}{
+		{"/faces", "200", 3}, // the 1st, 11th and 21st
+		{"/healthz", "200", 0},
+		{"/missing", "404", 7},
+		{"/boom", "500", 3},
+	}
+	for _, tt := range tests {
+		if got := logged(tt.path, tt.status); got != tt.want {
+			t.Errorf("%s %s: logged %d times, want %d", tt.path, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestAccessLogShouldLog(t *testing.T) {
+	tests := []struct {
+		sample int
+		skip   []string
+		path   string
+		status int
+		want   bool
+	}{
+		{0, nil, "/faces", 200, false},
+		{0, nil, "/faces", 404, true},
+		{0, nil, "/faces", 503, true},
+		{1, nil, "/faces", 200, true},
+		{1, []string{"/faces"}, "/faces", 200, false},
+		{1, []string{"/faces"}, "/faces", 400, true},
+		{1, []string{"/faces"}, "/count", 304, true},
+	}
+	for _, tt := range tests {
+		if got := newAccessLog(tt.sample, tt.skip).shouldLog(tt.path, tt.status); got != tt.want {
+			t.Errorf("sample %d skip %v: %s %d logged %v, want %v", tt.sample, tt.skip, tt.path, tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+/* ---------------------------- Dashboard config ----------------------------- */
+
+// AppConfig is the public runtime configuration served on /app-config.json so
+// a single static dashboard bundle can adapt to the server it talks to. It
+// must never carry secrets.
+type AppConfig struct {
+	PollIntervalMs float64      `json:"poll_interval_ms"`
+	FrameWidth     int          `json:"frame_width,omitempty"`  // once a frame has been captured
+	FrameHeight    int          `json:"frame_height,omitempty"` // once a frame has been captured
+	ScoreUnits     string       `json:"score_units"`            // "fraction" (0-1) or "percent" (0-100)
+	Endpoints      AppEndpoints `json:"endpoints"`
+	Features       AppFeatures  `json:"features"`
+}
+
+type AppEndpoints struct {
+	Faces  string `json:"faces"`
+	Stream string `json:"stream,omitempty"`
+	Crop   string `json:"crop,omitempty"` // template with {frame} and {id}
+	MJPEG  string `json:"mjpeg,omitempty"`
+	Frame  string `json:"frame,omitempty"` // latest annotated frame (JPEG)
+}
+
+type AppFeatures struct {
+	SSE       bool `json:"sse"`
+	WebSocket bool `json:"ws"`
+	MJPEG     bool `json:"mjpeg"`
+	Crops     bool `json:"crops"`
+}
+
+// buildAppConfig derives the dashboard config from the server settings and
+// the latest state, so features only show up once they are usable.
+func buildAppConfig(cfg ServerConfig, store *FaceStore) AppConfig {
+	snap, _ := store.Get()
+	ac := AppConfig{
+		FrameWidth:  snap.FrameWidth,
+		FrameHeight: snap.FrameHeight,
+		ScoreUnits:  "fraction",
+		Endpoints: AppEndpoints{
+			Faces:  "/faces",
+			Stream: "/faces/stream",
+		},
+		Features: AppFeatures{
+			SSE:   true,
+			MJPEG: cfg.Preview != nil && cfg.Preview.HasFrame(),
+			Crops: cfg.Frames != nil,
+		},
+	}
+	if cfg.Scores != nil && cfg.Scores.Percent {
+		ac.ScoreUnits = "percent"
+	}
+	if cfg.Stats != nil {
+		ac.PollIntervalMs = cfg.Stats.Report().IntervalMs
+	}
+	if ac.Features.MJPEG {
+		ac.Endpoints.MJPEG = "/stream.mjpg"
+		ac.Endpoints.Frame = "/snapshot.jpg"
+	}
+	if ac.Features.Crops {
+		ac.Endpoints.Crop = "/face/{frame}/{id}.jpg"
+	}
+	return ac
+}
+
+func appConfigHandler(cfg ServerConfig, store *FaceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(buildAppConfig(cfg, store))
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+func TestAppConfigHandler(t *testing.T) {
+	get := func(cfg ServerConfig, store *FaceStore) (AppConfig, map[string]json.RawMessage) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		appConfigHandler(cfg, store)(rec, httptest.NewRequest("GET", "/app-config.json", nil))
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("content type %q", ct)
+		}
+		var ac AppConfig
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &ac); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+			t.Fatal(err)
+		}
+		return ac, raw
+	}
+	checkKeys := func(name string, raw map[string]json.RawMessage, top, endpoints, features string) {
+		t.Helper()
+		body, _ := json.Marshal(raw)
+		if got := jsonKeys(t, body); got != top {
+			t.Errorf("%s: keys %q, want %q", name, got, top)
+		}
+		if got := jsonKeys(t, raw["endpoints"]); got != endpoints {
+			t.Errorf("%s: endpoint keys %q, want %q", name, got, endpoints)
+		}
+		if got := jsonKeys(t, raw["features"]); got != features {
+			t.Errorf("%s: feature keys %q, want %q", name, got, features)
+		}
+	}
+
+	// Bare server, nothing captured yet: only what is always there.
+	store := NewFaceStore()
+	ac, raw := get(ServerConfig{}, store)
+	checkKeys("bare", raw, "endpoints features poll_interval_ms score_units", "faces stream", "crops mjpeg sse ws")
+	if ac.Features != (AppFeatures{SSE: true}) || ac.ScoreUnits != "fraction" {
+		t.Errorf("bare: %+v", ac)
+	}
+
+	// Everything configured, but no frame yet: MJPEG isn't advertised.
+	preview, stats := NewPreview(nil, 0, nil), NewStats()
+	stats.ObserveCycle(1, 10*time.Millisecond, 200*time.Millisecond)
+	cfg := ServerConfig{
+		Preview: preview,
+		Frames:  NewFrameRing(4, 1<<20, 0),
+		Scores:  &scoreFormat{Percent: true},
+		Stats:   stats,
+	}
+	if ac, _ = get(cfg, store); ac.Features.MJPEG || ac.Endpoints.MJPEG != "" {
+		t.Errorf("no frame yet: %+v", ac)
+	}
+
+	img := gocv.NewMatWithSize(48, 64, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	snap := Snapshot{FrameWidth: 64, FrameHeight: 48}
+	store.Set(snap)
+	preview.Update(snap, img)
+	ac, raw = get(cfg, store)
+	checkKeys("full", raw, "endpoints features frame_height frame_width poll_interval_ms score_units",
+		"crop faces frame mjpeg stream", "crops mjpeg sse ws")
+	want := AppConfig{
+		PollIntervalMs: 200,
+		FrameWidth:     64,
+		FrameHeight:    48,
+		ScoreUnits:     "percent",
+		Endpoints: AppEndpoints{
+			Faces:  "/faces",
+			Stream: "/faces/stream",
+			Crop:   "/face/{frame}/{id}.jpg",
+			MJPEG:  "/stream.mjpg",
+			Frame:  "/snapshot.jpg",
+		},
+		Features: AppFeatures{SSE: true, MJPEG: true, Crops: true},
+	}
+	if ac != want {
+		t.Errorf("full: %+v, want %+v", ac, want)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "math"
+
+/* ------------------------------ Approx range ------------------------------- */
+
+// rangeReference calibrates the distance estimate: a face RefHeight pixels
+// tall (box height, at the capture resolution) stands RefDistance meters
+// from the camera.
+type rangeReference struct {
+	RefHeight   float64 // px
+	RefDistance float64 // m
+}
+
+// approxRange is the pinhole estimate of the distance of a face whose box is
+// height pixels tall: apparent size is inversely proportional to distance.
+// It assumes every face is about the reference size and is only a rough
+// "how close" indicator; 0 when the box has no height.
+func (r rangeReference) approxRange(height int) float64 {
+	if height <= 0 {
+		return 0
+	}
+	m := r.RefDistance * r.RefHeight / float64(height)
+	return math.Round(m*100) / 100 // cm resolution, the estimate is not finer
+}
+
+// applyRange fills Detection.ApproxRangeM; a nil reference leaves it unset.
+func applyRange(dets []Detection, ref *rangeReference) []Detection {
+	if ref == nil {
+		return dets
+	}
+	for i := range dets {
+		dets[i].ApproxRangeM = ref.approxRange(dets[i].BBox.Height)
+	}
+	return dets
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestApproxRange(t *testing.T) {
+	ref := rangeReference{RefHeight: 120, RefDistance: 1.5}
+	tests := []struct {
+		height int
+		want   float64
+	}{
+		{120, 1.5}, // the reference itself
+		{240, 0.75},
+		{60, 3},
+		{40, 4.5},
+		{7, 25.71}, // rounded to the cm
+		{0, 0},
+		{-5, 0},
+	}
+	for _, tt := range tests {
+		if got := ref.approxRange(tt.height); got != tt.want {
+			t.Errorf("approxRange(%d) = %g, want %g", tt.height, got, tt.want)
+		}
+	}
+
+	// Bigger box, closer face.
+	prev := ref.approxRange(1)
+	for h := 2; h <= 1000; h++ {
+		got := ref.approxRange(h)
+		if got > prev {
+			t.Fatalf("approxRange(%d) = %g, further than %g for %d px", h, got, prev, h-1)
+		}
+		prev = got
+	}
+	if near, far := ref.approxRange(200), ref.approxRange(100); near >= far {
+		t.Errorf("200 px at %gm, 100 px at %gm", near, far)
+	}
+}
+
+func TestApplyRange(t *testing.T) {
+	dets := []Detection{{BBox: Rect{Height: 120}}, {BBox: Rect{Height: 60}}}
+	if got := applyRange(dets, nil); got[0].ApproxRangeM != 0 || got[1].ApproxRangeM != 0 {
+		t.Errorf("disabled: %+v", got)
+	}
+	got := applyRange(dets, &rangeReference{RefHeight: 120, RefDistance: 2})
+	if got[0].ApproxRangeM != 2 || got[1].ApproxRangeM != 4 {
+		t.Errorf("ranges %g, %g, want 2, 4", got[0].ApproxRangeM, got[1].ApproxRangeM)
+	}
+}
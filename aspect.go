@@ -0,0 +1,25 @@
+package main
+
+/* ------------------------------ Aspect filter ------------------------------ */
+
+// filterAspect drops boxes whose width/height ratio lies outside
+// [minAspect, maxAspect], bounds included; a bound of 0 is not checked.
+// Faces are roughly square, so very elongated boxes are background clutter.
+// Boxes without height are dropped whenever a bound is set.
+func filterAspect(dets []Detection, minAspect, maxAspect float64) []Detection {
+	if minAspect <= 0 && maxAspect <= 0 {
+		return dets
+	}
+	out := dets[:0]
+	for _, d := range dets {
+		if d.BBox.Height <= 0 {
+			continue
+		}
+		ratio := float64(d.BBox.Width) / float64(d.BBox.Height)
+		if minAspect > 0 && ratio < minAspect || maxAspect > 0 && ratio > maxAspect {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
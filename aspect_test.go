@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestFilterAspect(t *testing.T) {
+	box := func(w, h int) Detection { return Detection{BBox: Rect{Width: w, Height: h}} }
+	tests := []struct {
+		name     string
+		det      Detection
+		min, max float64
+		keep     bool
+	}{
+		{"square", box(40, 40), 0.5, 1.5, true},
+		{"10:1", box(100, 10), 0.5, 1.5, false},
+		{"1:10", box(10, 100), 0.5, 1.5, false},
+		{"at the minimum", box(20, 40), 0.5, 1.5, true},
+		{"at the maximum", box(60, 40), 0.5, 1.5, true},
+		{"just below the minimum", box(19, 40), 0.5, 1.5, false},
+		{"just above the maximum", box(61, 40), 0.5, 1.5, false},
+		{"10:1, no maximum", box(100, 10), 0.5, 0, true},
+		{"1:10, no minimum", box(10, 100), 0, 1.5, true},
+		{"no height", box(40, 0), 0.5, 1.5, false},
+		{"no height, filter off", box(40, 0), 0, 0, true},
+	}
+	for _, tt := range tests {
+		if got := filterAspect([]Detection{tt.det}, tt.min, tt.max); (len(got) == 1) != tt.keep {
+			t.Errorf("%s: kept %v, want %v", tt.name, len(got) == 1, tt.keep)
+		}
+	}
+}
+
+func TestDetectorAspect(t *testing.T) {
+	net := &fakeNet{faces: [][4]float32{{0.2, 0.2, 0.6, 0.6}, {0, 0.4, 1, 0.5}}} // 40x40 and 100x10
+	cfg := DetectorConfig{MinAspect: 0.5, MaxAspect: 1.5}
+	d := newFakeDetector(t, cfg, &fakeLoader{next: []*fakeNet{net}})
+	_, faces, _, _, err := d.Detect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(faces) != 1 || faces[0].BBox != (Rect{X: 20, Y: 20, Width: 40, Height: 40}) {
+		t.Errorf("faces %+v, want only the square one", faces)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestBoxScale(t *testing.T) {
+	bounds := image.Rect(0, 0, 640, 480)
+	tests := []struct {
+		name string
+		s    boxScale
+		in   Rect
+		want Rect
+	}{
+		{"grow", boxScale{1.2, 1.2}, Rect{X: 100, Y: 100, Width: 100, Height: 50}, Rect{X: 90, Y: 95, Width: 120, Height: 60}},
+		{"shrink", boxScale{0.5, 0.5}, Rect{X: 100, Y: 100, Width: 100, Height: 50}, Rect{X: 125, Y: 113, Width: 50, Height: 25}},
+		{"per axis", boxScale{1, 1.5}, Rect{X: 100, Y: 100, Width: 100, Height: 40}, Rect{X: 100, Y: 90, Width: 100, Height: 60}},
+		{"top-left corner", boxScale{2, 2}, Rect{X: 5, Y: 10, Width: 40, Height: 40}, Rect{X: 0, Y: 0, Width: 65, Height: 70}},
+		{"bottom-right corner", boxScale{2, 2}, Rect{X: 600, Y: 450, Width: 40, Height: 30}, Rect{X: 580, Y: 435, Width: 60, Height: 45}},
+		{"whole frame", boxScale{3, 3}, Rect{X: 0, Y: 0, Width: 640, Height: 480}, Rect{X: 0, Y: 0, Width: 640, Height: 480}},
+	}
+	for _, tt := range tests {
+		if got := tt.s.scale(tt.in, bounds); got != tt.want {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// Away from the edges the center stays put; near them the box stays in
+	// the frame.
+	s := boxScale{1.3, 1.7}
+	for x := 0; x < 640; x += 37 {
+		for y := 0; y < 480; y += 29 {
+			in := Rect{X: x, Y: y, Width: 40, Height: 40}
+			got := s.scale(in, bounds)
+			if !image.Rect(got.X, got.Y, got.X+got.Width, got.Y+got.Height).In(bounds) {
+				t.Fatalf("%+v scaled out of the frame: %+v", in, got)
+			}
+			if x >= 20 && y >= 20 && x+60 <= 640 && y+60 <= 480 {
+				if cx, cy := 2*got.X+got.Width, 2*got.Y+got.Height; abs(cx-(2*x+40)) > 1 || abs(cy-(2*y+40)) > 1 {
+					t.Errorf("%+v scaled to %+v: center moved", in, got)
+				}
+			}
+		}
+	}
+}
+
+func TestParseBoxScale(t *testing.T) {
+	tests := []struct {
+		s    string
+		want *boxScale
+		ok   bool
+	}{
+		{"1", nil, true},
+		{"1,1", nil, true},
+		{"1.2", &boxScale{1.2, 1.2}, true},
+		{"1, 1.4", &boxScale{1, 1.4}, true},
+		{"0", nil, false},
+		{"-1.2", nil, false},
+		{"1,2,3", nil, false},
+		{"big", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := parseBoxScale(tt.s)
+		if (err == nil) != tt.ok || (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("parseBoxScale(%q) = %+v, %v", tt.s, got, err)
+		}
+	}
+}
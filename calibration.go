@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* --------------------------- Score calibration ----------------------------- */
+
+// scoreCalibrator maps a raw SSD confidence to a calibrated probability.
+// Implementations must be monotonically non-decreasing. A nil calibrator
+// means identity.
+type scoreCalibrator interface {
+	Calibrate(raw float64) float64
+}
+
+// piecewiseCalibration interpolates linearly between knots sorted by raw
+// score; scores outside the knots are clamped to the first/last value.
+type piecewiseCalibration struct {
+	xs, ys []float64
+}
+
+func (p piecewiseCalibration) Calibrate(raw float64) float64 {
+	i := sort.SearchFloat64s(p.xs, raw)
+	switch {
+	case i == 0:
+		return p.ys[0]
+	case i == len(p.xs):
+		return p.ys[len(p.ys)-1]
+	}
+	x0, x1, y0, y1 := p.xs[i-1], p.xs[i], p.ys[i-1], p.ys[i]
+	return y0 + (y1-y0)*(raw-x0)/(x1-x0)
+}
+
+// plattCalibration is the logistic mapping 1 / (1 + exp(A*raw + B)), with
+// A < 0 so that it is increasing.
+type plattCalibration struct {
+	a, b float64
+}
+
+func (p plattCalibration) Calibrate(raw float64) float64 {
+	return 1 / (1 + math.Exp(p.a*raw+p.b))
+}
+
+// parseCalibration parses FACE_CALIBRATION:
+//
+//	""                          identity (no calibration)
+//	"pl:0:0,0.5:0.2,0.9:0.8,1:1" piecewise-linear knots raw:calibrated
+//	"platt:-12,6"               Platt scaling with A=-12, B=6
+func parseCalibration(spec string) (scoreCalibrator, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	kind, args, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "pl":
+		var p piecewiseCalibration
+		for _, knot := range strings.Split(args, ",") {
+			xs, ys, ok := strings.Cut(strings.TrimSpace(knot), ":")
+			x, errX := strconv.ParseFloat(xs, 64)
+			y, errY := strconv.ParseFloat(ys, 64)
+			if !ok || errX != nil || errY != nil {
+				return nil, fmt.Errorf("calibration: invalid knot %q (want raw:calibrated)", knot)
+			}
+			if n := len(p.xs); n > 0 && (x <= p.xs[n-1] || y < p.ys[n-1]) {
+				return nil, fmt.Errorf("calibration: knots must have increasing raw and non-decreasing calibrated scores (at %q)", knot)
+			}
+			p.xs, p.ys = append(p.xs, x), append(p.ys, y)
+		}
+		if len(p.xs) < 2 {
+			return nil, fmt.Errorf("calibration: need at least two knots")
+		}
+		return p, nil
+	case "platt":
+		as, bs, ok := strings.Cut(args, ",")
+		a, errA := strconv.ParseFloat(strings.TrimSpace(as), 64)
+		b, errB := strconv.ParseFloat(strings.TrimSpace(bs), 64)
+		if !ok || errA != nil || errB != nil {
+			return nil, fmt.Errorf("calibration: invalid platt params %q (want A,B)", args)
+		}
+		if a >= 0 {
+			return nil, fmt.Errorf("calibration: platt A must be negative for an increasing mapping, got %v", a)
+		}
+		return plattCalibration{a: a, b: b}, nil
+	}
+	return nil, fmt.Errorf("calibration: unknown kind %q (want pl or platt)", kind)
+}
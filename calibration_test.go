@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalibrate(t *testing.T) {
+	tests := []struct {
+		spec string
+		raw  float64
+		want float64
+	}{
+		{"pl:0.2:0,0.5:0.2,0.9:0.8,1:1", 0.2, 0},
+		{"pl:0.2:0,0.5:0.2,0.9:0.8,1:1", 0.5, 0.2}, // on a knot
+		{"pl:0.2:0,0.5:0.2,0.9:0.8,1:1", 0.7, 0.5}, // halfway between knots
+		{"pl:0.2:0,0.5:0.2,0.9:0.8,1:1", 0.95, 0.9},
+		{"pl:0.2:0,0.5:0.2,0.9:0.8,1:1", 1, 1},
+		{"pl:0.2:0,0.5:0.2,0.9:0.8,1:1", 0.1, 0}, // clamped below
+		{"pl:0.2:0.1,0.8:0.6", 0.9, 0.6},         // clamped above
+		{"pl:0:0.3,0.5:0.3,1:1", 0.25, 0.3},      // flat segment
+		{"platt:-12,6", 0.5, 0.5},
+		{"platt:-12,6", 0, 1 / (1 + math.Exp(6))},
+		{"platt:-12,6", 1, 1 / (1 + math.Exp(-6))},
+		{"platt: -4 , 0", 0, 0.5},
+	}
+	for _, tt := range tests {
+		c, err := parseCalibration(tt.spec)
+		if err != nil {
+			t.Fatalf("parseCalibration(%q): %v", tt.spec, err)
+		}
+		if got := c.Calibrate(tt.raw); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Calibrate(%g) = %g, want %g", tt.spec, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestCalibrateMonotonic(t *testing.T) {
+	for _, spec := range []string{"pl:0.2:0,0.5:0.2,0.9:0.8,1:1", "pl:0:0.3,0.5:0.3,1:1", "platt:-12,6"} {
+		c, err := parseCalibration(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev := math.Inf(-1)
+		for i := 0; i <= 1000; i++ {
+			got := c.Calibrate(float64(i) / 1000)
+			if got < prev || got < 0 || got > 1 {
+				t.Fatalf("%s: Calibrate(%g) = %g after %g", spec, float64(i)/1000, got, prev)
+			}
+			prev = got
+		}
+	}
+}
+
+func TestParseCalibration(t *testing.T) {
+	if c, err := parseCalibration("  "); c != nil || err != nil {
+		t.Fatalf("empty spec = %v, %v; want identity", c, err)
+	}
+	for _, spec := range []string{
+		"pl:0.5:0.5",         // one knot
+		"pl:0.5:0.5,0.4:0.6", // raw decreasing
+		"pl:0.5:0.5,0.5:0.6", // raw repeated
+		"pl:0.4:0.6,0.5:0.5", // calibrated decreasing
+		"pl:0.4,0.5:0.5",     // not raw:calibrated
+		"pl:a:b,1:1",         // not numbers
+		"platt:12,6",         // decreasing
+		"platt:0,6",          // constant
+		"platt:-12",          // one parameter
+		"isotonic:0:0,1:1",   // unknown kind
+	} {
+		if _, err := parseCalibration(spec); err == nil {
+			t.Errorf("parseCalibration(%q) accepted", spec)
+		}
+	}
+}
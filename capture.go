@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+/* ---------------------------- Capture backends ----------------------------- */
+
+// exitSourceUnavailable is the process exit code used when the video source
+// cannot be opened with any backend, so orchestrators can tell it apart from
+// a crash.
+const exitSourceUnavailable = 3
+
+// captureAPIs maps FACE_CAP_API values to OpenCV videoio backends.
+var captureAPIs = map[string]gocv.VideoCaptureAPI{
+	"any":          gocv.VideoCaptureAny,
+	"v4l2":         gocv.VideoCaptureV4L2,
+	"ffmpeg":       gocv.VideoCaptureFFmpeg,
+	"gstreamer":    gocv.VideoCaptureGstreamer,
+	"avfoundation": gocv.VideoCaptureAVFoundation,
+	"msmf":         gocv.VideoCaptureMSMF,
+	"dshow":        gocv.VideoCaptureDshow,
+	"images":       gocv.VideoCaptureImages,
+}
+
+func parseCaptureAPI(name string) (gocv.VideoCaptureAPI, error) {
+	if api, ok := captureAPIs[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return api, nil
+	}
+	names := make([]string, 0, len(captureAPIs))
+	for n := range captureAPIs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("unknown capture backend %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// openCapture opens a webcam index or a file/stream URL with the given backend.
+func openCapture(source string, api gocv.VideoCaptureAPI) (*gocv.VideoCapture, error) {
+	var (
+		cap *gocv.VideoCapture
+		err error
+	)
+	if idx, convErr := strconv.Atoi(source); convErr == nil {
+		cap, err = gocv.OpenVideoCaptureWithAPI(idx, api)
+	} else {
+		cap, err = gocv.OpenVideoCaptureWithAPI(source, api)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSourceUnavailable, err)
+	}
+	if !cap.IsOpened() {
+		cap.Close()
+		return nil, fmt.Errorf("%w: %s (backend %s)", ErrSourceUnavailable, source, backendName(api))
+	}
+	return cap, nil
+}
+
+// captureProperties is the part of *gocv.VideoCapture used to tune it.
+type captureProperties interface {
+	Set(prop gocv.VideoCaptureProperties, param float64)
+	Get(prop gocv.VideoCaptureProperties) float64
+}
+
+// CaptureBufferStatus reports whether the backend took the requested
+// buffer size; many (e.g. FFmpeg for some streams) silently ignore it.
+type CaptureBufferStatus struct {
+	Requested int  `json:"requested"`
+	Honored   bool `json:"honored"`
+}
+
+// setCaptureBuffer asks the backend to keep at most n frames queued, so
+// reads return the newest frame instead of one seconds old. Whether it
+// worked is read back from the property.
+func setCaptureBuffer(cap captureProperties, n int) CaptureBufferStatus {
+	cap.Set(gocv.VideoCaptureBufferSize, float64(n))
+	st := CaptureBufferStatus{Requested: n, Honored: int(cap.Get(gocv.VideoCaptureBufferSize)) == n}
+	if !st.Honored {
+		log.Printf("[capture] backend ignored buffer size %d, frames may lag behind the source", n)
+	}
+	return st
+}
+
+// selectCaptureAPI returns the first backend for which try succeeds: the
+// preferred one, then VideoCaptureAny as a fallback.
+func selectCaptureAPI(preferred gocv.VideoCaptureAPI, try func(gocv.VideoCaptureAPI) error) (gocv.VideoCaptureAPI, error) {
+	err := try(preferred)
+	if err == nil || preferred == gocv.VideoCaptureAny {
+		return preferred, err
+	}
+	log.Printf("[capture] backend %s failed (%v), falling back to auto-detection", backendName(preferred), err)
+	if fallbackErr := try(gocv.VideoCaptureAny); fallbackErr != nil {
+		return preferred, errors.Join(err, fallbackErr)
+	}
+	return gocv.VideoCaptureAny, nil
+}
+
+// ProbeSource checks at startup that source can actually be opened and
+// returns the backend to use. On failure it logs the backends this OpenCV
+// build provides along with a remediation hint. Shared-memory sources don't
+// go through OpenCV: their buffer is mapped and released instead.
+func ProbeSource(source string, preferred gocv.VideoCaptureAPI) (gocv.VideoCaptureAPI, error) {
+	if isSyntheticSource(source) {
+		return preferred, nil
+	}
+	if isShmSource(source) {
+		s, err := NewShmSource(source)
+		if err != nil {
+			return preferred, err
+		}
+		return preferred, s.Close()
+	}
+	api, err := selectCaptureAPI(preferred, func(api gocv.VideoCaptureAPI) error {
+		cap, err := openCapture(source, api)
+		if err != nil {
+			return err
+		}
+		return cap.Close()
+	})
+	if err != nil {
+		logCaptureHints(source)
+	}
+	return api, err
+}
+
+func logCaptureHints(source string) {
+	var available []gocv.VideoCaptureAPI
+	if _, convErr := strconv.Atoi(source); convErr == nil {
+		available = gocv.VideoRegistry.GetCameraBackends()
+	} else {
+		available = gocv.VideoRegistry.GetStreamBackends()
+	}
+	names := make([]string, 0, len(available))
+	for _, api := range available {
+		names = append(names, backendName(api))
+	}
+	if len(names) == 0 {
+		log.Printf("[capture] this OpenCV build reports no usable backend for %q", source)
+	} else {
+		log.Printf("[capture] backends available for %q: %s", source, strings.Join(names, ", "))
+	}
+	log.Printf("[capture] hint: check the device/URL, pick one of the above with FACE_CAP_API, " +
+		"or rebuild OpenCV with FFmpeg/GStreamer support for network streams and compressed files")
+}
+
+func backendName(api gocv.VideoCaptureAPI) string {
+	if api == gocv.VideoCaptureAny {
+		return "any"
+	}
+	if name := gocv.VideoRegistry.GetBackendName(api); name != "" {
+		return name
+	}
+	return api.String()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestSelectCaptureAPI(t *testing.T) {
+	errPreferred := errors.New("preferred backend missing")
+	errAny := errors.New("no backend can open it")
+	tests := []struct {
+		name      string
+		preferred gocv.VideoCaptureAPI
+		fail      map[gocv.VideoCaptureAPI]error
+		want      gocv.VideoCaptureAPI
+		tried     []gocv.VideoCaptureAPI
+		wantErrs  []error
+	}{
+		{"preferred works", gocv.VideoCaptureV4L2, nil, gocv.VideoCaptureV4L2, []gocv.VideoCaptureAPI{gocv.VideoCaptureV4L2}, nil},
+		{"falls back to any", gocv.VideoCaptureV4L2, map[gocv.VideoCaptureAPI]error{gocv.VideoCaptureV4L2: errPreferred},
+			gocv.VideoCaptureAny, []gocv.VideoCaptureAPI{gocv.VideoCaptureV4L2, gocv.VideoCaptureAny}, nil},
+		{"both fail", gocv.VideoCaptureFFmpeg, map[gocv.VideoCaptureAPI]error{gocv.VideoCaptureFFmpeg: errPreferred, gocv.VideoCaptureAny: errAny},
+			gocv.VideoCaptureFFmpeg, []gocv.VideoCaptureAPI{gocv.VideoCaptureFFmpeg, gocv.VideoCaptureAny}, []error{errPreferred, errAny}},
+		{"any is not retried", gocv.VideoCaptureAny, map[gocv.VideoCaptureAPI]error{gocv.VideoCaptureAny: errAny},
+			gocv.VideoCaptureAny, []gocv.VideoCaptureAPI{gocv.VideoCaptureAny}, []error{errAny}},
+	}
+	for _, tt := range tests {
+		var tried []gocv.VideoCaptureAPI
+		got, err := selectCaptureAPI(tt.preferred, func(api gocv.VideoCaptureAPI) error {
+			tried = append(tried, api)
+			return tt.fail[api]
+		})
+		if got != tt.want || !slices.Equal(tried, tt.tried) {
+			t.Errorf("%s: got %v after trying %v, want %v after %v", tt.name, got, tried, tt.want, tt.tried)
+		}
+		if (err != nil) != (len(tt.wantErrs) > 0) {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+		for _, want := range tt.wantErrs {
+			if !errors.Is(err, want) {
+				t.Errorf("%s: err = %v, want it to wrap %v", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestSetCaptureBuffer(t *testing.T) {
+	for _, ignored := range []bool{false, true} {
+		cap := newFakeCapture()
+		cap.ignored = map[gocv.VideoCaptureProperties]bool{gocv.VideoCaptureBufferSize: ignored}
+		st := setCaptureBuffer(cap, 1)
+		if st.Requested != 1 || st.Honored == ignored {
+			t.Errorf("ignored=%v: status %+v", ignored, st)
+		}
+		if v, ok := cap.props[gocv.VideoCaptureBufferSize]; ok != !ignored || (ok && v != 1) {
+			t.Errorf("ignored=%v: buffer size property %v, set %v", ignored, v, ok)
+		}
+
+		stats := NewStats()
+		stats.SetCaptureBuffer(&st)
+		if got := stats.Report().CaptureBuffer; got == nil || *got != st {
+			t.Errorf("ignored=%v: /stats reports %+v, want %+v", ignored, got, st)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/* ------------------------------ Config check ------------------------------- */
+
+// CheckResult is one validation of the --check report.
+type CheckResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// CheckReport is what --check prints.
+type CheckReport struct {
+	OK     bool          `json:"ok"`
+	Checks []CheckResult `json:"checks"`
+}
+
+func (r *CheckReport) add(name string, err error) {
+	res := CheckResult{Name: name, OK: err == nil}
+	if err != nil {
+		res.Error = err.Error()
+		r.OK = false
+	}
+	r.Checks = append(r.Checks, res)
+}
+
+// runConfigCheck validates the configuration from the environment with the
+// same functions the startup uses, writes the report as JSON to w and
+// returns the exit code (1 if anything failed). Nothing is started and
+// nothing is left on disk.
+func runConfigCheck(w io.Writer) int {
+	rep := checkConfig(openNet, listenAddr)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(rep)
+	if !rep.OK {
+		return 1
+	}
+	return 0
+}
+
+// checkConfig runs the checks, loading the net with load and binding addr.
+func checkConfig(load netLoader, addr string) CheckReport {
+	rep := CheckReport{OK: true}
+
+	if envFile := os.Getenv("FACE_ENV_FILE"); envFile != "" {
+		rep.add("env file", loadEnvFile(envFile))
+	}
+	rep.add("opencv log level", applyOpenCVLogLevel(os.Getenv("FACE_OPENCV_LOG_LEVEL")))
+	_, err := requiredPath("FACE_PROTOTXT", defaultProtoTxt)
+	rep.add("prototxt", err)
+	_, err = requiredPath("FACE_MODEL", defaultModel)
+	rep.add("model file", err)
+
+	detCfg, err := loadDetectorConfig()
+	rep.add("detector config", err)
+	if err == nil {
+		rep.add("model load", checkModelLoad(detCfg, load))
+		_, err = ProbeSource(detCfg.Source, detCfg.CaptureAPI)
+		rep.add("source", err)
+	}
+
+	countOnly, err := loadPrivacyMode()
+	rep.add("privacy mode", err)
+	_, err = loadImageEncoders()
+	rep.add("image format", err)
+	_, err = loadOverlayLabel()
+	rep.add("overlay label", err)
+	if tz := os.Getenv("FACE_PEAKS_TZ"); tz != "" {
+		_, err = time.LoadLocation(tz)
+		rep.add("peaks time zone", err)
+	}
+
+	if dir := getenvDefault("FACE_STATIC", "public"); dir != "off" {
+		rep.add("static dir", checkStaticDir(dir))
+	}
+	if path := os.Getenv("FACE_STATE_FILE"); path != "" {
+		_, err = loadState(path)
+		if err == nil {
+			err = checkWritableDir(filepath.Dir(path), true)
+		}
+		rep.add("state file", err)
+	}
+	if dir := os.Getenv("FACE_MODELS_DIR"); dir != "" {
+		_, err = NewModels(dir).List()
+		rep.add("models dir", err)
+	}
+	if dir := os.Getenv("FACE_CAPTURE_DIR"); dir != "" && !countOnly {
+		rep.add("capture dir", checkWritableDir(dir, false))
+	}
+	if dir := os.Getenv("FACE_HIGHLIGHTS_DIR"); dir != "" && !countOnly {
+		rep.add("highlights dir", checkWritableDir(dir, false))
+	}
+	if dir := os.Getenv("FACE_CROP_DIR"); dir != "" && !countOnly {
+		rep.add("crop dir", checkWritableDir(dir, false))
+	}
+	if dir := os.Getenv("FACE_NEGATIVES_DIR"); dir != "" && !countOnly {
+		rep.add("negatives dir", checkWritableDir(dir, false))
+	}
+	configs, err := loadSinkConfigs()
+	rep.add("sinks", err)
+	for _, c := range configs {
+		if c.Type == "sqlite" {
+			rep.add("sink "+c.Name, checkWritableDir(filepath.Dir(c.Path), true))
+		}
+	}
+
+	rep.add("tls", checkTLS(os.Getenv("FACE_TLS_CERT"), os.Getenv("FACE_TLS_KEY")))
+	rep.add("listen address", checkListen(addr))
+	return rep
+}
+
+// checkModelLoad loads the detection net of cfg with load and its
+// classifier, then releases them.
+func checkModelLoad(cfg DetectorConfig, load netLoader) error {
+	net, err := load(cfg)
+	if err != nil {
+		return err
+	}
+	net.Close()
+	if cfg.Classifier != nil {
+		c, err := newFaceClassifier(*cfg.Classifier)
+		if err != nil {
+			return err
+		}
+		c.Close()
+	}
+	return nil
+}
+
+// checkWritableDir verifies that files can be created in dir. A missing dir
+// is fine, since the startup creates it, as long as its closest existing
+// parent is writable; unless mustExist is set.
+func checkWritableDir(dir string, mustExist bool) error {
+	d := dir
+	for {
+		info, err := os.Stat(d)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", d)
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) || mustExist {
+			return err
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return fmt.Errorf("%s: no existing parent directory", dir)
+		}
+		d = parent
+	}
+	f, err := os.CreateTemp(d, ".facetrack-check-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", d, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkStaticDir verifies that dir, when it exists (the embedded dashboard
+// is served otherwise), is a readable directory.
+func checkStaticDir(dir string) error {
+	f, err := os.Open(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
+
+// checkTLS verifies that a certificate and key, when set, are set together
+// and form a usable pair.
+func checkTLS(cert, key string) error {
+	if cert == "" && key == "" {
+		return nil
+	}
+	if cert == "" || key == "" {
+		return errors.New("FACE_TLS_CERT and FACE_TLS_KEY must be set together")
+	}
+	_, err := tls.LoadX509KeyPair(cert, key)
+	return err
+}
+
+// checkListen verifies that addr can be bound.
+func checkListen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
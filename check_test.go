@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"deploy.prototxt", "res10.caffemodel"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	valid := map[string]string{
+		"FACE_PROTOTXT":    filepath.Join(dir, "deploy.prototxt"),
+		"FACE_MODEL":       filepath.Join(dir, "res10.caffemodel"),
+		"FACE_SOURCE":      "synthetic://?w=64&h=48",
+		"FACE_STATIC":      dir,
+		"FACE_STATE_FILE":  filepath.Join(dir, "state.json"),
+		"FACE_CAPTURE_DIR": filepath.Join(dir, "stills", "today"),
+		"FACE_PEAKS_TZ":    "Europe/Paris",
+	}
+	loaded := func(DetectorConfig) (inferenceNet, error) { return &fakeNet{}, nil }
+	setenv := func(env map[string]string) {
+		for k, v := range env {
+			t.Setenv(k, v)
+		}
+	}
+	failed := func(rep CheckReport) map[string]bool {
+		names := map[string]bool{}
+		for _, c := range rep.Checks {
+			if !c.OK {
+				names[c.Name] = true
+			}
+		}
+		return names
+	}
+
+	setenv(valid)
+	if rep := checkConfig(loaded, "127.0.0.1:0"); !rep.OK {
+		t.Errorf("valid config: failed %v", failed(rep))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stills")); !os.IsNotExist(err) {
+		t.Errorf("the check created the capture dir: %v", err)
+	}
+
+	// Broken in every way the check should catch at once.
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	notDir := filepath.Join(dir, "deploy.prototxt")
+	setenv(map[string]string{
+		"FACE_PRIVACY":     "hidden",
+		"FACE_PEAKS_TZ":    "Mars/Olympus",
+		"FACE_STATE_FILE":  filepath.Join(dir, "missing", "state.json"),
+		"FACE_CAPTURE_DIR": filepath.Join(notDir, "stills"),
+		"FACE_TLS_CERT":    filepath.Join(dir, "cert.pem"),
+	})
+	broken := func(DetectorConfig) (inferenceNet, error) { return nil, ErrModelLoad }
+	rep := checkConfig(broken, busy.Addr().String())
+	want := []string{"model load", "privacy mode", "peaks time zone", "state file", "capture dir", "tls", "listen address"}
+	got := failed(rep)
+	for _, name := range want {
+		if !got[name] {
+			t.Errorf("broken config: %q passed", name)
+		}
+	}
+	if rep.OK || len(got) != len(want) {
+		t.Errorf("broken config: OK %v, failed %v, want %v", rep.OK, got, want)
+	}
+
+	// The report is JSON, with the exit code telling CI whether to go on.
+	var out bytes.Buffer
+	if code := runConfigCheck(&out); code != 1 {
+		t.Errorf("exit code %d, want 1", code)
+	}
+	var printed CheckReport
+	if err := json.Unmarshal(out.Bytes(), &printed); err != nil || printed.OK || len(printed.Checks) == 0 {
+		t.Errorf("report %q: %v", out.String(), err)
+	}
+}
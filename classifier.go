@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+
+	"gocv.io/x/gocv"
+)
+
+/* --------------------------- Second-stage classifier ----------------------- */
+
+// ClassifierConfig describes an optional per-face classification net (e.g.
+// mask / no_mask). Each output column is reported under the matching label
+// in Detection.Attributes; outputs are expected to be probabilities.
+type ClassifierConfig struct {
+	ModelPath  string   // anything gocv.ReadNet understands (ONNX, Caffe, TF...)
+	ConfigPath string   // optional, e.g. a prototxt
+	Labels     []string // one per output column
+	InputSize  int      // square network input (default 224)
+	Scale      float64  // pixel scale factor (default 1/255)
+	SwapRB     bool     // feed RGB instead of BGR
+}
+
+type faceClassifier struct {
+	net    inferenceNet
+	labels []string
+	size   image.Point
+	scale  float64
+	swapRB bool
+}
+
+func newFaceClassifier(cfg ClassifierConfig) (*faceClassifier, error) {
+	if len(cfg.Labels) == 0 {
+		return nil, fmt.Errorf("%w: classifier needs at least one label", ErrModelLoad)
+	}
+	net := gocv.ReadNet(cfg.ModelPath, cfg.ConfigPath)
+	if net.Empty() {
+		return nil, fmt.Errorf("%w (classifier model=%s)", ErrModelLoad, cfg.ModelPath)
+	}
+	if cfg.InputSize <= 0 {
+		cfg.InputSize = 224
+	}
+	if cfg.Scale <= 0 {
+		cfg.Scale = 1.0 / 255
+	}
+	return &faceClassifier{
+		net:    &cvNet{net},
+		labels: cfg.Labels,
+		size:   image.Pt(cfg.InputSize, cfg.InputSize),
+		scale:  cfg.Scale,
+		swapRB: cfg.SwapRB,
+	}, nil
+}
+
+func (c *faceClassifier) Close() {
+	c.net.Close()
+}
+
+// Annotate crops every detection out of img (whose coordinates the boxes
+// are in), classifies all crops in one batch and sets their Attributes.
+func (c *faceClassifier) Annotate(img gocv.Mat, dets []Detection) {
+	bounds := image.Rect(0, 0, img.Cols(), img.Rows())
+	crops := make([]gocv.Mat, 0, len(dets))
+	idx := make([]int, 0, len(dets)) // detection index of each crop
+	defer func() {
+		for i := range crops {
+			crops[i].Close()
+		}
+	}()
+	for i, d := range dets {
+		box := image.Rect(d.BBox.X, d.BBox.Y, d.BBox.X+d.BBox.Width, d.BBox.Y+d.BBox.Height).Intersect(bounds)
+		if box.Empty() {
+			continue
+		}
+		crops = append(crops, img.Region(box))
+		idx = append(idx, i)
+	}
+	if len(crops) == 0 {
+		return
+	}
+
+	blob := gocv.NewMat()
+	defer blob.Close()
+	gocv.BlobFromImages(crops, &blob, c.scale, c.size, gocv.NewScalar(0, 0, 0, 0), c.swapRB, false, gocv.MatTypeCV32F)
+	c.net.SetInput(blob, "")
+	out := c.net.Forward("") // [N, len(labels)]
+	defer out.Close()
+
+	if out.Total() != len(crops)*len(c.labels) {
+		log.Printf("[classifier] unexpected output size %d for %d faces x %d labels", out.Total(), len(crops), len(c.labels))
+		return
+	}
+	probs := out.Reshape(1, len(crops))
+	defer probs.Close()
+	for row, i := range idx {
+		attrs := make(map[string]float64, len(c.labels))
+		for k, label := range c.labels {
+			attrs[label] = float64(probs.GetFloatAt(row, k))
+		}
+		dets[i].Attributes = attrs
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// brightnessNet is a two-label classifier net scoring each image of the
+// batch by its mean brightness: [bright, dark].
+type brightnessNet struct {
+	batches []int // images per SetInput
+	means   []float64
+}
+
+func (n *brightnessNet) SetInput(blob gocv.Mat, _ string) {
+	data, _ := blob.DataPtrFloat32()
+	count := blob.Size()[0]
+	n.batches = append(n.batches, count)
+	n.means = make([]float64, count)
+	per := len(data) / count
+	for i := range count {
+		var sum float64
+		for _, v := range data[i*per : (i+1)*per] {
+			sum += float64(v)
+		}
+		n.means[i] = sum / float64(per)
+	}
+}
+
+func (n *brightnessNet) Forward(string) gocv.Mat {
+	out := gocv.NewMatWithSize(len(n.means), 2, gocv.MatTypeCV32F)
+	for i, m := range n.means {
+		out.SetFloatAt(i, 0, float32(m))
+		out.SetFloatAt(i, 1, float32(1-m))
+	}
+	return out
+}
+
+func (n *brightnessNet) ForwardLayers([]string) []gocv.Mat { return nil }
+func (n *brightnessNet) LastError() error                  { return nil }
+func (n *brightnessNet) Close() error                      { return nil }
+
+func TestFaceClassifierAnnotate(t *testing.T) {
+	// A white face on the left, a black one on the right of a grey frame.
+	img := gocv.NewMatWithSize(100, 200, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	white, black := Rect{X: 10, Y: 10, Width: 40, Height: 40}, Rect{X: 150, Y: 50, Width: 30, Height: 30}
+	gocv.Rectangle(&img, image.Rect(0, 0, 200, 100), color.RGBA{128, 128, 128, 0}, -1)
+	gocv.Rectangle(&img, image.Rect(10, 10, 50, 50), color.RGBA{255, 255, 255, 0}, -1)
+	gocv.Rectangle(&img, image.Rect(150, 50, 180, 80), color.RGBA{}, -1)
+
+	net := &brightnessNet{}
+	c := &faceClassifier{net: net, labels: []string{"bright", "dark"}, size: image.Pt(8, 8), scale: 1.0 / 255}
+	dets := []Detection{
+		{ID: 1, BBox: white},
+		{ID: 2, BBox: Rect{X: 300, Y: 10, Width: 20, Height: 20}}, // off the frame
+		{ID: 3, BBox: black},
+	}
+	c.Annotate(img, dets)
+
+	if len(net.batches) != 1 || net.batches[0] != 2 {
+		t.Fatalf("classified batches %v, want one of the 2 faces in the frame", net.batches)
+	}
+	want := []map[string]float64{{"bright": 1, "dark": 0}, nil, {"bright": 0, "dark": 1}}
+	for i, d := range dets {
+		if len(d.Attributes) != len(want[i]) {
+			t.Errorf("face %d: attributes %v, want %v", d.ID, d.Attributes, want[i])
+			continue
+		}
+		for label, p := range want[i] {
+			if got, ok := d.Attributes[label]; !ok || got < p-0.01 || got > p+0.01 {
+				t.Errorf("face %d: %s = %v, want %v", d.ID, label, got, p)
+			}
+		}
+	}
+
+	// A net answering for the wrong number of faces leaves them as they were.
+	c.labels = []string{"bright", "dark", "grey"}
+	dets = []Detection{{ID: 1, BBox: white}}
+	c.Annotate(img, dets)
+	if dets[0].Attributes != nil {
+		t.Errorf("mismatched output annotated %v", dets[0].Attributes)
+	}
+}
+
+func TestDetectorClassifier(t *testing.T) {
+	net := &fakeNet{faces: [][4]float32{{0.1, 0.1, 0.4, 0.4}, {0.5, 0.5, 0.9, 0.9}}}
+	d := newFakeDetector(t, DetectorConfig{}, &fakeLoader{next: []*fakeNet{net}})
+	classifier := &brightnessNet{}
+	d.classifier = &faceClassifier{net: classifier, labels: []string{"bright", "dark"}, size: image.Pt(8, 8), scale: 1.0 / 255}
+
+	_, dets, _, _, err := d.Detect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(classifier.batches) != 1 || classifier.batches[0] != 2 {
+		t.Errorf("classified batches %v, want the 2 detected faces at once", classifier.batches)
+	}
+	for _, det := range dets {
+		if det.Attributes["dark"] != 1 { // blank frames
+			t.Errorf("face %v: attributes %v", det.BBox, det.Attributes)
+		}
+	}
+}
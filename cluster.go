@@ -0,0 +1,66 @@
+package main
+
+import "math"
+
+/* ------------------------------ Clustering --------------------------------- */
+
+// clusterDetections merges detections whose centers are within dist pixels
+// of each other (single linkage: chains of close faces form one group) and
+// returns one enclosing box per group, with Count set to the group size and
+// Score to its best member's score. Unlike NMS this merges genuinely
+// different faces, trading identity for privacy-light crowd analytics.
+// dist <= 0 returns dets unchanged.
+func clusterDetections(dets []Detection, dist int) []Detection {
+	if dist <= 0 || len(dets) == 0 {
+		return dets
+	}
+
+	// Union-find over detection indices.
+	parent := make([]int, len(dets))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	center := func(d Detection) (float64, float64) {
+		return float64(d.BBox.X) + float64(d.BBox.Width)/2, float64(d.BBox.Y) + float64(d.BBox.Height)/2
+	}
+	for i := range dets {
+		xi, yi := center(dets[i])
+		for j := i + 1; j < len(dets); j++ {
+			xj, yj := center(dets[j])
+			if math.Hypot(xi-xj, yi-yj) <= float64(dist) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	// Build groups in order of first appearance so output is deterministic.
+	groupOf := make(map[int]int, len(dets))
+	out := make([]Detection, 0, len(dets))
+	for i, d := range dets {
+		root := find(i)
+		g, ok := groupOf[root]
+		if !ok {
+			g = len(out)
+			groupOf[root] = g
+			out = append(out, Detection{ID: g, BBox: d.BBox, Score: d.Score, Timestamp: d.Timestamp})
+		}
+		grp := &out[g]
+		grp.Count++
+		if grp.Count == 1 {
+			continue
+		}
+		x1, y1 := min(grp.BBox.X, d.BBox.X), min(grp.BBox.Y, d.BBox.Y)
+		x2 := max(grp.BBox.X+grp.BBox.Width, d.BBox.X+d.BBox.Width)
+		y2 := max(grp.BBox.Y+grp.BBox.Height, d.BBox.Y+d.BBox.Height)
+		grp.BBox = Rect{X: x1, Y: y1, Width: x2 - x1, Height: y2 - y1}
+		grp.Score = math.Max(grp.Score, d.Score)
+	}
+	return out
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClusterDetections(t *testing.T) {
+	det := func(x, y int, score float64) Detection {
+		return Detection{BBox: Rect{X: x, Y: y, Width: 20, Height: 20}, Score: score}
+	}
+	tests := []struct {
+		name string
+		dets []Detection
+		dist int
+		want []Detection
+	}{
+		{"one face", []Detection{det(10, 10, 0.9)}, 50,
+			[]Detection{{BBox: Rect{X: 10, Y: 10, Width: 20, Height: 20}, Count: 1, Score: 0.9}}},
+		{"two near", []Detection{det(10, 10, 0.6), det(40, 50, 0.9)}, 50,
+			[]Detection{{BBox: Rect{X: 10, Y: 10, Width: 50, Height: 60}, Count: 2, Score: 0.9}}},
+		{"two far", []Detection{det(10, 10, 0.6), det(100, 10, 0.9)}, 50,
+			[]Detection{
+				{ID: 0, BBox: Rect{X: 10, Y: 10, Width: 20, Height: 20}, Count: 1, Score: 0.6},
+				{ID: 1, BBox: Rect{X: 100, Y: 10, Width: 20, Height: 20}, Count: 1, Score: 0.9},
+			}},
+		{"exactly at distance", []Detection{det(0, 0, 0.6), det(30, 40, 0.9)}, 50,
+			[]Detection{{BBox: Rect{X: 0, Y: 0, Width: 50, Height: 60}, Count: 2, Score: 0.9}}},
+		{"chain", []Detection{det(0, 0, 0.5), det(80, 0, 0.6), det(40, 0, 0.7)}, 50,
+			[]Detection{{BBox: Rect{X: 0, Y: 0, Width: 100, Height: 20}, Count: 3, Score: 0.7}}},
+		{"disabled", []Detection{det(10, 10, 0.6), det(12, 10, 0.9)}, 0,
+			[]Detection{det(10, 10, 0.6), det(12, 10, 0.9)}},
+	}
+	for _, tt := range tests {
+		if got := clusterDetections(tt.dets, tt.dist); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+/* ------------------------------ Input colors ------------------------------- */
+
+// colorSpace is the channel layout the net expects. Frames are captured as
+// BGR and converted before the blob is built.
+type colorSpace string
+
+const (
+	colorBGR  colorSpace = "bgr"  // as captured (Res10)
+	colorRGB  colorSpace = "rgb"  // channels reversed
+	colorGray colorSpace = "gray" // luminance replicated on 3 channels
+	colorMono colorSpace = "mono" // luminance on a single channel (FACE_INPUT_CHANNELS=1)
+)
+
+// parseColorSpace validates FACE_COLOR_SPACE against FACE_SWAP_RB: swapRB
+// reverses the channels again inside the blob, so with rgb it would feed
+// BGR back to the net, and with gray it does nothing.
+func parseColorSpace(v string, swapRB bool) (colorSpace, error) {
+	switch cs := colorSpace(v); cs {
+	case "", colorBGR:
+		return colorBGR, nil
+	case colorRGB, colorGray:
+		if swapRB {
+			return "", fmt.Errorf("%s can't be combined with FACE_SWAP_RB=1 (use bgr with FACE_SWAP_RB=1, or rgb alone)", cs)
+		}
+		return cs, nil
+	}
+	return "", fmt.Errorf("unknown color space %q (want bgr, rgb or gray)", v)
+}
+
+// withChannels returns the color space for a net taking n input channels:
+// cs for 3, mono for 1, which only a bgr or gray FACE_COLOR_SPACE allows.
+// A grayscale camera then skips the replication to 3 channels, and the
+// net gets a single mean.
+func (cs colorSpace) withChannels(n int, swapRB bool) (colorSpace, error) {
+	switch n {
+	case 3:
+		return cs, nil
+	case 1:
+		if cs == colorRGB || swapRB {
+			return "", errors.New("1 channel can't be combined with FACE_COLOR_SPACE=rgb or FACE_SWAP_RB=1")
+		}
+		return colorMono, nil
+	}
+	return "", fmt.Errorf("want 1 or 3, got %d", n)
+}
+
+// channels is the number of channels of the blob.
+func (cs colorSpace) channels() int {
+	if cs == colorMono {
+		return 1
+	}
+	return 3
+}
+
+// checkInputChannels runs net once on a blank input of cs's channel count,
+// so a model that doesn't take it (Res10 needs 3) fails at load time rather
+// than on every frame.
+func checkInputChannels(net *gocv.Net, cs colorSpace, size image.Point) error {
+	typ := gocv.MatTypeCV8UC3
+	if cs.channels() == 1 {
+		typ = gocv.MatTypeCV8UC1
+	}
+	blank := gocv.NewMatWithSize(size.Y, size.X, typ)
+	defer blank.Close()
+	blob := gocv.NewMat()
+	defer blob.Close()
+	gocv.BlobFromImages([]gocv.Mat{blank}, &blob, 1, size, gocv.NewScalar(0, 0, 0, 0), false, false, gocv.MatTypeCV32F)
+	net.SetInput(blob, "")
+	out := net.Forward("")
+	defer out.Close()
+	if out.Empty() {
+		return fmt.Errorf("%w: the model rejects a %d-channel input: %v", ErrModelLoad, cs.channels(), gocv.LastExceptionError())
+	}
+	return nil
+}
+
+// inputMean returns the per-channel mean (given in BGR order) in the order
+// of cs, so each color keeps its mean; gray and mono use their average.
+func (cs colorSpace) inputMean(bgr gocv.Scalar) gocv.Scalar {
+	switch cs {
+	case colorRGB:
+		return gocv.NewScalar(bgr.Val3, bgr.Val2, bgr.Val1, bgr.Val4)
+	case colorGray:
+		m := (bgr.Val1 + bgr.Val2 + bgr.Val3) / 3
+		return gocv.NewScalar(m, m, m, bgr.Val4)
+	case colorMono:
+		return gocv.NewScalar((bgr.Val1+bgr.Val2+bgr.Val3)/3, 0, 0, 0)
+	}
+	return bgr
+}
+
+// convertInput returns img in color space cs: img itself for BGR,
+// otherwise *dst, which is overwritten. dst and tmp (a scratch buffer for
+// gray) are owned by the caller and reused across frames, so the conversion
+// doesn't allocate once their size is settled.
+func (cs colorSpace) convertInput(img gocv.Mat, dst, tmp *gocv.Mat) (gocv.Mat, error) {
+	var err error
+	switch cs {
+	case colorRGB:
+		err = gocv.CvtColor(img, dst, gocv.ColorBGRToRGB)
+	case colorGray:
+		if err = gocv.CvtColor(img, tmp, gocv.ColorBGRToGray); err == nil {
+			err = gocv.CvtColor(*tmp, dst, gocv.ColorGrayToBGR)
+		}
+	case colorMono:
+		if img.Channels() == 1 {
+			return img, nil
+		}
+		err = gocv.CvtColor(img, dst, gocv.ColorBGRToGray)
+	default:
+		return img, nil
+	}
+	if err != nil {
+		return img, fmt.Errorf("convert input to %s: %w", cs, err)
+	}
+	return *dst, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// inputNet records the first pixel of each channel of the blobs it is fed.
+type inputNet struct {
+	*fakeNet
+	pixel []float32
+}
+
+func (n *inputNet) SetInput(blob gocv.Mat, name string) {
+	n.fakeNet.SetInput(blob, name)
+	sz := blob.Size() // N, C, H, W
+	data, _ := blob.DataPtrFloat32()
+	n.pixel = n.pixel[:0]
+	for c := range sz[1] {
+		n.pixel = append(n.pixel, data[c*sz[2]*sz[3]])
+	}
+}
+
+// colorSource captures w x h frames of a single color.
+type colorSource struct {
+	c    color.RGBA
+	w, h int
+}
+
+func (s colorSource) Read(m *gocv.Mat) bool {
+	img := gocv.NewMatWithSize(s.h, s.w, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	gocv.Rectangle(&img, image.Rect(0, 0, s.w, s.h), s.c, -1)
+	img.CopyTo(m)
+	return true
+}
+
+func (colorSource) Close() error { return nil }
+
+func TestColorSpaceInput(t *testing.T) {
+	// B 10, G 100, R 200; luminance 119.6. Res10's mean is B 104, G 177, R 123.
+	const gray, grayMean = 119.6, (104.0 + 177 + 123) / 3
+	tests := []struct {
+		cs   colorSpace
+		want []float32
+	}{
+		{colorBGR, []float32{10 - 104, 100 - 177, 200 - 123}},
+		{colorRGB, []float32{200 - 123, 100 - 177, 10 - 104}},
+		{colorGray, []float32{gray - grayMean, gray - grayMean, gray - grayMean}},
+		{colorMono, []float32{gray - grayMean}},
+	}
+	for _, tt := range tests {
+		net := &inputNet{fakeNet: &fakeNet{faces: [][4]float32{{0.1, 0.1, 0.5, 0.5}}}}
+		d, err := newInferenceDetector(DetectorConfig{ColorSpace: tt.cs}, func(DetectorConfig) (inferenceNet, error) { return net, nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.cap = colorSource{color.RGBA{R: 200, G: 100, B: 10, A: 255}, 100, 100}
+		if _, _, _, _, err := d.Detect(); err != nil {
+			t.Fatalf("%s: %v", tt.cs, err)
+		}
+		d.Close()
+		if len(net.pixel) != len(tt.want) {
+			t.Errorf("%s: blob of %d channels, want %d", tt.cs, len(net.pixel), len(tt.want))
+			continue
+		}
+		for c := range tt.want {
+			if math.Abs(float64(net.pixel[c]-tt.want[c])) > 1 {
+				t.Errorf("%s: blob pixel %v, want %v", tt.cs, net.pixel, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseColorSpace(t *testing.T) {
+	tests := []struct {
+		v      string
+		swapRB bool
+		want   colorSpace
+		ok     bool
+	}{
+		{"", false, colorBGR, true},
+		{"bgr", true, colorBGR, true},
+		{"rgb", false, colorRGB, true},
+		{"rgb", true, "", false}, // swapped back to BGR
+		{"gray", false, colorGray, true},
+		{"gray", true, "", false},
+		{"mono", false, "", false}, // only through FACE_INPUT_CHANNELS=1
+		{"hsv", false, "", false},
+	}
+	for _, tt := range tests {
+		got, err := parseColorSpace(tt.v, tt.swapRB)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseColorSpace(%q, %v) = %q, %v", tt.v, tt.swapRB, got, err)
+		}
+	}
+}
+
+// graySource captures single-channel frames, like an IR camera.
+type graySource struct{}
+
+func (graySource) Read(m *gocv.Mat) bool {
+	img := gocv.NewMatWithSize(100, 100, gocv.MatTypeCV8UC1)
+	defer img.Close()
+	img.CopyTo(m)
+	return true
+}
+
+func (graySource) Close() error { return nil }
+
+func TestInputChannels(t *testing.T) {
+	tests := []struct {
+		channels int
+		cs       colorSpace
+		swapRB   bool
+		want     colorSpace
+		ok       bool
+	}{
+		{3, colorBGR, false, colorBGR, true},
+		{3, colorRGB, false, colorRGB, true},
+		{1, colorBGR, false, colorMono, true},
+		{1, colorGray, false, colorMono, true},
+		{1, colorRGB, false, "", false},
+		{1, colorBGR, true, "", false},
+		{2, colorBGR, false, "", false},
+		{4, colorBGR, false, "", false},
+	}
+	for _, tt := range tests {
+		if got, err := tt.cs.withChannels(tt.channels, tt.swapRB); got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("%s.withChannels(%d, %v) = %q, %v", tt.cs, tt.channels, tt.swapRB, got, err)
+		}
+	}
+
+	// Every blob, the warm-up's included, has the configured channel count,
+	// whether the camera is color or already grayscale.
+	for _, cs := range []colorSpace{colorBGR, colorGray, colorMono} {
+		for _, src := range []frameSource{fakeSource{w: 100, h: 100}, graySource{}} {
+			if cs != colorMono && src == (graySource{}) {
+				continue // a 3-channel net needs a color frame
+			}
+			net := &fakeNet{faces: [][4]float32{{0.1, 0.1, 0.5, 0.5}}}
+			d, err := newInferenceDetector(DetectorConfig{ColorSpace: cs, WarmUp: true}, func(DetectorConfig) (inferenceNet, error) { return net, nil })
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.cap = src
+			if _, _, _, _, err := d.Detect(); err != nil {
+				t.Fatalf("%s from %T: %v", cs, src, err)
+			}
+			d.Close()
+			if len(net.inputs) != 2 {
+				t.Fatalf("%s from %T: %d blobs, want the warm-up and the frame", cs, src, len(net.inputs))
+			}
+			for _, in := range net.inputs {
+				if in[1] != cs.channels() {
+					t.Errorf("%s from %T: blob %v, want %d channels", cs, src, in, cs.channels())
+				}
+			}
+		}
+	}
+}
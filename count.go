@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ---------------------------------- Count ---------------------------------- */
+
+// countHandler serves the face count of the latest snapshot, for scripts
+// that don't need the detections: JSON, or a bare integer with ?plain=1.
+// It shares the version of /faces, so the ETag changes with every frame.
+func countHandler(store *FaceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		plain := r.URL.Query().Get("plain") == "1"
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Cache-Control", "no-store")
+
+		snap, ver := store.Get()
+		etag := strings.TrimSuffix(store.ETag(ver, snap.Frame), `"`) + `-count"`
+		if plain {
+			etag = strings.TrimSuffix(etag, `"`) + `-plain"`
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		if plain {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte(strconv.Itoa(faceCount(snap)) + "\n"))
+			return
+		}
+		writeJSON(w, struct {
+			Count       int       `json:"count"`
+			Frame       int64     `json:"frame"`
+			GeneratedAt time.Time `json:"generated_at"`
+		}{faceCount(snap), snap.Frame, snap.GeneratedAt}, true)
+	}
+}
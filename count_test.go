@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountHandler(t *testing.T) {
+	at := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := NewFaceStore()
+	store.Set(Snapshot{Frame: 42, GeneratedAt: at, Detections: []Detection{
+		{ID: 1}, {ID: 2, Count: 2}, {ID: 3, Predicted: true}, // a cluster of two, a coasting track
+	}})
+	get := func(query, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/count"+query, nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		countHandler(store)(w, r)
+		return w
+	}
+
+	w := get("", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("JSON: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if keys := jsonKeys(t, w.Body.Bytes()); keys != "count frame generated_at" {
+		t.Errorf("JSON keys %q", keys)
+	}
+	var got struct {
+		Count       int       `json:"count"`
+		Frame       int64     `json:"frame"`
+		GeneratedAt time.Time `json:"generated_at"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Count != 3 || got.Frame != 42 || !got.GeneratedAt.Equal(at) {
+		t.Errorf("JSON %+v (%v), want 3 faces of frame 42 at %v", got, err, at)
+	}
+	jsonTag := w.Header().Get("ETag")
+
+	w = get("?plain=1", "")
+	if w.Code != http.StatusOK || w.Body.String() != "3\n" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("plain: %d %q %s", w.Code, w.Body, w.Header().Get("Content-Type"))
+	}
+	plainTag := w.Header().Get("ETag")
+	if jsonTag == "" || plainTag == "" || jsonTag == plainTag {
+		t.Fatalf("ETags %q and %q, want two distinct tags", jsonTag, plainTag)
+	}
+
+	// Same version: 304 without a body; the other variant's tag doesn't match.
+	tests := []struct {
+		query, etag string
+		code        int
+	}{
+		{"", jsonTag, http.StatusNotModified},
+		{"?plain=1", plainTag, http.StatusNotModified},
+		{"", plainTag, http.StatusOK},
+		{"?plain=1", jsonTag, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := get(tt.query, tt.etag); w.Code != tt.code || tt.code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%q with %s: %d %q, want %d", tt.query, tt.etag, w.Code, w.Body, tt.code)
+		}
+	}
+
+	// A new snapshot, even with the same count, is a new version like /faces.
+	store.Set(Snapshot{Frame: 43, Detections: []Detection{{ID: 1}, {ID: 2}, {ID: 4}}})
+	if w := get("", jsonTag); w.Code != http.StatusOK || w.Header().Get("ETag") == jsonTag {
+		t.Errorf("after a new snapshot: %d, ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+}
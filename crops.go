@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+/* ------------------------------- Crop saving ------------------------------- */
+
+// CropSaver writes face crops to disk as a dataset, at most one per track
+// per cooldown instead of one per frame. Tracks are told apart by their UUID
+// (FACE_TRACK=1); untracked faces share a single cooldown and are all saved
+// when it expires. In best-only mode the best crop of each cooldown window,
+// by quality when it is computed and by score otherwise, is kept in memory
+// and written when the window closes or the track ends. It is fed by the
+// detector loop and outlives detector restarts.
+type CropSaver struct {
+	dir      string
+	cooldown time.Duration
+	bestOnly bool
+	format   imageFormat
+	slots    map[string]*cropSlot
+}
+
+type cropSlot struct {
+	windowStart time.Time
+	best        *pendingCrop // best-only: best crop of the window, unwritten
+}
+
+type pendingCrop struct {
+	name  string
+	score float64
+	img   gocv.Mat
+}
+
+// NewCropSaver writes crops into dir, creating it if needed.
+func NewCropSaver(dir string, cooldown time.Duration, bestOnly bool, format imageFormat) (*CropSaver, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &CropSaver{dir: dir, cooldown: cooldown, bestOnly: bestOnly, format: format, slots: make(map[string]*cropSlot)}, nil
+}
+
+// cropKey is the cooldown key of a detection.
+func cropKey(d Detection) string {
+	return d.UUID // "" for untracked faces
+}
+
+// Observe considers the detections of one published frame; img is the frame
+// their boxes refer to.
+func (c *CropSaver) Observe(snap Snapshot, img gocv.Mat) {
+	due := make(map[string]bool, len(snap.Detections)) // per key, decided once per frame
+	t := snap.GeneratedAt
+	for _, d := range snap.Detections {
+		key := cropKey(d)
+		save, seen := due[key]
+		if !seen {
+			slot, ok := c.slots[key]
+			if !ok {
+				slot = &cropSlot{windowStart: t}
+				c.slots[key] = slot
+			}
+			save = !ok || t.Sub(slot.windowStart) >= c.cooldown
+			if save {
+				if c.bestOnly && ok {
+					c.flush(slot)
+				}
+				slot.windowStart = t
+			}
+			due[key] = save
+		}
+		name := cropName(snap.Frame, d, c.format)
+		if !c.bestOnly {
+			if save {
+				c.save(name, img, d.BBox)
+			}
+			continue
+		}
+		slot := c.slots[key]
+		if rank := cropRank(d); slot.best == nil || rank > slot.best.score {
+			if crop, ok := cropOf(img, d.BBox); ok {
+				if slot.best != nil {
+					slot.best.img.Close()
+				}
+				slot.best = &pendingCrop{name: name, score: rank, img: crop}
+			}
+		}
+	}
+	// Tracks that left: write their pending best crop.
+	for key, slot := range c.slots {
+		if _, ok := due[key]; !ok {
+			c.flush(slot)
+			delete(c.slots, key)
+		}
+	}
+}
+
+// memoryUsage, oldest and evict make the pending best crops a memoryHolder:
+// under memory pressure the oldest window is closed early and its crop
+// written.
+func (c *CropSaver) memoryUsage() int64 {
+	var n int64
+	for _, slot := range c.slots {
+		if slot.best != nil {
+			n += matBytes(slot.best.img)
+		}
+	}
+	return n
+}
+
+func (c *CropSaver) oldest() (time.Time, bool) {
+	slot := c.oldestPending()
+	if slot == nil {
+		return time.Time{}, false
+	}
+	return slot.windowStart, true
+}
+
+func (c *CropSaver) evict() int64 {
+	slot := c.oldestPending()
+	if slot == nil {
+		return 0
+	}
+	freed := matBytes(slot.best.img)
+	c.flush(slot)
+	return freed
+}
+
+func (c *CropSaver) oldestPending() *cropSlot {
+	var oldest *cropSlot
+	for _, slot := range c.slots {
+		if slot.best != nil && (oldest == nil || slot.windowStart.Before(oldest.windowStart)) {
+			oldest = slot
+		}
+	}
+	return oldest
+}
+
+// Close writes the pending best crops.
+func (c *CropSaver) Close() {
+	for key, slot := range c.slots {
+		c.flush(slot)
+		delete(c.slots, key)
+	}
+}
+
+func (c *CropSaver) flush(slot *cropSlot) {
+	if slot.best == nil {
+		return
+	}
+	c.write(slot.best.name, slot.best.img)
+	slot.best.img.Close()
+	slot.best = nil
+}
+
+func (c *CropSaver) save(name string, img gocv.Mat, box Rect) {
+	crop, ok := cropOf(img, box)
+	if !ok {
+		return
+	}
+	defer crop.Close()
+	c.write(name, crop)
+}
+
+func (c *CropSaver) write(name string, crop gocv.Mat) {
+	data, err := c.format.Encode(crop)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(c.dir, name), data, 0o644)
+	}
+	if err != nil {
+		log.Printf("[crops] %s: %v", name, err)
+	}
+}
+
+// cropRank orders the crops of a window in best-only mode.
+func cropRank(d Detection) float64 {
+	if d.Quality > 0 {
+		return d.Quality
+	}
+	return d.Score
+}
+
+// cropName is "<frame>-<uuid or id>.<ext>".
+func cropName(frame int64, d Detection, f imageFormat) string {
+	id := d.UUID
+	if id == "" {
+		id = strconv.Itoa(d.ID)
+	}
+	return fmt.Sprintf("%d-%s%s", frame, id, f.ext)
+}
+
+// cropOf returns a copy of box within img; false if they don't overlap.
+func cropOf(img gocv.Mat, box Rect) (gocv.Mat, bool) {
+	r := image.Rect(box.X, box.Y, box.X+box.Width, box.Y+box.Height).Intersect(image.Rect(0, 0, img.Cols(), img.Rows()))
+	if r.Empty() {
+		return gocv.Mat{}, false
+	}
+	region := img.Region(r)
+	defer region.Close()
+	return region.Clone(), true
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+func TestCropSaverCooldown(t *testing.T) {
+	img := gocv.NewMatWithSize(100, 100, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	box := Rect{X: 10, Y: 10, Width: 20, Height: 20}
+
+	// run feeds 25 frames 100ms apart: track a throughout, b from frame 5
+	// to 16, scored by score; it returns the files written.
+	run := func(bestOnly bool, score func(frame int) float64) []string {
+		dir := t.TempDir()
+		c, err := NewCropSaver(dir, time.Second, bestOnly, defaultJPEG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 25; i++ {
+			dets := []Detection{{ID: 1, UUID: "a", BBox: box, Score: score(i)}}
+			if i >= 5 && i <= 16 {
+				dets = append(dets, Detection{ID: 2, UUID: "b", BBox: box, Score: score(i)})
+			}
+			c.Observe(Snapshot{Frame: int64(i), GeneratedAt: t0.Add(time.Duration(i-1) * 100 * time.Millisecond), Detections: dets}, img)
+		}
+		c.Close()
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files []string
+		for _, e := range entries {
+			files = append(files, e.Name())
+		}
+		slices.Sort(files)
+		return files
+	}
+
+	// One crop per track per second, not one per frame.
+	got := run(false, func(int) float64 { return 0.9 })
+	want := []string{"1-a.jpg", "11-a.jpg", "15-b.jpg", "21-a.jpg", "5-b.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("crops %v, want %v", got, want)
+	}
+
+	// Best only: the best frame of each window, b's last window written
+	// when it leaves and a's when the saver closes.
+	peaks := map[int]float64{3: 0.99, 12: 0.99, 16: 0.98, 24: 0.99}
+	got = run(true, func(i int) float64 {
+		if s, ok := peaks[i]; ok {
+			return s
+		}
+		return 0.5
+	})
+	want = []string{"12-a.jpg", "12-b.jpg", "16-b.jpg", "24-a.jpg", "3-a.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("best-only crops %v, want %v", got, want)
+	}
+}
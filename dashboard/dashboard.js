@@ -0,0 +1,60 @@
+// Default dashboard, embedded in the binary and served when FACE_STATIC
+// doesn't exist: polls /faces and draws the boxes, scaled to the window.
+let refreshDelay = 200;     // ms, overridden by /app-config.json
+let scorePercent = false;   // scores served as 0-100 (FACE_SCORE_UNITS=percent)
+
+const canvas = document.getElementById('view');
+const ctx = canvas.getContext('2d');
+const status = document.getElementById('status');
+let lastETag = null;
+
+function draw(data) {
+    canvas.width = canvas.clientWidth;
+    canvas.height = canvas.clientHeight;
+    ctx.clearRect(0, 0, canvas.width, canvas.height);
+    if (!data.frame_width || !data.frame_height) return;
+
+    // Letterbox the frame into the canvas
+    const scale = Math.min(canvas.width / data.frame_width, canvas.height / data.frame_height);
+    const ox = (canvas.width - data.frame_width * scale) / 2;
+    const oy = (canvas.height - data.frame_height * scale) / 2;
+    ctx.strokeStyle = '#444';
+    ctx.strokeRect(ox, oy, data.frame_width * scale, data.frame_height * scale);
+
+    ctx.font = '12px sans-serif';
+    for (const d of data.detections || []) {
+        const b = d.bbox;
+        ctx.strokeStyle = ctx.fillStyle = d.color || '#3c3';
+        ctx.lineWidth = 2;
+        ctx.strokeRect(ox + b.x * scale, oy + b.y * scale, b.width * scale, b.height * scale);
+        const score = scorePercent ? `${d.score}%` : d.score.toFixed(2);
+        ctx.fillText(`#${d.id} ${score}`, ox + b.x * scale, oy + b.y * scale - 4);
+    }
+}
+
+async function poll() {
+    try {
+        const headers = lastETag ? { 'If-None-Match': lastETag } : {};
+        const res = await fetch('/faces?case=snake', { headers, cache: 'no-store' });
+        if (res.status === 200) {
+            lastETag = res.headers.get('ETag');
+            const data = await res.json();
+            const n = data.detections ? data.detections.length : 0;
+            status.textContent = `${data.source} · frame ${data.frame} · ${n} face(s)`;
+            draw(data);
+        }
+    } catch (e) {
+        status.textContent = `error: ${e}`;
+    }
+    setTimeout(poll, refreshDelay);
+}
+
+fetch('/app-config.json')
+    .then(res => res.ok ? res.json() : null)
+    .then(cfg => {
+        if (!cfg) return;
+        if (cfg.poll_interval_ms) refreshDelay = cfg.poll_interval_ms;
+        scorePercent = cfg.score_units === 'percent';
+    })
+    .catch(() => {})
+    .finally(poll);
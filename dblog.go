@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+/* ----------------------------- Detection log ------------------------------- */
+
+// Detection log batching: rows are written when a batch is full or after
+// dbFlushEvery, whichever comes first.
+const (
+	dbBatchRows  = 500
+	dbFlushEvery = time.Second
+)
+
+// detectionRow is one logged detection.
+type detectionRow struct {
+	Frame  int64
+	ID     int
+	X, Y   int
+	W, H   int
+	Score  float64
+	TS     time.Time
+	Source string
+}
+
+// detectionSink stores batches of rows (see sqlite.go).
+type detectionSink interface {
+	Insert(rows []detectionRow) error
+	Close() error
+}
+
+// detectionLogSink writes the detections of every sample-th snapshot to a
+// detectionSink (SQLite), batching rows into transactions: a batch is
+// written when it is full or once dbFlushEvery has passed since the last
+// write. Snapshots arrive from the sink's own goroutine, so the database
+// never slows the detector down.
+type detectionLogSink struct {
+	db     detectionSink
+	sample int64
+	batch  []detectionRow
+	last   time.Time // last write
+}
+
+func newDetectionLogSink(db detectionSink, sample int) *detectionLogSink {
+	return &detectionLogSink{db: db, sample: int64(max(sample, 1)), last: time.Now()}
+}
+
+func (s *detectionLogSink) Publish(snap Snapshot) error {
+	if snap.Frame%s.sample == 0 {
+		s.batch = append(s.batch, detectionRows(snap)...)
+	}
+	if len(s.batch) >= dbBatchRows || time.Since(s.last) >= dbFlushEvery {
+		return s.flush()
+	}
+	return nil
+}
+
+// flush writes the pending rows; they are dropped on error so a broken
+// database doesn't grow the batch forever.
+func (s *detectionLogSink) flush() error {
+	s.last = time.Now()
+	if len(s.batch) == 0 {
+		return nil
+	}
+	err := s.db.Insert(s.batch)
+	s.batch = s.batch[:0]
+	return err
+}
+
+func (s *detectionLogSink) Close() error {
+	return errors.Join(s.flush(), s.db.Close())
+}
+
+func detectionRows(snap Snapshot) []detectionRow {
+	rows := make([]detectionRow, len(snap.Detections))
+	for i, d := range snap.Detections {
+		rows[i] = detectionRow{
+			Frame: snap.Frame, ID: d.ID,
+			X: d.BBox.X, Y: d.BBox.Y, W: d.BBox.Width, H: d.BBox.Height,
+			Score: d.Score, TS: d.Timestamp, Source: snap.Source,
+		}
+	}
+	return rows
+}
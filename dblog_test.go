@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeDetectionDB records the batches it is given.
+type fakeDetectionDB struct {
+	batches [][]detectionRow
+	err     error
+	closed  bool
+}
+
+func (db *fakeDetectionDB) Insert(rows []detectionRow) error {
+	db.batches = append(db.batches, append([]detectionRow(nil), rows...))
+	return db.err
+}
+
+func (db *fakeDetectionDB) Close() error {
+	db.closed = true
+	return nil
+}
+
+func TestDetectionLogSink(t *testing.T) {
+	ts := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	snap := func(frame int64, faces int) Snapshot {
+		s := Snapshot{Source: "cam", Frame: frame}
+		for i := range faces {
+			s.Detections = append(s.Detections, Detection{ID: i + 1, BBox: Rect{X: i, Y: 2, Width: 3, Height: 4}, Score: 0.9, Timestamp: ts})
+		}
+		return s
+	}
+
+	// Every 2nd snapshot, batched until closed.
+	db := &fakeDetectionDB{}
+	s := newDetectionLogSink(db, 2)
+	for frame := int64(1); frame <= 4; frame++ {
+		if err := s.Publish(snap(frame, 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(db.batches) != 0 {
+		t.Fatalf("wrote %d batches before the batch filled up", len(db.batches))
+	}
+	if err := s.Close(); err != nil || !db.closed || len(db.batches) != 1 {
+		t.Fatalf("close: %v, closed %v, %d batches", err, db.closed, len(db.batches))
+	}
+	want := []detectionRow{
+		{Frame: 2, ID: 1, X: 0, Y: 2, W: 3, H: 4, Score: 0.9, TS: ts, Source: "cam"},
+		{Frame: 2, ID: 2, X: 1, Y: 2, W: 3, H: 4, Score: 0.9, TS: ts, Source: "cam"},
+		{Frame: 4, ID: 1, X: 0, Y: 2, W: 3, H: 4, Score: 0.9, TS: ts, Source: "cam"},
+		{Frame: 4, ID: 2, X: 1, Y: 2, W: 3, H: 4, Score: 0.9, TS: ts, Source: "cam"},
+	}
+	if !reflect.DeepEqual(db.batches[0], want) {
+		t.Errorf("rows %+v, want %+v", db.batches[0], want)
+	}
+
+	// A full batch is written right away, and so is anything pending once
+	// dbFlushEvery has passed.
+	db = &fakeDetectionDB{}
+	s = newDetectionLogSink(db, 1)
+	s.Publish(snap(1, dbBatchRows))
+	s.Publish(snap(2, 1))
+	s.last = time.Now().Add(-dbFlushEvery)
+	s.Publish(snap(3, 1))
+	if len(db.batches) != 2 || len(db.batches[0]) != dbBatchRows || len(db.batches[1]) != 2 {
+		t.Errorf("batches of %v rows, want %d then 2", batchSizes(db.batches), dbBatchRows)
+	}
+
+	// A failing database drops the batch instead of growing it.
+	db = &fakeDetectionDB{err: errors.New("disk full")}
+	s = newDetectionLogSink(db, 1)
+	s.Publish(snap(1, 1))
+	s.last = time.Now().Add(-dbFlushEvery)
+	if err := s.Publish(snap(2, 1)); err == nil {
+		t.Error("insert error not reported")
+	}
+	if len(s.batch) != 0 {
+		t.Errorf("%d rows kept after a failed insert", len(s.batch))
+	}
+}
+
+func batchSizes(batches [][]detectionRow) []int {
+	var n []int
+	for _, b := range batches {
+		n = append(n, len(b))
+	}
+	return n
+}
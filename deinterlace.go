@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+/* ------------------------------- Deinterlace ------------------------------- */
+
+// deinterlaceMode removes the comb artifacts of interlaced sources (old
+// analog cameras behind an encoder), whose two fields were captured 1/50 or
+// 1/60 s apart, before the blob is built. Only the inference input is
+// deinterlaced; crops and the preview show the frame as captured.
+type deinterlaceMode string
+
+const (
+	deinterlaceOff    deinterlaceMode = "off"
+	deinterlaceBlend  deinterlaceMode = "blend"  // each row averaged with its neighbours, [1 2 1]/4
+	deinterlaceDouble deinterlaceMode = "double" // even field only, stretched back to full height
+)
+
+func parseDeinterlace(v string) (deinterlaceMode, error) {
+	switch m := deinterlaceMode(v); m {
+	case "", deinterlaceOff:
+		return deinterlaceOff, nil
+	case deinterlaceBlend, deinterlaceDouble:
+		return m, nil
+	}
+	return "", fmt.Errorf("want off, blend or double, got %q", v)
+}
+
+// apply returns img deinterlaced: img itself when off, otherwise *dst,
+// which is overwritten. dst and field (the half-height even field) are owned
+// by the caller and reused across frames, like convertInput's buffers.
+//
+// blend cancels the row-to-row alternation entirely (the kernel's response
+// at the vertical Nyquist frequency is 0) at the cost of some vertical blur;
+// double drops the odd field, so motion is sharp but vertical resolution is
+// halved.
+func (m deinterlaceMode) apply(img gocv.Mat, dst, field *gocv.Mat) (gocv.Mat, error) {
+	var err error
+	switch m {
+	case deinterlaceBlend:
+		err = gocv.GaussianBlur(img, dst, image.Pt(1, 3), 0, 0, gocv.BorderDefault)
+	case deinterlaceDouble:
+		w, h := img.Cols(), img.Rows()
+		if h < 2 {
+			return img, nil
+		}
+		// Nearest neighbour halving keeps exactly the even rows
+		if err = gocv.Resize(img, field, image.Pt(w, h/2), 0, 0, gocv.InterpolationNearestNeighbor); err == nil {
+			err = gocv.Resize(*field, dst, image.Pt(w, h), 0, 0, gocv.InterpolationLinear)
+		}
+	default:
+		return img, nil
+	}
+	if err != nil {
+		return img, fmt.Errorf("deinterlace (%s): %w", m, err)
+	}
+	return *dst, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// combEnergy is the mean squared difference between each row and the average
+// of its neighbours: the comb of interlacing alternates row by row, so it
+// dominates this measure while smooth content barely registers.
+func combEnergy(m gocv.Mat) float64 {
+	var sum float64
+	n := 0
+	for y := 1; y < m.Rows()-1; y++ {
+		for x := range m.Cols() {
+			d := float64(m.GetUCharAt(y, x)) - (float64(m.GetUCharAt(y-1, x))+float64(m.GetUCharAt(y+1, x)))/2
+			sum += d * d
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+func TestDeinterlace(t *testing.T) {
+	// A bar moving right between the fields over a vertical gradient: the
+	// even rows show it at x 10..30, the odd rows at x 20..40.
+	const w, h = 64, 48
+	data := make([]byte, w*h)
+	for y := range h {
+		from := 10
+		if y%2 == 1 {
+			from = 20
+		}
+		for x := range w {
+			data[y*w+x] = byte(50 + y)
+			if x >= from && x < from+20 {
+				data[y*w+x] = 220
+			}
+		}
+	}
+	combed, err := gocv.NewMatFromBytes(h, w, gocv.MatTypeCV8UC1, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer combed.Close()
+	before := combEnergy(combed)
+
+	for _, mode := range []deinterlaceMode{deinterlaceBlend, deinterlaceDouble} {
+		dst, field := gocv.NewMat(), gocv.NewMat()
+		out, err := mode.apply(combed, &dst, &field)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if out.Cols() != w || out.Rows() != h {
+			t.Errorf("%s: %dx%d, want %dx%d", mode, out.Cols(), out.Rows(), w, h)
+		}
+		if after := combEnergy(out); after > before/10 {
+			t.Errorf("%s: comb energy %.0f, was %.0f", mode, after, before)
+		}
+		// Away from the bar, the gradient survives.
+		if v := out.GetUCharAt(24, 60); v < 72 || v > 76 {
+			t.Errorf("%s: gradient at row 24 is %d, want about 74", mode, v)
+		}
+		dst.Close()
+		field.Close()
+	}
+
+	// Off is a no-op: the frame itself, no copy.
+	dst, field := gocv.NewMat(), gocv.NewMat()
+	defer dst.Close()
+	defer field.Close()
+	if out, err := deinterlaceOff.apply(combed, &dst, &field); err != nil || combEnergy(out) != before || !dst.Empty() {
+		t.Errorf("off: %v, comb energy %.0f, want the frame untouched", err, combEnergy(out))
+	}
+}
+
+func TestParseDeinterlace(t *testing.T) {
+	tests := []struct {
+		v    string
+		want deinterlaceMode
+		ok   bool
+	}{
+		{"", deinterlaceOff, true},
+		{"off", deinterlaceOff, true},
+		{"blend", deinterlaceBlend, true},
+		{"double", deinterlaceDouble, true},
+		{"yadif", "", false},
+	}
+	for _, tt := range tests {
+		if got, err := parseDeinterlace(tt.v); got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseDeinterlace(%q) = %q, %v", tt.v, got, err)
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// fakeNet is an inferenceNet whose Forward returns the Res10 output of
+// faces, [1,1,N,7] with normalized boxes, unless fail says the call fails.
+type fakeNet struct {
+	mu     sync.Mutex
+	faces  [][4]float32                 // x1, y1, x2, y2 of each face, score 0.9
+	fail   func(call int) (bool, error) // whether Forward call n (from 1) fails, and why
+	delay  time.Duration                // time spent in each Forward
+	gate   chan struct{}                // when set, the calls after the first wait for it to close
+	calls  int                          // Forward calls
+	inputs [][]int                      // sizes of the blobs set as input
+	err    error
+	closed bool
+}
+
+func (n *fakeNet) SetInput(blob gocv.Mat, name string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.inputs = append(n.inputs, blob.Size())
+}
+
+func (n *fakeNet) Forward(string) gocv.Mat {
+	time.Sleep(n.delay)
+	if n.gate != nil && n.forwards() > 0 {
+		<-n.gate
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls++
+	n.err = nil
+	if n.fail != nil {
+		if failed, err := n.fail(n.calls); failed {
+			n.err = err
+			return gocv.NewMat()
+		}
+	}
+	return res10Output(n.faces)
+}
+
+func (n *fakeNet) ForwardLayers(names []string) []gocv.Mat {
+	outs := make([]gocv.Mat, len(names))
+	for i := range outs {
+		outs[i] = n.Forward(names[i])
+	}
+	return outs
+}
+
+func (n *fakeNet) LastError() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.err
+}
+
+func (n *fakeNet) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	return nil
+}
+
+func (n *fakeNet) forwards() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls
+}
+
+// res10Output builds a [1,1,N,7] detection output, one row per face of
+// image 0 with a 0.9 score.
+func res10Output(faces [][4]float32) gocv.Mat {
+	var data []byte
+	for _, f := range faces {
+		for _, v := range [7]float32{0, 1, 0.9, f[0], f[1], f[2], f[3]} {
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+		}
+	}
+	if len(faces) == 0 {
+		return gocv.NewMatWithSizes([]int{1, 1, 0, 7}, gocv.MatTypeCV32F)
+	}
+	m, err := gocv.NewMatWithSizesFromBytes([]int{1, 1, len(faces), 7}, gocv.MatTypeCV32F, data)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// fakeLoader hands out the nets of next in turn, counting the loads.
+type fakeLoader struct {
+	mu    sync.Mutex
+	next  []*fakeNet
+	loads int
+	fail  func(cfg DetectorConfig) error // rejects the loads it returns an error for, if set
+}
+
+func (l *fakeLoader) load(cfg DetectorConfig) (inferenceNet, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.fail != nil {
+		if err := l.fail(cfg); err != nil {
+			return nil, err
+		}
+	}
+	n := l.next[min(l.loads, len(l.next)-1)]
+	l.loads++
+	return n, nil
+}
+
+func (l *fakeLoader) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.loads
+}
+
+// fakeSource yields blank w x h frames.
+type fakeSource struct{ w, h int }
+
+func (s fakeSource) Read(m *gocv.Mat) bool {
+	img := gocv.NewMatWithSize(s.h, s.w, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	img.CopyTo(m)
+	return true
+}
+
+func (s fakeSource) Close() error { return nil }
+
+// newFakeDetector returns a detector running the nets of l on blank
+// 100x100 frames.
+func newFakeDetector(t *testing.T, cfg DetectorConfig, l *fakeLoader) *DNNDetector {
+	t.Helper()
+	d, err := newInferenceDetector(cfg, l.load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.cap = fakeSource{w: 100, h: 100}
+	t.Cleanup(d.Close)
+	return d
+}
+
+// startLoop runs the detector loop until the returned func is called.
+func startLoop(d *DNNDetector, cfg DetectorConfig, p *Pipeline) (stop func()) {
+	if p.Seq == nil {
+		p.Seq = &FrameCounter{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		runDetectorLoop(ctx, d, cfg, p)
+	}()
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
+// waitSnapshot waits up to 5s for a snapshot satisfying done.
+func waitSnapshot(t *testing.T, store *FaceStore, done func(Snapshot) bool) (Snapshot, uint64) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		changed := store.Changed()
+		if snap, ver := store.Get(); ver > 0 && done(snap) {
+			return snap, ver
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			snap, _ := store.Get()
+			t.Fatalf("store still at %+v after 5s", snap)
+		}
+	}
+}
+
+func TestDetectorLoopInferenceFailure(t *testing.T) {
+	face := [4]float32{0.1, 0.1, 0.3, 0.3}
+	// The first net succeeds once, then fails every attempt; the reloaded
+	// one finds two faces.
+	flaky := &fakeNet{faces: [][4]float32{face}, fail: func(call int) (bool, error) { return call > 1, nil }}
+	recovered := &fakeNet{faces: [][4]float32{face, {0.5, 0.5, 0.7, 0.7}}, gate: make(chan struct{})}
+	l := &fakeLoader{next: []*fakeNet{flaky, recovered}}
+	cfg := DetectorConfig{Interval: time.Millisecond, ReinitAfter: 2}
+	d := newFakeDetector(t, cfg, l)
+	p := &Pipeline{Store: NewFaceStore(), Stats: NewStats()}
+
+	stop := startLoop(d, cfg, p)
+	snap, ver := waitSnapshot(t, p.Store, func(s Snapshot) bool { return len(s.Detections) == 2 })
+	close(recovered.gate)
+	stop()
+
+	// Frames 2 and 3 failed without replacing frame 1, then the reloaded
+	// net published frame 4.
+	if snap.Frame != 4 || ver != 2 {
+		t.Fatalf("first snapshot of the reloaded net: frame %d, version %d; want frame 4, version 2", snap.Frame, ver)
+	}
+	if p.Stats.inferenceFailures != 2 || p.Stats.modelReinits != 1 {
+		t.Fatalf("%d inference failures, %d reinits; want 2 and 1", p.Stats.inferenceFailures, p.Stats.modelReinits)
+	}
+	if got := flaky.forwards(); got != 1+2*forwardAttempts {
+		t.Fatalf("first net ran %d times, want %d (one success, then retries)", got, 1+2*forwardAttempts)
+	}
+	if !flaky.closed || l.count() != 2 {
+		t.Fatalf("first net closed = %v after %d loads, want it replaced by the second load", flaky.closed, l.count())
+	}
+}
+
+// layeredNet is a net with an auxiliary output after the detections, and
+// no default output.
+type layeredNet struct {
+	*fakeNet
+	layers  [][]string // names asked of each ForwardLayers call
+	forward int        // Forward("") calls
+}
+
+func (n *layeredNet) Forward(string) gocv.Mat {
+	n.forward++
+	return gocv.NewMat()
+}
+
+func (n *layeredNet) ForwardLayers(names []string) []gocv.Mat {
+	n.layers = append(n.layers, names)
+	outs := []gocv.Mat{n.fakeNet.Forward(names[0])}
+	for range names[1:] {
+		outs = append(outs, gocv.NewMatWithSize(1, 5, gocv.MatTypeCV32F)) // e.g. landmarks
+	}
+	return outs
+}
+
+func TestDetectorOutputLayers(t *testing.T) {
+	net := &layeredNet{fakeNet: &fakeNet{faces: [][4]float32{{0.1, 0.2, 0.3, 0.6}}}}
+	cfg := DetectorConfig{OutputLayers: []string{"detection_out", "landmarks"}}
+	d, err := newInferenceDetector(cfg, func(DetectorConfig) (inferenceNet, error) { return net, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.cap = fakeSource{w: 100, h: 100}
+	defer d.Close()
+
+	_, dets, _, _, err := d.Detect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Rect{X: 10, Y: 20, Width: 20, Height: 40}); len(dets) != 1 || dets[0].BBox != want {
+		t.Errorf("detections %+v, want one at %v", dets, want)
+	}
+	if net.forward != 0 || len(net.layers) != 1 || !slices.Equal(net.layers[0], cfg.OutputLayers) {
+		t.Errorf("forwarded %v and %d default outputs, want only %v", net.layers, net.forward, cfg.OutputLayers)
+	}
+}
+
+type layerNames []string
+
+func (l layerNames) GetLayerNames() []string { return l }
+
+func TestCheckOutputLayers(t *testing.T) {
+	names := layerNames{"conv1", "detection_out", "landmarks"}
+	tests := []struct {
+		want []string
+		ok   bool
+	}{
+		{nil, true},
+		{[]string{"detection_out"}, true},
+		{[]string{"detection_out", "landmarks"}, true},
+		{[]string{"detection_out", "boxes"}, false},
+	}
+	for _, tt := range tests {
+		err := checkOutputLayers(names, tt.want)
+		if (err == nil) != tt.ok || (err != nil && !errors.Is(err, ErrModelLoad)) {
+			t.Errorf("%v: %v", tt.want, err)
+		}
+	}
+}
+
+func TestDetectorROI(t *testing.T) {
+	roi := &Rect{X: 20, Y: 10, Width: 40, Height: 80}
+	tests := []struct {
+		name         string
+		roiCoords    bool
+		want         Rect
+		wantW, wantH int
+	}{
+		{"frame coordinates", false, Rect{X: 30, Y: 30, Width: 10, Height: 40}, 100, 100},
+		{"roi coordinates", true, Rect{X: 10, Y: 20, Width: 10, Height: 40}, 40, 80},
+	}
+	for _, tt := range tests {
+		// The net sees the ROI only, and the face in its middle-left.
+		net := &fakeNet{faces: [][4]float32{{0.25, 0.25, 0.5, 0.75}}}
+		d := newFakeDetector(t, DetectorConfig{ROI: roi, ROICoords: tt.roiCoords}, &fakeLoader{next: []*fakeNet{net}})
+		_, dets, w, h, err := d.Detect()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(dets) != 1 || dets[0].BBox != tt.want || w != tt.wantW || h != tt.wantH {
+			t.Errorf("%s: %+v in %dx%d, want %v in %dx%d", tt.name, dets, w, h, tt.want, tt.wantW, tt.wantH)
+		}
+		if img, ok := d.LastFrame(); !ok || img.Cols() != w || img.Rows() != h {
+			t.Errorf("%s: last frame %dx%d, want it to match the reported %dx%d", tt.name, img.Cols(), img.Rows(), w, h)
+		}
+	}
+
+	outside := newFakeDetector(t, DetectorConfig{ROI: &Rect{X: 80, Y: 0, Width: 40, Height: 40}}, &fakeLoader{next: []*fakeNet{{}}})
+	if _, _, _, _, err := outside.Detect(); err == nil || !strings.Contains(err.Error(), "outside the 100x100 frame") {
+		t.Errorf("ROI beyond the frame: %v", err)
+	}
+	if _, ok := outside.LastFrame(); ok {
+		t.Error("ROI beyond the frame: the frame is still served")
+	}
+}
+
+func TestDetectorClockOffset(t *testing.T) {
+	face := [][4]float32{{0.1, 0.1, 0.3, 0.3}, {0.5, 0.5, 0.7, 0.7}}
+	for _, offset := range []time.Duration{0, time.Hour, -90 * time.Second} {
+		for _, pipelined := range []bool{false, true} {
+			cfg := DetectorConfig{Interval: time.Millisecond, ClockOffset: offset}
+			d := newFakeDetector(t, cfg, &fakeLoader{next: []*fakeNet{{faces: face}}})
+			p := &Pipeline{Store: NewFaceStore(), Stats: NewStats(), Seq: &FrameCounter{}}
+			before := time.Now()
+			var stop func()
+			if pipelined {
+				worker := newFakeDetector(t, cfg, &fakeLoader{next: []*fakeNet{{faces: face}}})
+				ctx, cancel := context.WithCancel(context.Background())
+				stopped := make(chan struct{})
+				go func() {
+					defer close(stopped)
+					runPipelinedLoop(ctx, d, []*DNNDetector{worker}, cfg, p)
+				}()
+				stop = func() {
+					cancel()
+					<-stopped
+				}
+			} else {
+				stop = startLoop(d, cfg, p)
+			}
+			snap, _ := waitSnapshot(t, p.Store, func(s Snapshot) bool { return len(s.Detections) == 2 })
+			stop()
+			after := time.Now()
+
+			name := fmt.Sprintf("offset %v, pipelined %v", offset, pipelined)
+			if snap.GeneratedAt.Before(before.Add(offset)) || snap.GeneratedAt.After(after.Add(offset)) {
+				t.Errorf("%s: generated at %v, want between %v and %v", name, snap.GeneratedAt, before.Add(offset), after.Add(offset))
+			}
+			for _, det := range snap.Detections {
+				// Stamped at inference, just before the snapshot.
+				if skew := snap.GeneratedAt.Sub(det.Timestamp); skew < 0 || skew > time.Second {
+					t.Errorf("%s: detection at %v, snapshot at %v", name, det.Timestamp, snap.GeneratedAt)
+				}
+			}
+			if got := p.Stats.Report().ClockOffsetMs; got != float64(offset.Milliseconds()) {
+				t.Errorf("%s: /stats reports a %vms offset", name, got)
+			}
+		}
+	}
+}
+
+func TestDetectorBufferReuse(t *testing.T) {
+	// The blob and conversion buffers are reused across frames: each blob
+	// must still hold the current frame, whatever the previous one was.
+	red, blue := color.RGBA{R: 200, A: 255}, color.RGBA{B: 200, A: 255}
+	frames := []colorSource{{red, 100, 100}, {blue, 100, 100}, {red, 64, 48}, {blue, 320, 240}, {red, 320, 240}}
+	for _, cs := range []colorSpace{colorBGR, colorRGB, colorGray} {
+		for _, tiling := range []*Tiling{nil, {Cols: 2, Rows: 1, Merge: 0.5}} {
+			net := &inputNet{fakeNet: &fakeNet{faces: [][4]float32{{0.1, 0.1, 0.5, 0.5}}}}
+			cfg := DetectorConfig{ColorSpace: cs, Tiling: tiling}
+			d, err := newInferenceDetector(cfg, func(DetectorConfig) (inferenceNet, error) { return net, nil })
+			if err != nil {
+				t.Fatal(err)
+			}
+			mean := cs.inputMean(gocv.NewScalar(104, 177, 123, 0))
+			for i, src := range frames {
+				d.cap = src
+				if _, _, fw, fh, err := d.Detect(); err != nil || fw != src.w || fh != src.h {
+					t.Fatalf("%s tiling %v frame %d: %dx%d, %v", cs, tiling != nil, i, fw, fh, err)
+				}
+				want := float32(src.c.B) - float32(mean.Val1) // first channel: blue, or red reversed
+				switch cs {
+				case colorRGB:
+					want = float32(src.c.R) - float32(mean.Val1)
+				case colorGray:
+					want = float32(0.114*float64(src.c.B)+0.299*float64(src.c.R)) - float32(mean.Val1)
+				}
+				if got := net.pixel[0]; math.Abs(float64(got-want)) > 1 {
+					t.Errorf("%s tiling %v frame %d: blob holds %g, want %g", cs, tiling != nil, i, got, want)
+				}
+			}
+
+			// No frame: the previous one isn't handed out again.
+			d.cap = fakeSource{}
+			if _, _, _, _, err := d.Detect(); !errors.Is(err, ErrEmptyFrame) {
+				t.Errorf("%s: empty frame gave %v", cs, err)
+			}
+			if _, ok := d.LastFrame(); ok {
+				t.Errorf("%s: the last frame is still served after an empty read", cs)
+			}
+			d.Close()
+		}
+	}
+}
+
+func TestDetectorWarmUp(t *testing.T) {
+	face := [][4]float32{{0.1, 0.1, 0.5, 0.5}}
+	for _, warm := range []bool{true, false} {
+		first, reloaded := &fakeNet{faces: face}, &fakeNet{faces: face}
+		cfg := DetectorConfig{WarmUp: warm}
+		d := newFakeDetector(t, cfg, &fakeLoader{next: []*fakeNet{first, reloaded}})
+		want := 0
+		if warm {
+			want = 1
+		}
+		if n := first.forwards(); n != want {
+			t.Errorf("warm-up %v: %d inferences at init, want %d", warm, n, want)
+		}
+		if warm && !slices.Equal(first.inputs[0], []int{1, 3, 300, 300}) {
+			t.Errorf("warm-up blob %v, want a blank 300x300 input", first.inputs[0])
+		}
+		if _, _, _, _, err := d.Detect(); err != nil || first.forwards() != want+1 {
+			t.Errorf("warm-up %v: %d inferences after a frame (%v), want %d", warm, first.forwards(), err, want+1)
+		}
+
+		// A reloaded net is warmed up again, once.
+		if err := d.Reinit(); err != nil {
+			t.Fatal(err)
+		}
+		if n := reloaded.forwards(); n != want {
+			t.Errorf("warm-up %v: %d inferences after a reload, want %d", warm, n, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+/* ---------------------------------- Diff ----------------------------------- */
+
+// SnapshotDiff is how the detections changed between two frames, by
+// detection ID. IDs are only stable across frames with tracking on
+// (Tracked); otherwise they are row numbers and the diff is approximate.
+type SnapshotDiff struct {
+	From      int64           `json:"from"`
+	To        int64           `json:"to"`
+	Tracked   bool            `json:"tracked"`
+	Added     []int           `json:"added"`
+	Removed   []int           `json:"removed"`
+	Moved     []DetectionMove `json:"moved"`
+	Unchanged []int           `json:"unchanged"`
+}
+
+// DetectionMove is a detection present in both frames whose box changed.
+type DetectionMove struct {
+	ID   int  `json:"id"`
+	From Rect `json:"from"`
+	To   Rect `json:"to"`
+	DX   int  `json:"dx"` // center shift, px
+	DY   int  `json:"dy"`
+}
+
+// diffSnapshots compares the detections of a and b. A detection present in
+// both counts as moved when a box edge shifted by more than tolerance
+// pixels. Every list is sorted by ID.
+func diffSnapshots(a, b Snapshot, tolerance int) SnapshotDiff {
+	d := SnapshotDiff{
+		From: a.Frame, To: b.Frame,
+		Tracked:   tracked(a.Detections) && tracked(b.Detections),
+		Added:     []int{},
+		Removed:   []int{},
+		Moved:     []DetectionMove{},
+		Unchanged: []int{},
+	}
+	before := make(map[int]Rect, len(a.Detections))
+	for _, det := range a.Detections {
+		before[det.ID] = det.BBox
+	}
+	seen := make(map[int]bool, len(b.Detections))
+	for _, det := range b.Detections {
+		seen[det.ID] = true
+		prev, ok := before[det.ID]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, det.ID)
+		case boxShift(prev, det.BBox) > tolerance:
+			d.Moved = append(d.Moved, DetectionMove{
+				ID: det.ID, From: prev, To: det.BBox,
+				DX: (det.BBox.X + det.BBox.Width/2) - (prev.X + prev.Width/2),
+				DY: (det.BBox.Y + det.BBox.Height/2) - (prev.Y + prev.Height/2),
+			})
+		default:
+			d.Unchanged = append(d.Unchanged, det.ID)
+		}
+	}
+	for id := range before {
+		if !seen[id] {
+			d.Removed = append(d.Removed, id)
+		}
+	}
+	slices.Sort(d.Added)
+	slices.Sort(d.Removed)
+	slices.Sort(d.Unchanged)
+	slices.SortFunc(d.Moved, func(x, y DetectionMove) int { return x.ID - y.ID })
+	return d
+}
+
+// tracked reports whether dets carry track IDs; an empty frame doesn't
+// tell otherwise.
+func tracked(dets []Detection) bool {
+	for _, d := range dets {
+		if d.UUID == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// boxShift is the largest displacement of any edge between two boxes.
+func boxShift(a, b Rect) int {
+	return max(
+		abs(a.X-b.X), abs(a.Y-b.Y),
+		abs(a.X+a.Width-b.X-b.Width), abs(a.Y+a.Height-b.Y-b.Height),
+	)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// diffHandler serves /diff?from=<frame>&to=<frame>[&tolerance=px] from the
+// retained frames; 404 when either frame is no longer retained.
+func diffHandler(frames *FrameRing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, err1 := strconv.ParseInt(q.Get("from"), 10, 64)
+		to, err2 := strconv.ParseInt(q.Get("to"), 10, 64)
+		if err1 != nil || err2 != nil {
+			http.Error(w, "from and to must be frame numbers", http.StatusBadRequest)
+			return
+		}
+		tolerance := 0
+		if v := q.Get("tolerance"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "invalid tolerance", http.StatusBadRequest)
+				return
+			}
+			tolerance = n
+		}
+		if frames == nil {
+			http.Error(w, "frame retention disabled", http.StatusNotFound)
+			return
+		}
+		a, okA := frames.Snapshot(from)
+		b, okB := frames.Snapshot(to)
+		if !okA || !okB {
+			http.Error(w, "frame no longer retained", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, diffSnapshots(a, b, tolerance), true)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	det := func(id, x, y int) Detection {
+		return Detection{ID: id, UUID: "u", BBox: Rect{X: x, Y: y, Width: 40, Height: 40}}
+	}
+	a := Snapshot{Frame: 10, Detections: []Detection{det(1, 0, 0), det(2, 100, 100), det(3, 200, 200), det(5, 300, 300)}}
+	b := Snapshot{Frame: 12, Detections: []Detection{det(5, 302, 300), det(4, 50, 50), det(2, 110, 96), det(1, 0, 0)}}
+
+	tests := []struct {
+		tolerance int
+		want      SnapshotDiff
+	}{
+		{0, SnapshotDiff{
+			From: 10, To: 12, Tracked: true,
+			Added:   []int{4},
+			Removed: []int{3},
+			Moved: []DetectionMove{
+				{ID: 2, From: a.Detections[1].BBox, To: b.Detections[2].BBox, DX: 10, DY: -4},
+				{ID: 5, From: a.Detections[3].BBox, To: b.Detections[0].BBox, DX: 2, DY: 0},
+			},
+			Unchanged: []int{1},
+		}},
+		{2, SnapshotDiff{
+			From: 10, To: 12, Tracked: true,
+			Added:     []int{4},
+			Removed:   []int{3},
+			Moved:     []DetectionMove{{ID: 2, From: a.Detections[1].BBox, To: b.Detections[2].BBox, DX: 10, DY: -4}},
+			Unchanged: []int{1, 5}, // within tolerance
+		}},
+	}
+	for _, tt := range tests {
+		if got := diffSnapshots(a, b, tt.tolerance); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tolerance %d:\n got %+v\nwant %+v", tt.tolerance, got, tt.want)
+		}
+	}
+
+	// Untracked rows, and empty frames: empty lists, not null.
+	untracked := Snapshot{Frame: 13, Detections: []Detection{{ID: 1}}}
+	if d := diffSnapshots(b, untracked, 0); d.Tracked {
+		t.Errorf("diff with an untracked frame reported as tracked")
+	}
+	empty := diffSnapshots(Snapshot{}, Snapshot{}, 0)
+	if empty.Added == nil || empty.Removed == nil || empty.Moved == nil || empty.Unchanged == nil || !empty.Tracked {
+		t.Errorf("empty diff %+v", empty)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import "fmt"
+
+/* ------------------------------- Frame edges ------------------------------- */
+
+// edgePolicy handles faces cut by the frame border: their boxes are clamped
+// to the edge, so their size and aspect ratio are wrong. A box within
+// Margin px of a border is flagged with Edge, or dropped when Drop is set.
+type edgePolicy struct {
+	Margin int
+	Drop   bool
+}
+
+// parseEdgePolicy reads the mode ("off", "flag" or "drop"); off returns nil.
+func parseEdgePolicy(mode string, margin int) (*edgePolicy, error) {
+	if margin < 0 {
+		return nil, fmt.Errorf("margin must be >= 0, got %d", margin)
+	}
+	switch mode {
+	case "off":
+		return nil, nil
+	case "flag":
+		return &edgePolicy{Margin: margin}, nil
+	case "drop":
+		return &edgePolicy{Margin: margin, Drop: true}, nil
+	}
+	return nil, fmt.Errorf("want off, flag or drop, got %q", mode)
+}
+
+// touches reports whether r lies within the margin of a border of a w x h
+// frame. A box ending exactly Margin px from a border touches it.
+func (p edgePolicy) touches(r Rect, w, h int) bool {
+	return r.X <= p.Margin || r.Y <= p.Margin ||
+		r.X+r.Width >= w-p.Margin || r.Y+r.Height >= h-p.Margin
+}
+
+// applyEdges flags or drops the boxes touching the edges of a w x h frame;
+// a nil policy leaves them as they are.
+func applyEdges(dets []Detection, p *edgePolicy, w, h int) []Detection {
+	if p == nil {
+		return dets
+	}
+	out := dets[:0]
+	for _, d := range dets {
+		if p.touches(d.BBox, w, h) {
+			if p.Drop {
+				continue
+			}
+			d.Edge = true
+		}
+		out = append(out, d)
+	}
+	return out
+}
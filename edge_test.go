@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestApplyEdges(t *testing.T) {
+	// 640x480 frame, 10 px margin.
+	tests := []struct {
+		name string
+		box  Rect
+		edge bool
+	}{
+		{"inside", Rect{X: 100, Y: 100, Width: 50, Height: 50}, false},
+		{"just inside the margin", Rect{X: 11, Y: 11, Width: 618, Height: 458}, false},
+		{"touching the frame", Rect{X: 0, Y: 200, Width: 50, Height: 50}, true},
+		{"on the left margin", Rect{X: 10, Y: 200, Width: 50, Height: 50}, true},
+		{"on the top margin", Rect{X: 200, Y: 10, Width: 50, Height: 50}, true},
+		{"on the right margin", Rect{X: 580, Y: 200, Width: 50, Height: 50}, true},
+		{"on the bottom margin", Rect{X: 200, Y: 420, Width: 50, Height: 50}, true},
+		{"straddling the right margin", Rect{X: 600, Y: 200, Width: 35, Height: 50}, true},
+		{"straddling the bottom margin", Rect{X: 200, Y: 440, Width: 50, Height: 35}, true},
+	}
+	for _, tt := range tests {
+		det := []Detection{{ID: 1, BBox: tt.box}}
+		if got := applyEdges(det, &edgePolicy{Margin: 10}, 640, 480); len(got) != 1 || got[0].Edge != tt.edge {
+			t.Errorf("flag, %s: %+v, want edge %v", tt.name, got, tt.edge)
+		}
+		det = []Detection{{ID: 1, BBox: tt.box}}
+		if got := applyEdges(det, &edgePolicy{Margin: 10, Drop: true}, 640, 480); (len(got) == 0) != tt.edge || len(got) == 1 && got[0].Edge {
+			t.Errorf("drop, %s: %+v, want dropped %v", tt.name, got, tt.edge)
+		}
+	}
+
+	// Without a margin only the frame border counts; without a policy
+	// nothing changes.
+	dets := []Detection{{BBox: Rect{X: 1, Y: 1, Width: 10, Height: 10}}, {BBox: Rect{X: 0, Y: 1, Width: 10, Height: 10}}}
+	if got := applyEdges(dets, &edgePolicy{}, 640, 480); got[0].Edge || !got[1].Edge {
+		t.Errorf("no margin: %+v", got)
+	}
+	dets = []Detection{{BBox: Rect{X: 0, Y: 0, Width: 640, Height: 480}}}
+	if got := applyEdges(dets, nil, 640, 480); len(got) != 1 || got[0].Edge {
+		t.Errorf("no policy: %+v", got)
+	}
+}
+
+func TestParseEdgePolicy(t *testing.T) {
+	tests := []struct {
+		mode   string
+		margin int
+		want   *edgePolicy
+		ok     bool
+	}{
+		{"off", 5, nil, true},
+		{"flag", 5, &edgePolicy{Margin: 5}, true},
+		{"drop", 0, &edgePolicy{Drop: true}, true},
+		{"flag", -1, nil, false},
+		{"clip", 0, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := parseEdgePolicy(tt.mode, tt.margin)
+		if (err == nil) != tt.ok || (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("parseEdgePolicy(%q, %d) = %+v, %v", tt.mode, tt.margin, got, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+/* ------------------------------ Image encoding ----------------------------- */
+
+// imwriteJpegSamplingFactor is cv::IMWRITE_JPEG_SAMPLING_FACTOR, which gocv
+// doesn't export.
+const imwriteJpegSamplingFactor = 7
+
+// jpegSubsampling maps FACE_JPEG_SUBSAMPLING to OpenCV sampling factors.
+var jpegSubsampling = map[string]int{
+	"444": 0x111111,
+	"422": 0x211111,
+	"420": 0x221111,
+}
+
+// imageFormat is one output encoding for the snapshot, stream and crops.
+type imageFormat struct {
+	Name        string
+	ContentType string
+	ext         gocv.FileExt
+	params      []int
+}
+
+func (f imageFormat) Encode(img gocv.Mat) ([]byte, error) {
+	buf, err := gocv.IMEncodeWithParams(f.ext, img, f.params)
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Close()
+	if buf.Len() == 0 {
+		return nil, fmt.Errorf("%s encoding produced no data", f.Name)
+	}
+	return append([]byte(nil), buf.GetBytes()...), nil
+}
+
+var defaultJPEG = imageFormat{Name: "jpeg", ContentType: "image/jpeg", ext: gocv.JPEGFileExt}
+
+// ImageEncoders picks the output format per request. Mode "jpeg" always
+// serves JPEG, "webp" serves WebP unless the client's Accept header rules it
+// out, and "auto" serves WebP only to clients that list image/webp. WebP
+// falls back to JPEG when this OpenCV build can't encode it.
+type ImageEncoders struct {
+	mode   string
+	jpeg   imageFormat
+	webp   imageFormat
+	webpOK bool
+	warn   sync.Once
+}
+
+// NewImageEncoders validates the settings and probes WebP support.
+func NewImageEncoders(mode string, jpegQuality int, subsampling string, webpQuality int) (*ImageEncoders, error) {
+	if mode != "jpeg" && mode != "webp" && mode != "auto" {
+		return nil, fmt.Errorf("unknown image format %q (want jpeg, webp or auto)", mode)
+	}
+	e := &ImageEncoders{mode: mode, jpeg: defaultJPEG}
+	e.jpeg.params = []int{gocv.IMWriteJpegQuality, jpegQuality}
+	if subsampling != "" {
+		factor, ok := jpegSubsampling[subsampling]
+		if !ok {
+			return nil, fmt.Errorf("unknown JPEG subsampling %q (want 444, 422 or 420)", subsampling)
+		}
+		e.jpeg.params = append(e.jpeg.params, imwriteJpegSamplingFactor, factor)
+	}
+	if mode != "jpeg" {
+		e.webp = imageFormat{Name: "webp", ContentType: "image/webp", ext: gocv.FileExt(".webp"),
+			params: []int{gocv.IMWriteWebpQuality, webpQuality}}
+		e.webpOK = canEncode(e.webp)
+	}
+	return e, nil
+}
+
+// canEncode tries f on a tiny image.
+func canEncode(f imageFormat) bool {
+	probe := gocv.NewMatWithSize(8, 8, gocv.MatTypeCV8UC3)
+	defer probe.Close()
+	_, err := f.Encode(probe)
+	return err == nil
+}
+
+// Negotiate returns the format to use for a request with the given Accept
+// header. A nil receiver always selects JPEG.
+func (e *ImageEncoders) Negotiate(accept string) imageFormat {
+	if e == nil {
+		return defaultJPEG
+	}
+	if e.mode == "jpeg" {
+		return e.jpeg
+	}
+	wantsWebP := acceptsType(accept, "image/webp", e.mode == "webp")
+	if !wantsWebP {
+		return e.jpeg
+	}
+	if !e.webpOK {
+		e.warn.Do(func() { log.Printf("[http] WebP not supported by this OpenCV build, serving JPEG") })
+		return e.jpeg
+	}
+	return e.webp
+}
+
+// acceptsType reports whether an Accept header allows typ. With wildcards
+// set, image/* and */* count too; an empty header accepts per wildcards. The
+// most specific matching range decides, so "image/webp;q=0, */*" refuses
+// WebP.
+func acceptsType(accept, typ string, wildcards bool) bool {
+	if strings.TrimSpace(accept) == "" {
+		return wildcards
+	}
+	major, _, _ := strings.Cut(typ, "/")
+	best, ok := 0, false // specificity of the deciding range, and its verdict
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		rank := 0
+		switch {
+		case mt == typ:
+			rank = 3
+		case wildcards && mt == major+"/*":
+			rank = 2
+		case wildcards && mt == "*/*":
+			rank = 1
+		}
+		if rank > best {
+			q, err := strconv.ParseFloat(params["q"], 64)
+			best, ok = rank, err != nil || q > 0
+		}
+	}
+	return ok
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestImageEncodersNegotiate(t *testing.T) {
+	const (
+		webpAccept = "image/webp,image/*;q=0.8"
+		browser    = "image/avif,image/webp,*/*;q=0.8"
+		jpegOnly   = "image/jpeg"
+		anyImage   = "image/*"
+		noWebP     = "image/webp;q=0, image/jpeg"
+		noWebPDec  = "image/webp;q=0.0, */*"
+	)
+	encoders := func(mode string, webpOK bool) *ImageEncoders {
+		e, err := NewImageEncoders(mode, 80, "", 75)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.webpOK = webpOK && mode != "jpeg"
+		return e
+	}
+	tests := []struct {
+		mode   string
+		webpOK bool
+		accept string
+		want   string
+	}{
+		{"jpeg", true, webpAccept, "jpeg"},
+		{"jpeg", true, "", "jpeg"},
+		{"auto", true, webpAccept, "webp"},
+		{"auto", true, browser, "webp"},
+		{"auto", true, anyImage, "jpeg"}, // only explicit WebP support
+		{"auto", true, "", "jpeg"},
+		{"auto", true, noWebP, "jpeg"},
+		{"webp", true, "", "webp"},
+		{"webp", true, anyImage, "webp"},
+		{"webp", true, "*/*", "webp"},
+		{"webp", true, jpegOnly, "jpeg"},
+		{"webp", true, noWebP, "jpeg"},
+		{"webp", true, noWebPDec, "jpeg"},
+		// WebP missing from the OpenCV build: JPEG whatever the client says.
+		{"webp", false, webpAccept, "jpeg"},
+		{"auto", false, browser, "jpeg"},
+	}
+	for _, tt := range tests {
+		if got := encoders(tt.mode, tt.webpOK).Negotiate(tt.accept); got.Name != tt.want {
+			t.Errorf("mode %s (webp %v), Accept %q: %s, want %s", tt.mode, tt.webpOK, tt.accept, got.Name, tt.want)
+		}
+	}
+
+	var none *ImageEncoders
+	if got := none.Negotiate(webpAccept); got.Name != "jpeg" {
+		t.Errorf("nil encoders: %s, want jpeg", got.Name)
+	}
+}
+
+func TestImageEncodersFallback(t *testing.T) {
+	e, err := NewImageEncoders("webp", 80, "420", 75)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Probe a codec no OpenCV build has, as a build without WebP would.
+	e.webp.ext = gocv.FileExt(".nope")
+	if e.webpOK = canEncode(e.webp); e.webpOK {
+		t.Fatal("probe accepted an unknown codec")
+	}
+	f := e.Negotiate("image/webp")
+	if f.Name != "jpeg" || f.ContentType != "image/jpeg" {
+		t.Fatalf("fallback format %+v, want JPEG", f)
+	}
+	img := gocv.NewMatWithSize(16, 16, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	if b, err := f.Encode(img); err != nil || len(b) < 2 || b[0] != 0xff || b[1] != 0xd8 {
+		t.Fatalf("fallback encoding: %v, %x", err, b[:min(len(b), 2)])
+	}
+
+	for _, bad := range [][2]string{{"gif", ""}, {"jpeg", "411"}} {
+		if _, err := NewImageEncoders(bad[0], 80, bad[1], 75); err == nil {
+			t.Errorf("format %q, subsampling %q accepted", bad[0], bad[1])
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "errors"
+
+/* -------------------------------- Errors ----------------------------------- */
+
+// Errors returned (possibly wrapped) by the detector, so a supervisor can
+// tell a transient condition from a fatal one with errors.Is.
+var (
+	// ErrSourceUnavailable: the video source could not be opened with any
+	// backend (missing device, unreachable stream, unsupported file).
+	// Usually worth retrying later.
+	ErrSourceUnavailable = errors.New("video source unavailable")
+
+	// ErrModelLoad: the model files could not be loaded or don't match the
+	// configuration (e.g. an unknown output layer). Retrying won't help.
+	ErrModelLoad = errors.New("model load failed")
+
+	// ErrInference: the net produced no output even after retries (e.g. a
+	// transient accelerator error). The previous snapshot is kept.
+	ErrInference = errors.New("inference failed")
+
+	// ErrEmptyFrame: the source was open but returned no frame (end of file,
+	// dropped stream, camera hiccup). Detect returns it for that cycle only.
+	ErrEmptyFrame = errors.New("empty frame")
+)
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectorErrors(t *testing.T) {
+	sentinels := []error{ErrSourceUnavailable, ErrModelLoad, ErrInference, ErrEmptyFrame}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	_, shmErr := openSource("shm://"+missing, 0)
+	_, capErr := openSource(missing+".mp4", 0)
+	_, modelErr := NewDNNDetector(DetectorConfig{Source: "synthetic://", ProtoTxtPath: missing, ModelPath: missing})
+
+	empty := newFakeDetector(t, DetectorConfig{}, &fakeLoader{next: []*fakeNet{{}}})
+	empty.cap = fakeSource{}
+	_, _, _, _, emptyErr := empty.Detect()
+
+	broken := newFakeDetector(t, DetectorConfig{}, &fakeLoader{next: []*fakeNet{{fail: func(int) (bool, error) { return true, nil }}}})
+	_, _, _, _, inferErr := broken.Detect()
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+		also error // a cause kept along the sentinel, if any
+	}{
+		{"shm buffer", shmErr, ErrSourceUnavailable, fs.ErrNotExist},
+		{"capture", capErr, ErrSourceUnavailable, nil},
+		{"model files", modelErr, ErrModelLoad, nil},
+		{"output layer", checkOutputLayers(layerNames{"conv1"}, []string{"out"}), ErrModelLoad, nil},
+		{"empty frame", emptyErr, ErrEmptyFrame, nil},
+		{"inference", inferErr, ErrInference, nil},
+	}
+	for _, tt := range tests {
+		// A supervisor may wrap them again.
+		for _, err := range []error{tt.err, fmt.Errorf("camera 1: %w", tt.err)} {
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%s: %v is not %v", tt.name, err, tt.want)
+			}
+			if tt.also != nil && !errors.Is(err, tt.also) {
+				t.Errorf("%s: %v lost its cause %v", tt.name, err, tt.also)
+			}
+			for _, other := range sentinels {
+				if other != tt.want && errors.Is(err, other) {
+					t.Errorf("%s: %v is also %v", tt.name, err, other)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/* ------------------------------- Crop export ------------------------------- */
+
+// CropManifest is manifest.json, the last entry of a crop export.
+type CropManifest struct {
+	From        int64          `json:"from"`
+	To          int64          `json:"to"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Crops       []ExportedCrop `json:"crops"`
+	Gaps        []FrameGap     `json:"gaps"` // frames of the range that are not in the archive
+}
+
+// ExportedCrop maps a file of the archive to its detection.
+type ExportedCrop struct {
+	File   string    `json:"file"`
+	Source string    `json:"source"`
+	Frame  int64     `json:"frame"`
+	ID     int       `json:"id"`
+	UUID   string    `json:"uuid,omitempty"`
+	Score  float64   `json:"score"`
+	BBox   Rect      `json:"bbox"`
+	TS     time.Time `json:"ts"`
+}
+
+// FrameGap is a run of frame numbers that aren't retained: aged out, or
+// captured without an image.
+type FrameGap struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// frameGaps returns the runs of [from, to] missing from retained, which is
+// sorted.
+func frameGaps(from, to int64, retained []int64) []FrameGap {
+	gaps := []FrameGap{}
+	next := from
+	for _, f := range retained {
+		if f > next {
+			gaps = append(gaps, FrameGap{From: next, To: f - 1})
+		}
+		next = f + 1
+	}
+	if next <= to {
+		gaps = append(gaps, FrameGap{From: next, To: to})
+	}
+	return gaps
+}
+
+// writeCropExport writes the crops of the retained frames of [from, to] to
+// zw, one at a time so memory stays bounded, followed by the manifest. A
+// frame evicted while the archive is written counts as a gap.
+func writeCropExport(zw *zip.Writer, frames *FrameRing, format imageFormat, from, to int64) error {
+	m := CropManifest{From: from, To: to, GeneratedAt: time.Now().UTC(), Crops: []ExportedCrop{}}
+	var exported []int64
+	for _, frame := range frames.Retained(from, to) {
+		snap, ok := frames.Snapshot(frame)
+		if !ok {
+			continue
+		}
+		for _, d := range snap.Detections {
+			id := d.UUID
+			if id == "" {
+				id = strconv.Itoa(d.ID)
+			}
+			crop, ok := frames.Crop(frame, id)
+			if !ok {
+				continue
+			}
+			data, err := format.Encode(crop)
+			crop.Close()
+			if err != nil {
+				return fmt.Errorf("encode crop %d/%s: %w", frame, id, err)
+			}
+			file := "crops/" + cropName(frame, d, format)
+			// Images are compressed already: stored, not deflated
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: file, Method: zip.Store, Modified: d.Timestamp})
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			m.Crops = append(m.Crops, ExportedCrop{File: file, Source: snap.Source, Frame: frame, ID: d.ID, UUID: d.UUID,
+				Score: d.Score, BBox: d.BBox, TS: d.Timestamp})
+		}
+		if _, still := frames.Snapshot(frame); still {
+			exported = append(exported, frame)
+		}
+	}
+	m.Gaps = frameGaps(from, to, exported)
+
+	w, err := zw.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// exportCropsHandler serves GET /export/crops?from=<frame>&to=<frame>: a zip
+// of the crops of every detection retained in that range, streamed as it is
+// built, with a manifest.json mapping each file to its frame, ID and score.
+func exportCropsHandler(frames *FrameRing, encoders *ImageEncoders) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		from, err1 := strconv.ParseInt(q.Get("from"), 10, 64)
+		to, err2 := strconv.ParseInt(q.Get("to"), 10, 64)
+		if err1 != nil || err2 != nil || to < from {
+			http.Error(w, "from and to must be frame numbers, from <= to", http.StatusBadRequest)
+			return
+		}
+		if frames == nil {
+			http.Error(w, "frame retention disabled", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="crops-%d-%d.zip"`, from, to))
+		w.Header().Set("Cache-Control", "no-store")
+		zw := zip.NewWriter(w)
+		if err := writeCropExport(zw, frames, encoders.Negotiate(""), from, to); err != nil {
+			// The status is sent already; a truncated archive won't open
+			log.Printf("[http] crop export %d-%d: %v", from, to, err)
+			return
+		}
+		_ = zw.Close()
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestFrameGaps(t *testing.T) {
+	tests := []struct {
+		from, to int64
+		retained []int64
+		want     []FrameGap
+	}{
+		{1, 5, []int64{1, 2, 3, 4, 5}, []FrameGap{}},
+		{1, 5, nil, []FrameGap{{1, 5}}},
+		{1, 5, []int64{3}, []FrameGap{{1, 2}, {4, 5}}},
+		{1, 10, []int64{1, 2, 5, 6, 10}, []FrameGap{{3, 4}, {7, 9}}},
+		{4, 4, []int64{4}, []FrameGap{}},
+	}
+	for _, tt := range tests {
+		if got := frameGaps(tt.from, tt.to, tt.retained); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("frameGaps(%d, %d, %v) = %v, want %v", tt.from, tt.to, tt.retained, got, tt.want)
+		}
+	}
+}
+
+func TestExportCrops(t *testing.T) {
+	ring := NewFrameRing(3, 1<<20, 0)
+	img := gocv.NewMatWithSize(100, 200, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	box := Rect{X: 10, Y: 20, Width: 30, Height: 40}
+	frames := []Snapshot{
+		{Frame: 1, Source: "cam", Detections: []Detection{{ID: 1, BBox: box, Score: 0.9}}}, // aged out
+		{Frame: 2, Source: "cam", Detections: []Detection{{ID: 1, UUID: "a", BBox: box, Score: 0.9}}},
+		{Frame: 4, Source: "cam", Detections: []Detection{
+			{ID: 1, UUID: "a", BBox: box, Score: 0.8},
+			{ID: 2, BBox: Rect{X: 150, Y: 50, Width: 20, Height: 20}, Score: 0.7},
+			{ID: 3, BBox: Rect{X: 500, Y: 500, Width: 20, Height: 20}, Score: 0.6}, // off the frame: no crop
+		}},
+		{Frame: 5, Source: "cam"},
+	}
+	for _, snap := range frames {
+		ring.Add(snap, img)
+	}
+
+	w := httptest.NewRecorder()
+	exportCropsHandler(ring, nil)(w, httptest.NewRequest(http.MethodGet, "/export/crops?from=1&to=6", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("GET /export/crops: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var m CropManifest
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Name == "manifest.json" {
+			err = json.NewDecoder(rc).Decode(&m)
+		} else {
+			var cfg image.Config
+			if cfg, err = jpeg.DecodeConfig(rc); err == nil && f.Name == "crops/4-2.jpg" && (cfg.Width != 20 || cfg.Height != 20) {
+				t.Errorf("%s is %dx%d, want the 20x20 box", f.Name, cfg.Width, cfg.Height)
+			}
+		}
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+	}
+
+	wantNames := []string{"crops/2-a.jpg", "crops/4-a.jpg", "crops/4-2.jpg", "manifest.json"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("archive %v, want %v", names, wantNames)
+	}
+	if m.From != 1 || m.To != 6 || !reflect.DeepEqual(m.Gaps, []FrameGap{{1, 1}, {3, 3}, {6, 6}}) {
+		t.Errorf("manifest range %d-%d, gaps %v", m.From, m.To, m.Gaps)
+	}
+	var got []ExportedCrop
+	for _, c := range m.Crops {
+		c.TS = c.TS.UTC()
+		got = append(got, c)
+	}
+	want := []ExportedCrop{
+		{File: "crops/2-a.jpg", Source: "cam", Frame: 2, ID: 1, UUID: "a", Score: 0.9, BBox: box},
+		{File: "crops/4-a.jpg", Source: "cam", Frame: 4, ID: 1, UUID: "a", Score: 0.8, BBox: box},
+		{File: "crops/4-2.jpg", Source: "cam", Frame: 4, ID: 2, Score: 0.7, BBox: Rect{X: 150, Y: 50, Width: 20, Height: 20}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("manifest crops\n got %+v\nwant %+v", got, want)
+	}
+
+	for _, q := range []string{"from=5&to=4", "from=a&to=4", "to=4"} {
+		w := httptest.NewRecorder()
+		exportCropsHandler(ring, nil)(w, httptest.NewRequest(http.MethodGet, "/export/crops?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("?%s: %d, want 400", q, w.Code)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+// Package facetrackclient is a client for the tracking-go HTTP API: it polls
+// /faces with ETag revalidation and follows the /faces/stream SSE feed,
+// reconnecting with backoff.
+//
+// The server lives in package main, so its types can't be imported; the ones
+// below mirror its JSON schema and must be kept in sync with it.
+package facetrackclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* --------------------------------- Schema ---------------------------------- */
+
+// Rect is a bounding box in pixels relative to the captured frame.
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Point is a 2D landmark point.
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Pose is a coarse head orientation in degrees.
+type Pose struct {
+	Yaw   float64 `json:"yaw"`
+	Pitch float64 `json:"pitch"`
+	Roll  float64 `json:"roll"`
+}
+
+// Detection is a single detected face.
+type Detection struct {
+	ID         int                `json:"id"`
+	UUID       string             `json:"uuid,omitempty"`
+	BBox       Rect               `json:"bbox"`
+	Landmarks  []Point            `json:"landmarks,omitempty"`
+	Pose       *Pose              `json:"pose,omitempty"`
+	Count      int                `json:"count,omitempty"`
+	Color      string             `json:"color,omitempty"`
+	Zone       string             `json:"zone,omitempty"`
+	Attributes map[string]float64 `json:"attributes,omitempty"`
+	Score      float64            `json:"score"`
+	RawScore   float64            `json:"raw_score,omitempty"`
+	Timestamp  time.Time          `json:"ts"`
+
+	ApproxRangeM float64 `json:"approx_range_m,omitempty"`
+	Quality      float64 `json:"quality,omitempty"`
+	DwellS       float64 `json:"dwell_s,omitempty"`
+	Edge         bool    `json:"edge,omitempty"`
+
+	TrackConfidence float64 `json:"track_confidence,omitempty"`
+	StableMs        int64   `json:"stable_ms,omitempty"`
+	Predicted       bool    `json:"predicted,omitempty"`
+
+	Velocity *Velocity `json:"velocity,omitempty"`
+}
+
+// Velocity is the speed of a tracked face in pixels per second.
+type Velocity struct {
+	VX float64 `json:"vx"`
+	VY float64 `json:"vy"`
+}
+
+// TrackEvent is a change of a track, e.g. "stable" once it has been at rest
+// for the configured time, or "zone_enter"/"zone_exit" when it crosses the
+// border of a zone.
+type TrackEvent struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	UUID      string    `json:"uuid"`
+	BBox      Rect      `json:"bbox"`
+	Timestamp time.Time `json:"ts"`
+	StableMs  int64     `json:"stable_ms,omitempty"`
+	Zone      string    `json:"zone,omitempty"`
+}
+
+// ZoneCount is the number of faces in a configured zone.
+type ZoneCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// SnapshotMeta describes the detector settings that produced a snapshot.
+type SnapshotMeta struct {
+	Model      string  `json:"model"`
+	Confidence float32 `json:"confidence"`
+	InputW     int     `json:"input_w"`
+	InputH     int     `json:"input_h"`
+	Backend    string  `json:"backend"`
+	Target     string  `json:"target"`
+}
+
+// Snapshot is the payload of /faces and of each /faces/stream event.
+type Snapshot struct {
+	SchemaVersion   int           `json:"schema_version"`
+	Source          string        `json:"source"`
+	Frame           int64         `json:"frame"`
+	FrameWidth      int           `json:"frame_width"`
+	FrameHeight     int           `json:"frame_height"`
+	Detections      []Detection   `json:"detections"`
+	GeneratedAt     time.Time     `json:"generated_at"`
+	FrameIntervalMs float64       `json:"frame_interval_ms,omitempty"`
+	Frozen          bool          `json:"frozen,omitempty"`
+	Zones           []ZoneCount   `json:"zones,omitempty"`
+	Events          []TrackEvent  `json:"events,omitempty"`
+	Meta            *SnapshotMeta `json:"meta,omitempty"`
+	Count           int           `json:"count,omitempty"` // count-only (privacy) mode, Detections is then empty
+}
+
+/* --------------------------------- Client ---------------------------------- */
+
+// Stream reconnection backoff.
+const (
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// Client talks to one tracking-go server.
+type Client struct {
+	baseURL string
+	http    *http.Client
+
+	mu   sync.Mutex
+	etag string
+	last Snapshot
+}
+
+// New returns a client for the server at baseURL (e.g. "http://cam:8080").
+// A nil httpClient uses http.DefaultClient; it must not set a Timeout if
+// Stream is used.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), http: httpClient}
+}
+
+// Latest returns the current snapshot. It revalidates with the ETag of the
+// previous call, so an unchanged snapshot costs a 304 and is served from
+// the client's copy.
+func (c *Client) Latest(ctx context.Context) (Snapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/faces?case=snake", nil) // whatever FACE_JSON_CASE is
+	if err != nil {
+		return Snapshot{}, err
+	}
+	c.mu.Lock()
+	if c.etag != "" {
+		req.Header.Set("If-None-Match", c.etag)
+	}
+	c.mu.Unlock()
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer resp.Body.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return c.last, nil
+	case http.StatusOK:
+		var snap Snapshot
+		if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+			return Snapshot{}, fmt.Errorf("decode /faces: %w", err)
+		}
+		c.etag, c.last = resp.Header.Get("ETag"), snap
+		return snap, nil
+	default:
+		return Snapshot{}, statusError(resp)
+	}
+}
+
+// Stream follows /faces/stream. The first connection is made before Stream
+// returns, so a wrong URL fails fast; after that, dropped connections are
+// retried with exponential backoff until ctx is done, which closes the
+// channel. A slow reader only delays the stream: snapshots are not dropped.
+func (c *Client) Stream(ctx context.Context) (<-chan Snapshot, error) {
+	body, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan Snapshot)
+	go func() {
+		defer close(out)
+		backoff := minBackoff
+		for {
+			if readEvents(ctx, body, out) {
+				backoff = minBackoff // the connection worked, start over
+			}
+			body.Close()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff = min(backoff*2, maxBackoff)
+				if rc, err := c.connect(ctx); err == nil {
+					body = rc
+					break
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (c *Client) connect(ctx context.Context) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/faces/stream?case=snake", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(resp)
+	}
+	return resp.Body, nil
+}
+
+// readEvents forwards the snapshot events of an SSE body to out until the
+// body ends or ctx is done. It reports whether any event was received.
+func readEvents(ctx context.Context, body io.Reader, out chan<- Snapshot) bool {
+	got := false
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	var event string
+	var data strings.Builder
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if data.Len() > 0 && (event == "" || event == "snapshot") {
+				var snap Snapshot
+				if err := json.Unmarshal([]byte(data.String()), &snap); err == nil {
+					got = true
+					select {
+					case out <- snap:
+					case <-ctx.Done():
+						return got
+					}
+				}
+			}
+			event = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"): // comment, e.g. keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return got
+}
+
+// StatusError is returned for unexpected HTTP statuses.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("facetrack: HTTP %d: %s", e.Code, e.Message)
+}
+
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+}
+
+// IsStatus reports whether err is a StatusError with the given code.
+func IsStatus(err error, code int) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == code
+}
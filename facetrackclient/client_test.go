@@ -0,0 +1,150 @@
+package facetrackclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLatestRevalidates(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string // If-None-Match of each request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/faces" {
+			http.NotFound(w, r)
+			return
+		}
+		inm := r.Header.Get("If-None-Match")
+		mu.Lock()
+		seen = append(seen, inm)
+		mu.Unlock()
+		if inm == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"schema_version":1,"frame":7,"detections":[{"id":3,"bbox":{"x":1,"y":2,"width":30,"height":40},"score":0.9}]}`)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL+"/", nil)
+	first, err := c.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Frame != 7 || len(first.Detections) != 1 || first.Detections[0].BBox.Width != 30 {
+		t.Fatalf("first snapshot = %+v", first)
+	}
+	second, err := c.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Frame != 7 || len(second.Detections) != 1 {
+		t.Fatalf("304 didn't serve the cached snapshot: %+v", second)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 2 || seen[0] != "" || seen[1] != `"v1"` {
+		t.Fatalf("If-None-Match sent = %q, want [\"\" \"v1\"]", seen)
+	}
+}
+
+func TestLatestStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no detector", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, nil).Latest(context.Background())
+	if !IsStatus(err, http.StatusServiceUnavailable) {
+		t.Fatalf("err = %v, want HTTP 503", err)
+	}
+}
+
+func TestReadEvents(t *testing.T) {
+	body := strings.Join([]string{
+		": keep-alive",
+		"",
+		`data: {"frame":1}`,
+		"",
+		"event: snapshot",
+		`data: {"frame":`,
+		`data: 2}`,
+		"",
+		"event: other",
+		`data: {"frame":99}`,
+		"",
+		"data: not json",
+		"",
+		`data:{"frame":3}`,
+		"",
+	}, "\n") + "\n"
+
+	out := make(chan Snapshot, 10)
+	if !readEvents(context.Background(), strings.NewReader(body), out) {
+		t.Fatal("readEvents reported no events")
+	}
+	close(out)
+	var frames []int64
+	for s := range out {
+		frames = append(frames, s.Frame)
+	}
+	if fmt.Sprint(frames) != "[1 2 3]" {
+		t.Fatalf("frames = %v, want [1 2 3]", frames)
+	}
+}
+
+func TestStreamReconnects(t *testing.T) {
+	var mu sync.Mutex
+	conns := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/faces/stream" || r.Header.Get("Accept") != "text/event-stream" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		conns++
+		n := conns
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		// One event per connection, then drop it.
+		fmt.Fprintf(w, "data: {\"frame\":%d}\n\n", n)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := New(srv.URL, nil).Stream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for want := int64(1); want <= 2; want++ {
+		select {
+		case s := <-ch:
+			if s.Frame != want {
+				t.Fatalf("frame = %d, want %d", s.Frame, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no snapshot %d, stream didn't reconnect", want)
+		}
+	}
+
+	cancel()
+	for range ch { // drained and closed once ctx is done
+	}
+}
+
+func TestStreamFailsFast(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	if _, err := New(srv.URL, nil).Stream(context.Background()); !IsStatus(err, http.StatusNotFound) {
+		t.Fatalf("err = %v, want HTTP 404", err)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/* ------------------------------ Hard negatives ----------------------------- */
+
+// Feedback collects hard negatives for retraining: detections a human marks
+// as false positives are cropped from the retained frames into dir, and
+// recorded in dir/feedback.jsonl with their box, score and source.
+type Feedback struct {
+	dir    string
+	frames *FrameRing
+	format imageFormat
+
+	mu sync.Mutex // serializes writes to the log
+}
+
+// NegativeSample is one line of feedback.jsonl and the /feedback response.
+type NegativeSample struct {
+	File      string    `json:"file"` // crop, relative to the negatives dir
+	Source    string    `json:"source"`
+	Frame     int64     `json:"frame"`
+	Detection Detection `json:"detection"`
+	MarkedAt  time.Time `json:"marked_at"`
+}
+
+// errNotRetained is returned for a frame or detection that is gone.
+var errNotRetained = errors.New("frame or detection no longer retained")
+
+// NewFeedback saves negatives into dir, creating it if needed.
+func NewFeedback(dir string, frames *FrameRing, format imageFormat) (*Feedback, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Feedback{dir: dir, frames: frames, format: format}, nil
+}
+
+// MarkFalsePositive saves the crop of detection id (ID or UUID) of a
+// retained frame. Marking the same detection again is a no-op; created is
+// false then.
+func (f *Feedback) MarkFalsePositive(frame int64, id string) (sample NegativeSample, created bool, err error) {
+	snap, ok := f.frames.Snapshot(frame)
+	if !ok {
+		return sample, false, errNotRetained
+	}
+	i := -1
+	for j := range snap.Detections {
+		if snap.Detections[j].hasID(id) {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		return sample, false, errNotRetained
+	}
+	det := snap.Detections[i]
+	sample = NegativeSample{
+		File: cropName(frame, det, f.format), Source: snap.Source, Frame: frame,
+		Detection: det, MarkedAt: time.Now().UTC(),
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := filepath.Join(f.dir, sample.File)
+	if _, err := os.Stat(path); err == nil {
+		return sample, false, nil
+	}
+	crop, ok := f.frames.Crop(frame, id)
+	if !ok {
+		return sample, false, errNotRetained
+	}
+	defer crop.Close()
+	data, err := f.format.Encode(crop)
+	if err != nil {
+		return sample, false, fmt.Errorf("encode crop: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return sample, false, err
+	}
+	if err := f.appendLog(sample); err != nil {
+		return sample, false, err
+	}
+	log.Printf("[feedback] frame %d detection %s marked false positive, saved %s", frame, id, sample.File)
+	return sample, true, nil
+}
+
+func (f *Feedback) appendLog(sample NegativeSample) error {
+	line, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(f.dir, "feedback.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// feedbackHandler serves POST /feedback?frame=<frame>&id=<id or uuid>,
+// marking a detection of a retained frame as a false positive: 201 with the
+// sample, 200 if it was already marked, 404 once the frame is evicted.
+func feedbackHandler(f *Feedback) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		frame, err := strconv.ParseInt(q.Get("frame"), 10, 64)
+		id := q.Get("id")
+		if err != nil || id == "" {
+			http.Error(w, "frame and id are required", http.StatusBadRequest)
+			return
+		}
+		sample, created, err := f.MarkFalsePositive(frame, id)
+		switch {
+		case errors.Is(err, errNotRetained):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			log.Printf("[feedback] %v", err)
+			http.Error(w, "cannot save the sample", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if !created {
+			writeJSON(w, sample, true)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusCreated)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(sample)
+	}
+}
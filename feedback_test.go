@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestFeedback(t *testing.T) {
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	img := gocv.NewMatWithSize(100, 200, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	gocv.Rectangle(&img, image.Rect(20, 20, 60, 50), red, -1)
+	gocv.Rectangle(&img, image.Rect(120, 40, 150, 70), blue, -1)
+	snap := Snapshot{Source: "cam", Frame: 7, FrameWidth: 200, FrameHeight: 100, Detections: []Detection{
+		{ID: 1, BBox: Rect{X: 20, Y: 20, Width: 40, Height: 30}, Score: 0.9},
+		{ID: 2, UUID: "b", BBox: Rect{X: 120, Y: 40, Width: 30, Height: 30}, Score: 0.6},
+	}}
+	frames := NewFrameRing(2, 1<<20, 0)
+	frames.Add(snap, img)
+
+	dir := filepath.Join(t.TempDir(), "negatives")
+	pngFormat := imageFormat{Name: "png", ContentType: "image/png", ext: gocv.PNGFileExt}
+	fb, err := NewFeedback(dir, frames, pngFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		feedbackHandler(fb)(w, httptest.NewRequest(http.MethodPost, "/feedback"+query, nil))
+		return w
+	}
+
+	// By UUID: the crop of that detection, not its neighbour's.
+	w := post("?frame=7&id=b")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("mark: %d %s", w.Code, w.Body)
+	}
+	var sample NegativeSample
+	if err := json.Unmarshal(w.Body.Bytes(), &sample); err != nil {
+		t.Fatal(err)
+	}
+	if sample.File != "7-b.png" || sample.Source != "cam" || sample.Frame != 7 || sample.Detection.BBox != snap.Detections[1].BBox {
+		t.Errorf("sample %+v", sample)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, sample.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crop, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := crop.Bounds(); b.Dx() != 30 || b.Dy() != 30 {
+		t.Errorf("crop is %v, want 30x30", b)
+	}
+	for _, p := range []image.Point{{0, 0}, {15, 15}, {29, 29}} {
+		if r, _, b, _ := crop.At(p.X, p.Y).RGBA(); r != 0 || b>>8 != 255 {
+			t.Errorf("crop pixel %v is not the blue face", p)
+		}
+	}
+
+	// By ID, and again: already marked, logged once.
+	if w := post("?frame=7&id=1"); w.Code != http.StatusCreated {
+		t.Errorf("mark by ID: %d %s", w.Code, w.Body)
+	}
+	if w := post("?frame=7&id=b"); w.Code != http.StatusOK {
+		t.Errorf("marked again: %d, want 200", w.Code)
+	}
+	f, err := os.Open(filepath.Join(dir, "feedback.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logged []string
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var s NegativeSample
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		logged = append(logged, s.File)
+	}
+	f.Close()
+	if len(logged) != 2 || logged[0] != "7-b.png" || logged[1] != "7-1.png" {
+		t.Errorf("feedback.jsonl lists %v, want each sample once", logged)
+	}
+
+	// Evicted frames and unknown detections.
+	frames.Add(Snapshot{Frame: 8}, img)
+	frames.Add(Snapshot{Frame: 9}, img)
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"?frame=7&id=1", http.StatusNotFound}, // evicted
+		{"?frame=9&id=1", http.StatusNotFound}, // no such detection
+		{"?frame=99&id=1", http.StatusNotFound},
+		{"?frame=x&id=1", http.StatusBadRequest},
+		{"?frame=9", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := post(tt.query); w.Code != tt.code {
+			t.Errorf("%s: %d %s, want %d", tt.query, w.Code, w.Body, tt.code)
+		}
+	}
+	w = httptest.NewRecorder()
+	feedbackHandler(fb)(w, httptest.NewRequest(http.MethodGet, "/feedback?frame=9&id=1", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: %d, want 405", w.Code)
+	}
+}
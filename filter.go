@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/* ------------------------------ Filter language ---------------------------- */
+
+// A filter expression selects detections, e.g.
+//
+//	score > 0.8 && width > 50 && (x < 320 || count >= 2)
+//
+// Operands are numbers or the fields below; operators are the comparisons
+// < <= > >= == !=, the boolean && || ! and parentheses. Nothing else is
+// accepted, so an expression can only ever read these fields.
+var filterFields = map[string]func(*Detection) float64{
+	"id":        func(d *Detection) float64 { return float64(d.ID) },
+	"score":     func(d *Detection) float64 { return d.Score },
+	"raw_score": func(d *Detection) float64 { return d.RawScore },
+	"x":         func(d *Detection) float64 { return float64(d.BBox.X) },
+	"y":         func(d *Detection) float64 { return float64(d.BBox.Y) },
+	"width":     func(d *Detection) float64 { return float64(d.BBox.Width) },
+	"height":    func(d *Detection) float64 { return float64(d.BBox.Height) },
+	"area":      func(d *Detection) float64 { return float64(d.BBox.Width * d.BBox.Height) },
+	"count":     func(d *Detection) float64 { return float64(d.Count) },
+
+	"approx_range_m": func(d *Detection) float64 { return d.ApproxRangeM },
+	"quality":        func(d *Detection) float64 { return d.Quality },
+	"dwell_s":        func(d *Detection) float64 { return d.DwellS },
+
+	"track_confidence": func(d *Detection) float64 { return d.TrackConfidence },
+	"stable_ms":        func(d *Detection) float64 { return float64(d.StableMs) },
+	"edge": func(d *Detection) float64 {
+		if d.Edge {
+			return 1
+		}
+		return 0
+	},
+	"vx": func(d *Detection) float64 {
+		if d.Velocity == nil {
+			return 0
+		}
+		return d.Velocity.VX
+	},
+	"vy": func(d *Detection) float64 {
+		if d.Velocity == nil {
+			return 0
+		}
+		return d.Velocity.VY
+	},
+	"predicted": func(d *Detection) float64 {
+		if d.Predicted {
+			return 1
+		}
+		return 0
+	},
+}
+
+const (
+	maxFilterLen   = 512
+	maxFilterDepth = 32
+)
+
+// filterError is a parse error at a byte offset of the expression.
+type filterError struct {
+	pos int
+	msg string
+}
+
+func (e *filterError) Error() string {
+	return fmt.Sprintf("filter: %s at position %d", e.msg, e.pos)
+}
+
+// filterExpr is a compiled expression.
+type filterExpr interface {
+	match(d *Detection) bool
+}
+
+type (
+	orExpr  struct{ l, r filterExpr }
+	andExpr struct{ l, r filterExpr }
+	notExpr struct{ e filterExpr }
+	cmpExpr struct {
+		op   string
+		l, r operand
+	}
+	operand struct {
+		field func(*Detection) float64 // nil for a literal
+		value float64
+	}
+)
+
+func (e orExpr) match(d *Detection) bool  { return e.l.match(d) || e.r.match(d) }
+func (e andExpr) match(d *Detection) bool { return e.l.match(d) && e.r.match(d) }
+func (e notExpr) match(d *Detection) bool { return !e.e.match(d) }
+
+func (e cmpExpr) match(d *Detection) bool {
+	l, r := e.l.eval(d), e.r.eval(d)
+	switch e.op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	case "==":
+		return l == r
+	default: // "!="
+		return l != r
+	}
+}
+
+func (o operand) eval(d *Detection) float64 {
+	if o.field != nil {
+		return o.field(d)
+	}
+	return o.value
+}
+
+// applyFilter returns snap with only the detections matching e. The shared
+// snapshot is not modified.
+func applyFilter(snap Snapshot, e filterExpr) Snapshot {
+	if e == nil || len(snap.Detections) == 0 {
+		return snap
+	}
+	kept := make([]Detection, 0, len(snap.Detections))
+	for i := range snap.Detections {
+		if e.match(&snap.Detections[i]) {
+			kept = append(kept, snap.Detections[i])
+		}
+	}
+	snap.Detections = kept
+	return snap
+}
+
+/* -------------------------------- Parsing ---------------------------------- */
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// tokenize splits src into tokens, rejecting any character outside the
+// language.
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			toks = append(toks, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			toks = append(toks, token{tokRParen, ")", i})
+			i++
+		case c >= '0' && c <= '9' || c == '.' || c == '-':
+			j := i + 1
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, src[i:j], i})
+			i = j
+		case c >= 'a' && c <= 'z' || c == '_':
+			j := i + 1
+			for j < len(src) && (src[j] >= 'a' && src[j] <= 'z' || src[j] == '_') {
+				j++
+			}
+			toks = append(toks, token{tokIdent, src[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, cand := range []string{"&&", "||", "<=", ">=", "==", "!=", "<", ">", "!"} {
+				if strings.HasPrefix(src[i:], cand) {
+					op = cand
+					break
+				}
+			}
+			if op == "" {
+				return nil, &filterError{i, fmt.Sprintf("unexpected character %q", c)}
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(src)}), nil
+}
+
+type filterParser struct {
+	toks  []token
+	i     int
+	depth int
+}
+
+// parseFilter compiles a filter expression.
+func parseFilter(src string) (filterExpr, error) {
+	if len(src) > maxFilterLen {
+		return nil, &filterError{maxFilterLen, fmt.Sprintf("expression longer than %d characters", maxFilterLen)}
+	}
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{toks: toks}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &filterError{t.pos, fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return e, nil
+}
+
+func (p *filterParser) peek() token { return p.toks[p.i] }
+
+// next consumes a token; the final EOF token is never consumed.
+func (p *filterParser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *filterParser) or() (filterExpr, error) {
+	l, err := p.and()
+	for err == nil && p.peek().text == "||" && p.peek().kind == tokOp {
+		p.next()
+		var r filterExpr
+		if r, err = p.and(); err == nil {
+			l = orExpr{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *filterParser) and() (filterExpr, error) {
+	l, err := p.unary()
+	for err == nil && p.peek().text == "&&" && p.peek().kind == tokOp {
+		p.next()
+		var r filterExpr
+		if r, err = p.unary(); err == nil {
+			l = andExpr{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *filterParser) unary() (filterExpr, error) {
+	t := p.peek()
+	if p.depth++; p.depth > maxFilterDepth {
+		return nil, &filterError{t.pos, "expression nested too deeply"}
+	}
+	defer func() { p.depth-- }()
+
+	switch {
+	case t.kind == tokOp && t.text == "!":
+		p.next()
+		e, err := p.unary()
+		return notExpr{e}, err
+	case t.kind == tokLParen:
+		p.next()
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tokRParen {
+			return nil, &filterError{c.pos, "missing )"}
+		}
+		return e, nil
+	}
+	return p.comparison()
+}
+
+func (p *filterParser) comparison() (filterExpr, error) {
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.next()
+	switch t.text {
+	case "<", "<=", ">", ">=", "==", "!=":
+	default:
+		return nil, &filterError{t.pos, "expected a comparison operator"}
+	}
+	r, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return cmpExpr{op: t.text, l: l, r: r}, nil
+}
+
+func (p *filterParser) operand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return operand{}, &filterError{t.pos, fmt.Sprintf("invalid number %q", t.text)}
+		}
+		return operand{value: v}, nil
+	case tokIdent:
+		f, ok := filterFields[t.text]
+		if !ok {
+			return operand{}, &filterError{t.pos, fmt.Sprintf("unknown field %q", t.text)}
+		}
+		return operand{field: f}, nil
+	case tokEOF:
+		return operand{}, &filterError{t.pos, "unexpected end of expression"}
+	}
+	return operand{}, &filterError{t.pos, fmt.Sprintf("expected a field or number, got %q", t.text)}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"too long", "score > " + strings.Repeat("1", maxFilterLen), "longer than"},
+		{"nested parens", strings.Repeat("(", maxFilterDepth+1) + "score > 0" + strings.Repeat(")", maxFilterDepth+1), "nested too deeply"},
+		{"nested nots", strings.Repeat("!", maxFilterDepth+1) + "score > 0", "nested too deeply"},
+		{"deep unbalanced", strings.Repeat("(", maxFilterLen), "nested too deeply"},
+		{"unknown field", "password > 0", `unknown field "password"`},
+		{"uppercase field", "Score > 0", "unexpected character"},
+		{"lone minus", "score > -", `invalid number "-"`},
+		{"lone dot", ". < score", `invalid number "."`},
+		{"double dot", "score > 1.2.3", `invalid number "1.2.3"`},
+		{"call", "score > len(x)", `unknown field "len"`},
+		{"quote", `score == "x"`, "unexpected character"},
+		{"semicolon", "score > 0; id > 0", "unexpected character"},
+		{"single amp", "score > 0 & id > 0", "unexpected character"},
+		{"missing paren", "(score > 0", "missing )"},
+		{"stray paren", "score > 0)", `unexpected ")"`},
+		{"no operator", "score", "expected a comparison operator"},
+		{"chained comparison", "0 < score < 1", `unexpected "<"`},
+		{"dangling and", "score > 0 &&", "unexpected end of expression"},
+		{"empty", "", "unexpected end of expression"},
+		{"bare field", "!score", "expected a comparison operator"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFilter(tt.src)
+			var fe *filterError
+			if !errors.As(err, &fe) {
+				t.Fatalf("parseFilter(%.40q) = %v, want a filterError", tt.src, err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("parseFilter(%.40q) = %v, want %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterLimits(t *testing.T) {
+	// Right at the limits is still accepted.
+	nested := strings.Repeat("(", maxFilterDepth-1) + "score > 0" + strings.Repeat(")", maxFilterDepth-1)
+	if _, err := parseFilter(nested); err != nil {
+		t.Fatalf("depth %d: %v", maxFilterDepth-1, err)
+	}
+	long := "score > 0" + strings.Repeat(" ", maxFilterLen-len("score > 0"))
+	if _, err := parseFilter(long); err != nil {
+		t.Fatalf("length %d: %v", maxFilterLen, err)
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	d := Detection{ID: 3, Score: 0.9, BBox: Rect{X: 400, Y: 10, Width: 60, Height: 80}, Count: 1}
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"score > 0.8 && width > 50", true},
+		{"score>0.8&&width>50", true},
+		{"-1 < x", true},
+		{"x == 400 && area == 4800", true},
+		{"predicted == 0 && vx == 0", true},
+		// && binds tighter than ||
+		{"id == 1 || id == 3 && score > 0.5", true},
+		{"id == 3 || id == 1 && score > 0.95", true},
+		{"(id == 3 || id == 1) && score > 0.95", false},
+		{"id == 1 && score > 0.5 || id == 3", true},
+		{"id == 1 && (score > 0.5 || id == 3)", false},
+		// ! applies to the next operand only
+		{"!id == 3 || score > 0.5", true},
+		{"!(id == 3 || score > 0.5)", false},
+		{"!!(id == 3)", true},
+		{"score > 0.8 && width > 50 && (x < 320 || count >= 2)", false},
+	}
+	for _, tt := range tests {
+		e, err := parseFilter(tt.src)
+		if err != nil {
+			t.Fatalf("parseFilter(%q): %v", tt.src, err)
+		}
+		if got := e.match(&d); got != tt.want {
+			t.Errorf("%q = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestFacesFilter(t *testing.T) {
+	dets := []Detection{
+		{ID: 1, BBox: Rect{X: 10, Y: 10, Width: 40, Height: 40}, Score: 0.95},
+		{ID: 2, BBox: Rect{X: 100, Y: 10, Width: 80, Height: 80}, Score: 0.6},
+		{ID: 3, BBox: Rect{X: 300, Y: 10, Width: 80, Height: 80}, Score: 0.9},
+	}
+	store := NewFaceStore()
+	store.Set(Snapshot{Frame: 1, Detections: dets})
+	get := func(filter string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		facesHandler(ServerConfig{}, store)(w, httptest.NewRequest(http.MethodGet, "/faces?filter="+url.QueryEscape(filter), nil))
+		return w
+	}
+
+	w := get("score > 0.8 && width > 50")
+	var body struct{ Detections []Detection }
+	if err := json.Unmarshal(w.Body.Bytes(), &body); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("%d %s: %v", w.Code, w.Body, err)
+	}
+	if len(body.Detections) != 1 || body.Detections[0].ID != 3 {
+		t.Errorf("filtered /faces %+v, want detection 3 only", body.Detections)
+	}
+
+	// The error names the position of the offending token.
+	w = get("score > 0.8 && wdth > 50")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `unknown field "wdth" at position 15`) {
+		t.Errorf("invalid filter: %d %q", w.Code, w.Body)
+	}
+
+	if snap, _ := store.Get(); !reflect.DeepEqual(snap.Detections, dets) {
+		t.Errorf("store holds %+v, want every detection", snap.Detections)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"image"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+/* ---------------------------- Retained frames ------------------------------ */
+
+// FrameRing keeps the images of the most recent frames together with their
+// snapshots, bounded both by frame count and by an explicit memory budget
+// (decoded pixel bytes). Oldest frames are evicted first. Frames larger than
+// maxDim on their longest side are downscaled before being retained; the
+// inference input is not affected.
+type FrameRing struct {
+	mu          sync.Mutex
+	maxCount    int
+	maxBytes    int64
+	maxDim      int
+	items       []retainedFrame // oldest first
+	bytes       int64
+	warned      bool
+	warnedScale bool
+	downscaled  int64
+}
+
+type retainedFrame struct {
+	snap  Snapshot
+	img   gocv.Mat
+	scale float64 // retained size / captured size
+	bytes int64
+}
+
+// NewFrameRing returns a ring retaining at most maxCount frames and maxBytes
+// of pixel data, or nil (retention disabled) when either limit is <= 0.
+// maxDim <= 0 keeps frames at full resolution.
+func NewFrameRing(maxCount int, maxBytes int64, maxDim int) *FrameRing {
+	if maxCount <= 0 || maxBytes <= 0 {
+		return nil
+	}
+	return &FrameRing{maxCount: maxCount, maxBytes: maxBytes, maxDim: maxDim}
+}
+
+// Add retains a copy of img for snap.Frame, evicting old frames as needed.
+// Frames that alone exceed the memory budget are not retained.
+func (r *FrameRing) Add(snap Snapshot, img gocv.Mat) {
+	kept, scale := r.fit(img)
+	size := matBytes(kept)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if scale != 1 {
+		r.downscaled++
+		if !r.warnedScale {
+			log.Printf("[frames] %dx%d frames exceed FACE_RETAIN_MAX_DIM=%d, retaining them at %dx%d",
+				img.Cols(), img.Rows(), r.maxDim, kept.Cols(), kept.Rows())
+			r.warnedScale = true
+		}
+	}
+
+	if size > r.maxBytes {
+		if !r.warned {
+			log.Printf("[frames] %dx%d frame (%d bytes) exceeds retention budget of %d bytes; not retaining",
+				img.Cols(), img.Rows(), size, r.maxBytes)
+			r.warned = true
+		}
+		kept.Close()
+		return
+	}
+	for len(r.items) > 0 && (len(r.items) >= r.maxCount || r.bytes+size > r.maxBytes) {
+		r.evictOldest()
+	}
+	snap.Raw = nil // only the latest snapshot serves ?raw=true
+	r.items = append(r.items, retainedFrame{snap: snap.clone(), img: kept, scale: scale, bytes: size})
+	r.bytes += size
+}
+
+// fit returns a private copy of img no larger than maxDim on its longest
+// side, and the scale applied.
+func (r *FrameRing) fit(img gocv.Mat) (gocv.Mat, float64) {
+	longest := max(img.Cols(), img.Rows())
+	if r.maxDim <= 0 || longest <= r.maxDim {
+		return img.Clone(), 1
+	}
+	scale := float64(r.maxDim) / float64(longest)
+	small := gocv.NewMat()
+	size := image.Pt(max(int(float64(img.Cols())*scale), 1), max(int(float64(img.Rows())*scale), 1))
+	_ = gocv.Resize(img, &small, size, 0, 0, gocv.InterpolationArea)
+	return small, scale
+}
+
+// RetentionStats describes the retained frames and their memory budget.
+type RetentionStats struct {
+	Frames      int   `json:"frames"`
+	MaxFrames   int   `json:"max_frames"`
+	Bytes       int64 `json:"bytes"`
+	BudgetBytes int64 `json:"budget_bytes"`
+	MaxDim      int   `json:"max_dim,omitempty"`
+	Downscaled  int64 `json:"downscaled_frames"`
+}
+
+func (r *FrameRing) Stats() RetentionStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return RetentionStats{
+		Frames:      len(r.items),
+		MaxFrames:   r.maxCount,
+		Bytes:       r.bytes,
+		BudgetBytes: r.maxBytes,
+		MaxDim:      r.maxDim,
+		Downscaled:  r.downscaled,
+	}
+}
+
+func (r *FrameRing) evictOldest() {
+	old := r.items[0]
+	old.img.Close()
+	r.bytes -= old.bytes
+	r.items[0] = retainedFrame{}
+	r.items = r.items[1:]
+}
+
+// memoryUsage, oldest and evict make the ring a memoryHolder.
+func (r *FrameRing) memoryUsage() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.bytes
+	for i := range r.items {
+		n += snapshotBytes(r.items[i].snap)
+	}
+	return n
+}
+
+func (r *FrameRing) oldest() (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.items) == 0 {
+		return time.Time{}, false
+	}
+	return r.items[0].snap.GeneratedAt, true
+}
+
+func (r *FrameRing) evict() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.items) == 0 {
+		return 0
+	}
+	freed := r.items[0].bytes + snapshotBytes(r.items[0].snap)
+	r.evictOldest()
+	return freed
+}
+
+// Crop returns a copy of the box of the detection with the given ID (or
+// UUID) in the retained frame, or false if the frame is no longer retained or
+// has no such detection. The caller must Close the returned Mat.
+func (r *FrameRing) Crop(frame int64, id string) (gocv.Mat, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.items {
+		it := &r.items[i]
+		if it.snap.Frame != frame {
+			continue
+		}
+		for _, d := range it.snap.Detections {
+			if d.hasID(id) {
+				return it.crop(d.BBox)
+			}
+		}
+		return gocv.Mat{}, false
+	}
+	return gocv.Mat{}, false
+}
+
+// Region returns a copy of box (in frame coordinates) of the retained frame,
+// or false if the frame is no longer retained or box is outside of it. The
+// caller must Close the returned Mat.
+func (r *FrameRing) Region(frame int64, box Rect) (gocv.Mat, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.items {
+		if r.items[i].snap.Frame == frame {
+			return r.items[i].crop(box)
+		}
+	}
+	return gocv.Mat{}, false
+}
+
+// crop copies box, in frame coordinates, out of the retained image.
+func (it *retainedFrame) crop(b Rect) (gocv.Mat, bool) {
+	sc := func(v int) int { return int(float64(v) * it.scale) }
+	box := image.Rect(sc(b.X), sc(b.Y), sc(b.X+b.Width), sc(b.Y+b.Height)).
+		Intersect(image.Rect(0, 0, it.img.Cols(), it.img.Rows()))
+	if box.Empty() {
+		return gocv.Mat{}, false
+	}
+	region := it.img.Region(box)
+	defer region.Close()
+	return region.Clone(), true
+}
+
+// Snapshot returns the snapshot of a retained frame, or false if it is no
+// longer retained. It is shared and must be treated as read-only.
+func (r *FrameRing) Snapshot(frame int64) (Snapshot, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.items {
+		if r.items[i].snap.Frame == frame {
+			return r.items[i].snap, true
+		}
+	}
+	return Snapshot{}, false
+}
+
+// Retained returns the numbers of the retained frames from from to to
+// (inclusive), in ascending order.
+func (r *FrameRing) Retained(from, to int64) []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var frames []int64
+	for i := range r.items {
+		if f := r.items[i].snap.Frame; f >= from && f <= to {
+			frames = append(frames, f)
+		}
+	}
+	return frames
+}
+
+// hasID reports whether id, as given in a URL, is the detection's ID or,
+// with tracking, its UUID.
+func (d Detection) hasID(id string) bool {
+	return strconv.Itoa(d.ID) == id || d.UUID != "" && d.UUID == id
+}
+
+// matBytes is the decoded pixel size of an 8-bit Mat.
+func matBytes(m gocv.Mat) int64 {
+	return int64(m.Rows()) * int64(m.Cols()) * int64(m.Channels())
+}
+
+// faceCropHandler serves /face/<frame>/<id>.jpg from the retained frames;
+// <id> is the detection ID or, with tracking, its UUID.
+func faceCropHandler(frames *FrameRing, encoders *ImageEncoders) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/face/")
+		frameStr, file, ok := strings.Cut(rest, "/")
+		id, isJPEG := strings.CutSuffix(file, ".jpg")
+		frame, err := strconv.ParseInt(frameStr, 10, 64)
+		if !ok || !isJPEG || err != nil || id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if frames == nil {
+			http.Error(w, "frame retention disabled", http.StatusNotFound)
+			return
+		}
+
+		crop, found := frames.Crop(frame, id)
+		if !found {
+			http.Error(w, "frame or detection no longer retained", http.StatusNotFound)
+			return
+		}
+		defer crop.Close()
+
+		format := encoders.Negotiate(r.Header.Get("Accept"))
+		data, err := format.Encode(crop)
+		if err != nil {
+			http.Error(w, "encode failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", format.ContentType)
+		w.Header().Set("Vary", "Accept")
+		w.Header().Set("Cache-Control", "public, max-age=60") // a given frame/id never changes
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		_, _ = w.Write(data)
+	}
+}
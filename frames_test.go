@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestFrameRingMaxDim(t *testing.T) {
+	tests := []struct {
+		name       string
+		w, h       int
+		maxDim     int
+		wantW      int
+		wantH      int
+		downscaled int64
+	}{
+		{"4K", 3840, 2160, 640, 640, 360, 1},
+		{"portrait", 720, 1280, 640, 360, 640, 1},
+		{"at the limit", 640, 480, 640, 640, 480, 0},
+		{"small", 320, 240, 640, 320, 240, 0},
+		{"no limit", 3840, 2160, 0, 3840, 2160, 0},
+	}
+	for _, tt := range tests {
+		r := NewFrameRing(4, 1<<30, tt.maxDim)
+		img := gocv.NewMatWithSize(tt.h, tt.w, gocv.MatTypeCV8UC3)
+		img.SetUCharAt(0, 0, 200)
+		r.Add(Snapshot{Frame: 1}, img)
+
+		kept := r.items[0]
+		if kept.img.Cols() != tt.wantW || kept.img.Rows() != tt.wantH {
+			t.Errorf("%s: retained %dx%d, want %dx%d", tt.name, kept.img.Cols(), kept.img.Rows(), tt.wantW, tt.wantH)
+		}
+		if want := float64(tt.wantW) / float64(tt.w); kept.scale != want {
+			t.Errorf("%s: scale %v, want %v", tt.name, kept.scale, want)
+		}
+		st := r.Stats()
+		if st.Downscaled != tt.downscaled || st.MaxDim != tt.maxDim || st.Bytes != int64(tt.wantW*tt.wantH*3) {
+			t.Errorf("%s: stats %+v", tt.name, st)
+		}
+		if tt.downscaled == 0 {
+			// Passed through untouched, but as a private copy.
+			want := img.ToBytes()
+			img.SetUCharAt(0, 0, 0)
+			if !bytes.Equal(kept.img.ToBytes(), want) {
+				t.Errorf("%s: retained pixels differ from the captured frame", tt.name)
+			}
+		}
+		img.Close()
+	}
+}
+
+func TestFaceCropHandler(t *testing.T) {
+	img := gocv.NewMatWithSize(100, 200, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	snap := func(frame int64) Snapshot {
+		return Snapshot{Frame: frame, Detections: []Detection{
+			{ID: 1, BBox: Rect{X: 20, Y: 20, Width: 40, Height: 30}},
+			{ID: 2, UUID: "b", BBox: Rect{X: 120, Y: 40, Width: 30, Height: 30}},
+		}}
+	}
+	encoders, err := NewImageEncoders("jpeg", 90, "", 75)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// get returns the status of path and the size of the image served.
+	get := func(frames *FrameRing, path string) (int, image.Point) {
+		w := httptest.NewRecorder()
+		faceCropHandler(frames, encoders)(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			return w.Code, image.Point{}
+		}
+		crop, err := jpeg.Decode(w.Body)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return w.Code, crop.Bounds().Size()
+	}
+
+	full := NewFrameRing(2, 1<<20, 0)
+	full.Add(snap(1), img)
+	small := NewFrameRing(2, 1<<20, 100) // retained at half size
+	small.Add(snap(1), img)
+	tests := []struct {
+		frames *FrameRing
+		path   string
+		code   int
+		size   image.Point
+	}{
+		{full, "/face/1/1.jpg", http.StatusOK, image.Pt(40, 30)},
+		{full, "/face/1/b.jpg", http.StatusOK, image.Pt(30, 30)},
+		{small, "/face/1/1.jpg", http.StatusOK, image.Pt(20, 15)},
+		{small, "/face/1/b.jpg", http.StatusOK, image.Pt(15, 15)},
+		{full, "/face/1/9.jpg", http.StatusNotFound, image.Point{}},
+		{full, "/face/2/1.jpg", http.StatusNotFound, image.Point{}},
+		{full, "/face/x/1.jpg", http.StatusNotFound, image.Point{}},
+		{full, "/face/1/1.png", http.StatusNotFound, image.Point{}},
+		{full, "/face/1/.jpg", http.StatusNotFound, image.Point{}},
+		{full, "/face/1", http.StatusNotFound, image.Point{}},
+		{nil, "/face/1/1.jpg", http.StatusNotFound, image.Point{}},
+	}
+	for _, tt := range tests {
+		if code, size := get(tt.frames, tt.path); code != tt.code || size != tt.size {
+			t.Errorf("%s: %d %v, want %d %v", tt.path, code, size, tt.code, tt.size)
+		}
+	}
+
+	// The oldest frames go first, whichever limit is hit.
+	const frameBytes = 200 * 100 * 3
+	for _, r := range []*FrameRing{NewFrameRing(2, 1<<20, 0), NewFrameRing(10, 2*frameBytes+1, 0)} {
+		for f := range int64(3) {
+			r.Add(snap(f+1), img)
+		}
+		for f, code := range map[int64]int{1: http.StatusNotFound, 2: http.StatusOK, 3: http.StatusOK} {
+			if got, _ := get(r, fmt.Sprintf("/face/%d/1.jpg", f)); got != code {
+				t.Errorf("%+v: frame %d served %d, want %d", r.Stats(), f, got, code)
+			}
+		}
+		if st := r.Stats(); st.Frames != 2 || st.Bytes != 2*frameBytes {
+			t.Errorf("stats %+v, want the 2 newest frames", st)
+		}
+	}
+
+	// A frame larger than the whole budget is never retained.
+	r := NewFrameRing(2, frameBytes-1, 0)
+	r.Add(snap(1), img)
+	if code, _ := get(r, "/face/1/1.jpg"); code != http.StatusNotFound || r.Stats().Bytes != 0 {
+		t.Errorf("over-budget frame: %d, stats %+v", code, r.Stats())
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+/* ------------------------------ Frigate events ----------------------------- */
+
+// The frigate sink turns tracked detections into the event messages Frigate
+// publishes on its frigate/events MQTT topic, and POSTs each one as JSON to
+// a URL (an MQTT bridge, Node-RED, or an NVR accepting them over HTTP):
+//
+//	{"type": "new" | "update" | "end", "before": {...}, "after": {...}}
+//
+// One event object per track, mapped from Detection as follows:
+//
+//	id            "<start_time>-<first 6 chars of uuid>" (or "-id<ID>")
+//	camera        the sink's camera setting, default "facetrack"
+//	label         the sink's label setting, default "face"
+//	score         Score of the latest frame
+//	top_score     best Score over the track
+//	box, region   BBox as [x_min, y_min, x_max, y_max] in frame pixels; the
+//	              region is the whole frame
+//	area, ratio   BBox width*height and width/height
+//	frame_time    Timestamp of the latest detection, Unix seconds
+//	start_time    Timestamp of the first detection of the track
+//	end_time      null, then the last frame_time on "end"
+//	current_zones Zone, if any; entered_zones accumulates them
+//	stationary    true from the track's stable event (FACE_STABLE_AFTER)
+//	              until it moves again (its StableMs restarts)
+//
+// "new" is sent when a track appears, "update" when its top score improves,
+// its zone changes or it becomes stationary or moves again, and "end" when
+// it is gone from a snapshot. Events
+// need stable IDs, so tracking (FACE_ID_STRATEGY=track) is expected.
+type frigateEvent struct {
+	Type   string         `json:"type"`
+	Before *frigateObject `json:"before"`
+	After  *frigateObject `json:"after"`
+}
+
+type frigateObject struct {
+	ID            string   `json:"id"`
+	Camera        string   `json:"camera"`
+	FrameTime     float64  `json:"frame_time"`
+	Label         string   `json:"label"`
+	SubLabel      *string  `json:"sub_label"`
+	Score         float64  `json:"score"`
+	TopScore      float64  `json:"top_score"`
+	Box           [4]int   `json:"box"`
+	Area          int      `json:"area"`
+	Ratio         float64  `json:"ratio"`
+	Region        [4]int   `json:"region"`
+	CurrentZones  []string `json:"current_zones"`
+	EnteredZones  []string `json:"entered_zones"`
+	StartTime     float64  `json:"start_time"`
+	EndTime       *float64 `json:"end_time"`
+	FalsePositive bool     `json:"false_positive"`
+	Stationary    bool     `json:"stationary"`
+	HasClip       bool     `json:"has_clip"`
+	HasSnapshot   bool     `json:"has_snapshot"`
+
+	stableMs int64 // of the latest detection, to tell when it moved again
+}
+
+// frigateSink keeps the last event object of every live track.
+type frigateSink struct {
+	url    string
+	camera string
+	label  string
+	client *http.Client
+	live   map[string]*frigateObject // by UUID, or ID without tracking
+	warned bool                      // about untracked detections
+}
+
+func newFrigateSink(url, camera, label string) *frigateSink {
+	if camera == "" {
+		camera = "facetrack"
+	}
+	if label == "" {
+		label = "face"
+	}
+	return &frigateSink{url: url, camera: camera, label: label,
+		client: &http.Client{Timeout: 5 * time.Second}, live: make(map[string]*frigateObject)}
+}
+
+// unixSeconds is t in Frigate's float Unix time.
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixMicro()) / 1e6
+}
+
+// object maps d of snap to an event object continuing prev, which may be
+// nil for a new track.
+func (s *frigateSink) object(snap Snapshot, d Detection, prev *frigateObject, stable bool) *frigateObject {
+	b := d.BBox
+	o := &frigateObject{
+		Camera:       s.camera,
+		FrameTime:    unixSeconds(d.Timestamp),
+		Label:        s.label,
+		Score:        d.Score,
+		TopScore:     d.Score,
+		Box:          [4]int{b.X, b.Y, b.X + b.Width, b.Y + b.Height},
+		Area:         b.Width * b.Height,
+		Region:       [4]int{0, 0, snap.FrameWidth, snap.FrameHeight},
+		CurrentZones: []string{},
+		EnteredZones: []string{},
+		StartTime:    unixSeconds(d.Timestamp),
+		Stationary:   stable,
+		stableMs:     d.StableMs,
+	}
+	if b.Height > 0 {
+		o.Ratio = float64(b.Width) / float64(b.Height)
+	}
+	if d.Zone != "" {
+		o.CurrentZones = []string{d.Zone}
+	}
+	if prev != nil {
+		o.ID, o.StartTime = prev.ID, prev.StartTime
+		o.TopScore = max(prev.TopScore, d.Score)
+		o.EnteredZones = prev.EnteredZones
+		o.Stationary = stable || prev.Stationary && d.StableMs >= prev.stableMs
+	} else {
+		suffix := "id" + strconv.Itoa(d.ID)
+		if len(d.UUID) >= 6 {
+			suffix = d.UUID[:6]
+		}
+		o.ID = fmt.Sprintf("%.6f-%s", o.StartTime, suffix)
+	}
+	if d.Zone != "" && !slices.Contains(o.EnteredZones, d.Zone) {
+		o.EnteredZones = append(append([]string(nil), o.EnteredZones...), d.Zone)
+	}
+	return o
+}
+
+// events returns the events of snap and updates the live tracks.
+func (s *frigateSink) events(snap Snapshot) []frigateEvent {
+	var events []frigateEvent
+	stable := make(map[string]bool, len(snap.Events))
+	for _, ev := range snap.Events {
+		if ev.Type == "stable" {
+			stable[ev.UUID] = true
+		}
+	}
+	seen := make(map[string]bool, len(snap.Detections))
+	for _, d := range snap.Detections {
+		key := d.UUID
+		if key == "" {
+			if !s.warned {
+				log.Printf("[frigate] detections have no track, events need FACE_ID_STRATEGY=track")
+				s.warned = true
+			}
+			key = "id" + strconv.Itoa(d.ID)
+		}
+		seen[key] = true
+		prev := s.live[key]
+		o := s.object(snap, d, prev, d.UUID != "" && stable[d.UUID])
+		s.live[key] = o
+		switch {
+		case prev == nil:
+			events = append(events, frigateEvent{Type: "new", Before: o, After: o})
+		case o.TopScore > prev.TopScore || o.Stationary != prev.Stationary || !slices.Equal(o.CurrentZones, prev.CurrentZones):
+			events = append(events, frigateEvent{Type: "update", Before: prev, After: o})
+		}
+	}
+	for key, prev := range s.live {
+		if !seen[key] {
+			events = append(events, s.end(prev))
+			delete(s.live, key)
+		}
+	}
+	return events
+}
+
+// end is the "end" event of a track last seen as prev.
+func (s *frigateSink) end(prev *frigateObject) frigateEvent {
+	o := *prev
+	end := prev.FrameTime
+	o.EndTime = &end
+	return frigateEvent{Type: "end", Before: prev, After: &o}
+}
+
+func (s *frigateSink) Publish(snap Snapshot) error {
+	return s.post(context.Background(), s.events(snap))
+}
+
+// Close ends the tracks still live, so the NVR doesn't keep them open.
+func (s *frigateSink) Close() error {
+	var events []frigateEvent
+	for key, prev := range s.live {
+		events = append(events, s.end(prev))
+		delete(s.live, key)
+	}
+	return s.post(context.Background(), events)
+}
+
+// post sends the events in order, stopping at the first failure.
+func (s *frigateSink) post(ctx context.Context, events []frigateEvent) error {
+	for _, ev := range events {
+		if err := postJSON(ctx, s.client, s.url, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
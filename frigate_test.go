@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFrigateEvents(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(i int) time.Time { return t0.Add(time.Duration(i) * 100 * time.Millisecond) }
+	face := func(i int, score float64, zone string) Detection {
+		return Detection{ID: 1, UUID: "abcdef12-3456", BBox: Rect{X: 10, Y: 20, Width: 30, Height: 60}, Score: score, Zone: zone, Timestamp: at(i)}
+	}
+	snap := func(i int, dets ...Detection) Snapshot {
+		return Snapshot{Frame: int64(i), FrameWidth: 640, FrameHeight: 480, Detections: dets, GeneratedAt: at(i)}
+	}
+	s := newFrigateSink("", "", "")
+
+	// Enter: "new", with the documented mapping.
+	evs := s.events(snap(0, face(0, 0.8, "")))
+	if len(evs) != 1 || evs[0].Type != "new" {
+		t.Fatalf("enter: %+v, want one new event", evs)
+	}
+	start := unixSeconds(at(0))
+	want := &frigateObject{
+		ID: "1767225600.000000-abcdef", Camera: "facetrack", Label: "face", FrameTime: start,
+		Score: 0.8, TopScore: 0.8, Box: [4]int{10, 20, 40, 80}, Area: 1800, Ratio: 0.5,
+		Region: [4]int{0, 0, 640, 480}, CurrentZones: []string{}, EnteredZones: []string{},
+		StartTime: start,
+	}
+	if !reflect.DeepEqual(evs[0].After, want) || evs[0].Before != evs[0].After {
+		t.Errorf("new event\n got %+v\nwant %+v", evs[0].After, want)
+	}
+
+	tests := []struct {
+		name string
+		snap Snapshot
+		want []string // event types
+	}{
+		{"same score", snap(1, face(1, 0.8, "")), nil},
+		{"lower score", snap(2, face(2, 0.7, "")), nil},
+		{"top score", snap(3, face(3, 0.9, "")), []string{"update"}},
+		{"zone", snap(4, face(4, 0.9, "door")), []string{"update"}},
+		{"stationary", Snapshot{Frame: 5, FrameWidth: 640, FrameHeight: 480, Detections: []Detection{face(5, 0.9, "door")},
+			Events: []TrackEvent{{Type: "stable", UUID: "abcdef12-3456"}}}, []string{"update"}},
+		{"leave", snap(6), []string{"end"}},
+		{"empty", snap(7), nil},
+	}
+	var last frigateEvent
+	for _, tt := range tests {
+		var types []string
+		for _, ev := range s.events(tt.snap) {
+			types = append(types, ev.Type)
+			last = ev
+		}
+		if !reflect.DeepEqual(types, tt.want) {
+			t.Errorf("%s: events %v, want %v", tt.name, types, tt.want)
+		}
+	}
+
+	end := last.After
+	if end.EndTime == nil || *end.EndTime != unixSeconds(at(5)) || end.ID != want.ID || end.TopScore != 0.9 ||
+		!end.Stationary || !reflect.DeepEqual(end.EnteredZones, []string{"door"}) || last.Before.EndTime != nil {
+		t.Errorf("end event %+v (before %+v)", end, last.Before)
+	}
+	if len(s.live) != 0 {
+		t.Errorf("%d tracks still live after the end event", len(s.live))
+	}
+}
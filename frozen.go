@@ -0,0 +1,48 @@
+package main
+
+import (
+	"hash/fnv"
+
+	"gocv.io/x/gocv"
+)
+
+/* ------------------------------ Frozen feeds ------------------------------- */
+
+// frozenHashStride samples one byte in this many when hashing a frame.
+const frozenHashStride = 7
+
+// frameHash is a cheap fingerprint of the pixel data. It is deliberately not
+// perceptual: a live camera's sensor noise changes it on almost every frame,
+// even on a static scene, while a frozen feed repeats it exactly.
+func frameHash(img gocv.Mat) uint64 {
+	data, err := img.DataPtrUint8()
+	if err != nil { // non-continuous (e.g. an ROI view)
+		data = img.ToBytes()
+	}
+	h := fnv.New64a()
+	buf := make([]byte, 0, len(data)/frozenHashStride+1)
+	for i := 0; i < len(data); i += frozenHashStride {
+		buf = append(buf, data[i])
+	}
+	_, _ = h.Write(buf)
+	return h.Sum64()
+}
+
+// frozenDetector flags a feed whose frames have been identical for
+// threshold consecutive frames.
+type frozenDetector struct {
+	threshold int
+	last      uint64
+	same      int
+}
+
+// Observe records a frame and reports whether the feed is frozen.
+func (f *frozenDetector) Observe(img gocv.Mat) bool {
+	h := frameHash(img)
+	if h == f.last {
+		f.same++
+	} else {
+		f.last, f.same = h, 0
+	}
+	return f.same >= f.threshold
+}
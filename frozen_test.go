@@ -0,0 +1,70 @@
+package main
+
+import (
+	"image"
+	"math/rand/v2"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestFrozenDetector(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	scene := make([]byte, 64*48*3)
+	for i := range scene {
+		scene[i] = byte(64 + rng.IntN(128))
+	}
+	// noisy returns the scene as a live sensor sees it: every pixel off by
+	// at most one level.
+	noisy := func() []byte {
+		b := make([]byte, len(scene))
+		for i, v := range scene {
+			b[i] = v + byte(rng.IntN(3)) - 1
+		}
+		return b
+	}
+	frame := func(data []byte) gocv.Mat {
+		m, err := gocv.NewMatFromBytes(48, 64, gocv.MatTypeCV8UC3, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	const threshold = 3
+	tests := []struct {
+		name   string
+		frames func(i int) []byte
+		frozen []bool
+	}{
+		{"identical", func(int) []byte { return scene }, []bool{false, false, false, true, true, true}},
+		{"near-identical", func(int) []byte { return noisy() }, []bool{false, false, false, false, false, false}},
+		{"unfreezes", func(i int) []byte {
+			if i == 4 {
+				return noisy()
+			}
+			return scene
+		}, []bool{false, false, false, true, false, false}},
+	}
+	for _, tt := range tests {
+		f := &frozenDetector{threshold: threshold}
+		for i, want := range tt.frozen {
+			img := frame(tt.frames(i))
+			if got := f.Observe(img); got != want {
+				t.Errorf("%s: frame %d frozen = %v, want %v", tt.name, i, got, want)
+			}
+			img.Close()
+		}
+	}
+
+	// An ROI view isn't continuous: it hashes the same as a copy of it.
+	img := frame(scene)
+	defer img.Close()
+	region := img.Region(image.Rect(8, 8, 40, 40))
+	defer region.Close()
+	copied := region.Clone()
+	defer copied.Close()
+	if frameHash(region) != frameHash(copied) {
+		t.Error("an ROI view hashes differently from its copy")
+	}
+}
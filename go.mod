@@ -2,4 +2,13 @@ module tracking-go
 
 go 1.24
 
-require gocv.io/x/gocv v0.42.0
+require (
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/segmentio/kafka-go v0.4.47
+	gocv.io/x/gocv v0.42.0
+)
+
+require (
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+)
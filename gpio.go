@@ -0,0 +1,56 @@
+//go:build linux && gpio
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+/* ---------------------------------- GPIO ----------------------------------- */
+
+// sysfsGPIO reads an input pin through /sys/class/gpio. Only built with
+// `-tags gpio` on Linux, so other builds don't carry it.
+type sysfsGPIO struct {
+	value *os.File
+	buf   [1]byte
+}
+
+// openGPIO exports pin if needed and configures it as an input. pin is the
+// kernel's GPIO number: on recent Raspberry Pi kernels that is the BCM
+// number plus the chip base (see /sys/class/gpio/gpiochip*/base).
+func openGPIO(pin int) (gpioReader, error) {
+	dir := fmt.Sprintf("/sys/class/gpio/gpio%d", pin)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile("/sys/class/gpio/export", []byte(strconv.Itoa(pin)), 0o200); err != nil {
+			return nil, fmt.Errorf("export gpio %d: %w", pin, err)
+		}
+	}
+	// udev fixes the permissions of a freshly exported pin shortly after the
+	// export. Exported pins are inputs by default, so a failure is not fatal.
+	for i := 0; i < 20; i++ {
+		if err := os.WriteFile(dir+"/direction", []byte("in"), 0o200); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	f, err := os.Open(dir + "/value")
+	if err != nil {
+		return nil, fmt.Errorf("open gpio %d: %w", pin, err)
+	}
+	return &sysfsGPIO{value: f}, nil
+}
+
+func (g *sysfsGPIO) Read() (bool, error) {
+	if _, err := g.value.ReadAt(g.buf[:], 0); err != nil {
+		return false, fmt.Errorf("read gpio: %w", err)
+	}
+	return g.buf[0] == '1', nil
+}
+
+func (g *sysfsGPIO) Close() error {
+	return g.value.Close()
+}
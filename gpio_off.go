@@ -0,0 +1,9 @@
+//go:build !linux || !gpio
+
+package main
+
+import "errors"
+
+func openGPIO(pin int) (gpioReader, error) {
+	return nil, errors.New("built without GPIO support, rebuild on Linux with -tags gpio")
+}
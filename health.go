@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+)
+
+/* --------------------------------- Health ---------------------------------- */
+
+// Health states, per check and overall.
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded" // still serving, worth a look
+	healthDown     = "down"     // /healthz?verbose=1 answers 503
+)
+
+// detectorHealth is what the detector loop reports about its dependencies.
+type detectorHealth struct {
+	SourceOpen  bool
+	ModelLoaded bool
+	LastFrame   time.Time // last successful capture, zero before the first
+	Frozen      bool
+	Paused      bool // no frames on purpose
+}
+
+// HealthCheck is the state of one dependency.
+type HealthCheck struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// HealthReport is the JSON payload of /healthz?verbose=1.
+type HealthReport struct {
+	Status          string      `json:"status"`
+	Source          HealthCheck `json:"source"`
+	Frames          HealthCheck `json:"frames"`
+	Model           HealthCheck `json:"model"`
+	LastFrameAgeSec *float64    `json:"last_frame_age_s"` // null before the first frame
+}
+
+// snapshotAge is how old snap is at now. GeneratedAt carries the clock
+// offset, so it is compared to now shifted by the same offset; a snapshot
+// never generated is infinitely old.
+func snapshotAge(snap Snapshot, now time.Time, offset time.Duration) time.Duration {
+	if snap.GeneratedAt.IsZero() {
+		return math.MaxInt64
+	}
+	return now.Add(offset).Sub(snap.GeneratedAt)
+}
+
+// assessHealth aggregates h at now. A closed source, an unloaded model or no
+// frame for longer than maxAge is critical (down); a frozen feed or one that
+// hasn't delivered its first frame yet is degraded. A paused detector is
+// fine without frames.
+func assessHealth(h detectorHealth, now time.Time, maxAge time.Duration) HealthReport {
+	rep := HealthReport{
+		Source: HealthCheck{Status: healthOK},
+		Frames: HealthCheck{Status: healthOK},
+		Model:  HealthCheck{Status: healthOK},
+	}
+	if !h.SourceOpen {
+		rep.Source = HealthCheck{Status: healthDown, Detail: "source not open"}
+	}
+	if !h.ModelLoaded {
+		rep.Model = HealthCheck{Status: healthDown, Detail: "model not loaded"}
+	}
+	switch {
+	case h.Paused:
+		rep.Frames = HealthCheck{Status: healthOK, Detail: "detector paused"}
+	case h.LastFrame.IsZero():
+		rep.Frames = HealthCheck{Status: healthDegraded, Detail: "no frame captured yet"}
+	case now.Sub(h.LastFrame) > maxAge:
+		rep.Frames = HealthCheck{Status: healthDown, Detail: "no frame for more than " + maxAge.String()}
+	case h.Frozen:
+		rep.Frames = HealthCheck{Status: healthDegraded, Detail: "identical frames"}
+	}
+	if !h.LastFrame.IsZero() {
+		age := now.Sub(h.LastFrame).Seconds()
+		rep.LastFrameAgeSec = &age
+	}
+
+	rep.Status = healthOK
+	for _, c := range []HealthCheck{rep.Source, rep.Frames, rep.Model} {
+		if c.Status == healthDown {
+			rep.Status = healthDown
+			break
+		}
+		if c.Status == healthDegraded {
+			rep.Status = healthDegraded
+		}
+	}
+	return rep
+}
+
+// healthzHandler answers "ok" to plain probes. ?verbose=1 returns the
+// HealthReport, with 503 when a critical dependency is down.
+func healthzHandler(stats *Stats, maxAge time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("verbose") != "1" {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ok"))
+			return
+		}
+		rep := assessHealth(stats.Health(), time.Now(), maxAge)
+		body, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status := http.StatusOK
+		if rep.Status == healthDown {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		_, _ = w.Write(append(body, '\n'))
+	}
+}
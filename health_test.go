@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAssessHealth(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	fresh, old := now.Add(-time.Second), now.Add(-time.Minute)
+	healthy := detectorHealth{SourceOpen: true, ModelLoaded: true, LastFrame: fresh}
+	with := func(f func(h *detectorHealth)) detectorHealth {
+		h := healthy
+		f(&h)
+		return h
+	}
+	tests := []struct {
+		name                          string
+		h                             detectorHealth
+		status, source, frames, model string
+	}{
+		{"healthy", healthy, healthOK, healthOK, healthOK, healthOK},
+		{"source closed", with(func(h *detectorHealth) { h.SourceOpen = false }), healthDown, healthDown, healthOK, healthOK},
+		{"model not loaded", with(func(h *detectorHealth) { h.ModelLoaded = false }), healthDown, healthOK, healthOK, healthDown},
+		{"no frame yet", with(func(h *detectorHealth) { h.LastFrame = time.Time{} }), healthDegraded, healthOK, healthDegraded, healthOK},
+		{"stale frames", with(func(h *detectorHealth) { h.LastFrame = old }), healthDown, healthOK, healthDown, healthOK},
+		{"frozen", with(func(h *detectorHealth) { h.Frozen = true }), healthDegraded, healthOK, healthDegraded, healthOK},
+		{"frozen and stale", with(func(h *detectorHealth) { h.Frozen, h.LastFrame = true, old }), healthDown, healthOK, healthDown, healthOK},
+		{"paused", with(func(h *detectorHealth) { h.Paused, h.LastFrame = true, old }), healthOK, healthOK, healthOK, healthOK},
+		{"degraded and down", with(func(h *detectorHealth) { h.Frozen, h.ModelLoaded = true, false }), healthDown, healthOK, healthDegraded, healthDown},
+		{"down first", with(func(h *detectorHealth) { h.SourceOpen, h.LastFrame = false, time.Time{} }), healthDown, healthDown, healthDegraded, healthOK},
+		{"all down", detectorHealth{LastFrame: old}, healthDown, healthDown, healthDown, healthDown},
+	}
+	for _, tt := range tests {
+		rep := assessHealth(tt.h, now, 10*time.Second)
+		if rep.Status != tt.status || rep.Source.Status != tt.source || rep.Frames.Status != tt.frames || rep.Model.Status != tt.model {
+			t.Errorf("%s: %s (source %s, frames %s, model %s), want %s (%s, %s, %s)", tt.name,
+				rep.Status, rep.Source.Status, rep.Frames.Status, rep.Model.Status, tt.status, tt.source, tt.frames, tt.model)
+		}
+		if (rep.LastFrameAgeSec == nil) != tt.h.LastFrame.IsZero() {
+			t.Errorf("%s: last frame age %v", tt.name, rep.LastFrameAgeSec)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+/* -------------------------------- Highlights ------------------------------- */
+
+// highlightCodec is the FourCC of the highlight clips (Motion JPEG in AVI,
+// available in every OpenCV build).
+const highlightCodec = "MJPG"
+
+// detectionSetKey identifies who is in a frame: the track IDs when tracking
+// is on, otherwise only the number of faces since untracked IDs are just
+// row numbers.
+func detectionSetKey(dets []Detection) string {
+	ids := make([]int, 0, len(dets))
+	for _, d := range dets {
+		if d.UUID == "" {
+			return "n=" + strconv.Itoa(len(dets))
+		}
+		ids = append(ids, d.ID)
+	}
+	slices.Sort(ids)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return "ids=" + strings.Join(parts, ",")
+}
+
+// highlightSegmenter decides which frames belong to the highlight reel: a
+// frame whose detection set differs from the previous one, plus post frames
+// after it. The pre frames before a segment are kept by the caller.
+type highlightSegmenter struct {
+	post      int
+	last      string
+	seen      bool
+	remaining int // frames still to write in the open segment
+}
+
+// Observe records the detection set of the next frame. write reports
+// whether the frame belongs to the reel, start whether it opens a new
+// segment (the pre-roll goes first).
+func (s *highlightSegmenter) Observe(key string) (write, start bool) {
+	changed := s.seen && key != s.last
+	s.seen, s.last = true, key
+	if changed {
+		start = s.remaining == 0
+		s.remaining = s.post + 1
+	}
+	if s.remaining == 0 {
+		return false, false
+	}
+	s.remaining--
+	return true, start
+}
+
+// Highlights records annotated frames around every change of the detection
+// set (a face entering or leaving) into one clip per segment, instead of
+// continuous recording. It is fed by the detector loop and outlives
+// detector restarts.
+type Highlights struct {
+	dir  string
+	fps  float64
+	pre  int
+	seg  highlightSegmenter
+	ring []heldFrame // last pre frames not written, oldest first
+
+	label *labelTemplate // text above each box, nil = default
+
+	writer *gocv.VideoWriter // open segment, nil between segments
+	size   [2]int            // frame size of the open segment
+	path   string
+}
+
+type heldFrame struct {
+	at  time.Time
+	img gocv.Mat
+}
+
+// NewHighlights writes clips into dir, creating it if needed. fps is the
+// playback rate of the clips, pre and post the frames kept before and after
+// each change, label the text drawn above the boxes (nil = default).
+func NewHighlights(dir string, fps float64, pre, post int, label *labelTemplate) (*Highlights, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Highlights{dir: dir, fps: fps, pre: max(pre, 0), seg: highlightSegmenter{post: max(post, 0)}, label: label}, nil
+}
+
+// Observe feeds one published frame and its snapshot.
+func (h *Highlights) Observe(snap Snapshot, img gocv.Mat) {
+	write, start := h.seg.Observe(detectionSetKey(snap.Detections))
+	if !write {
+		h.closeSegment()
+		if h.pre > 0 {
+			h.keep(heldFrame{at: snap.GeneratedAt, img: h.annotate(snap, img)})
+		}
+		return
+	}
+	frame := h.annotate(snap, img)
+	defer frame.Close()
+	if start || h.writer == nil {
+		if err := h.openSegment(snap.GeneratedAt, frame.Cols(), frame.Rows()); err != nil {
+			log.Printf("[highlights] %v", err)
+			return
+		}
+		for _, f := range h.ring {
+			h.write(f.img)
+			f.img.Close()
+		}
+		h.ring = h.ring[:0]
+	}
+	h.write(frame)
+}
+
+// Close ends the open segment and releases the pre-roll.
+func (h *Highlights) Close() {
+	h.closeSegment()
+	for _, f := range h.ring {
+		f.img.Close()
+	}
+	h.ring = nil
+}
+
+func (h *Highlights) annotate(snap Snapshot, img gocv.Mat) gocv.Mat {
+	frame := img.Clone()
+	drawOverlay(&frame, snap.Detections, 0, h.label)
+	return frame
+}
+
+// keep adds frame to the pre-roll, evicting the oldest one.
+func (h *Highlights) keep(frame heldFrame) {
+	if len(h.ring) == h.pre {
+		h.evict()
+	}
+	h.ring = append(h.ring, frame)
+}
+
+// memoryUsage, oldest and evict make the pre-roll a memoryHolder: under
+// memory pressure the next clip starts with fewer frames.
+func (h *Highlights) memoryUsage() int64 {
+	var n int64
+	for _, f := range h.ring {
+		n += matBytes(f.img)
+	}
+	return n
+}
+
+func (h *Highlights) oldest() (time.Time, bool) {
+	if len(h.ring) == 0 {
+		return time.Time{}, false
+	}
+	return h.ring[0].at, true
+}
+
+func (h *Highlights) evict() int64 {
+	if len(h.ring) == 0 {
+		return 0
+	}
+	freed := matBytes(h.ring[0].img)
+	h.ring[0].img.Close()
+	h.ring = append(h.ring[:0], h.ring[1:]...)
+	return freed
+}
+
+func (h *Highlights) openSegment(t time.Time, w, ht int) error {
+	h.closeSegment()
+	path := filepath.Join(h.dir, "highlight-"+t.Format("20060102-150405.000")+".avi")
+	vw, err := gocv.VideoWriterFile(path, highlightCodec, h.fps, w, ht, true)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	if !vw.IsOpened() {
+		vw.Close()
+		return fmt.Errorf("open %s: no %s encoder", path, highlightCodec)
+	}
+	h.writer, h.size, h.path = vw, [2]int{w, ht}, path
+	return nil
+}
+
+// write appends frame to the open segment; frames of another size (the
+// source changed resolution) are skipped since a clip has a fixed size.
+func (h *Highlights) write(frame gocv.Mat) {
+	if h.writer == nil || frame.Cols() != h.size[0] || frame.Rows() != h.size[1] {
+		return
+	}
+	if err := h.writer.Write(frame); err != nil {
+		log.Printf("[highlights] write %s: %v", h.path, err)
+	}
+}
+
+func (h *Highlights) closeSegment() {
+	if h.writer == nil {
+		return
+	}
+	if err := h.writer.Close(); err != nil {
+		log.Printf("[highlights] close %s: %v", h.path, err)
+	} else {
+		log.Printf("[highlights] saved %s", h.path)
+	}
+	h.writer = nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+func TestHighlightSegmenter(t *testing.T) {
+	const (
+		empty = "ids="
+		one   = "ids=1"
+		two   = "ids=1,2"
+	)
+	tests := []struct {
+		name  string
+		post  int
+		keys  []string
+		write []int // frames written
+		start []int // frames opening a segment
+	}{
+		{"static scene", 2, []string{empty, empty, empty, empty, empty}, nil, nil},
+		{"static with a face", 2, []string{one, one, one, one}, nil, nil},
+		{"enter", 2, []string{empty, empty, one, one, one, one, one}, []int{2, 3, 4}, []int{2}},
+		{"enter then leave", 1, []string{empty, one, one, one, empty, empty, empty}, []int{1, 2, 4, 5}, []int{1, 4}},
+		{"leave within the padding", 2, []string{empty, one, empty, empty, empty, empty}, []int{1, 2, 3, 4}, []int{1}},
+		{"second face", 0, []string{one, one, two, two}, []int{2}, []int{2}},
+	}
+	for _, tt := range tests {
+		s := highlightSegmenter{post: tt.post}
+		var write, start []int
+		for i, key := range tt.keys {
+			w, st := s.Observe(key)
+			if w {
+				write = append(write, i)
+			}
+			if st {
+				start = append(start, i)
+			}
+		}
+		if !reflect.DeepEqual(write, tt.write) || !reflect.DeepEqual(start, tt.start) {
+			t.Errorf("%s: wrote %v starting %v, want %v starting %v", tt.name, write, start, tt.write, tt.start)
+		}
+	}
+
+	if got := detectionSetKey([]Detection{{ID: 2, UUID: "b"}, {ID: 1, UUID: "a"}}); got != two {
+		t.Errorf("tracked key %q, want %q", got, two)
+	}
+	if got := detectionSetKey([]Detection{{ID: 1}, {ID: 2}}); got != "n=2" {
+		t.Errorf("untracked key %q, want n=2", got)
+	}
+}
+
+// TestHighlightsPreRoll checks the frames held for the next segment: the
+// last pre frames of the static scene.
+func TestHighlightsPreRoll(t *testing.T) {
+	h, err := NewHighlights(t.TempDir(), 10, 3, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	img := gocv.NewMatWithSize(20, 20, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		h.Observe(Snapshot{GeneratedAt: t0.Add(time.Duration(i) * time.Second)}, img)
+	}
+	var held []time.Time
+	for _, f := range h.ring {
+		held = append(held, f.at)
+	}
+	want := []time.Time{t0.Add(2 * time.Second), t0.Add(3 * time.Second), t0.Add(4 * time.Second)}
+	if !reflect.DeepEqual(held, want) {
+		t.Errorf("pre-roll %v, want the last 3 frames %v", held, want)
+	}
+	if h.writer != nil {
+		t.Errorf("a segment was opened for a static scene")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+/* --------------------------------- Ingest ---------------------------------- */
+
+// ingestSource is the snapshot source of frames posted to /ingest.
+const ingestSource = "ingest"
+
+// Ingest carries frames posted to /ingest to the detector loop, which runs
+// them through the same net between ticks so it is never used concurrently.
+// It outlives detector restarts.
+type Ingest struct {
+	requests chan ingestRequest
+	maxBytes int64
+}
+
+type ingestRequest struct {
+	img    gocv.Mat
+	update bool // also publish the result to the store
+	reply  chan ingestResult
+}
+
+type ingestResult struct {
+	snap Snapshot
+	err  error
+}
+
+// NewIngest accepts posted images of up to maxBytes (default 10 MiB).
+func NewIngest(maxBytes int64) *Ingest {
+	if maxBytes <= 0 {
+		maxBytes = 10 << 20
+	}
+	return &Ingest{requests: make(chan ingestRequest), maxBytes: maxBytes}
+}
+
+// Detect hands img to the detector loop and waits for its snapshot. img is
+// only read until Detect returns.
+func (in *Ingest) Detect(ctx context.Context, img gocv.Mat, update bool) (Snapshot, error) {
+	req := ingestRequest{img: img, update: update, reply: make(chan ingestResult, 1)}
+	select {
+	case in.requests <- req:
+	case <-ctx.Done():
+		return Snapshot{}, ctx.Err()
+	}
+	select {
+	case res := <-req.reply:
+		return res.snap, res.err
+	case <-ctx.Done():
+		// The loop may still be reading img: wait for it before the caller
+		// releases the Mat.
+		res := <-req.reply
+		return res.snap, ctx.Err()
+	}
+}
+
+// DetectImage runs detection on img instead of a captured frame. The image
+// is copied, so LastFrame returns it like any captured frame.
+func (d *DNNDetector) DetectImage(img gocv.Mat) (string, []Detection, int, int, error) {
+	if img.Empty() {
+		d.hasFrame = false
+		return ingestSource, nil, 0, 0, ErrEmptyFrame
+	}
+	_ = img.CopyTo(&d.frame)
+	d.hasFrame = true
+	_, dets, w, h, err := d.detectFrame()
+	return ingestSource, dets, w, h, err
+}
+
+// ingestContentTypes are the accepted image types.
+var ingestContentTypes = map[string]bool{"image/jpeg": true, "image/png": true}
+
+// ingestHandler serves POST /ingest: the body is a JPEG or PNG image, the
+// response the snapshot of its detections. ?update=1 also publishes that
+// snapshot to the store, as if the frame had been captured.
+func ingestHandler(ingest *Ingest, store *FaceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || !ingestContentTypes[mt] {
+			http.Error(w, "content type must be image/jpeg or image/png", http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, ingest.maxBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "image too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "cannot read body", http.StatusBadRequest)
+			return
+		}
+		img, err := gocv.IMDecode(body, gocv.IMReadColor)
+		if err != nil || img.Empty() {
+			img.Close()
+			http.Error(w, "cannot decode image", http.StatusBadRequest)
+			return
+		}
+		defer img.Close()
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		snap, err := ingest.Detect(ctx, img, r.URL.Query().Get("update") == "1")
+		switch {
+		case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+			http.Error(w, "detector not running", http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			http.Error(w, "detection failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, store.redact(snap), true)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pngFixture encodes a w x h grey PNG.
+func pngFixture(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestIngestHandler(t *testing.T) {
+	net := &fakeNet{faces: [][4]float32{{0.1, 0.2, 0.3, 0.6}}}
+	cfg := DetectorConfig{Interval: time.Hour}
+	d := newFakeDetector(t, cfg, &fakeLoader{next: []*fakeNet{net}})
+	ingest := NewIngest(64 << 10)
+	p := &Pipeline{Store: NewFaceStore(), Stats: NewStats(), Ingest: ingest}
+	stop := startLoop(d, cfg, p)
+	defer stop()
+
+	post := func(query, contentType string, body []byte) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/ingest"+query, bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		ingestHandler(ingest, p.Store)(w, r)
+		return w
+	}
+	fixture := pngFixture(t, 200, 100)
+
+	// Detected like a captured frame, not published unless asked.
+	w := post("", "image/png", fixture)
+	if w.Code != http.StatusOK {
+		t.Fatalf("fixture: %d %s", w.Code, w.Body)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &snap); err != nil {
+		t.Fatal(err)
+	}
+	want := Rect{X: 20, Y: 20, Width: 40, Height: 40}
+	if snap.Source != ingestSource || snap.FrameWidth != 200 || snap.FrameHeight != 100 ||
+		len(snap.Detections) != 1 || snap.Detections[0].BBox != want {
+		t.Errorf("snapshot %+v, want one face at %v in the 200x100 ingest frame", snap, want)
+	}
+	_, ver := p.Store.Get()
+	post("", "image/png", fixture)
+	if _, v := p.Store.Get(); v != ver {
+		t.Errorf("store moved to version %d without ?update=1", v)
+	}
+	if w := post("?update=1", "image/png; charset=binary", fixture); w.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", w.Code, w.Body)
+	}
+	if s, v := p.Store.Get(); v != ver+1 || s.Source != ingestSource || len(s.Detections) != 1 {
+		t.Errorf("after ?update=1: version %d source %q, want %d %q", v, s.Source, ver+1, ingestSource)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		code        int
+	}{
+		{"malformed", "image/jpeg", []byte("not a jpeg at all"), http.StatusBadRequest},
+		{"truncated", "image/png", fixture[:len(fixture)/2], http.StatusBadRequest},
+		{"empty", "image/png", nil, http.StatusBadRequest},
+		{"not an image type", "application/json", fixture, http.StatusUnsupportedMediaType},
+		{"no content type", "", fixture, http.StatusUnsupportedMediaType},
+		{"too large", "image/png", bytes.Repeat([]byte{0}, 64<<10+1), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		if w := post("", tt.contentType, tt.body); w.Code != tt.code {
+			t.Errorf("%s: %d %s, want %d", tt.name, w.Code, strings.TrimSpace(w.Body.String()), tt.code)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/ingest", nil)
+	w = httptest.NewRecorder()
+	ingestHandler(ingest, p.Store)(w, r)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("GET: %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	// Nobody serving the requests: the client isn't left hanging.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r = httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(fixture)).WithContext(ctx)
+	r.Header.Set("Content-Type", "image/png")
+	w = httptest.NewRecorder()
+	ingestHandler(NewIngest(0), p.Store)(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("detector not running: %d, want 503", w.Code)
+	}
+}
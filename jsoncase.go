@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+/* ------------------------------- JSON casing ------------------------------- */
+
+// jsonCase is the casing of the field names of the snapshots served by
+// /faces and /faces/stream: snake_case (the default, as declared in the
+// struct tags) or camelCase (FACE_JSON_CASE, or ?case= per request).
+type jsonCase int
+
+const (
+	caseSnake jsonCase = iota
+	caseCamel
+)
+
+func parseJSONCase(s string) (jsonCase, error) {
+	switch s {
+	case "snake":
+		return caseSnake, nil
+	case "camel":
+		return caseCamel, nil
+	}
+	return caseSnake, fmt.Errorf("want snake or camel, got %q", s)
+}
+
+// forRequest returns the casing asked by ?case=, c without it.
+func (c jsonCase) forRequest(q url.Values) (jsonCase, error) {
+	v := q.Get("case")
+	if v == "" {
+		return c, nil
+	}
+	rc, err := parseJSONCase(v)
+	if err != nil {
+		return c, fmt.Errorf("invalid case: %w", err)
+	}
+	return rc, nil
+}
+
+// wrap returns v encoded with c's field names.
+func (c jsonCase) wrap(v any) any {
+	if c == caseCamel {
+		return camelJSON{v}
+	}
+	return v
+}
+
+// camelJSON encodes v with its field names in camelCase. The encoding is
+// rewritten rather than declared twice, so every nested type (Detection,
+// Rect, Point, ...) and custom marshaler follows without a second set of
+// tags.
+type camelJSON struct{ v any }
+
+func (c camelJSON) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(c.v)
+	if err != nil {
+		return nil, err
+	}
+	return camelKeys(data), nil
+}
+
+// dataKeyed are the fields holding maps: their keys are data (classifier
+// labels), not field names, and are kept as is.
+var dataKeyed = map[string]bool{"attributes": true}
+
+// camelKeys rewrites the object keys of a valid JSON document from
+// snake_case to camelCase, leaving the values and the layout alone.
+func camelKeys(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	var data []bool // per open container: its keys are data
+	key := ""       // the key whose value comes next
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch c {
+		case '"':
+			end := stringEnd(src, i)
+			j := end
+			for j < len(src) && isJSONSpace(src[j]) {
+				j++
+			}
+			if j < len(src) && src[j] == ':' { // a key
+				k := string(src[i+1 : end-1])
+				key = k
+				if len(data) == 0 || !data[len(data)-1] {
+					k = snakeToCamel(k)
+				}
+				out = append(out, '"')
+				out = append(out, k...)
+				out = append(out, '"')
+			} else {
+				out = append(out, src[i:end]...)
+				key = ""
+			}
+			i = end
+			continue
+		case '{', '[':
+			data = append(data, c == '{' && dataKeyed[key])
+			key = ""
+		case '}', ']':
+			if len(data) > 0 {
+				data = data[:len(data)-1]
+			}
+		case ':':
+		default:
+			if !isJSONSpace(c) {
+				key = ""
+			}
+		}
+		out = append(out, c)
+		i++
+	}
+	return out
+}
+
+// stringEnd returns the index just past the string literal starting at i.
+func stringEnd(src []byte, i int) int {
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// snakeToCamel turns "frame_width" into "frameWidth".
+func snakeToCamel(s string) string {
+	out := make([]byte, 0, len(s))
+	upper := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && len(out) > 0 {
+			upper = true
+			continue
+		}
+		if upper && 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		out = append(out, c)
+	}
+	return string(out)
+}
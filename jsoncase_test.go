@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recase renames the object keys of a decoded JSON document with f, except
+// inside data-keyed maps.
+func recase(v any, f func(string) string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			if dataKeyed[k] {
+				out[f(k)] = e
+				continue
+			}
+			out[f(k)] = recase(e, f)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = recase(e, f)
+		}
+		return out
+	}
+	return v
+}
+
+func TestJSONCaseRoundTrip(t *testing.T) {
+	snap := fullSnapshot()
+	snap.Source = `rtsp://cam/"frame_width": 1`
+	snap.Detections[0].Attributes = map[string]float64{"has_glasses": 1}
+
+	decode := func(c jsonCase) (map[string]any, string) {
+		data, err := json.Marshal(c.wrap(snap))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]any
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+		return m, string(data)
+	}
+	snake, _ := decode(caseSnake)
+	camel, raw := decode(caseCamel)
+
+	if !reflect.DeepEqual(recase(snake, snakeToCamel), camel) {
+		t.Errorf("camelCase encoding doesn't carry the same data:\n%s", raw)
+	}
+	for _, key := range []string{`"frameWidth":`, `"generatedAt":`, `"frameIntervalMs":`, `"rawScore":`, `"approxRangeM":`, `"trackConfidence":`, `"has_glasses":`, `"frame_width\": 1"`} {
+		if !strings.Contains(raw, key) {
+			t.Errorf("camelCase encoding lacks %s:\n%s", key, raw)
+		}
+	}
+	if _, ok := snake["frame_width"]; !ok {
+		t.Errorf("snake_case is not the default")
+	}
+
+	// Decoding the camelCase document into the snake_case types, after
+	// renaming its keys back, gives back the snapshot.
+	back, err := json.Marshal(recase(camel, camelToSnake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Snapshot
+	if err := json.Unmarshal(back, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := snap.clone()
+	want.Detections[0].Timestamp, got.Detections[0].Timestamp = want.Detections[0].Timestamp.UTC(), got.Detections[0].Timestamp.UTC()
+	want.GeneratedAt, got.GeneratedAt = want.GeneratedAt.UTC(), got.GeneratedAt.UTC()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip\n got %+v\nwant %+v", got, want)
+	}
+
+	for _, tt := range []struct {
+		q    string
+		want jsonCase
+		err  bool
+	}{{"", caseSnake, false}, {"case=camel", caseCamel, false}, {"case=snake", caseSnake, false}, {"case=kebab", caseSnake, true}} {
+		q, _ := url.ParseQuery(tt.q)
+		if got, err := caseSnake.forRequest(q); got != tt.want || (err != nil) != tt.err {
+			t.Errorf("forRequest(%q) = %v, %v", tt.q, got, err)
+		}
+	}
+}
+
+// camelToSnake turns "frameWidth" into "frame_width".
+func camelToSnake(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if 'A' <= c && c <= 'Z' {
+			b.WriteByte('_')
+			c += 'a' - 'A'
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+//go:build kafka
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+/* --------------------------------- Kafka ----------------------------------- */
+
+// kafkaPublisher writes snapshots to a Kafka topic. Only built with
+// `-tags kafka` so default builds don't pull the client in.
+type kafkaPublisher struct {
+	w *kafka.Writer
+}
+
+func newKafkaPublisher(brokers []string, topic string) (Publisher, error) {
+	return &kafkaPublisher{w: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{}, // same key (source) -> same partition
+		BatchTimeout: 10 * time.Millisecond,
+		RequiredAcks: kafka.RequireOne,
+	}}, nil
+}
+
+func (k *kafkaPublisher) Publish(ctx context.Context, key, value []byte) error {
+	return k.w.WriteMessages(ctx, kafka.Message{Key: key, Value: value})
+}
+
+func (k *kafkaPublisher) Close() error {
+	return k.w.Close()
+}
@@ -0,0 +1,9 @@
+//go:build !kafka
+
+package main
+
+import "errors"
+
+func newKafkaPublisher(brokers []string, topic string) (Publisher, error) {
+	return nil, errors.New("built without Kafka support, rebuild with -tags kafka")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/* ------------------------------ Overlay labels ----------------------------- */
+
+// labelFields renders the detection fields a label template may reference.
+// Optional fields render blank when unset, e.g. {zone} outside every zone or
+// {dwell} without tracking.
+var labelFields = map[string]func(d *Detection) string{
+	"id":     func(d *Detection) string { return strconv.Itoa(d.ID) },
+	"uuid":   func(d *Detection) string { return d.UUID },
+	"score":  func(d *Detection) string { return strconv.FormatFloat(d.Score, 'f', 2, 64) },
+	"x":      func(d *Detection) string { return strconv.Itoa(d.BBox.X) },
+	"y":      func(d *Detection) string { return strconv.Itoa(d.BBox.Y) },
+	"width":  func(d *Detection) string { return strconv.Itoa(d.BBox.Width) },
+	"height": func(d *Detection) string { return strconv.Itoa(d.BBox.Height) },
+	"zone":   func(d *Detection) string { return d.Zone },
+	"count": func(d *Detection) string {
+		if d.Count == 0 {
+			return ""
+		}
+		return strconv.Itoa(d.Count)
+	},
+	"quality": func(d *Detection) string {
+		if d.Quality == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(d.Quality, 'f', 2, 64)
+	},
+	"range": func(d *Detection) string {
+		if d.ApproxRangeM == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(d.ApproxRangeM, 'f', 1, 64) + "m"
+	},
+	"dwell": func(d *Detection) string {
+		if d.UUID == "" {
+			return ""
+		}
+		return strconv.FormatFloat(d.DwellS, 'f', 0, 64) + "s"
+	},
+}
+
+// labelTemplate is the text drawn above each box: literal text with {field}
+// placeholders, "{{" and "}}" standing for braces. The nil template draws
+// "#{id} {score}".
+type labelTemplate struct {
+	parts []labelPart
+}
+
+type labelPart struct {
+	text  string
+	field func(d *Detection) string // nil for literal text
+}
+
+// parseLabelTemplate compiles s, rejecting unknown fields so typos show at
+// startup rather than as blank labels.
+func parseLabelTemplate(s string) (*labelTemplate, error) {
+	t := &labelTemplate{}
+	var lit strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '{' && i+1 < len(s) && s[i+1] == '{', c == '}' && i+1 < len(s) && s[i+1] == '}':
+			lit.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed { at %d in %q", i, s)
+			}
+			name := strings.TrimSpace(s[i+1 : i+end])
+			field, ok := labelFields[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown field {%s} in %q", name, s)
+			}
+			if lit.Len() > 0 {
+				t.parts = append(t.parts, labelPart{text: lit.String()})
+				lit.Reset()
+			}
+			t.parts = append(t.parts, labelPart{field: field})
+			i += end
+		case c == '}':
+			return nil, fmt.Errorf("unexpected } at %d in %q", i, s)
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	if lit.Len() > 0 {
+		t.parts = append(t.parts, labelPart{text: lit.String()})
+	}
+	if len(t.parts) == 0 {
+		return nil, errors.New("empty label template")
+	}
+	return t, nil
+}
+
+// render returns the label of d. Blank fields leave no doubled or trailing
+// spaces behind.
+func (t *labelTemplate) render(d Detection) string {
+	if t == nil {
+		return fmt.Sprintf("#%d %.2f", d.ID, d.Score)
+	}
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.field != nil {
+			b.WriteString(p.field(&d))
+		} else {
+			b.WriteString(p.text)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestLabelTemplate(t *testing.T) {
+	full := Detection{ID: 7, UUID: "u-7", Score: 0.934, BBox: Rect{X: 10, Y: 20, Width: 30, Height: 40}, Zone: "door", Count: 2, Quality: 0.8, ApproxRangeM: 2.46, DwellS: 12.4}
+	bare := Detection{ID: 3, Score: 0.5}
+	tests := []struct {
+		tmpl       string
+		full, bare string
+	}{
+		{"#{id} {score}", "#7 0.93", "#3 0.50"},
+		{"{zone} {dwell}", "door 12s", ""},
+		{"face {id} {zone} ({range})", "face 7 door (2.5m)", "face 3 ()"},
+		{"{ count }x {quality}", "2x 0.80", "x"},
+		{"{x},{y} {width}x{height}", "10,20 30x40", "0,0 0x0"},
+		{"{{{id}}}", "{7}", "{3}"},
+	}
+	for _, tt := range tests {
+		lt, err := parseLabelTemplate(tt.tmpl)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.tmpl, err)
+		}
+		if got := lt.render(full); got != tt.full {
+			t.Errorf("%q with all fields = %q, want %q", tt.tmpl, got, tt.full)
+		}
+		if got := lt.render(bare); got != tt.bare {
+			t.Errorf("%q without optional fields = %q, want %q", tt.tmpl, got, tt.bare)
+		}
+	}
+	if got := (*labelTemplate)(nil).render(full); got != "#7 0.93" {
+		t.Errorf("default label = %q", got)
+	}
+
+	for _, bad := range []string{"", "{id", "{name}", "id}"} {
+		if _, err := parseLabelTemplate(bad); err == nil {
+			t.Errorf("%q parsed, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"time"
+)
+
+/* ---------------------------- Latency scheduler ---------------------------- */
+
+// LatencyTarget configures the latency scheduler (FACE_LATENCY_*): instead
+// of a fixed FACE_INTERVAL, the detector loop aims for detections no older
+// than Target, tuning its interval within [MinInterval, MaxInterval] and,
+// when even the shortest interval can't meet it, shrinking the network
+// input down to MinInput px on its short side.
+type LatencyTarget struct {
+	Target      time.Duration
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	MinInput    int // 0 = never downscale
+}
+
+// parseLatencyTarget validates the scheduler settings; a zero target turns
+// it off (nil).
+func parseLatencyTarget(target, minInterval, maxInterval time.Duration, minInput int) (*LatencyTarget, error) {
+	if target <= 0 {
+		return nil, nil
+	}
+	if minInterval <= 0 || maxInterval < minInterval {
+		return nil, fmt.Errorf("want 0 < min interval <= max interval, got %v and %v", minInterval, maxInterval)
+	}
+	if minInput < 0 {
+		return nil, fmt.Errorf("min input must be >= 0, got %d", minInput)
+	}
+	return &LatencyTarget{Target: target, MinInterval: minInterval, MaxInterval: maxInterval, MinInput: minInput}, nil
+}
+
+const (
+	latencyAlpha    = 0.3 // EWMA weight of each measured cycle
+	latencyCooldown = 5   // cycles between input size changes, to let the EWMA settle
+	latencyStep     = 0.9 // input scale factor per downscale step (inverse to upscale)
+	latencyHeadroom = 0.6 // upscale once the latency is below this share of the budget
+)
+
+// latencyScheduler is the controller of the latency scheduler. A detection
+// is at most one cycle (capture to publish) plus one interval old when it
+// is replaced, so the interval is what the smoothed cycle time leaves of the
+// target. Inference time grows with the input area: a cycle too slow for
+// the target at the shortest interval shrinks the input by latencyStep, and
+// one comfortably under it grows it back, up to the configured size.
+type latencyScheduler struct {
+	cfg      LatencyTarget
+	full     image.Point // configured input size
+	minScale float64
+
+	latency  float64 // EWMA of the cycle time, ns; 0 until the first cycle
+	interval time.Duration
+	scale    float64 // current input size / full
+	cooldown int
+}
+
+func newLatencyScheduler(cfg LatencyTarget, full image.Point, interval time.Duration) *latencyScheduler {
+	s := &latencyScheduler{cfg: cfg, full: full, minScale: 1, scale: 1}
+	s.interval = min(max(interval, cfg.MinInterval), cfg.MaxInterval)
+	if side := min(full.X, full.Y); cfg.MinInput > 0 && cfg.MinInput < side {
+		s.minScale = float64(cfg.MinInput) / float64(side)
+	}
+	return s
+}
+
+// Observe records the duration of a completed cycle and returns the
+// interval and input size to use from now on.
+func (s *latencyScheduler) Observe(cycle time.Duration) (time.Duration, image.Point) {
+	if s.latency == 0 {
+		s.latency = float64(cycle)
+	} else {
+		s.latency += latencyAlpha * (float64(cycle) - s.latency)
+	}
+	latency := time.Duration(s.latency)
+	s.interval = min(max(s.cfg.Target-latency, s.cfg.MinInterval), s.cfg.MaxInterval).Round(time.Millisecond)
+
+	if s.cooldown > 0 {
+		s.cooldown--
+		return s.interval, s.inputSize()
+	}
+	switch {
+	case latency+s.cfg.MinInterval > s.cfg.Target && s.scale > s.minScale:
+		s.scale = max(s.scale*latencyStep, s.minScale)
+		s.cooldown = latencyCooldown
+	case latency < time.Duration(latencyHeadroom*float64(s.cfg.Target)) && s.scale < 1:
+		s.scale = min(s.scale/latencyStep, 1)
+		s.cooldown = latencyCooldown
+	}
+	return s.interval, s.inputSize()
+}
+
+// inputSize is the full input size at the current scale, in even pixels.
+func (s *latencyScheduler) inputSize() image.Point {
+	if s.scale == 1 {
+		return s.full
+	}
+	even := func(v int) int { return max(2*int(math.Round(float64(v)*s.scale/2)), 2) }
+	return image.Pt(even(s.full.X), even(s.full.Y))
+}
+
+// Latency is the smoothed cycle time.
+func (s *latencyScheduler) Latency() time.Duration {
+	return time.Duration(s.latency)
+}
+
+// SetInputSize changes the network input size for the next frames. The
+// snapshots already published keep the previous meta.
+func (d *DNNDetector) SetInputSize(size image.Point) {
+	if size == d.inputSize {
+		return
+	}
+	d.inputSize = size
+	meta := *d.meta
+	meta.InputW, meta.InputH = size.X, size.Y
+	d.meta = &meta
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+// TestLatencySchedulerConverges drives the scheduler with a fake inferer
+// whose cycle time grows with the input area and alternates by ±10ms.
+func TestLatencySchedulerConverges(t *testing.T) {
+	target := LatencyTarget{Target: 150 * time.Millisecond, MinInterval: 10 * time.Millisecond, MaxInterval: time.Second, MinInput: 150}
+	full := image.Pt(300, 300)
+	tests := []struct {
+		name   string
+		cost   time.Duration // cycle time at the full input size
+		wantIn image.Point
+	}{
+		{"fast enough", 40 * time.Millisecond, full},
+		{"too slow", 200 * time.Millisecond, image.Pt(244, 244)}, // 0.81 * 300, rounded to even
+		{"hopeless", time.Second, image.Pt(150, 150)},            // floored at MinInput
+	}
+	for _, tt := range tests {
+		s := newLatencyScheduler(target, full, 500*time.Millisecond)
+		cycle := func(i int, size image.Point) time.Duration {
+			c := time.Duration(float64(tt.cost) * float64(size.X*size.Y) / float64(full.X*full.Y))
+			if i%2 == 0 {
+				return c + 10*time.Millisecond
+			}
+			return c - 10*time.Millisecond
+		}
+		size := full
+		var interval time.Duration
+		var e2e time.Duration
+		for i := range 200 {
+			c := cycle(i, size)
+			interval, size = s.Observe(c)
+			if i >= 150 {
+				e2e += c + interval
+			}
+		}
+		e2e /= 50
+		if size != tt.wantIn {
+			t.Errorf("%s: input %v, want %v", tt.name, size, tt.wantIn)
+		}
+		if tt.name == "hopeless" {
+			if interval != target.MinInterval {
+				t.Errorf("%s: interval %v, want the minimum", tt.name, interval)
+			}
+			continue
+		}
+		if diff := e2e - target.Target; diff < -5*time.Millisecond || diff > 5*time.Millisecond {
+			t.Errorf("%s: end-to-end latency %v, want ~%v", tt.name, e2e, target.Target)
+		}
+	}
+}
+
+func TestLatencySchedulerRecovers(t *testing.T) {
+	target := LatencyTarget{Target: 150 * time.Millisecond, MinInterval: 10 * time.Millisecond, MaxInterval: time.Second, MinInput: 100}
+	full := image.Pt(300, 300)
+	s := newLatencyScheduler(target, full, 100*time.Millisecond)
+	size := full
+	run := func(cost time.Duration) {
+		for range 200 {
+			_, size = s.Observe(time.Duration(float64(cost) * float64(size.X*size.Y) / float64(full.X*full.Y)))
+		}
+	}
+	run(400 * time.Millisecond) // loaded host
+	if size == full {
+		t.Fatalf("input %v under load, want downscaled", size)
+	}
+	run(50 * time.Millisecond) // load gone
+	if size != full {
+		t.Errorf("input %v once the load is gone, want %v", size, full)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+/* ------------------------------- Lifecycle --------------------------------- */
+
+// Lifecycle lets the process be drained or reconfigured from outside, either
+// through signals or through the authenticated /control endpoints.
+type Lifecycle struct {
+	// Reload re-reads the configuration and restarts the detector with it.
+	Reload func() error
+	// Playback controls file sources (seek, speed); nil disables /control/seek.
+	Playback *Playback
+
+	cancel context.CancelFunc
+	drain  atomic.Int64 // requested drain window in ns, 0 = server default
+}
+
+// NewLifecycle returns a Lifecycle and the context it cancels on shutdown.
+func NewLifecycle(parent context.Context) (*Lifecycle, context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	return &Lifecycle{cancel: cancel}, ctx
+}
+
+// Shutdown cancels the application context (detector and HTTP server),
+// giving in-flight requests up to drain to complete.
+func (l *Lifecycle) Shutdown(drain time.Duration) {
+	l.drain.Store(int64(drain))
+	l.cancel()
+}
+
+// Drain returns the drain window requested via Shutdown, or def if none.
+func (l *Lifecycle) Drain(def time.Duration) time.Duration {
+	if d := time.Duration(l.drain.Load()); d > 0 {
+		return d
+	}
+	return def
+}
+
+// reloadOnSIGHUP triggers lc.Reload on every SIGHUP until ctx is done.
+func reloadOnSIGHUP(ctx context.Context, lc *Lifecycle) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Printf("[reload] SIGHUP received")
+			if err := lc.Reload(); err != nil {
+				log.Printf("[reload] failed: %v", err)
+			}
+		}
+	}
+}
+
+// controlHandler serves POST /control/shutdown?drain=5s, POST /control/reload
+// and POST /control/seek?msec=|frame=&speed=, all requiring
+// "Authorization: Bearer <token>".
+func controlHandler(token string, lc *Lifecycle) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/control/shutdown":
+			var drain time.Duration
+			if v := r.URL.Query().Get("drain"); v != "" {
+				d, err := time.ParseDuration(v)
+				if err != nil || d <= 0 {
+					http.Error(w, "invalid drain duration", http.StatusBadRequest)
+					return
+				}
+				drain = d
+			}
+			log.Printf("[control] shutdown requested by %s (drain=%v)", r.RemoteAddr, drain)
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte("shutting down\n"))
+			lc.Shutdown(drain)
+		case "/control/reload":
+			if lc.Reload == nil {
+				http.Error(w, "reload unsupported", http.StatusNotImplemented)
+				return
+			}
+			log.Printf("[control] reload requested by %s", r.RemoteAddr)
+			if err := lc.Reload(); err != nil {
+				http.Error(w, "reload failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write([]byte("reloaded\n"))
+		case "/control/seek":
+			if lc.Playback == nil {
+				http.Error(w, "seek unsupported", http.StatusNotImplemented)
+				return
+			}
+			req, speed, err := parseSeekQuery(r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if req != nil {
+				ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+				err := lc.Playback.Seek(ctx, *req)
+				cancel()
+				switch {
+				case errors.Is(err, errNotSeekable):
+					http.Error(w, err.Error(), http.StatusConflict)
+					return
+				case err != nil:
+					http.Error(w, "detector not running", http.StatusServiceUnavailable)
+					return
+				}
+			}
+			if speed > 0 {
+				lc.Playback.SetSpeed(speed)
+			}
+			log.Printf("[control] seek requested by %s (%s)", r.RemoteAddr, r.URL.RawQuery)
+			_, _ = w.Write([]byte("ok\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// authorized reports whether r carries "Authorization: Bearer <token>".
+func authorized(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+/* ----------------------------- Config reload ------------------------------- */
+
+// loadEnvFile sets the process environment from a KEY=VALUE file. Blank
+// lines and lines starting with # are ignored. Keys removed from the file
+// keep their previous value.
+func loadEnvFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return fmt.Errorf("%s:%d: want KEY=VALUE", path, n)
+		}
+		if err := os.Setenv(strings.TrimSpace(k), strings.Trim(strings.TrimSpace(v), `"`)); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// detectorSupervisor owns the running detector loop so it can be restarted
+// with a new configuration.
+type detectorSupervisor struct {
+	ctx      context.Context
+	pipeline *Pipeline
+
+	mu     sync.Mutex
+	cfg    DetectorConfig
+	cancel context.CancelFunc
+	done   <-chan struct{}
+}
+
+// Start launches the detector loop with cfg.
+func (s *detectorSupervisor) Start(cfg DetectorConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.start(cfg)
+}
+
+func (s *detectorSupervisor) start(cfg DetectorConfig) error {
+	ctx, cancel := context.WithCancel(s.ctx)
+	done, err := StartDetectorLoop(ctx, cfg, s.pipeline)
+	if err != nil {
+		cancel()
+		return err
+	}
+	s.cfg, s.cancel, s.done = cfg, cancel, done
+	return nil
+}
+
+// Reload stops the current loop and starts a new one with cfg. The capture
+// device is released first since most cameras can't be opened twice. If cfg
+// fails to initialize, the previous configuration is restored. Like Restart,
+// it gives up with errDetectorWedged if the old loop doesn't stop within
+// grace.
+func (s *detectorSupervisor) Reload(cfg DetectorConfig, grace time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancel()
+	select {
+	case <-s.done:
+	case <-time.After(grace):
+		return errDetectorWedged
+	}
+	if err := s.start(cfg); err != nil {
+		log.Printf("[reload] new detector config failed (%v), restoring previous", err)
+		if restoreErr := s.start(s.cfg); restoreErr != nil {
+			return errors.Join(err, restoreErr)
+		}
+		return err
+	}
+	log.Printf("[reload] detector restarted with new config")
+	return nil
+}
+
+// errDetectorWedged means the detector loop ignored cancellation, typically
+// because it is stuck inside a capture read.
+var errDetectorWedged = errors.New("detector loop did not stop")
+
+// detectorStopGrace is how long a reload or restart waits for the old
+// detector loop to stop.
+const detectorStopGrace = 5 * time.Second
+
+// Restart stops the current loop and starts a new one with the same config,
+// reopening the source. It gives up with errDetectorWedged if the old loop
+// doesn't stop within grace.
+func (s *detectorSupervisor) Restart(grace time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancel()
+	select {
+	case <-s.done:
+	case <-time.After(grace):
+		return errDetectorWedged
+	}
+	return s.start(s.cfg)
+}
+
+// Paused reports whether the detector is paused on purpose.
+func (s *detectorSupervisor) Paused() bool {
+	return s.pipeline.Pause.Paused()
+}
+
+// Wait blocks until the current detector loop has stopped.
+func (s *detectorSupervisor) Wait() {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+	<-done
+}
+
+/* -------------------------------- Watchdog --------------------------------- */
+
+// exitDetectorWedged is the process exit code used when the detector hangs
+// and can't be restarted in-process, so an orchestrator restarts us.
+const exitDetectorWedged = 4
+
+// watchedDetector is what the watchdog checks and restarts.
+type watchedDetector interface {
+	Paused() bool
+	Restart(grace time.Duration) error
+}
+
+// watchDetector restarts the detector when the store hasn't received a new
+// snapshot for timeout (e.g. a capture read that never returns). If the
+// stuck loop can't be stopped, the process exits with exitDetectorWedged.
+// A paused detector publishes nothing on purpose, so the deadline only runs
+// while it is active.
+func watchDetector(ctx context.Context, store *FaceStore, det watchedDetector, timeout time.Duration) {
+	tick := time.NewTicker(max(timeout/4, 10*time.Millisecond))
+	defer tick.Stop()
+
+	_, lastVer := store.Get()
+	lastChange := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		if _, ver := store.Get(); ver != lastVer {
+			lastVer, lastChange = ver, time.Now()
+			continue
+		}
+		if det.Paused() {
+			lastChange = time.Now()
+			continue
+		}
+		stalled := time.Since(lastChange)
+		if stalled < timeout {
+			continue
+		}
+		log.Printf("[watchdog] no snapshot for %v, restarting detector", stalled.Round(time.Second))
+		switch err := det.Restart(detectorStopGrace); {
+		case errors.Is(err, errDetectorWedged):
+			log.Printf("[watchdog] detector is wedged, exiting")
+			os.Exit(exitDetectorWedged)
+		case err != nil:
+			log.Printf("[watchdog] restart failed: %v", err)
+		default:
+			log.Printf("[watchdog] detector restarted")
+		}
+		lastChange = time.Now()
+	}
+}
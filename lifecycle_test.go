@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRestarter stands for the supervisor of a detector; restart, if set,
+// runs on every Restart.
+type fakeRestarter struct {
+	paused   atomic.Bool
+	restarts atomic.Int32
+	restart  func()
+}
+
+func (f *fakeRestarter) Paused() bool { return f.paused.Load() }
+
+func (f *fakeRestarter) Restart(time.Duration) error {
+	f.restarts.Add(1)
+	if f.restart != nil {
+		f.restart()
+	}
+	return nil
+}
+
+// startWatchdog runs watchDetector with a 40ms timeout, checked every 10ms,
+// until the returned func is called.
+func startWatchdog(store *FaceStore, det watchedDetector) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		watchDetector(ctx, store, det, 40*time.Millisecond)
+		close(stopped)
+	}()
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
+func TestWatchDetectorHungSource(t *testing.T) {
+	store := NewFaceStore()
+	store.Set(Snapshot{Frame: 1}) // then the source hangs
+
+	// The restarted detector reads frames again.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	det := &fakeRestarter{}
+	det.restart = func() {
+		go func() {
+			for frame := int64(2); ctx.Err() == nil; frame++ {
+				store.Set(Snapshot{Frame: frame})
+				time.Sleep(5 * time.Millisecond)
+			}
+		}()
+	}
+	stop := startWatchdog(store, det)
+	defer stop()
+
+	waitSnapshot(t, store, func(s Snapshot) bool { return s.Frame >= 20 })
+	if n := det.restarts.Load(); n != 1 {
+		t.Fatalf("%d restarts, want 1: the recovered detector keeps publishing", n)
+	}
+}
+
+func TestWatchDetectorPaused(t *testing.T) {
+	store := NewFaceStore()
+	det := &fakeRestarter{}
+	det.paused.Store(true)
+	stop := startWatchdog(store, det)
+
+	// Several timeouts without a single snapshot.
+	time.Sleep(200 * time.Millisecond)
+	if n := det.restarts.Load(); n != 0 {
+		t.Fatalf("watchdog restarted a paused detector %d times", n)
+	}
+
+	// Once resumed, the deadline runs again.
+	det.paused.Store(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for det.restarts.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+	if det.restarts.Load() == 0 {
+		t.Fatal("watchdog never restarted the resumed detector")
+	}
+}
+
+func TestReloadWedged(t *testing.T) {
+	sup := &detectorSupervisor{
+		ctx:      context.Background(),
+		pipeline: &Pipeline{Store: NewFaceStore()},
+		cancel:   func() {},
+		done:     make(chan struct{}), // never stops
+	}
+
+	res := make(chan error, 1)
+	go func() { res <- sup.Reload(DetectorConfig{}, 50*time.Millisecond) }()
+	select {
+	case err := <-res:
+		if !errors.Is(err, errDetectorWedged) {
+			t.Fatalf("Reload = %v, want errDetectorWedged", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload hung on a wedged detector")
+	}
+
+	// The supervisor lock is released, so the watchdog can still act.
+	if err := sup.Restart(50 * time.Millisecond); !errors.Is(err, errDetectorWedged) {
+		t.Fatalf("Restart = %v, want errDetectorWedged", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* -------------------------------- Log ring --------------------------------- */
+
+// LogLine is one retained log entry.
+type LogLine struct {
+	Time time.Time `json:"time"`
+	Line string    `json:"line"`
+}
+
+// LogRing keeps the last log lines in memory for /debug/logs, where grabbing
+// the container logs is awkward. It is an io.Writer meant to sit next to the
+// console in log.SetOutput; the log package writes one entry per call.
+type LogRing struct {
+	mu    sync.Mutex
+	lines []LogLine // circular, next is the oldest once full
+	next  int
+	full  bool
+}
+
+// NewLogRing keeps the last size lines.
+func NewLogRing(size int) *LogRing {
+	return &LogRing{lines: make([]LogLine, max(size, 1))}
+}
+
+func (r *LogRing) Write(p []byte) (int, error) {
+	line := LogLine{Time: time.Now().UTC(), Line: strings.TrimRight(string(p), "\n")}
+	r.mu.Lock()
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.mu.Unlock()
+	return len(p), nil
+}
+
+// Last returns up to n of the most recent lines, oldest first; n <= 0
+// returns all of them.
+func (r *LogRing) Last(n int) []LogLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]LogLine, 0, len(r.lines))
+	if r.full {
+		out = append(out, r.lines[r.next:]...)
+	}
+	out = append(out, r.lines[:r.next]...)
+	if n > 0 && n < len(out) {
+		out = out[len(out)-n:]
+	}
+	return out
+}
+
+// logsHandler serves GET /debug/logs?n=&format=text|json, requiring
+// "Authorization: Bearer <token>".
+func logsHandler(ring *LogRing, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		q := r.URL.Query()
+		n := 0
+		if v := q.Get("n"); v != "" {
+			var err error
+			if n, err = strconv.Atoi(v); err != nil || n <= 0 {
+				http.Error(w, "invalid n", http.StatusBadRequest)
+				return
+			}
+		}
+		lines := ring.Last(n)
+		w.Header().Set("Cache-Control", "no-store")
+		switch q.Get("format") {
+		case "", "text":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			var b strings.Builder
+			for _, l := range lines {
+				b.WriteString(l.Line)
+				b.WriteByte('\n')
+			}
+			_, _ = w.Write([]byte(b.String()))
+		case "json":
+			writeJSON(w, lines, true)
+		default:
+			http.Error(w, "invalid format (want text or json)", http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestLogRing(t *testing.T) {
+	lines := func(ls []LogLine) []string {
+		var out []string
+		for _, l := range ls {
+			out = append(out, l.Line)
+		}
+		return out
+	}
+	r := NewLogRing(3)
+	if got := r.Last(0); len(got) != 0 {
+		t.Errorf("empty ring: %v", got)
+	}
+	fmt.Fprintln(r, "one")
+	fmt.Fprintln(r, "two")
+	if got := lines(r.Last(0)); !slices.Equal(got, []string{"one", "two"}) {
+		t.Errorf("not full: %q", got)
+	}
+	for _, s := range []string{"three", "four", "five"} {
+		fmt.Fprintln(r, s)
+	}
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{0, []string{"three", "four", "five"}},
+		{2, []string{"four", "five"}},
+		{10, []string{"three", "four", "five"}},
+	}
+	for _, tt := range tests {
+		if got := lines(r.Last(tt.n)); !slices.Equal(got, tt.want) {
+			t.Errorf("Last(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+
+	// Next to the console, one entry per log call, bounded under load.
+	r = NewLogRing(50)
+	logger := log.New(r, "", 0)
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				logger.Printf("[w%d] line %d", g, i)
+			}
+		}()
+	}
+	wg.Wait()
+	got := r.Last(0)
+	if len(got) != 50 {
+		t.Errorf("%d lines kept, want the last 50", len(got))
+	}
+	for _, l := range got {
+		var g, i int
+		if n, err := fmt.Sscanf(l.Line, "[w%d] line %d", &g, &i); n != 2 || err != nil {
+			t.Errorf("mangled line %q", l.Line)
+		}
+	}
+}
+
+func TestLogsHandler(t *testing.T) {
+	r := NewLogRing(10)
+	for i := range 4 {
+		fmt.Fprintf(r, "[test] line %d\n", i)
+	}
+	get := func(query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/debug/logs"+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		logsHandler(r, "s3cret")(w, req)
+		return w
+	}
+
+	if w := get("?n=2", "s3cret"); w.Code != http.StatusOK || w.Body.String() != "[test] line 2\n[test] line 3\n" {
+		t.Errorf("text: %d %q", w.Code, w.Body)
+	}
+	w := get("?format=json", "s3cret")
+	var got []LogLine
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || len(got) != 4 || got[0].Line != "[test] line 0" || got[0].Time.IsZero() {
+		t.Errorf("json: %v %s", err, w.Body)
+	}
+	tests := []struct {
+		query, token string
+		code         int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"", "wrong", http.StatusUnauthorized},
+		{"?n=0", "s3cret", http.StatusBadRequest},
+		{"?n=many", "s3cret", http.StatusBadRequest},
+		{"?format=xml", "s3cret", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := get(tt.query, tt.token); w.Code != tt.code {
+			t.Errorf("%q with %q: %d, want %d", tt.query, tt.token, w.Code, tt.code)
+		}
+	}
+}
@@ -3,14 +3,24 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"image"
+	"io"
 	"log"
+	"maps"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -37,94 +47,426 @@ type Point struct {
 
 // Detection represents a single detected face.
 type Detection struct {
-	ID        int       `json:"id"`
-	BBox      Rect      `json:"bbox"`
-	Landmarks []Point   `json:"landmarks,omitempty"`
-	Score     float64   `json:"score"`
-	Timestamp time.Time `json:"ts"`
+	ID         int                `json:"id"`
+	UUID       string             `json:"uuid,omitempty"` // unique per track appearance, for correlation (tracking only)
+	BBox       Rect               `json:"bbox"`
+	Landmarks  []Point            `json:"landmarks,omitempty"`
+	Pose       *Pose              `json:"pose,omitempty"`       // only when landmarks are present
+	Count      int                `json:"count,omitempty"`      // faces merged into this box (cluster mode only)
+	Color      string             `json:"color,omitempty"`      // "#rrggbb" derived from the track ID (tracking only)
+	Zone       string             `json:"zone,omitempty"`       // first configured zone containing the box center
+	Attributes map[string]float64 `json:"attributes,omitempty"` // second-stage classifier output, by label
+	Score      float64            `json:"score"`
+	RawScore   float64            `json:"raw_score,omitempty"` // uncalibrated score, only when a calibration is set
+	Timestamp  time.Time          `json:"ts"`
+
+	ApproxRangeM float64 `json:"approx_range_m,omitempty"` // rough distance from the box height (FACE_RANGE_*)
+	Quality      float64 `json:"quality,omitempty"`        // confidence, size and sharpness combined (FACE_QUALITY_*)
+	DwellS       float64 `json:"dwell_s,omitempty"`        // seconds since the track appeared (tracking only)
+	Edge         bool    `json:"edge,omitempty"`           // box within FACE_EDGE_MARGIN of the frame border, likely a partial face
+
+	TrackConfidence float64 `json:"track_confidence,omitempty"` // how sure the tracker is this is the same face as before, 0 (omitted) for a new track (tracking only)
+	StableMs        int64   `json:"stable_ms,omitempty"`        // how long the track has been at rest (FACE_STABLE_AFTER, tracking only)
+	Predicted       bool    `json:"predicted,omitempty"`        // box extrapolated for a track the detector missed (FACE_TRACK_PREDICT), never a detection
+
+	Velocity *Velocity `json:"velocity,omitempty"` // of the box center (tracking only)
+}
+
+// Velocity is the speed of a tracked face in frame pixels per second,
+// positive rightward and downward, smoothed over the last frames.
+type Velocity struct {
+	VX float64 `json:"vx"`
+	VY float64 `json:"vy"`
 }
 
 // Snapshot is the JSON payload returned by /faces.
 type Snapshot struct {
+	SchemaVersion int `json:"schema_version"` // set when encoding, see schema.go
+
 	Source      string      `json:"source"`
 	Frame       int64       `json:"frame"`
 	FrameWidth  int         `json:"frame_width"`  // <— width of the captured frame in pixels
 	FrameHeight int         `json:"frame_height"` // <— height of the captured frame in pixels
 	Detections  []Detection `json:"detections"`
 	GeneratedAt time.Time   `json:"generated_at"`
+
+	// Detections before tracking and smoothing, for ?raw=true; nil when
+	// nothing changes them. Only the latest snapshot keeps them.
+	Raw []Detection `json:"-"`
+
+	// Monotonic time since the previous successfully captured frame; grows
+	// when the source stalls even if the wall clock is adjusted.
+	FrameIntervalMs float64 `json:"frame_interval_ms,omitempty"`
+	Frozen          bool    `json:"frozen,omitempty"` // the source kept returning an identical frame
+
+	Zones []ZoneCount `json:"zones,omitempty"` // faces per configured zone
+
+	Events []TrackEvent `json:"events,omitempty"` // track events of this frame (tracking only)
+
+	Meta *SnapshotMeta `json:"meta,omitempty"` // detector settings in effect; shared, never modified
+
+	// Count-only (privacy) mode: the store drops Detections and keeps Count.
+	Count     int  `json:"-"`
+	CountOnly bool `json:"-"`
+}
+
+// SnapshotMeta describes the detector configuration that produced a
+// snapshot, so archived snapshots stay interpretable across reloads.
+type SnapshotMeta struct {
+	Model      string  `json:"model"`
+	Confidence float32 `json:"confidence"`
+	InputW     int     `json:"input_w"`
+	InputH     int     `json:"input_h"`
+	Backend    string  `json:"backend"`
+	Target     string  `json:"target"`
+}
+
+// countSnapshot is the JSON form of a count-only snapshot.
+type countSnapshot struct {
+	SchemaVersion int           `json:"schema_version"`
+	Source        string        `json:"source"`
+	Frame         int64         `json:"frame"`
+	Count         int           `json:"count"`
+	GeneratedAt   time.Time     `json:"generated_at"`
+	Zones         []ZoneCount   `json:"zones,omitempty"`
+	Meta          *SnapshotMeta `json:"meta,omitempty"`
+}
+
+// MarshalJSON emits only the aggregate count for count-only snapshots.
+func (s Snapshot) MarshalJSON() ([]byte, error) {
+	if s.CountOnly {
+		return json.Marshal(countSnapshot{SchemaVersion: snapshotSchemaVersion, Source: s.Source, Frame: s.Frame, Count: s.Count, GeneratedAt: s.GeneratedAt, Zones: s.Zones, Meta: s.Meta})
+	}
+	type plain Snapshot // without this method
+	s.SchemaVersion = snapshotSchemaVersion
+	return json.Marshal(plain(s))
+}
+
+// clone returns a copy of the snapshot that shares no slices, maps or
+// pointers with s, except the never-modified Meta.
+func (s Snapshot) clone() Snapshot {
+	s.Detections = cloneDetections(s.Detections)
+	s.Raw = cloneDetections(s.Raw)
+	if s.Zones != nil {
+		s.Zones = append([]ZoneCount(nil), s.Zones...)
+	}
+	if s.Events != nil {
+		s.Events = append([]TrackEvent(nil), s.Events...)
+	}
+	return s
+}
+
+func cloneDetections(ds []Detection) []Detection {
+	if ds == nil {
+		return nil
+	}
+	out := make([]Detection, len(ds))
+	for i, d := range ds {
+		if d.Landmarks != nil {
+			d.Landmarks = append([]Point(nil), d.Landmarks...)
+		}
+		if d.Pose != nil {
+			p := *d.Pose
+			d.Pose = &p
+		}
+		if d.Attributes != nil {
+			d.Attributes = maps.Clone(d.Attributes)
+		}
+		if d.Velocity != nil {
+			v := *d.Velocity
+			d.Velocity = &v
+		}
+		out[i] = d
+	}
+	return out
 }
 
 /* --------------------------- Thread-safe storage -------------------------- */
 
+// FaceStore holds the latest snapshot. Readers never block: each write
+// publishes a fresh immutable copy (copy-on-write). Writers are serialized so
+// every Set/Update bumps the version exactly once.
 type FaceStore struct {
-	mu      sync.RWMutex
+	mu        sync.Mutex // serializes writers
+	cur       atomic.Pointer[storeState]
+	nonce     string // per-process, so ETags never repeat across restarts
+	countOnly bool   // privacy mode: never hold per-face data
+	peaks     *Peaks // occupancy aggregates, updated on every write
+}
+
+type storeState struct {
 	snap    Snapshot
 	version uint64
+	changed chan struct{} // closed when a newer state is published
+}
+
+func NewFaceStore() *FaceStore {
+	s := &FaceStore{nonce: newETagNonce(), peaks: NewPeaks(time.Local)}
+	s.cur.Store(&storeState{changed: make(chan struct{})})
+	return s
+}
+
+// NewCountOnlyFaceStore returns a store for privacy mode: every snapshot it
+// publishes is reduced to its face count, so boxes, landmarks and scores
+// never reach any reader.
+func NewCountOnlyFaceStore() *FaceStore {
+	s := NewFaceStore()
+	s.countOnly = true
+	return s
 }
 
+// Set publishes an immutable copy of snap as the next version; the caller
+// keeps ownership of snap.
 func (s *FaceStore) Set(snap Snapshot) {
 	s.mu.Lock()
-	s.snap = snap
-	atomic.AddUint64(&s.version, 1)
-	s.mu.Unlock()
+	defer s.mu.Unlock()
+	s.commit(snap.clone())
+}
+
+// Update applies fn to a private copy of the current snapshot and publishes
+// the result as a single new version, so several stages can each fill in
+// their own fields. fn must not retain the pointer.
+//
+// The detector loop publishes whole frames with Set. Update is the extension
+// point for writers that fill in a published frame afterwards (ensemble
+// members, asynchronous post-processing stages): keep it even while no such
+// stage is configured.
+func (s *FaceStore) Update(fn func(*Snapshot)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.cur.Load().snap.clone()
+	fn(&next)
+	s.commit(next)
+}
+
+// commit publishes snap, which the store takes over, as the next version.
+// The caller holds mu.
+func (s *FaceStore) commit(snap Snapshot) {
+	next := &storeState{snap: s.redact(snap), changed: make(chan struct{})}
+	s.peaks.Observe(faceCount(next.snap), next.snap.GeneratedAt)
+	prev := s.cur.Load()
+	next.version = prev.version + 1
+	s.cur.Store(next)
+	close(prev.changed)
+}
+
+// redact reduces snap to its face count in privacy mode.
+func (s *FaceStore) redact(snap Snapshot) Snapshot {
+	if s.countOnly {
+		snap.Count = faceCount(snap)
+		snap.Detections, snap.Raw, snap.Events = nil, nil, nil
+		snap.CountOnly = true
+	}
+	return snap
 }
 
+// Get returns the latest snapshot and its version. The snapshot is shared
+// with other readers and must be treated as read-only.
 func (s *FaceStore) Get() (Snapshot, uint64) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.snap, atomic.LoadUint64(&s.version)
+	st := s.cur.Load()
+	if st == nil {
+		return Snapshot{}, 0
+	}
+	return st.snap, st.version
+}
+
+// Changed returns a channel that is closed once a snapshot newer than the
+// current one is published. Streaming handlers wait on it instead of polling.
+func (s *FaceStore) Changed() <-chan struct{} {
+	return s.cur.Load().changed
+}
+
+// Peaks returns the occupancy aggregates maintained by the store.
+func (s *FaceStore) Peaks() *Peaks {
+	return s.peaks
+}
+
+// ETag returns the weak validator for the given version/frame of this store.
+func (s *FaceStore) ETag(version uint64, frame int64) string {
+	return `W/"` + toETag(s.nonce, version, frame) + `"`
 }
 
 /* ------------------------------ DNN detector ------------------------------ */
 
+// frameSource is what the detector pulls frames from: a *gocv.VideoCapture
+// or one of the built-in sources (see SyntheticSource, ShmSource).
+type frameSource interface {
+	Read(m *gocv.Mat) bool
+	Close() error
+}
+
+// inferenceNet is the part of gocv.Net the detector runs, so the inference
+// can be faked in tests.
+type inferenceNet interface {
+	SetInput(blob gocv.Mat, name string)
+	Forward(outputName string) gocv.Mat
+	ForwardLayers(outBlobNames []string) []gocv.Mat
+	LastError() error // why the last Forward returned no output, if known
+	Close() error
+}
+
+// cvNet is an OpenCV net. OpenCV reports a failed Forward through its last
+// exception rather than a return value.
+type cvNet struct {
+	gocv.Net
+}
+
+func (n *cvNet) LastError() error {
+	return gocv.LastExceptionError()
+}
+
+// netLoader loads the detection net of a config.
+type netLoader func(cfg DetectorConfig) (inferenceNet, error)
+
+// openNet is the netLoader of the OpenCV nets.
+func openNet(cfg DetectorConfig) (inferenceNet, error) {
+	net, err := loadNet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &cvNet{net}, nil
+}
+
 // DNNDetector wraps the Res10 SSD (Caffe) face detector.
 type DNNDetector struct {
-	cap        *gocv.VideoCapture
-	net        gocv.Net
+	cap        frameSource
+	net        inferenceNet
+	load       netLoader // reloads the net (Reinit, CPU fallback, model switch)
 	source     string
 	inputSize  image.Point
 	meanBGR    gocv.Scalar
 	scale      float64
 	swapRB     bool
+	colors     colorSpace // conversion of the frame before the blob
+	deint      deinterlaceMode
 	crop       bool
 	confThresh float32
+	calib      scoreCalibrator // nil = raw scores
+	maxYaw     float32
+	minAspect  float64 // width/height bounds, 0 = unchecked
+	maxAspect  float64
+	clusterPx  int
+	rangeRef   *rangeReference      // distance estimate, nil = off
+	boxScale   *boxScale            // grow/shrink boxes around their center, nil = as detected
+	edges      *edgePolicy          // flag or drop boxes at the frame border, nil = off
+	spatialIDs bool                 // IDs in reading order
+	quality    *qualityConfig       // per-detection quality, nil = off
+	outputs    []string             // named output layers; outputs[0] holds the detections
+	roi        *Rect                // pre-crop applied before inference, nil = whole frame
+	rotROI     *RotatedROI          // rotated region warped upright before inference, nil = off
+	classifier *faceClassifier      // optional second stage, nil = off
+	tiling     *Tiling              // split the frame into tiles, nil = whole frame
+	capBuffer  *CaptureBufferStatus // outcome of the buffer size request, nil if none
+	selection  *scoreSelection      // per-frame top-K/percent instead of confThresh, nil = off
+	clockSkew  time.Duration        // added to every emitted timestamp
+	meta       *SnapshotMeta
+	netCfg     DetectorConfig // to reload the model
+	roiCoords  bool           // report coordinates relative to the ROI
+	maxBatch   int            // tiles per Forward after an out-of-memory failure, 0 = all
+	stats      *Stats         // accelerator degradations are reported here, may be nil
+
+	frame    gocv.Mat // last captured frame, reused across Detect calls
+	region   gocv.Mat // ROI view into frame
+	hasFrame bool
+
+	// Per-frame buffers reused across calls: OpenCV only reallocates them
+	// when the input size changes. Never read before being overwritten.
+	input   gocv.Mat // frame converted to the net's color space
+	scratch gocv.Mat // intermediate of the gray conversion
+	deinted gocv.Mat // deinterlaced frame
+	field   gocv.Mat // even field, for line doubling
+	blob    gocv.Mat // network input
 }
 
 type DetectorConfig struct {
-	Source         string        // "0" (webcam), "rtsp://...", or "/path/video.mp4"
-	ProtoTxtPath   string        // e.g., models/deploy.prototxt
-	ModelPath      string        // e.g., models/res10_300x300_ssd_iter_140000.caffemodel
-	Interval       time.Duration // e.g., 200 * time.Millisecond
-	Confidence     float32       // e.g., 0.5
-	InputW, InputH int           // network input size (default 300x300)
+	Source         string               // "0" (webcam), "rtsp://...", "/path/video.mp4", "synthetic://?w=640&h=480&faces=2" or "shm://name"
+	CaptureAPI     gocv.VideoCaptureAPI // OpenCV backend (default: auto)
+	CaptureBuffer  int                  // frames the backend may queue; 0 = backend default
+	ProtoTxtPath   string               // e.g., models/deploy.prototxt
+	ModelPath      string               // e.g., models/res10_300x300_ssd_iter_140000.caffemodel
+	Interval       time.Duration        // e.g., 200 * time.Millisecond
+	Confidence     float32              // e.g., 0.5, compared to the calibrated score
+	Selection      *scoreSelection      // relative threshold replacing Confidence; nil = absolute
+	Calibration    scoreCalibrator      // optional raw -> calibrated score mapping
+	InputW, InputH int                  // network input size (default 300x300)
+	ColorSpace     colorSpace           // channel layout the net expects (default BGR; mono for a 1-channel net)
+	Deinterlace    deinterlaceMode      // for interlaced sources, before the blob; off by default
+	SwapRB         bool                 // let BlobFromImage swap R and B
+	MaxYaw         float32              // drop faces turned more than this (degrees); 0 = keep all
+	Range          *rangeReference      // estimate approx_range_m from box heights; nil = off
+	BoxScale       *boxScale            // scale every box around its center, clamped to the frame; nil = as detected
+	Edges          *edgePolicy          // flag or drop boxes touching the frame border; nil = off
+	Quality        *qualityConfig       // compute Detection.Quality; nil = off
+	MinAspect      float64              // drop boxes narrower than this width/height ratio; 0 = off
+	MaxAspect      float64              // drop boxes wider than this width/height ratio; 0 = off
+	ClusterDist    int                  // merge faces whose centers are closer (px) into groups; 0 = off
+	OutputLayers   []string             // layers to forward, the first one being the [1,1,N,7] detections; empty = default output
+	Backend        gocv.NetBackendType  // DNN backend (default: OpenCV's default)
+	Target         gocv.NetTargetType   // DNN device (default: CPU); falls back to the CPU on out-of-memory failures
+	WarmUp         bool                 // run one inference on a blank frame after loading a net
+	ROI            *Rect                // only this part of the frame is processed; nil = whole frame
+	RotatedROI     *RotatedROI          // like ROI, for a rotated rectangle; exclusive with ROI
+	ROICoords      bool                 // report boxes and frame size relative to the ROI instead of the full frame
+	Classifier     *ClassifierConfig    // optional per-face classifier; nil = off
+	ClockOffset    time.Duration        // added to all emitted timestamps, to align cameras (default 0)
+	ReinitAfter    int                  // reload the model after this many consecutive inference failures; 0 = never
+	FrozenFrames   int                  // flag the feed as frozen after this many identical frames; 0 = off
+	Zones          []Zone               // named polygons counted per snapshot
+	ZoneEvents     int                  // frames a tracked face must hold a zone change for its zone_enter/zone_exit event; 0 = no events
+	Tiling         *Tiling              // run inference on overlapping tiles of the frame; nil = off
+	Latency        *LatencyTarget       // tune the interval and input size toward a latency target; nil = fixed Interval
+	Track          bool                 // keep IDs stable across frames
+	SpatialIDs     bool                 // number detections in reading order instead; exclusive with Track
+	TrackIoU       float64              // minimum box overlap to continue a track (default 0.3)
+	TrackMaxMissed int                  // frames a track survives without a match (default 5)
+	TrackSmooth    float64              // EMA weight of each new box in the tracked one; 0 = as detected
+	TrackPredict   int                  // missed frames reported with a predicted box; 0 = off
+	StableAfter    time.Duration        // a track at rest this long emits a stable event; 0 = off
+	StableMove     float64              // movement, in box sizes, that restarts the rest (default 0.1)
+	Order          detectionOrder       // order of the published detections (default as detected)
+	Workers        int                  // inference goroutines fed by a capture goroutine; 0 = single loop
+	QueueDepth     int                  // frames waiting for a worker, oldest dropped first (default 1)
 }
 
 func NewDNNDetector(cfg DetectorConfig) (*DNNDetector, error) {
 	// Open video source
-	var (
-		cap *gocv.VideoCapture
-		err error
-	)
-	if idx, convErr := strconv.Atoi(cfg.Source); convErr == nil {
-		cap, err = gocv.OpenVideoCapture(idx)
-	} else {
-		cap, err = gocv.OpenVideoCapture(cfg.Source)
-	}
+	cap, err := openSource(cfg.Source, cfg.CaptureAPI)
 	if err != nil {
-		return nil, fmt.Errorf("open video source: %w", err)
+		return nil, err
 	}
-	if !cap.IsOpened() {
-		return nil, fmt.Errorf("video source not opened: %s", cfg.Source)
+
+	var capBuffer *CaptureBufferStatus
+	if props, ok := cap.(captureProperties); ok && cfg.CaptureBuffer > 0 {
+		st := setCaptureBuffer(props, cfg.CaptureBuffer)
+		capBuffer = &st
 	}
 
-	// Load DNN (Caffe)
-	net := gocv.ReadNetFromCaffe(cfg.ProtoTxtPath, cfg.ModelPath)
-	if net.Empty() {
+	d, err := newInferenceDetector(cfg, openNet)
+	if err != nil {
 		cap.Close()
-		return nil, fmt.Errorf("failed to load DNN model (prototxt=%s, model=%s)", cfg.ProtoTxtPath, cfg.ModelPath)
+		return nil, err
+	}
+	d.cap, d.capBuffer = cap, capBuffer
+	return d, nil
+}
+
+// newInferenceDetector loads the net of cfg with load, and its classifier,
+// without opening the source, for the pipeline's inference workers.
+func newInferenceDetector(cfg DetectorConfig, load netLoader) (*DNNDetector, error) {
+	// Load DNN (Caffe)
+	net, err := load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	backend, target := cfg.Backend, cfg.Target
+
+	var classifier *faceClassifier
+	if cfg.Classifier != nil {
+		if classifier, err = newFaceClassifier(*cfg.Classifier); err != nil {
+			net.Close()
+			return nil, err
+		}
 	}
-	net.SetPreferableBackend(gocv.NetBackendDefault)
-	net.SetPreferableTarget(gocv.NetTargetCPU)
 
 	if cfg.InputW == 0 {
 		cfg.InputW = 300
@@ -136,17 +478,147 @@ func NewDNNDetector(cfg DetectorConfig) (*DNNDetector, error) {
 		cfg.Confidence = 0.5
 	}
 
-	return &DNNDetector{
-		cap:        cap,
+	d := &DNNDetector{
 		net:        net,
+		load:       load,
 		source:     cfg.Source,
 		inputSize:  image.Pt(cfg.InputW, cfg.InputH),
-		meanBGR:    gocv.NewScalar(104.0, 177.0, 123.0, 0), // Res10 expects BGR mean
+		meanBGR:    cfg.ColorSpace.inputMean(gocv.NewScalar(104.0, 177.0, 123.0, 0)), // Res10 expects BGR mean
 		scale:      1.0,
-		swapRB:     false,
+		swapRB:     cfg.SwapRB,
+		colors:     cfg.ColorSpace,
+		deint:      cfg.Deinterlace,
 		crop:       false,
 		confThresh: cfg.Confidence,
-	}, nil
+		calib:      cfg.Calibration,
+		maxYaw:     cfg.MaxYaw,
+		rangeRef:   cfg.Range,
+		boxScale:   cfg.BoxScale,
+		edges:      cfg.Edges,
+		spatialIDs: cfg.SpatialIDs,
+		quality:    cfg.Quality,
+		minAspect:  cfg.MinAspect,
+		maxAspect:  cfg.MaxAspect,
+		clusterPx:  cfg.ClusterDist,
+		outputs:    cfg.OutputLayers,
+		roi:        cfg.ROI,
+		rotROI:     cfg.RotatedROI,
+		roiCoords:  cfg.ROICoords,
+		classifier: classifier,
+		tiling:     cfg.Tiling,
+		selection:  cfg.Selection,
+		clockSkew:  cfg.ClockOffset,
+		netCfg:     cfg,
+		meta: &SnapshotMeta{
+			Model:      filepath.Base(cfg.ModelPath),
+			Confidence: cfg.Confidence,
+			InputW:     cfg.InputW,
+			InputH:     cfg.InputH,
+			Backend:    backend.String(),
+			Target:     target.String(),
+		},
+		frame:   gocv.NewMat(),
+		region:  gocv.NewMat(),
+		input:   gocv.NewMat(),
+		scratch: gocv.NewMat(),
+		deinted: gocv.NewMat(),
+		field:   gocv.NewMat(),
+		blob:    gocv.NewMat(),
+	}
+	if cfg.WarmUp {
+		d.warmUp()
+	}
+	return d, nil
+}
+
+// warmUp runs one inference on a blank frame. OpenCV allocates the net's
+// buffers lazily on the first Forward, which would otherwise make the first
+// real frame several times slower than the next ones.
+func (d *DNNDetector) warmUp() {
+	t0 := time.Now()
+	typ := gocv.MatTypeCV8UC3
+	if d.colors.channels() == 1 {
+		typ = gocv.MatTypeCV8UC1
+	}
+	blank := gocv.NewMatWithSize(d.inputSize.Y, d.inputSize.X, typ)
+	defer blank.Close()
+	gocv.BlobFromImages([]gocv.Mat{blank}, &d.blob, d.scale, d.inputSize, d.meanBGR, d.swapRB, d.crop, gocv.MatTypeCV32F)
+	d.net.SetInput(d.blob, "")
+	out := d.forward()
+	defer out.Close()
+	if out.Empty() {
+		log.Printf("[detector] warm-up inference returned no output")
+		return
+	}
+	log.Printf("[detector] warm-up inference took %v", time.Since(t0).Round(time.Millisecond))
+}
+
+// loadNet reads the Caffe model and prepares it for inference.
+func loadNet(cfg DetectorConfig) (gocv.Net, error) {
+	net := gocv.ReadNetFromCaffe(cfg.ProtoTxtPath, cfg.ModelPath)
+	if net.Empty() {
+		return net, fmt.Errorf("%w (prototxt=%s, model=%s)", ErrModelLoad, cfg.ProtoTxtPath, cfg.ModelPath)
+	}
+	if err := checkOutputLayers(&net, cfg.OutputLayers); err != nil {
+		net.Close()
+		return net, err
+	}
+	net.SetPreferableBackend(cfg.Backend)
+	net.SetPreferableTarget(cfg.Target)
+	if cfg.ColorSpace.channels() != 3 {
+		size := image.Pt(cfg.InputW, cfg.InputH)
+		if size.X == 0 || size.Y == 0 {
+			size = image.Pt(300, 300)
+		}
+		if err := checkInputChannels(&net, cfg.ColorSpace, size); err != nil {
+			net.Close()
+			return net, err
+		}
+	}
+	return net, nil
+}
+
+// Reinit reloads the model from disk, e.g. after repeated inference
+// failures. The current net is kept if the reload fails.
+func (d *DNNDetector) Reinit() error {
+	net, err := d.load(d.netCfg)
+	if err != nil {
+		return err
+	}
+	d.net.Close()
+	d.net = net
+	if d.netCfg.WarmUp {
+		d.warmUp()
+	}
+	return nil
+}
+
+// checkOutputLayers verifies that every requested output layer exists in net,
+// logging the available names otherwise.
+func checkOutputLayers(net interface{ GetLayerNames() []string }, want []string) error {
+	if len(want) == 0 {
+		return nil
+	}
+	names := net.GetLayerNames()
+	for _, w := range want {
+		if !slices.Contains(names, w) {
+			log.Printf("[detector] model layers: %s", strings.Join(names, ", "))
+			return fmt.Errorf("%w: output layer %q not found", ErrModelLoad, w)
+		}
+	}
+	return nil
+}
+
+// openSource opens a webcam index, a file/stream URL understood by OpenCV,
+// a synthetic:// test pattern or a shm:// buffer.
+func openSource(source string, api gocv.VideoCaptureAPI) (frameSource, error) {
+	if isSyntheticSource(source) {
+		return NewSyntheticSource(source)
+	}
+	if isShmSource(source) {
+		return NewShmSource(source)
+	}
+	return openCapture(source, api)
 }
 
 func (d *DNNDetector) Close() {
@@ -154,49 +626,223 @@ func (d *DNNDetector) Close() {
 		d.cap.Close()
 	}
 	d.net.Close()
+	if d.classifier != nil {
+		d.classifier.Close()
+	}
+	d.region.Close()
+	d.frame.Close()
+	d.input.Close()
+	d.scratch.Close()
+	d.deinted.Close()
+	d.field.Close()
+	d.blob.Close()
 }
 
-// Detect grabs one frame and returns detections plus frame size (w,h).
+// Detect grabs one frame and returns detections plus frame size (w,h). It
+// returns ErrEmptyFrame when the source yields no frame.
 // Res10 output: [1,1,N,7] -> (image_id, class_id, confidence, x1, y1, x2, y2) in normalized coords.
-func (d *DNNDetector) Detect() (string, []Detection, int, int) {
-	img := gocv.NewMat()
-	if ok := d.cap.Read(&img); !ok || img.Empty() {
-		img.Close()
-		return d.source, nil, 0, 0
-	}
-	defer img.Close()
-
-	blob := gocv.BlobFromImage(img, d.scale, d.inputSize, d.meanBGR, d.swapRB, d.crop)
-	d.net.SetInput(blob, "")
-	dets := d.net.Forward("") // [1,1,N,7]
-	blob.Close()
-	if dets.Empty() || dets.Total() < 7 {
+func (d *DNNDetector) Detect() (string, []Detection, int, int, error) {
+	d.hasFrame = false
+	if ok := d.cap.Read(&d.frame); !ok || d.frame.Empty() {
+		return d.source, nil, 0, 0, ErrEmptyFrame
+	}
+	d.hasFrame = true
+	return d.detectFrame()
+}
+
+// detectFrame runs detection on d.frame.
+func (d *DNNDetector) detectFrame() (string, []Detection, int, int, error) {
+	img := d.frame
+
+	// Pre-crop to the ROI; offset maps ROI coordinates back to the frame.
+	var offset image.Point
+	fullW, fullH := img.Cols(), img.Rows()
+	if d.roi != nil {
+		r := image.Rect(d.roi.X, d.roi.Y, d.roi.X+d.roi.Width, d.roi.Y+d.roi.Height)
+		if !r.In(image.Rect(0, 0, fullW, fullH)) {
+			d.hasFrame = false
+			return d.source, nil, fullW, fullH, fmt.Errorf("ROI %v lies outside the %dx%d frame", r, fullW, fullH)
+		}
+		d.region.Close()
+		d.region = img.Region(r)
+		img = d.region
+		if !d.roiCoords {
+			offset = r.Min
+		}
+	}
+	// Or warp the rotated ROI upright; unwarp maps its boxes back.
+	var unwarp *affine
+	if d.rotROI != nil {
+		d.region.Close()
+		d.region = gocv.NewMat()
+		inv, err := d.rotROI.warp(img, &d.region)
+		if err != nil {
+			d.hasFrame = false
+			return d.source, nil, fullW, fullH, err
+		}
+		img = d.region
+		if !d.roiCoords {
+			unwarp = &inv
+		}
+	}
+	outW, outH := fullW, fullH
+	if d.reportsRegion() {
+		outW, outH = img.Cols(), img.Rows()
+	}
+
+	bounds := image.Rect(0, 0, img.Cols(), img.Rows())
+	tiles := []image.Rectangle{bounds}
+	if d.tiling != nil {
+		tiles = d.tiling.Rects(bounds)
+	}
+	out, tileOf, err := d.infer(img, tiles, offset)
+	if err != nil {
+		return d.source, nil, outW, outH, err
+	}
+	if d.tiling != nil {
+		out = mergeTileDetections(out, tileOf, tiles, bounds, offset, d.tiling.Merge)
+	}
+	out = filterAspect(out, d.minAspect, d.maxAspect)
+	out = d.selection.apply(out)
+	if unwarp != nil {
+		frameBounds := image.Rect(0, 0, fullW, fullH)
+		for i := range out {
+			out[i].BBox = unwarp.mapBox(out[i].BBox, frameBounds)
+		}
+	}
+	out = applyPose(out, d.maxYaw)
+	out = clusterDetections(out, d.clusterPx)
+	out = applyRange(out, d.rangeRef)
+	// Before the box scale, which clamps grown boxes to the frame.
+	out = applyEdges(out, d.edges, outW, outH)
+	out = applyBoxScale(out, d.boxScale, image.Rect(0, 0, outW, outH))
+	if d.classifier != nil {
+		boxesIn, _ := d.LastFrame()
+		d.classifier.Annotate(boxesIn, out)
+	}
+	if d.quality != nil {
+		boxesIn, _ := d.LastFrame()
+		d.quality.Annotate(boxesIn, out)
+	}
+	if d.spatialIDs {
+		out = assignSpatialIDs(out)
+	}
+
+	return d.source, out, outW, outH, nil
+}
+
+// infer runs the net on the given tiles of img in one batch and returns the
+// detections in frame coordinates (tile origin plus offset) along with the
+// tile each one came from. A single tile covering img is inferred directly.
+func (d *DNNDetector) infer(img gocv.Mat, tiles []image.Rectangle, offset image.Point) ([]Detection, []int, error) {
+	img, err := d.deint.apply(img, &d.deinted, &d.field)
+	if err != nil {
+		return nil, nil, err
+	}
+	img, err = d.colors.convertInput(img, &d.input, &d.scratch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out []Detection
+	var tileOf []int
+	idBase := 0
+	for first := 0; first < len(tiles); {
+		n := len(tiles) - first
+		if d.maxBatch > 0 {
+			n = min(n, d.maxBatch)
+		}
+		dets, err := d.forwardBatch(img, tiles[first:first+n])
+		if errors.Is(err, errAcceleratorOOM) {
+			if d.recoverOOM(n, err) {
+				continue // same tiles, smaller batch or on the CPU
+			}
+			err = fmt.Errorf("%w: %v", ErrInference, err)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		batch, batchTiles, rows, err := d.parseDetections(dets, tiles[first:first+n], offset, idBase)
 		dets.Close()
-		return d.source, nil, img.Cols(), img.Rows()
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range batchTiles {
+			batchTiles[i] += first
+		}
+		out, tileOf = append(out, batch...), append(tileOf, batchTiles...)
+		idBase += rows
+		first += n
 	}
-	defer dets.Close()
+	return out, tileOf, nil
+}
 
-	rows := int(dets.Total() / 7)
-	flat := dets.Reshape(1, rows) // N x 7
-	defer flat.Close()
+// forwardBatch runs the net on tiles of img, one image of the batch each;
+// a single tile covering img is inferred directly.
+func (d *DNNDetector) forwardBatch(img gocv.Mat, tiles []image.Rectangle) (gocv.Mat, error) {
+	// The blob is written into d.blob, whose buffer is reused as long as the
+	// input size and tile count don't change.
+	if len(tiles) == 1 && tiles[0] == image.Rect(0, 0, img.Cols(), img.Rows()) {
+		gocv.BlobFromImages([]gocv.Mat{img}, &d.blob, d.scale, d.inputSize, d.meanBGR, d.swapRB, d.crop, gocv.MatTypeCV32F)
+	} else {
+		regions := make([]gocv.Mat, len(tiles))
+		for i, t := range tiles {
+			regions[i] = img.Region(t)
+		}
+		gocv.BlobFromImages(regions, &d.blob, d.scale, d.inputSize, d.meanBGR, d.swapRB, d.crop, gocv.MatTypeCV32F)
+		for i := range regions {
+			regions[i].Close()
+		}
+	}
+	d.net.SetInput(d.blob, "")
+	return d.forwardRetry() // [1,1,N,7]
+}
 
-	h := float32(img.Rows())
-	w := float32(img.Cols())
+// parseDetections reads the [1,1,N,7] output of a batch of tiles: the
+// detections above the threshold, in frame coordinates, with the index of
+// their tile and IDs numbered from idBase by row. rows is N.
+func (d *DNNDetector) parseDetections(dets gocv.Mat, tiles []image.Rectangle, offset image.Point, idBase int) (out []Detection, tileOf []int, rows int, err error) {
+	if dets.Total() < 7 {
+		return nil, nil, 0, nil
+	}
 
-	out := make([]Detection, 0, rows)
-	now := time.Now().UTC()
+	// Read the rows in place rather than through a reshaped header.
+	data, err := dets.DataPtrFloat32()
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("%w: %v", ErrInference, err)
+	}
+	rows = int(dets.Total() / 7)
+	at := func(i, j int) float32 { return data[i*7+j] }
+
+	out = make([]Detection, 0, rows)
+	tileOf = make([]int, 0, rows)
+	now := time.Now().Add(d.clockSkew).UTC()
+	thresh := d.confThresh
+	if d.selection != nil {
+		thresh = d.selection.Floor // the per-frame cut comes later
+	}
 
 	for i := 0; i < rows; i++ {
-		conf := flat.GetFloatAt(i, 2)
-		if conf < d.confThresh {
+		raw := float64(at(i, 2))
+		score := raw
+		if d.calib != nil {
+			score = d.calib.Calibrate(raw)
+		}
+		if score < float64(thresh) {
+			continue
+		}
+		tile := int(at(i, 0)) // image_id: index in the batch
+		if tile < 0 || tile >= len(tiles) {
 			continue
 		}
-		x1 := int(flat.GetFloatAt(i, 3) * w)
-		y1 := int(flat.GetFloatAt(i, 4) * h)
-		x2 := int(flat.GetFloatAt(i, 5) * w)
-		y2 := int(flat.GetFloatAt(i, 6) * h)
+		t := tiles[tile]
+		w, h := float32(t.Dx()), float32(t.Dy())
+		x1 := int(at(i, 3) * w)
+		y1 := int(at(i, 4) * h)
+		x2 := int(at(i, 5) * w)
+		y2 := int(at(i, 6) * h)
 
-		// Clamp to image bounds
+		// Clamp to tile bounds
 		if x1 < 0 {
 			x1 = 0
 		}
@@ -216,54 +862,371 @@ func (d *DNNDetector) Detect() (string, []Detection, int, int) {
 			y2 = int(h)
 		}
 
-		out = append(out, Detection{
-			ID: i,
+		det := Detection{
+			ID: idBase + i,
 			BBox: Rect{
-				X:      x1,
-				Y:      y1,
+				X:      x1 + t.Min.X + offset.X,
+				Y:      y1 + t.Min.Y + offset.Y,
 				Width:  x2 - x1,
 				Height: y2 - y1,
 			},
-			Score:     float64(conf),
+			Score:     score,
 			Timestamp: now,
-		})
+		}
+		if d.calib != nil {
+			det.RawScore = raw
+		}
+		out = append(out, det)
+		tileOf = append(tileOf, tile)
 	}
+	return out, tileOf, rows, nil
+}
 
-	return d.source, out, img.Cols(), img.Rows()
+// Inference retries: forwardAttempts tries, backing off from forwardBackoff.
+const (
+	forwardAttempts = 3
+	forwardBackoff  = 10 * time.Millisecond
+)
+
+// forwardRetry runs forward until it yields an output, with a short
+// exponential backoff. It returns ErrInference if every attempt failed.
+func (d *DNNDetector) forwardRetry() (gocv.Mat, error) {
+	backoff := forwardBackoff
+	for attempt := 1; ; attempt++ {
+		out := d.forward()
+		if !out.Empty() {
+			return out, nil
+		}
+		out.Close()
+		if err := d.net.LastError(); isAcceleratorOOM(err) {
+			return gocv.Mat{}, fmt.Errorf("%w: %v", errAcceleratorOOM, err)
+		}
+		if attempt == forwardAttempts {
+			return gocv.Mat{}, fmt.Errorf("%w: no output after %d attempts", ErrInference, attempt)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// forward runs the net and returns the detection output. With named output
+// layers, the auxiliary outputs are released and only the first is kept.
+func (d *DNNDetector) forward() gocv.Mat {
+	if len(d.outputs) == 0 {
+		return d.net.Forward("")
+	}
+	outs := d.net.ForwardLayers(d.outputs)
+	if len(outs) == 0 {
+		return gocv.NewMat()
+	}
+	for i := 1; i < len(outs); i++ {
+		outs[i].Close()
+	}
+	return outs[0]
+}
+
+// Meta describes the settings this detector runs with.
+func (d *DNNDetector) Meta() *SnapshotMeta {
+	return d.meta
+}
+
+// LastFrame returns the frame read by the last Detect call, or false if that
+// read failed. It is the ROI crop when coordinates are ROI-relative, so boxes
+// always line up with it. The Mat is owned by the detector and overwritten by
+// the next Detect; clone it to keep it.
+func (d *DNNDetector) LastFrame() (gocv.Mat, bool) {
+	if d.reportsRegion() {
+		return d.region, d.hasFrame
+	}
+	return d.frame, d.hasFrame
+}
+
+// reportsRegion tells whether boxes and frame size are relative to the
+// (possibly rotated) ROI rather than the frame.
+func (d *DNNDetector) reportsRegion() bool {
+	return (d.roi != nil || d.rotROI != nil) && d.roiCoords
 }
 
 /* ------------------------------ Detector loop ----------------------------- */
 
-// StartDetectorLoop launches the background detection loop at a fixed interval.
-func StartDetectorLoop(ctx context.Context, cfg DetectorConfig, store *FaceStore) {
+// Pipeline groups what the detector loop feeds.
+type Pipeline struct {
+	Store    *FaceStore
+	Frames   *FrameRing    // retained frames, may be nil
+	Preview  *Preview      // latest frame for the live views, may be nil
+	Playback *Playback     // seek/speed requests, may be nil
+	Pause    *Pause        // skips the ticks while set, may be nil
+	Ingest   *Ingest       // frames posted to /ingest, may be nil
+	Models   *Models       // model switch requests, may be nil
+	Trigger  *Trigger      // on-demand still captures, may be nil
+	Regions  *RegionScorer // classifies /score-region boxes, may be nil
+	Presence *Presence     // debounced occupancy, may be nil
+	Stats    *Stats
+	Seq      *FrameCounter // frame numbers, shared by successive loops
+
+	Highlights *Highlights   // clips around detection changes, may be nil
+	Crops      *CropSaver    // face crops saved to disk, may be nil
+	Sinks      *SinkFanout   // output sinks, may be nil
+	Memory     *MemoryBudget // global cap over the frames held above, may be nil
+}
+
+// publish hands a detector snapshot and its frame, if any, to the store and
+// everything fed alongside it.
+func (p *Pipeline) publish(snap Snapshot, img gocv.Mat, hasImg bool, frozen *frozenDetector) {
+	if p.Presence != nil && p.Presence.Observe(faceCount(snap), snap.GeneratedAt) {
+		log.Printf("[presence] present=%v", p.Presence.State().Present)
+	}
+	if hasImg && frozen != nil {
+		snap.Frozen = frozen.Observe(img)
+		p.Stats.SetFrozen(snap.Frozen)
+	}
+	if hasImg && p.Preview != nil {
+		p.Preview.Update(snap, img) // before Set, so streams woken by it see this frame
+	}
+	p.Store.Set(snap)
+	if p.Sinks != nil {
+		published, _ := p.Store.Get() // redacted in count-only mode
+		p.Sinks.Publish(published)
+	}
+	if hasImg && p.Frames != nil {
+		p.Frames.Add(snap, img)
+	}
+	if hasImg && p.Highlights != nil {
+		p.Highlights.Observe(snap, img)
+	}
+	if hasImg && p.Crops != nil {
+		p.Crops.Observe(snap, img)
+	}
+	if p.Memory != nil {
+		p.Memory.Enforce()
+	}
+}
+
+// StartDetectorLoop opens the detector and launches the background detection
+// loop at a fixed interval. It returns once the detector is initialized; done
+// is closed after ctx is canceled and the detector has been released.
+func StartDetectorLoop(ctx context.Context, cfg DetectorConfig, p *Pipeline) (<-chan struct{}, error) {
+	if cfg.Workers > 0 && (p.Ingest != nil || p.Models != nil || p.Trigger != nil) {
+		return nil, errors.New("FACE_WORKERS can't be combined with FACE_INGEST, FACE_MODELS_DIR or FACE_CAPTURE_DIR")
+	}
+	if p.Seq == nil {
+		p.Seq = &FrameCounter{}
+	}
+	cfg = p.Models.apply(cfg)
 	det, err := NewDNNDetector(cfg)
 	if err != nil {
-		log.Fatalf("[detector] init error: %v", err)
+		return nil, err
 	}
-	defer det.Close()
+	det.stats = p.Stats
+	var workers []*DNNDetector
+	for i := 0; i < cfg.Workers; i++ {
+		w, err := newInferenceDetector(cfg, openNet)
+		if err != nil {
+			for _, w := range workers {
+				w.Close()
+			}
+			det.Close()
+			return nil, err
+		}
+		w.stats = p.Stats
+		workers = append(workers, w)
+	}
+	done := make(chan struct{})
+	p.Stats.SetDetectorUp(true, true)
+	go func() {
+		defer close(done)
+		defer det.Close()
+		defer p.Stats.SetDetectorUp(false, false)
+		if len(workers) > 0 {
+			runPipelinedLoop(ctx, det, workers, cfg, p)
+			return
+		}
+		runDetectorLoop(ctx, det, cfg, p)
+	}()
+	return done, nil
+}
 
-	ticker := time.NewTicker(cfg.Interval)
+func runDetectorLoop(ctx context.Context, det *DNNDetector, cfg DetectorConfig, p *Pipeline) {
+	// With a latency target, the scheduler tunes the configured interval.
+	configured := cfg.Interval
+	var sched *latencyScheduler
+	if cfg.Latency != nil {
+		sched = newLatencyScheduler(*cfg.Latency, det.inputSize, cfg.Interval)
+		configured = sched.interval
+	}
+	p.Playback.SetConfigured(configured)
+	interval := p.Playback.Interval(configured)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	var seeks <-chan seekRequest
+	var timingChanged <-chan struct{}
+	if p.Playback != nil {
+		seeks, timingChanged = p.Playback.requests, p.Playback.timingChanged
+	}
+	var ingests <-chan ingestRequest
+	if p.Ingest != nil {
+		ingests = p.Ingest.requests
+	}
+	var modelSwitches <-chan modelRequest
+	if p.Models != nil {
+		modelSwitches = p.Models.requests
+	}
+	var triggers <-chan triggerRequest
+	if p.Trigger != nil {
+		triggers = p.Trigger.requests
+	}
+	var regions <-chan regionRequest
+	if p.Regions != nil {
+		regions = p.Regions.requests
+	}
+
 	var frame int64
+	var lastCapture time.Time // monotonic reading of the last good frame
+	var lastErr string        // logged once until it changes
+	var failures int          // consecutive inference failures
+	var frozen *frozenDetector
+	if cfg.FrozenFrames > 0 {
+		frozen = &frozenDetector{threshold: cfg.FrozenFrames}
+	}
+	var tracker *Tracker
+	if cfg.Track {
+		tracker = NewTracker(cfg.TrackIoU, cfg.TrackMaxMissed, cfg.TrackSmooth)
+		tracker.SetStability(cfg.StableAfter, cfg.StableMove)
+		tracker.SetPrediction(cfg.TrackPredict)
+		if cfg.ZoneEvents > 0 {
+			tracker.SetZones(cfg.Zones, cfg.ZoneEvents)
+		}
+	}
 	log.Printf("[detector] started (interval=%v, source=%s)", cfg.Interval, cfg.Source)
+	p.Stats.SetClockOffset(cfg.ClockOffset)
+	p.Stats.SetModel(det.Meta().Model)
+	p.Stats.SetCaptureBuffer(det.capBuffer)
 
 	for {
 		select {
 		case <-ctx.Done():
 			log.Printf("[detector] stopping")
 			return
+		case req := <-seeks:
+			req.reply <- det.Seek(req)
+		case req := <-triggers:
+			still, err := captureStill(det, cfg, p.Trigger)
+			req.reply <- triggerResult{still: still, err: err}
+		case req := <-regions:
+			req.reply <- det.classifyRegion(req.img)
+		case req := <-modelSwitches:
+			err := det.SwapModel(req.entry)
+			if err != nil {
+				log.Printf("[detector] model %s failed to load, keeping %s: %v", req.entry.Name, det.Meta().Model, err)
+			} else {
+				log.Printf("[detector] switched to model %s", req.entry.Name)
+				p.Models.setActive(req.entry)
+				p.Stats.SetModel(det.Meta().Model)
+			}
+			req.reply <- err
+		case req := <-ingests:
+			source, faces, fw, fh, err := det.DetectImage(req.img)
+			if err != nil {
+				req.reply <- ingestResult{err: err}
+				continue
+			}
+			var raw []Detection
+			if req.update {
+				faces, raw = trackFaces(tracker, faces)
+			}
+			faces = cfg.Order.apply(faces)
+			snap := Snapshot{
+				Source:      source,
+				FrameWidth:  fw,
+				FrameHeight: fh,
+				Detections:  faces,
+				Raw:         raw,
+				GeneratedAt: time.Now().Add(cfg.ClockOffset).UTC(),
+				Meta:        det.Meta(),
+				Zones:       assignZones(faces, cfg.Zones),
+			}
+			if req.update {
+				frame = p.Seq.Next()
+				snap.Frame = frame
+				snap.Events = tracker.Events()
+				img, hasImg := det.LastFrame()
+				p.publish(snap, img, hasImg, frozen)
+			}
+			req.reply <- ingestResult{snap: snap}
+		case <-timingChanged:
+			interval = p.Playback.Interval(configured)
+			ticker.Reset(interval)
+			log.Printf("[detector] interval %v (speed %gx)", interval, p.Playback.Speed())
 		case <-ticker.C:
-			frame++
-			source, faces, fw, fh := det.Detect()
-			store.Set(Snapshot{
+			if p.Pause.Paused() {
+				continue
+			}
+			t0 := time.Now()
+			frame = p.Seq.Next()
+			source, faces, fw, fh, err := det.Detect()
+			if errors.Is(err, ErrInference) {
+				// Keep the previous snapshot rather than publishing an empty one.
+				p.Stats.ObserveInferenceFailure()
+				if failures++; cfg.ReinitAfter > 0 && failures >= cfg.ReinitAfter {
+					log.Printf("[detector] %d consecutive inference failures, reloading the model", failures)
+					if err := det.Reinit(); err != nil {
+						log.Printf("[detector] model reload failed: %v", err)
+					} else {
+						p.Stats.ObserveModelReinit()
+					}
+					failures = 0
+				}
+				p.Stats.ObserveCycle(frame, time.Since(t0), interval)
+				continue
+			}
+			failures = 0
+			if err != nil && !errors.Is(err, ErrEmptyFrame) {
+				if err.Error() != lastErr {
+					log.Printf("[detector] %v", err)
+				}
+				lastErr = err.Error()
+			} else {
+				lastErr = ""
+			}
+			faces, raw := trackFaces(tracker, faces)
+			faces = cfg.Order.apply(faces)
+			snap := Snapshot{
 				Source:      source,
 				Frame:       frame,
 				FrameWidth:  fw,
 				FrameHeight: fh,
 				Detections:  faces,
-				GeneratedAt: time.Now().UTC(),
-			})
+				Raw:         raw,
+				GeneratedAt: time.Now().Add(cfg.ClockOffset).UTC(),
+				Meta:        det.Meta(),
+				Zones:       assignZones(faces, cfg.Zones),
+				Events:      tracker.Events(),
+			}
+			if err == nil {
+				if !lastCapture.IsZero() {
+					snap.FrameIntervalMs = ms(t0.Sub(lastCapture))
+				}
+				lastCapture = t0
+				p.Stats.ObserveFrame(t0)
+			}
+			img, hasImg := det.LastFrame()
+			p.publish(snap, img, hasImg, frozen)
+			cycle := time.Since(t0)
+			p.Stats.ObserveCycle(frame, cycle, interval)
+			if sched != nil {
+				next, size := sched.Observe(cycle)
+				if size != det.inputSize {
+					log.Printf("[detector] latency %v for a %v target, input %dx%d", sched.Latency().Round(time.Millisecond), cfg.Latency.Target, size.X, size.Y)
+					det.SetInputSize(size)
+				}
+				if next != configured {
+					configured = next
+					p.Playback.SetConfigured(configured)
+					interval = p.Playback.Interval(configured)
+					ticker.Reset(interval)
+				}
+			}
 			// log.Printf("[detector] frame=%d faces=%d (%dx%d)", frame, len(faces), fw, fh)
 		}
 	}
@@ -271,73 +1234,291 @@ func StartDetectorLoop(ctx context.Context, cfg DetectorConfig, store *FaceStore
 
 /* ------------------------------ HTTP server -------------------------------- */
 
-// StartHTTPServer serves /faces JSON, /healthz, and static files from staticDir.
-func StartHTTPServer(ctx context.Context, addr string, store *FaceStore, staticDir string) error {
-	mux := http.NewServeMux()
+// ServerConfig holds the HTTP server settings.
+type ServerConfig struct {
+	Addr             string         // e.g., ":8080"
+	StaticDir        string         // served on "/", the embedded dashboard if missing; empty = API only, "/" answers 404
+	EarlyHints       bool           // send 103 Early Hints for the embedded dashboard's assets
+	Frames           *FrameRing     // retained frames for /face/..., may be nil
+	Memory           *MemoryBudget  // global memory cap reported in /stats, may be nil
+	Preview          *Preview       // latest frame for /snapshot.jpg and /stream.mjpg, may be nil
+	Images           *ImageEncoders // output format of the image endpoints; nil = default JPEG
+	Ingest           *Ingest        // enables POST /ingest, may be nil
+	StreamJSON       bool           // encode /faces straight to the client instead of buffering it first
+	Scores           *scoreFormat   // rounding/units of the scores in /faces and /faces/stream; nil = full precision
+	JSONCase         jsonCase       // field names of /faces and /faces/stream unless ?case= (default snake_case)
+	Models           *Models        // enables /models, may be nil
+	Trigger          *Trigger       // enables POST /trigger/capture, may be nil
+	Feedback         *Feedback      // enables POST /feedback, may be nil
+	Regions          *RegionScorer  // enables POST /score-region, may be nil
+	Presence         *Presence      // enables /presence, may be nil
+	MetricsExemplars bool           // serve OpenMetrics with exemplars to scrapers that accept it
+	HealthMaxAge     time.Duration  // /healthz?verbose=1 is down past this without a frame (default 10s)
+	MaxSnapshotAge   time.Duration  // /faces answers 503 when the snapshot is older; 0 = always serve it
+	Stats            *Stats         // served on /stats and /metrics
+	Lifecycle        *Lifecycle     // shutdown/reload hooks, may be nil
+	ControlToken     string         // bearer token for /control/* and /debug/logs; empty disables them
+	Logs             *LogRing       // recent log lines for /debug/logs, may be nil
+	DrainTimeout     time.Duration  // default graceful shutdown window (default 5s)
+	RateLimit        float64        // requests per second per client IP; 0 = unlimited
+	RateBurst        int            // bucket size (default 2*RateLimit)
+	AccessLogSample  int            // log 1 in N successful requests (0 = none); errors are always logged
+	AccessLogSkip    []string       // paths whose successful requests are never logged
+	TLSCert          string         // PEM certificate; with TLSKey enables HTTPS
+	TLSKey           string         // PEM private key
+	TLSSelfSigned    bool           // HTTPS with a generated certificate (local testing)
+}
 
-	// Health check
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok"))
+// StartHTTPServer serves /faces JSON (polled or streamed), /healthz, the
+// annotated live frame, face crops from the retained frames, /control
+// endpoints, and static files. It returns once the server has shut down and
+// in-flight requests have drained.
+func StartHTTPServer(ctx context.Context, cfg ServerConfig, store *FaceStore) error {
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = 5 * time.Second
+	}
+	if cfg.HealthMaxAge <= 0 {
+		cfg.HealthMaxAge = 10 * time.Second
+	}
+	mux, embedded := newServeMux(ctx, cfg, store)
+
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           newAccessLog(cfg.AccessLogSample, cfg.AccessLogSkip).middleware(newRateLimiter(cfg.RateLimit, cfg.RateBurst).middleware(mux)),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	conns := newConnTracker()
+	srv.ConnState = conns.ConnState
+	srv.RegisterOnShutdown(func() {
+		log.Printf("[http] shutting down, %d requests in flight", conns.Active())
 	})
 
+	// Graceful shutdown: stop accepting, let in-flight requests finish. The
+	// streams end on ctx, so they don't hold the drain window.
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		drain := cfg.DrainTimeout
+		if cfg.Lifecycle != nil {
+			drain = cfg.Lifecycle.Drain(drain)
+		}
+		logShutdown(shutdownServer(srv, conns, drain), drain)
+	}()
+
+	switch {
+	case embedded:
+		log.Printf("[http] static directory %q not found, serving the embedded dashboard", cfg.StaticDir)
+	case cfg.StaticDir != "":
+		log.Printf("[http] serving static from %s", cfg.StaticDir)
+	default:
+		log.Printf("[http] static files disabled")
+	}
+	log.Printf("[http] listening on %s", cfg.Addr)
+	if err := listenAndServe(srv, cfg); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	<-shutdownDone
+	return nil
+}
+
+// newServeMux routes every endpoint StartHTTPServer serves; embedded reports
+// whether the static site falls back to the embedded dashboard.
+func newServeMux(ctx context.Context, cfg ServerConfig, store *FaceStore) (mux *http.ServeMux, embedded bool) {
+	mux = http.NewServeMux()
+
+	// Health check, with per-dependency detail on ?verbose=1
+	mux.HandleFunc("/healthz", healthzHandler(cfg.Stats, cfg.HealthMaxAge))
+
 	// Latest snapshot (shared result)
-	mux.HandleFunc("/faces", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/faces", facesHandler(cfg, store))
+
+	// Server-sent events, one per new snapshot, filtered per subscriber
+	mux.HandleFunc("/faces/stream", streamHandler(ctx, store, cfg.Scores, cfg.JSONCase))
+
+	// Face count only, JSON or ?plain=1
+	mux.HandleFunc("/count", countHandler(store))
+
+	// Faces per named polygon zone
+	mux.HandleFunc("/zones", zonesHandler(store))
+
+	// Public runtime settings for the dashboard
+	mux.HandleFunc("/app-config.json", appConfigHandler(cfg, store))
+
+	// Runtime counters
+	mux.HandleFunc("/stats", statsHandler(cfg))
+	mux.HandleFunc("/stats/peaks", peaksHandler(store.Peaks()))
+	mux.HandleFunc("/metrics", metricsHandler(cfg.Stats, cfg.MetricsExemplars))
+
+	// Latest frame with the detections drawn on it
+	mux.HandleFunc("/snapshot.jpg", snapshotHandler(cfg.Preview, cfg.Images))
+	mux.HandleFunc("/stream.mjpg", mjpegHandler(ctx, store, cfg.Preview, cfg.Images))
+
+	// Cropped face of a retained frame: /face/<frame>/<id>.jpg
+	mux.HandleFunc("/face/", faceCropHandler(cfg.Frames, cfg.Images))
+
+	// Crops of a range of retained frames as a zip: /export/crops?from=&to=
+	mux.HandleFunc("/export/crops", exportCropsHandler(cfg.Frames, cfg.Images))
+
+	// How detections changed between two retained frames
+	mux.HandleFunc("/diff", diffHandler(cfg.Frames))
+
+	// Sources and their live interval: GET /sources, POST /sources/<name>/interval?value=
+	if cfg.Lifecycle != nil && cfg.Lifecycle.Playback != nil {
+		sources := sourcesHandler(store, cfg.Lifecycle.Playback, cfg.ControlToken)
+		mux.HandleFunc("/sources", sources)
+		mux.HandleFunc("/sources/", sources)
+	}
+
+	// Model listing and switching: GET /models, POST /models/active?name=
+	if cfg.Models != nil {
+		mux.HandleFunc("/models", modelsHandler(cfg.Models, cfg.ControlToken, cfg.Stats))
+		mux.HandleFunc("/models/", modelsHandler(cfg.Models, cfg.ControlToken, cfg.Stats))
+	}
+
+	// Debounced occupancy
+	if cfg.Presence != nil {
+		mux.HandleFunc("/presence", presenceHandler(cfg.Presence))
+	}
+
+	// Hard negatives for retraining: POST /feedback?frame=&id=
+	if cfg.Feedback != nil {
+		mux.HandleFunc("/feedback", feedbackHandler(cfg.Feedback))
+	}
+
+	// Detector confidence for a box of a retained frame: POST /score-region?frame=&box=
+	if cfg.Regions != nil {
+		mux.HandleFunc("/score-region", scoreRegionHandler(cfg.Regions))
+	}
+
+	// On-demand annotated still: POST /trigger/capture
+	if cfg.Trigger != nil {
+		mux.HandleFunc("/trigger/capture", triggerHandler(cfg.Trigger))
+	}
+
+	// Detection on posted frames: POST /ingest
+	if cfg.Ingest != nil {
+		mux.HandleFunc("/ingest", ingestHandler(cfg.Ingest, store))
+	}
+
+	// Orchestration: POST /control/shutdown?drain=5s, POST /control/reload
+	if cfg.ControlToken != "" && cfg.Lifecycle != nil {
+		mux.Handle("/control/", controlHandler(cfg.ControlToken, cfg.Lifecycle))
+	}
+
+	// Recent log lines: GET /debug/logs?n=100&format=json
+	if cfg.ControlToken != "" && cfg.Logs != nil {
+		mux.HandleFunc("/debug/logs", logsHandler(cfg.Logs, cfg.ControlToken))
+	}
+
+	// Static site (e.g., index.html, js, css) served from staticDir, or the
+	// embedded dashboard, preferring precompressed .gz siblings
+	if cfg.StaticDir != "" {
+		var root http.FileSystem
+		root, embedded = staticRoot(cfg.StaticDir)
+		var preloads []string
+		if embedded && cfg.EarlyHints {
+			preloads = dashboardPreloads
+		}
+		mux.Handle("/", staticHandler(root, preloads))
+	}
+	return mux, embedded
+}
+
+// facesHandler serves the latest snapshot, filtered, rounded and projected
+// per request.
+func facesHandler(cfg ServerConfig, store *FaceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Optional ?filter= expression, e.g. "score > 0.8 && width > 50"
+		var filter filterExpr
+		if src := r.URL.Query().Get("filter"); src != "" {
+			var err error
+			if filter, err = parseFilter(src); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		// ?raw=true: detections before tracking and smoothing
+		raw, err := parseRawParam(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// ?case=camel|snake: field names
+		jc, err := cfg.JSONCase.forRequest(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Vary", "Accept")
 
 		snap, ver := store.Get()
-		etag := `W/"` + toETag(ver, snap.Frame) + `"`
+		if cfg.MaxSnapshotAge > 0 {
+			var offset time.Duration
+			if cfg.Stats != nil {
+				offset = cfg.Stats.ClockOffset()
+			}
+			if age := snapshotAge(snap, time.Now(), offset); age > cfg.MaxSnapshotAge {
+				// Stale: fail loudly rather than serve old faces as current
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(cfg.MaxSnapshotAge.Seconds()))))
+				http.Error(w, "snapshot is stale (older than "+cfg.MaxSnapshotAge.String()+")", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		etag := store.ETag(ver, snap.Frame)
+		if raw {
+			snap = rawView(snap)
+			etag = strings.TrimSuffix(etag, `"`) + `-raw"`
+		}
+		v1 := wantsV1(r.Header.Get("Accept"))
+		if v1 {
+			etag = strings.TrimSuffix(etag, `"`) + `-v1"` // another representation
+		}
+		if jc == caseCamel {
+			etag = strings.TrimSuffix(etag, `"`) + `-camel"`
+		}
 		if r.Header.Get("If-None-Match") == etag {
 			w.WriteHeader(http.StatusNotModified)
 			return
 		}
 		w.Header().Set("ETag", etag)
-
-		enc := json.NewEncoder(w)
-		enc.SetIndent("", "  ")
-		_ = enc.Encode(snap)
-	})
-
-	// Static site (e.g., index.html, js, css) served from staticDir
-	fs := http.FileServer(http.Dir(staticDir))
-	mux.Handle("/", fs)
-
-	srv := &http.Server{
-		Addr:              addr,
-		Handler:           loggingMiddleware(mux),
-		ReadHeaderTimeout: 5 * time.Second,
+		if v1 {
+			w.Header().Set("Content-Type", mediaTypeV1+"; charset=utf-8")
+			// v1 is frozen: scores stay 0-1 at full precision
+			writeJSON(w, jc.wrap(projectV1(applyFilter(snap, filter))), !cfg.StreamJSON)
+			return
+		}
+		writeJSON(w, jc.wrap(cfg.Scores.apply(applyFilter(snap, filter))), !cfg.StreamJSON)
 	}
+}
 
-	// Graceful shutdown
-	go func() {
-		<-ctx.Done()
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		_ = srv.Shutdown(shutdownCtx)
-	}()
+/* --------------------------------- Utils ---------------------------------- */
 
-	log.Printf("[http] serving static from %s", staticDir)
-	log.Printf("[http] listening on %s", addr)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		return err
-	}
-	return nil
+func toETag(nonce string, version uint64, frame int64) string {
+	return nonce + "-" + strconv.FormatUint(version, 36) + "-" + strconv.FormatInt(frame, 36)
 }
 
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		t0 := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("[http] %s %s %s", r.Method, r.URL.Path, time.Since(t0))
-	})
+// newETagNonce returns a short random token identifying this process.
+func newETagNonce() string {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return strconv.FormatUint(uint64(binary.BigEndian.Uint32(b[:])), 36)
 }
 
-/* --------------------------------- Utils ---------------------------------- */
-
-func toETag(version uint64, frame int64) string {
-	return strconv.FormatUint(version, 36) + "-" + strconv.FormatInt(frame, 36)
+// splitList splits a comma-separated value, dropping empty items.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
 }
 
 func getenvDefault(k, def string) string {
@@ -348,14 +1529,22 @@ func getenvDefault(k, def string) string {
 }
 
 func getenvRequired(k, def string) string {
+	v, err := requiredPath(k, def)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return v
+}
+
+// requiredPath returns the value of k, or def if k is unset and def exists.
+func requiredPath(k, def string) (string, error) {
 	if v := os.Getenv(k); v != "" {
-		return v
+		return v, nil
 	}
-	if _, err := os.Stat(def); err == nil {
-		return def
+	if _, err := os.Stat(def); err != nil {
+		return "", fmt.Errorf("%s not set and default not found on disk: %s", k, def)
 	}
-	log.Fatalf("%s not set and default not found on disk: %s", k, def)
-	return ""
+	return def, nil
 }
 
 func getenvDurationDefault(k string, def time.Duration) time.Duration {
@@ -367,6 +1556,15 @@ func getenvDurationDefault(k string, def time.Duration) time.Duration {
 	return def
 }
 
+func getenvIntDefault(k string, def int) int {
+	if v := os.Getenv(k); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
 func getenvFloat32Default(k string, def float32) float32 {
 	if v := os.Getenv(k); v != "" {
 		if f, err := strconv.ParseFloat(v, 32); err == nil {
@@ -378,39 +1576,545 @@ func getenvFloat32Default(k string, def float32) float32 {
 
 /* --------------------------------- Main ----------------------------------- */
 
+// listenAddr is where the HTTP server listens.
+const listenAddr = ":8080"
+
+// loadPrivacyMode reads FACE_PRIVACY and reports whether count-only mode is
+// on.
+func loadPrivacyMode() (bool, error) {
+	privacy := getenvDefault("FACE_PRIVACY", "off")
+	if privacy != "off" && privacy != "count" {
+		return false, fmt.Errorf("FACE_PRIVACY: unknown mode %q (want off or count)", privacy)
+	}
+	return privacy == "count", nil
+}
+
+// loadOverlayLabel reads the text template drawn above each box; nil keeps
+// the default.
+func loadOverlayLabel() (*labelTemplate, error) {
+	v := os.Getenv("FACE_OVERLAY_LABEL")
+	if v == "" {
+		return nil, nil
+	}
+	t, err := parseLabelTemplate(v)
+	if err != nil {
+		return nil, fmt.Errorf("FACE_OVERLAY_LABEL: %w", err)
+	}
+	return t, nil
+}
+
+// loadImageEncoders reads the output format of the image endpoints.
+func loadImageEncoders() (*ImageEncoders, error) {
+	return NewImageEncoders(
+		getenvDefault("FACE_IMAGE_FORMAT", "jpeg"),
+		getenvIntDefault("FACE_JPEG_QUALITY", 90),
+		os.Getenv("FACE_JPEG_SUBSAMPLING"),
+		getenvIntDefault("FACE_WEBP_QUALITY", 80),
+	)
+}
+
+// loadDetectorConfig builds the detector settings from the environment. It
+// runs at startup and again on every reload.
+func loadDetectorConfig() (DetectorConfig, error) {
+	capAPI, err := parseCaptureAPI(getenvDefault("FACE_CAP_API", "any"))
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_CAP_API: %w", err)
+	}
+	calib, err := parseCalibration(os.Getenv("FACE_CALIBRATION"))
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_CALIBRATION: %w", err)
+	}
+	var roi *Rect
+	if v := os.Getenv("FACE_ROI"); v != "" {
+		r, err := parseRect(v)
+		if err != nil || r.X < 0 || r.Y < 0 {
+			return DetectorConfig{}, fmt.Errorf("FACE_ROI: want x,y,w,h inside the frame, got %q", v)
+		}
+		roi = &r
+	}
+	var rotROI *RotatedROI
+	if v := os.Getenv("FACE_ROI_ROTATED"); v != "" {
+		if roi != nil {
+			return DetectorConfig{}, errors.New("FACE_ROI_ROTATED: can't be combined with FACE_ROI")
+		}
+		if rotROI, err = parseRotatedROI(v); err != nil {
+			return DetectorConfig{}, fmt.Errorf("FACE_ROI_ROTATED: %w", err)
+		}
+	}
+	var classifier *ClassifierConfig
+	if model := os.Getenv("FACE_CLASSIFIER_MODEL"); model != "" {
+		classifier = &ClassifierConfig{
+			ModelPath:  model,
+			ConfigPath: os.Getenv("FACE_CLASSIFIER_CONFIG"),
+			Labels:     splitList(getenvDefault("FACE_CLASSIFIER_LABELS", "mask,no_mask")),
+			InputSize:  getenvIntDefault("FACE_CLASSIFIER_SIZE", 224),
+			Scale:      float64(getenvFloat32Default("FACE_CLASSIFIER_SCALE", 1.0/255)),
+			SwapRB:     getenvDefault("FACE_CLASSIFIER_SWAP_RB", "1") == "1",
+		}
+	}
+	zones, err := parseZones(os.Getenv("FACE_ZONES"))
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_ZONES: %w", err)
+	}
+	tiling, err := parseTiling(os.Getenv("FACE_TILES"),
+		float64(getenvFloat32Default("FACE_TILE_OVERLAP", 0.2)),
+		float64(getenvFloat32Default("FACE_TILE_MERGE", 0.5)))
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_TILES: %w", err)
+	}
+	selection, err := parseScoreSelection(os.Getenv("FACE_THRESHOLD_MODE"),
+		float64(getenvFloat32Default("FACE_THRESHOLD_PARAM", 0)),
+		float64(getenvFloat32Default("FACE_SCORE_FLOOR", 0.1)))
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_THRESHOLD_MODE: %w", err)
+	}
+	colors, err := parseColorSpace(os.Getenv("FACE_COLOR_SPACE"), getenvDefault("FACE_SWAP_RB", "0") == "1")
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_COLOR_SPACE: %w", err)
+	}
+	if colors, err = colors.withChannels(getenvIntDefault("FACE_INPUT_CHANNELS", 3), getenvDefault("FACE_SWAP_RB", "0") == "1"); err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_INPUT_CHANNELS: %w", err)
+	}
+	deint, err := parseDeinterlace(os.Getenv("FACE_DEINTERLACE"))
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_DEINTERLACE: %w", err)
+	}
+	roiCoords := getenvDefault("FACE_ROI_COORDS", "frame")
+	if roiCoords != "frame" && roiCoords != "roi" {
+		return DetectorConfig{}, fmt.Errorf("FACE_ROI_COORDS: want frame or roi, got %q", roiCoords)
+	}
+	var rangeRef *rangeReference
+	if h := float64(getenvFloat32Default("FACE_RANGE_REF_HEIGHT", 0)); h > 0 {
+		dist := float64(getenvFloat32Default("FACE_RANGE_REF_DIST", 1))
+		if dist <= 0 {
+			return DetectorConfig{}, fmt.Errorf("FACE_RANGE_REF_DIST: must be > 0, got %g", dist)
+		}
+		rangeRef = &rangeReference{RefHeight: h, RefDistance: dist}
+	}
+	boxScale, err := parseBoxScale(getenvDefault("FACE_BOX_SCALE", "1"))
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_BOX_SCALE: %w", err)
+	}
+	edges, err := parseEdgePolicy(getenvDefault("FACE_EDGE", "off"), getenvIntDefault("FACE_EDGE_MARGIN", 0))
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_EDGE: %w", err)
+	}
+	var quality *qualityConfig
+	if v := os.Getenv("FACE_QUALITY_WEIGHTS"); v != "" {
+		w, err := parseQualityWeights(v)
+		if err != nil {
+			return DetectorConfig{}, fmt.Errorf("FACE_QUALITY_WEIGHTS: %w", err)
+		}
+		quality = &qualityConfig{
+			WScore: w[0], WSize: w[1], WSharp: w[2],
+			SizeRef:  float64(getenvFloat32Default("FACE_QUALITY_SIZE_REF", 112)),
+			SharpRef: float64(getenvFloat32Default("FACE_QUALITY_SHARP_REF", 100)),
+		}
+	}
+	trackSmooth := float64(getenvFloat32Default("FACE_TRACK_SMOOTH", 0))
+	if trackSmooth < 0 || trackSmooth > 1 {
+		return DetectorConfig{}, fmt.Errorf("FACE_TRACK_SMOOTH: want 0 (off) to 1, got %g", trackSmooth)
+	}
+	trackPredict := getenvIntDefault("FACE_TRACK_PREDICT", 0)
+	if trackPredict < 0 {
+		return DetectorConfig{}, fmt.Errorf("FACE_TRACK_PREDICT: must be >= 0, got %d", trackPredict)
+	}
+	ids, err := parseIDStrategy(os.Getenv("FACE_ID_STRATEGY"), getenvDefault("FACE_TRACK", "0") == "1")
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_ID_STRATEGY: %w", err)
+	}
+	order, err := parseDetectionOrder(os.Getenv("FACE_DETECTION_ORDER"))
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_DETECTION_ORDER: %w", err)
+	}
+	var zoneEvents int
+	if getenvDefault("FACE_ZONE_EVENTS", "0") == "1" {
+		if ids != idStrategyTrack || len(zones) == 0 {
+			return DetectorConfig{}, errors.New("FACE_ZONE_EVENTS needs FACE_ZONES and tracking (FACE_ID_STRATEGY=track)")
+		}
+		if zoneEvents = getenvIntDefault("FACE_ZONE_DEBOUNCE", 3); zoneEvents < 1 {
+			return DetectorConfig{}, fmt.Errorf("FACE_ZONE_DEBOUNCE: must be >= 1, got %d", zoneEvents)
+		}
+	}
+	if os.Getenv("FACE_STABLE_AFTER") != "" && ids != idStrategyTrack {
+		return DetectorConfig{}, errors.New("FACE_STABLE_AFTER needs tracking (FACE_ID_STRATEGY=track)")
+	}
+	backend, target, err := parseDNNTarget(getenvDefault("FACE_DNN_TARGET", "cpu"))
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_DNN_TARGET: %w", err)
+	}
+	workers := getenvIntDefault("FACE_WORKERS", 0)
+	if workers < 0 {
+		return DetectorConfig{}, fmt.Errorf("FACE_WORKERS: must be >= 0, got %d", workers)
+	}
+	latency, err := parseLatencyTarget(getenvDurationDefault("FACE_LATENCY_TARGET", 0),
+		getenvDurationDefault("FACE_LATENCY_MIN_INTERVAL", 10*time.Millisecond),
+		getenvDurationDefault("FACE_LATENCY_MAX_INTERVAL", time.Second),
+		getenvIntDefault("FACE_LATENCY_MIN_INPUT", 0))
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_LATENCY_TARGET: %w", err)
+	}
+	if latency != nil && workers > 0 {
+		return DetectorConfig{}, errors.New("FACE_LATENCY_TARGET: can't be combined with FACE_WORKERS")
+	}
+	// http(s) model files are downloaded once into the cache
+	modelCache := getenvDefault("FACE_MODEL_CACHE", defaultModelCache)
+	protoTxt, err := resolveModelPath(getenvDefault("FACE_PROTOTXT", defaultProtoTxt), os.Getenv("FACE_PROTOTXT_SHA256"), modelCache)
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_PROTOTXT: %w", err)
+	}
+	model, err := resolveModelPath(getenvDefault("FACE_MODEL", defaultModel), os.Getenv("FACE_MODEL_SHA256"), modelCache)
+	if err != nil {
+		return DetectorConfig{}, fmt.Errorf("FACE_MODEL: %w", err)
+	}
+
+	return DetectorConfig{
+		Source:       getenvDefault("FACE_SOURCE", "0"), // webcam 0 by default
+		CaptureAPI:   capAPI,
+		ProtoTxtPath: protoTxt,
+		ModelPath:    model,
+		Interval:     getenvDurationDefault("FACE_INTERVAL", 200*time.Millisecond),
+		Confidence:   getenvFloat32Default("FACE_CONF", 0.5),
+		Calibration:  calib,
+		Selection:    selection,
+		InputW:       300,
+		InputH:       300,
+		ColorSpace:   colors,
+		Deinterlace:  deint,
+		SwapRB:       getenvDefault("FACE_SWAP_RB", "0") == "1",
+		MaxYaw:       getenvFloat32Default("FACE_MAX_YAW", 0), // degrees, 0 = disabled
+		Range:        rangeRef,
+		BoxScale:     boxScale,
+		Edges:        edges,
+		Quality:      quality,
+		MinAspect:    float64(getenvFloat32Default("FACE_MIN_ASPECT", 0.5)),
+		MaxAspect:    float64(getenvFloat32Default("FACE_MAX_ASPECT", 1.5)),
+		ClusterDist:  getenvIntDefault("FACE_CLUSTER_DIST", 0), // px, 0 = individual faces
+		OutputLayers: splitList(os.Getenv("FACE_OUTPUT_LAYERS")),
+		WarmUp:       getenvDefault("FACE_WARMUP", "1") == "1",
+		Backend:      backend,
+		Target:       target,
+		ROI:          roi,
+		RotatedROI:   rotROI,
+		ROICoords:    roiCoords == "roi",
+		Classifier:   classifier,
+		ClockOffset:  getenvDurationDefault("FACE_CLOCK_OFFSET", 0),
+		ReinitAfter:  getenvIntDefault("FACE_REINIT_AFTER", 10),
+		FrozenFrames: getenvIntDefault("FACE_FROZEN_FRAMES", 0),
+		Zones:        zones,
+		ZoneEvents:   zoneEvents,
+		Tiling:       tiling,
+		Latency:      latency,
+
+		Track:          ids == idStrategyTrack,
+		SpatialIDs:     ids == idStrategySpatial,
+		TrackIoU:       float64(getenvFloat32Default("FACE_TRACK_IOU", 0.3)),
+		TrackMaxMissed: getenvIntDefault("FACE_TRACK_MAX_MISSED", 5),
+		TrackSmooth:    trackSmooth,
+		TrackPredict:   trackPredict,
+		StableAfter:    getenvDurationDefault("FACE_STABLE_AFTER", 0),
+		StableMove:     float64(getenvFloat32Default("FACE_STABLE_MOVE", 0.1)),
+		Order:          order,
+
+		CaptureBuffer: getenvIntDefault("FACE_CAP_BUFFER", 1),
+
+		Workers:    workers,
+		QueueDepth: getenvIntDefault("FACE_QUEUE_DEPTH", 1),
+	}, nil
+}
+
+const (
+	defaultProtoTxt   = "models/deploy.prototxt"
+	defaultModel      = "models/res10_300x300_ssd_iter_140000.caffemodel"
+	defaultModelCache = "models/cache"
+)
+
 func main() {
-	prototxt := getenvRequired("FACE_PROTOTXT", "models/deploy.prototxt")
-	model := getenvRequired("FACE_MODEL", "models/res10_300x300_ssd_iter_140000.caffemodel")
+	// Validate the configuration and exit (CI/deploy pipelines)
+	check := flag.Bool("check", false, "validate the configuration, print a JSON report and exit (1 on problems)")
+	flag.Parse()
+	if *check {
+		os.Exit(runConfigCheck(os.Stdout))
+	}
+
+	// Optional KEY=VALUE file, re-read on reload
+	envFile := os.Getenv("FACE_ENV_FILE")
+	if envFile != "" {
+		if err := loadEnvFile(envFile); err != nil {
+			log.Fatalf("FACE_ENV_FILE: %v", err)
+		}
+	}
+
+	// OpenCV/FFmpeg verbosity, before anything opens a capture or a net
+	if err := applyOpenCVLogLevel(os.Getenv("FACE_OPENCV_LOG_LEVEL")); err != nil {
+		log.Fatalf("FACE_OPENCV_LOG_LEVEL: %v", err)
+	}
+
+	// Recent log lines kept for /debug/logs, next to the console
+	var logs *LogRing
+	if n := getenvIntDefault("FACE_LOG_RING", 500); n > 0 {
+		logs = NewLogRing(n)
+		log.SetOutput(io.MultiWriter(os.Stderr, logs))
+	}
+
+	// Model files must exist (or be http(s) URLs)
+	getenvRequired("FACE_PROTOTXT", defaultProtoTxt)
+	getenvRequired("FACE_MODEL", defaultModel)
 
 	// Video source and loop tuning
-	source := getenvDefault("FACE_SOURCE", "0") // webcam 0 by default
-	interval := getenvDurationDefault("FACE_INTERVAL", 200*time.Millisecond)
-	conf := getenvFloat32Default("FACE_CONF", 0.5)
+	detCfg, err := loadDetectorConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Privacy mode: only aggregate counts, no boxes or images anywhere
+	countOnly, err := loadPrivacyMode()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Retained frames for /face/<frame>/<id>.jpg (disabled by default)
+	retainFrames := getenvIntDefault("FACE_RETAIN_FRAMES", 0)
+	retainMB := getenvIntDefault("FACE_RETAIN_MB", 64)         // pixel memory budget for retained frames
+	retainMaxDim := getenvIntDefault("FACE_RETAIN_MAX_DIM", 0) // px, longest side of retained copies
+	frames := NewFrameRing(retainFrames, int64(retainMB)<<20, retainMaxDim)
+
+	// Text drawn above each box on the preview, highlights and captures
+	label, err := loadOverlayLabel()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Latest frame for /snapshot.jpg and /stream.mjpg
+	var preview *Preview
+	if getenvDefault("FACE_PREVIEW", "1") == "1" {
+		trails := NewTrails(getenvIntDefault("FACE_TRAIL_LENGTH", 0), float64(getenvFloat32Default("FACE_TRAIL_FADE", 0.2)))
+		preview = NewPreview(trails, float64(getenvFloat32Default("FACE_OVERLAY_MIN_SCORE", 0)), label)
+	}
+	if countOnly {
+		log.Printf("[privacy] count-only mode: frame retention, crops and preview disabled")
+		frames, preview = nil, nil
+	}
+
+	// Output format of /snapshot.jpg, /stream.mjpg and crops
+	images, err := loadImageEncoders()
+	if err != nil {
+		log.Fatalf("[http] %v", err)
+	}
+
+	// Scores as served in the JSON
+	scores, err := parseScoreFormat(getenvDefault("FACE_SCORE_UNITS", "fraction"), getenvIntDefault("FACE_SCORE_DECIMALS", -1))
+	if err != nil {
+		log.Fatalf("FACE_SCORE_UNITS: %v", err)
+	}
+	jsonCase, err := parseJSONCase(getenvDefault("FACE_JSON_CASE", "snake"))
+	if err != nil {
+		log.Fatalf("FACE_JSON_CASE: %v", err)
+	}
 
 	// Static dir
 	staticDir := getenvDefault("FACE_STATIC", "public")
-	if _, err := os.Stat(staticDir); os.IsNotExist(err) {
-		log.Printf("[warn] static directory %q not found, creating it", staticDir)
-		_ = os.MkdirAll(staticDir, 0755)
+	if staticDir == "off" {
+		staticDir = ""
+	}
+
+	// Fail early with an actionable message if the source can't be opened
+	detCfg.CaptureAPI, err = ProbeSource(detCfg.Source, detCfg.CaptureAPI)
+	if err != nil {
+		log.Printf("[capture] cannot open source %q: %v", detCfg.Source, err)
+		os.Exit(exitSourceUnavailable)
+	}
+
+	store := NewFaceStore()
+	if countOnly {
+		store = NewCountOnlyFaceStore()
+	}
+	if tz := os.Getenv("FACE_PEAKS_TZ"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			log.Fatalf("FACE_PEAKS_TZ: %v", err)
+		}
+		store.Peaks().SetLocation(loc)
+	}
+	seq := &FrameCounter{}
+	stateFile := os.Getenv("FACE_STATE_FILE")
+	if stateFile != "" {
+		if err := restoreState(stateFile, store, seq, detCfg.Interval); err != nil {
+			log.Fatalf("FACE_STATE_FILE: %v", err)
+		}
+		if seq.Last() > 0 {
+			log.Printf("[state] frame numbers continue after %d", seq.Last())
+		}
 	}
 
-	store := &FaceStore{}
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
+	lc, ctx := NewLifecycle(sigCtx)
+	playback := NewPlayback()
+	lc.Playback = playback
+	var models *Models
+	if dir := os.Getenv("FACE_MODELS_DIR"); dir != "" {
+		models = NewModels(dir)
+	}
+	var trigger *Trigger
+	if dir := os.Getenv("FACE_CAPTURE_DIR"); dir != "" && !countOnly {
+		if trigger, err = NewTrigger(dir, label); err != nil {
+			log.Fatalf("FACE_CAPTURE_DIR: %v", err)
+		}
+	}
+	var feedback *Feedback
+	if dir := os.Getenv("FACE_NEGATIVES_DIR"); dir != "" && !countOnly {
+		if frames == nil {
+			log.Fatalf("FACE_NEGATIVES_DIR: needs FACE_RETAIN_FRAMES, negatives are cropped from the retained frames")
+		}
+		if feedback, err = NewFeedback(dir, frames, images.Negotiate("")); err != nil {
+			log.Fatalf("FACE_NEGATIVES_DIR: %v", err)
+		}
+	}
+	var regions *RegionScorer
+	if frames != nil {
+		// The classifier runs in the single detector loop only
+		regions = NewRegionScorer(frames, detCfg.Classifier != nil && detCfg.Workers == 0)
+	}
+	var presence *Presence
+	if hold := getenvDurationDefault("FACE_PRESENCE_HOLD", 0); hold > 0 {
+		presence = NewPresence(hold)
+	}
+	var highlights *Highlights
+	if dir := os.Getenv("FACE_HIGHLIGHTS_DIR"); dir != "" && !countOnly {
+		fps := float64(time.Second) / float64(max(detCfg.Interval, time.Millisecond))
+		highlights, err = NewHighlights(dir, fps, getenvIntDefault("FACE_HIGHLIGHTS_PRE", 5), getenvIntDefault("FACE_HIGHLIGHTS_POST", 5), label)
+		if err != nil {
+			log.Fatalf("FACE_HIGHLIGHTS_DIR: %v", err)
+		}
+		defer highlights.Close() // after the detector has stopped
+	}
+	var crops *CropSaver
+	if dir := os.Getenv("FACE_CROP_DIR"); dir != "" && !countOnly {
+		crops, err = NewCropSaver(dir, getenvDurationDefault("FACE_CROP_COOLDOWN", 5*time.Second), getenvDefault("FACE_CROP_BEST_ONLY", "0") == "1", images.Negotiate(""))
+		if err != nil {
+			log.Fatalf("FACE_CROP_DIR: %v", err)
+		}
+		defer crops.Close() // after the detector has stopped
+	}
+	// One memory cap over the retained frames, pre-roll and pending crops
+	memory := NewMemoryBudget(int64(getenvIntDefault("FACE_MEMORY_MB", 0)) << 20)
+	if memory != nil {
+		if frames != nil {
+			memory.Register("frames", frames)
+		}
+		if highlights != nil {
+			memory.Register("highlights", highlights)
+		}
+		if crops != nil {
+			memory.Register("crops", crops)
+		}
+	}
+	var ingest *Ingest
+	if getenvDefault("FACE_INGEST", "0") == "1" {
+		ingest = NewIngest(int64(getenvIntDefault("FACE_INGEST_MAX_BYTES", 10<<20)))
+	}
 
-	// Background detector
-	go StartDetectorLoop(ctx, DetectorConfig{
-		Source:       source,
-		ProtoTxtPath: prototxt,
-		ModelPath:    model,
-		Interval:     interval,
-		Confidence:   conf,
-		InputW:       300,
-		InputH:       300,
-	}, store)
+	// Background detector, restarted with a fresh config on reload
+	stats := NewStats()
+
+	// Detection gated by a sensor (PIR...) on a GPIO input
+	var pause *Pause
+	if pin := getenvIntDefault("FACE_GPIO_PIN", -1); pin >= 0 {
+		in, err := openGPIO(pin)
+		if err != nil {
+			log.Fatalf("FACE_GPIO_PIN: %v", err)
+		}
+		pause = &Pause{}
+		gate := NewGPIOGate(in, getenvDefault("FACE_GPIO_ACTIVE_LOW", "0") == "1",
+			getenvDurationDefault("FACE_GPIO_HOLD", 0), getenvDurationDefault("FACE_GPIO_POLL", 100*time.Millisecond), pause, stats)
+		go gate.Run(ctx)
+		log.Printf("[gpio] detection gated by pin %d", pin)
+	}
+
+	// Output sinks (Kafka, SQLite...) fed with every published snapshot
+	var sinks *SinkFanout
+	if configs, err := loadSinkConfigs(); err != nil {
+		log.Fatal(err)
+	} else if len(configs) > 0 {
+		opened, err := openSinks(configs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var done <-chan struct{}
+		sinks, done = StartSinks(ctx, configs, opened, stats)
+		defer func() { <-done }()
+	}
+	sup := &detectorSupervisor{ctx: ctx, pipeline: &Pipeline{Store: store, Frames: frames, Preview: preview, Playback: playback, Pause: pause, Ingest: ingest, Models: models, Trigger: trigger, Regions: regions, Presence: presence, Stats: stats, Seq: seq, Highlights: highlights, Crops: crops, Sinks: sinks, Memory: memory}}
+	if err := sup.Start(detCfg); err != nil {
+		log.Fatalf("[detector] init error: %v", err)
+	}
+	defer sup.Wait()
+
+	lc.Reload = func() error {
+		if envFile != "" {
+			if err := loadEnvFile(envFile); err != nil {
+				return err
+			}
+		}
+		cfg, err := loadDetectorConfig()
+		if err != nil {
+			return err
+		}
+		return sup.Reload(cfg, detectorStopGrace)
+	}
+	go reloadOnSIGHUP(ctx, lc)
+
+	// Persist peaks across restarts
+	if stateFile != "" {
+		done := StartStateSaver(ctx, stateFile, store, seq)
+		defer func() { <-done }()
+	}
+
+	// Notify presence transitions
+	if url := os.Getenv("FACE_PRESENCE_WEBHOOK"); url != "" && presence != nil {
+		StartPresenceWebhook(ctx, presence, url)
+	}
+
+	// Restart the detector if it stops producing snapshots
+	if wd := getenvDurationDefault("FACE_WATCHDOG", 0); wd > 0 {
+		go watchDetector(ctx, store, sup, wd)
+	}
 
 	// HTTP server (static + JSON)
-	if err := StartHTTPServer(ctx, ":8080", store, staticDir); err != nil {
+	if err := StartHTTPServer(ctx, ServerConfig{
+		Addr:             listenAddr,
+		StaticDir:        staticDir,
+		EarlyHints:       getenvDefault("FACE_EARLY_HINTS", "1") == "1",
+		Frames:           frames,
+		Memory:           memory,
+		Preview:          preview,
+		Images:           images,
+		Ingest:           ingest,
+		Models:           models,
+		Trigger:          trigger,
+		Feedback:         feedback,
+		Regions:          regions,
+		Presence:         presence,
+		MetricsExemplars: getenvDefault("FACE_METRICS_EXEMPLARS", "0") == "1",
+		HealthMaxAge:     getenvDurationDefault("FACE_HEALTH_MAX_AGE", 10*time.Second),
+		MaxSnapshotAge:   getenvDurationDefault("FACE_MAX_SNAPSHOT_AGE", 0),
+		StreamJSON:       getenvDefault("FACE_JSON_BUFFER", "1") == "0",
+		Scores:           scores,
+		JSONCase:         jsonCase,
+		Stats:            stats,
+		Lifecycle:        lc,
+		ControlToken:     os.Getenv("FACE_CONTROL_TOKEN"),
+		Logs:             logs,
+		DrainTimeout:     getenvDurationDefault("FACE_SHUTDOWN_TIMEOUT", 5*time.Second),
+		RateLimit:        float64(getenvFloat32Default("FACE_RATE_LIMIT", 0)),
+		RateBurst:        getenvIntDefault("FACE_RATE_BURST", 0),
+		AccessLogSample:  getenvIntDefault("FACE_ACCESS_LOG_SAMPLE", 1),
+		AccessLogSkip:    splitList(os.Getenv("FACE_ACCESS_LOG_SKIP")),
+		TLSCert:          os.Getenv("FACE_TLS_CERT"),
+		TLSKey:           os.Getenv("FACE_TLS_KEY"),
+		TLSSelfSigned:    getenvDefault("FACE_TLS_SELFSIGNED", "0") == "1",
+	}, store); err != nil {
 		log.Fatal(err)
 	}
 }
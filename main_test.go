@@ -0,0 +1,243 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFaceStoreSetCopies(t *testing.T) {
+	store := NewFaceStore()
+	dets := []Detection{{ID: 1, BBox: Rect{X: 10}, Landmarks: []Point{{X: 1}}}}
+	store.Set(Snapshot{Frame: 1, Detections: dets})
+
+	// The caller reuses its buffers for the next frame.
+	dets[0].BBox.X = 99
+	dets[0].Landmarks[0].X = 99
+
+	got, ver := store.Get()
+	if ver != 1 {
+		t.Fatalf("version = %d, want 1", ver)
+	}
+	if got.Detections[0].BBox.X != 10 || got.Detections[0].Landmarks[0].X != 1 {
+		t.Fatalf("stored snapshot changed with the caller's slice: %+v", got.Detections[0])
+	}
+}
+
+func TestFaceStoreConcurrent(t *testing.T) {
+	const writers, frames = 4, 200
+	store := NewFaceStore()
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dets := make([]Detection, 3)
+			for i := range frames {
+				for j := range dets {
+					dets[j] = Detection{ID: j, BBox: Rect{X: i, Y: w}}
+				}
+				store.Set(Snapshot{Frame: int64(i), Detections: dets})
+			}
+		}()
+	}
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for range 4 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			var last uint64
+			for {
+				select {
+				case <-stop:
+					return
+				case <-store.Changed():
+				default:
+				}
+				snap, ver := store.Get()
+				if ver < last {
+					t.Errorf("version went back from %d to %d", last, ver)
+					return
+				}
+				last = ver
+				for _, d := range snap.Detections {
+					// Every box of a snapshot comes from the same write.
+					if d.BBox != snap.Detections[0].BBox {
+						t.Errorf("torn snapshot: %+v", snap.Detections)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	if _, ver := store.Get(); ver != writers*frames {
+		t.Fatalf("version = %d, want %d", ver, writers*frames)
+	}
+}
+
+func TestFaceStoreConcurrentUpdate(t *testing.T) {
+	const rounds = 200
+	store := NewFaceStore()
+
+	// Two stages fill in their own fields of the same snapshot.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range rounds {
+			store.Update(func(s *Snapshot) {
+				s.Frame = int64(i + 1)
+				s.Detections = append(s.Detections[:0], Detection{ID: i})
+			})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := range rounds {
+			store.Update(func(s *Snapshot) {
+				s.Zones = append(s.Zones[:0], ZoneCount{Name: "door", Count: i + 1})
+			})
+		}
+	}()
+	wg.Wait()
+
+	snap, ver := store.Get()
+	if ver != 2*rounds {
+		t.Fatalf("version = %d, want %d", ver, 2*rounds)
+	}
+	// Neither stage overwrote the other's last write.
+	if snap.Frame != rounds || snap.Detections[0].ID != rounds-1 || snap.Zones[0].Count != rounds {
+		t.Fatalf("final snapshot = frame %d, %+v, %+v", snap.Frame, snap.Detections, snap.Zones)
+	}
+}
+
+func TestETagAcrossRestarts(t *testing.T) {
+	// Two processes publishing the same version and frame, e.g. before and
+	// after a restart that restored the frame counter.
+	before, after := NewFaceStore(), NewFaceStore()
+	before.Set(Snapshot{Frame: 42})
+	after.Set(Snapshot{Frame: 42})
+	_, v1 := before.Get()
+	_, v2 := after.Get()
+	if v1 != v2 {
+		t.Fatalf("versions %d and %d, want equal", v1, v2)
+	}
+	if before.ETag(v1, 42) == after.ETag(v2, 42) {
+		t.Fatalf("ETag %s repeated across restarts", before.ETag(v1, 42))
+	}
+
+	get := func(store *FaceStore, inm string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/count", nil)
+		if inm != "" {
+			r.Header.Set("If-None-Match", inm)
+		}
+		w := httptest.NewRecorder()
+		countHandler(store)(w, r)
+		return w
+	}
+	etag := get(before, "").Header().Get("ETag")
+	if w := get(before, etag); w.Code != http.StatusNotModified {
+		t.Fatalf("same process: %d, want 304", w.Code)
+	}
+	if w := get(after, etag); w.Code != http.StatusOK {
+		t.Fatalf("ETag of the previous process: %d, want 200", w.Code)
+	}
+}
+
+func TestETagChangesWithVersion(t *testing.T) {
+	store := NewFaceStore()
+	seen := map[string]bool{}
+	for range 3 {
+		store.Set(Snapshot{Frame: 1}) // a republished frame is still a new version
+		_, ver := store.Get()
+		etag := store.ETag(ver, 1)
+		if seen[etag] {
+			t.Fatalf("ETag %s repeated", etag)
+		}
+		seen[etag] = true
+	}
+}
+
+func TestSnapshotClone(t *testing.T) {
+	orig := Snapshot{
+		Detections: []Detection{{
+			ID:         1,
+			Landmarks:  []Point{{X: 1}},
+			Pose:       &Pose{Yaw: 1},
+			Attributes: map[string]float64{"mask": 0.1},
+			Velocity:   &Velocity{VX: 1},
+		}},
+		Raw:    []Detection{{ID: 1, Velocity: &Velocity{VX: 1}}},
+		Zones:  []ZoneCount{{Name: "door", Count: 1}},
+		Events: []TrackEvent{{Type: "stable", ID: 1}},
+	}
+	c := orig.clone()
+	c.Detections[0].Landmarks[0].X = 9
+	c.Detections[0].Pose.Yaw = 9
+	c.Detections[0].Attributes["mask"] = 9
+	c.Detections[0].Velocity.VX = 9
+	c.Raw[0].ID = 9
+	c.Raw[0].Velocity.VX = 9
+	c.Zones[0].Count = 9
+	c.Events[0].ID = 9
+
+	d := orig.Detections[0]
+	if d.Landmarks[0].X != 1 || d.Pose.Yaw != 1 || d.Attributes["mask"] != 0.1 || d.Velocity.VX != 1 {
+		t.Fatalf("detection shared with the clone: %+v", d)
+	}
+	if orig.Raw[0].ID != 1 || orig.Raw[0].Velocity.VX != 1 {
+		t.Fatalf("raw shared with the clone: %+v", orig.Raw[0])
+	}
+	if orig.Zones[0].Count != 1 || orig.Events[0].ID != 1 {
+		t.Fatalf("zones or events shared with the clone: %+v %+v", orig.Zones, orig.Events)
+	}
+
+	// No per-face data, nothing to copy.
+	if c := (Snapshot{Frame: 3}).clone(); c.Detections != nil || c.Raw != nil || c.Frame != 3 {
+		t.Fatalf("clone of an empty snapshot = %+v", c)
+	}
+}
+
+func TestFacesMaxSnapshotAge(t *testing.T) {
+	now := time.Now()
+	skewed := NewStats()
+	skewed.SetClockOffset(time.Hour)
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		gen    time.Time // zero: nothing published yet
+		stats  *Stats
+		code   int
+	}{
+		{"fresh", 2500 * time.Millisecond, now, nil, http.StatusOK},
+		{"aged", 2500 * time.Millisecond, now.Add(-10 * time.Second), nil, http.StatusServiceUnavailable},
+		{"disabled", 0, now.Add(-time.Hour), nil, http.StatusOK},
+		{"never generated", 2500 * time.Millisecond, time.Time{}, nil, http.StatusServiceUnavailable},
+		{"fresh with a clock offset", 2500 * time.Millisecond, now.Add(time.Hour), skewed, http.StatusOK},
+		{"aged with a clock offset", 2500 * time.Millisecond, now, skewed, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		store := NewFaceStore()
+		if !tt.gen.IsZero() {
+			store.Set(Snapshot{Frame: 1, GeneratedAt: tt.gen})
+		}
+		w := httptest.NewRecorder()
+		facesHandler(ServerConfig{MaxSnapshotAge: tt.maxAge, Stats: tt.stats}, store)(w, httptest.NewRequest(http.MethodGet, "/faces", nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: %d, want %d", tt.name, w.Code, tt.code)
+		}
+		if retry := w.Header().Get("Retry-After"); (tt.code == http.StatusServiceUnavailable) != (retry == "3") {
+			t.Errorf("%s: Retry-After %q", tt.name, retry)
+		}
+	}
+}
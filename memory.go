@@ -0,0 +1,129 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+/* ------------------------------ Memory budget ------------------------------ */
+
+// memoryHolder is a feature keeping frames in memory (retained frames,
+// highlight pre-roll, pending crops) that can give up its oldest entry when
+// the global budget is exceeded. It is only called from the goroutine
+// publishing snapshots, like the holders' own Observe/Add.
+type memoryHolder interface {
+	memoryUsage() int64        // estimated bytes held
+	oldest() (time.Time, bool) // time of the oldest evictable entry
+	evict() (freed int64)      // drops the oldest entry
+}
+
+// MemoryBudget is a soft cap on the memory of all the holders together, on
+// top of their own limits. When the estimated usage exceeds it after a
+// frame is published, the oldest entries across holders are evicted first
+// until it fits again.
+type MemoryBudget struct {
+	limit   int64
+	names   []string
+	holders []memoryHolder
+
+	mu        sync.Mutex
+	usage     map[string]int64 // as of the last Enforce
+	evictions map[string]int64
+	evicted   int64 // bytes
+}
+
+// NewMemoryBudget returns a budget of limit bytes, or nil (no global cap)
+// when limit <= 0.
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &MemoryBudget{limit: limit, usage: make(map[string]int64), evictions: make(map[string]int64)}
+}
+
+// Register adds a holder under name (as reported in /stats). Holders are
+// registered before the first Enforce.
+func (b *MemoryBudget) Register(name string, h memoryHolder) {
+	b.names = append(b.names, name)
+	b.holders = append(b.holders, h)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.usage[name], b.evictions[name] = 0, 0
+}
+
+// Enforce evicts the oldest entries until the holders fit the budget. A
+// holder with nothing evictable left is skipped, so usage may stay above
+// the limit when what remains can't be given up.
+func (b *MemoryBudget) Enforce() {
+	usage := make([]int64, len(b.holders))
+	var total int64
+	for i, h := range b.holders {
+		usage[i] = h.memoryUsage()
+		total += usage[i]
+	}
+	evictions := make([]int64, len(b.holders))
+	var evicted int64
+	for total > b.limit {
+		victim := -1
+		var at time.Time
+		for i, h := range b.holders {
+			if t, ok := h.oldest(); ok && (victim < 0 || t.Before(at)) {
+				victim, at = i, t
+			}
+		}
+		if victim < 0 {
+			break
+		}
+		freed := b.holders[victim].evict()
+		usage[victim] -= freed
+		total -= freed
+		evictions[victim]++
+		evicted += freed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, name := range b.names {
+		b.usage[name] = usage[i]
+		b.evictions[name] += evictions[i]
+	}
+	b.evicted += evicted
+}
+
+// MemoryStats is the memory budget section of /stats.
+type MemoryStats struct {
+	BudgetBytes  int64            `json:"budget_bytes"`
+	Bytes        int64            `json:"bytes"` // estimated, as of the last published frame
+	Usage        map[string]int64 `json:"usage"` // bytes per holder
+	Evictions    map[string]int64 `json:"evictions"`
+	EvictedBytes int64            `json:"evicted_bytes"`
+}
+
+func (b *MemoryBudget) Stats() MemoryStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := MemoryStats{
+		BudgetBytes:  b.limit,
+		Usage:        make(map[string]int64, len(b.usage)),
+		Evictions:    make(map[string]int64, len(b.evictions)),
+		EvictedBytes: b.evicted,
+	}
+	for name, n := range b.usage {
+		st.Usage[name] = n
+		st.Bytes += n
+	}
+	for name, n := range b.evictions {
+		st.Evictions[name] = n
+	}
+	return st
+}
+
+// snapshotBytes is a rough estimate of the memory of a retained snapshot:
+// the struct and strings, plus each detection and its landmarks.
+func snapshotBytes(snap Snapshot) int64 {
+	n := int64(256 + 32*len(snap.Zones))
+	for _, d := range snap.Detections {
+		n += 256 + 16*int64(len(d.Landmarks)) + 48*int64(len(d.Attributes))
+	}
+	return n
+}
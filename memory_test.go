@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeHolder holds entries of the given sizes, oldest first, aged by at.
+type fakeHolder struct {
+	sizes []int64
+	at    []time.Time
+}
+
+func (h *fakeHolder) memoryUsage() int64 {
+	var n int64
+	for _, s := range h.sizes {
+		n += s
+	}
+	return n
+}
+
+func (h *fakeHolder) oldest() (time.Time, bool) {
+	if len(h.sizes) == 0 {
+		return time.Time{}, false
+	}
+	return h.at[0], true
+}
+
+func (h *fakeHolder) evict() int64 {
+	n := h.sizes[0]
+	h.sizes, h.at = h.sizes[1:], h.at[1:]
+	return n
+}
+
+// pinnedHolder uses memory but has nothing it can evict.
+type pinnedHolder int64
+
+func (h pinnedHolder) memoryUsage() int64      { return int64(h) }
+func (pinnedHolder) oldest() (time.Time, bool) { return time.Time{}, false }
+func (pinnedHolder) evict() int64              { panic("evict with nothing evictable") }
+
+func TestMemoryBudgetEnforce(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := func(secs ...int) []time.Time {
+		var out []time.Time
+		for _, s := range secs {
+			out = append(out, t0.Add(time.Duration(s)*time.Second))
+		}
+		return out
+	}
+	frames := &fakeHolder{sizes: []int64{100, 100, 100}, at: ts(1, 4, 6)}
+	crops := &fakeHolder{sizes: []int64{50, 50}, at: ts(2, 5)}
+
+	b := NewMemoryBudget(300)
+	b.Register("frames", frames)
+	b.Register("crops", crops)
+
+	// 400 bytes for 300: the oldest entries go first, across holders,
+	// until it fits.
+	b.Enforce()
+	st := b.Stats()
+	want := MemoryStats{
+		BudgetBytes:  300,
+		Bytes:        300,
+		Usage:        map[string]int64{"frames": 200, "crops": 100},
+		Evictions:    map[string]int64{"frames": 1, "crops": 0},
+		EvictedBytes: 100,
+	}
+	if !reflect.DeepEqual(st, want) {
+		t.Fatalf("after exceeding the budget:\n got %+v\nwant %+v", st, want)
+	}
+	if !reflect.DeepEqual(frames.at, ts(4, 6)) || !reflect.DeepEqual(crops.at, ts(2, 5)) {
+		t.Errorf("kept frames %v, crops %v: want the oldest evicted", frames.at, crops.at)
+	}
+
+	// Within the budget: counters unchanged.
+	b.Enforce()
+	if st := b.Stats(); !reflect.DeepEqual(st, want) {
+		t.Errorf("within the budget: %+v, want %+v", st, want)
+	}
+
+	// A new frame tips it over again: the oldest crop, then the oldest
+	// frame go, and the counters accumulate.
+	frames.sizes, frames.at = append(frames.sizes, 100), append(frames.at, ts(7)...)
+	b.Enforce()
+	st = b.Stats()
+	if st.Bytes != 250 || st.Evictions["frames"] != 2 || st.Evictions["crops"] != 1 || st.EvictedBytes != 250 {
+		t.Errorf("after a second overflow: %+v", st)
+	}
+
+	// Nothing evictable left: Enforce gives up, usage stays above the limit.
+	small := NewMemoryBudget(10)
+	small.Register("pinned", pinnedHolder(50))
+	small.Register("empty", &fakeHolder{})
+	done := make(chan struct{})
+	go func() {
+		small.Enforce()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Enforce never returned with nothing to evict")
+	}
+	if st := small.Stats(); st.Bytes != 50 || st.EvictedBytes != 0 || st.Evictions["pinned"] != 0 {
+		t.Errorf("nothing evictable: %+v", st)
+	}
+	if NewMemoryBudget(0) != nil {
+		t.Errorf("NewMemoryBudget(0) != nil")
+	}
+}
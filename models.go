@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* --------------------------------- Models ---------------------------------- */
+
+// errUnknownModel is returned when switching to a model that isn't listed.
+var errUnknownModel = errors.New("unknown model")
+
+// ModelEntry is a model found in the models directory.
+type ModelEntry struct {
+	Name     string `json:"name"`
+	Model    string `json:"model"`  // .caffemodel path
+	ProtoTxt string `json:"config"` // .prototxt path
+}
+
+// Models lists the models of a directory and carries switch requests to the
+// detector loop, which loads the new net between frames while the capture
+// stays open. The chosen model outlives detector restarts and reloads.
+type Models struct {
+	dir      string
+	requests chan modelRequest
+
+	mu     sync.Mutex
+	active *ModelEntry // nil = the configured FACE_MODEL
+}
+
+type modelRequest struct {
+	entry ModelEntry
+	reply chan error
+}
+
+func NewModels(dir string) *Models {
+	return &Models{dir: dir, requests: make(chan modelRequest)}
+}
+
+// List returns the models of the directory, sorted by name. Each
+// <name>.caffemodel is paired with <name>.prototxt, or with deploy.prototxt
+// when there is no such file.
+func (m *Models) List() ([]ModelEntry, error) {
+	files, err := filepath.Glob(filepath.Join(m.dir, "*.caffemodel"))
+	if err != nil {
+		return nil, err
+	}
+	var out []ModelEntry
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".caffemodel")
+		proto := filepath.Join(m.dir, name+".prototxt")
+		if _, err := os.Stat(proto); err != nil {
+			proto = filepath.Join(m.dir, "deploy.prototxt")
+			if _, err := os.Stat(proto); err != nil {
+				continue
+			}
+		}
+		out = append(out, ModelEntry{Name: name, Model: f, ProtoTxt: proto})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Switch makes the named model active. The detector loads it first and
+// keeps the current model if that fails.
+func (m *Models) Switch(ctx context.Context, name string) error {
+	list, err := m.List()
+	if err != nil {
+		return err
+	}
+	i := sort.Search(len(list), func(i int) bool { return list[i].Name >= name })
+	if i == len(list) || list[i].Name != name {
+		return fmt.Errorf("%w %q", errUnknownModel, name)
+	}
+	req := modelRequest{entry: list[i], reply: make(chan error, 1)}
+	select {
+	case m.requests <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// Once accepted, the loop swaps the model whatever happens to ctx: wait
+	// for the outcome rather than report a switch that happened as failed.
+	return <-req.reply
+}
+
+// setActive records a successful switch; called by the detector loop.
+func (m *Models) setActive(e ModelEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active = &e
+}
+
+// apply overrides the model paths of cfg with the active model, if any.
+func (m *Models) apply(cfg DetectorConfig) DetectorConfig {
+	if m == nil {
+		return cfg
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active != nil {
+		cfg.ModelPath, cfg.ProtoTxtPath = m.active.Model, m.active.ProtoTxt
+	}
+	return cfg
+}
+
+// SwapModel loads the given model and replaces the current net with it. The
+// current net is kept if the new one fails to load.
+func (d *DNNDetector) SwapModel(e ModelEntry) error {
+	cfg := d.netCfg
+	cfg.ModelPath, cfg.ProtoTxtPath = e.Model, e.ProtoTxt
+	net, err := d.load(cfg)
+	if err != nil {
+		return err
+	}
+	d.net.Close()
+	d.net, d.netCfg = net, cfg
+	meta := *d.meta // snapshots already published keep the old one
+	meta.Model = filepath.Base(e.Model)
+	d.meta = &meta
+	if cfg.WarmUp {
+		d.warmUp()
+	}
+	return nil
+}
+
+// modelsHandler serves GET /models and POST /models/active?name=<name>, the
+// latter requiring "Authorization: Bearer <token>".
+func modelsHandler(models *Models, token string, stats *Stats) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/models" && r.Method == http.MethodGet:
+			list, err := models.List()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Cache-Control", "no-store")
+			writeJSON(w, struct {
+				Active string       `json:"active"`
+				Models []ModelEntry `json:"models"`
+			}{stats.Report().Model, list}, true)
+		case r.URL.Path == "/models/active" && r.Method == http.MethodPost:
+			if token == "" || !authorized(r, token) {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			name := r.URL.Query().Get("name")
+			ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+			err := models.Switch(ctx, name)
+			cancel()
+			switch {
+			case errors.Is(err, errUnknownModel):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, ErrModelLoad):
+				http.Error(w, "model kept: "+err.Error(), http.StatusUnprocessableEntity)
+			case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+				http.Error(w, "detector not running", http.StatusServiceUnavailable)
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			default:
+				log.Printf("[models] %s switched the detector to %s", r.RemoteAddr, name)
+				_, _ = w.Write([]byte("ok\n"))
+			}
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestModelsSwitch(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"a.caffemodel", "broken.caffemodel", "slow.caffemodel", "deploy.prototxt"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := &fakeLoader{next: []*fakeNet{{}}, fail: func(cfg DetectorConfig) error {
+		switch filepath.Base(cfg.ModelPath) {
+		case "broken.caffemodel":
+			return fmt.Errorf("%w (model=%s)", ErrModelLoad, cfg.ModelPath)
+		case "slow.caffemodel":
+			time.Sleep(300 * time.Millisecond)
+		}
+		return nil
+	}}
+	cfg := DetectorConfig{Interval: time.Hour, ModelPath: "default.caffemodel"}
+	d := newFakeDetector(t, cfg, l)
+	models := NewModels(dir)
+	p := &Pipeline{Store: NewFaceStore(), Stats: NewStats(), Models: models}
+	stop := startLoop(d, cfg, p)
+	defer stop()
+
+	post := func(name string) int {
+		r := httptest.NewRequest(http.MethodPost, "/models/active?name="+name, nil)
+		r.Header.Set("Authorization", "Bearer tok")
+		w := httptest.NewRecorder()
+		modelsHandler(models, "tok", p.Stats)(w, r)
+		return w.Code
+	}
+	tests := []struct {
+		name   string
+		code   int
+		active string // model in /stats afterwards
+	}{
+		{"a", http.StatusOK, "a.caffemodel"},
+		{"broken", http.StatusUnprocessableEntity, "a.caffemodel"}, // rolled back
+		{"missing", http.StatusNotFound, "a.caffemodel"},
+	}
+	for _, tt := range tests {
+		if code := post(tt.name); code != tt.code {
+			t.Fatalf("switch to %s: %d, want %d", tt.name, code, tt.code)
+		}
+		if got := p.Stats.Report().Model; got != tt.active {
+			t.Fatalf("after switching to %s: active model %q, want %q", tt.name, got, tt.active)
+		}
+	}
+	if got := models.apply(cfg).ModelPath; got != filepath.Join(dir, "a.caffemodel") {
+		t.Fatalf("model kept across restarts %q, want a.caffemodel", got)
+	}
+
+	// The loop has accepted the switch when the caller gives up: the switch
+	// still happens, and is reported as such.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := models.Switch(ctx, "slow"); err != nil {
+		t.Fatalf("switch outliving its context: %v, want nil", err)
+	}
+	if got := p.Stats.Report().Model; got != "slow.caffemodel" {
+		t.Fatalf("active model %q, want slow.caffemodel", got)
+	}
+}
+
+func TestSnapshotMetaFollowsSwitch(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"b.caffemodel", "deploy.prototxt"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	face := [][4]float32{{0.1, 0.1, 0.3, 0.3}}
+	l := &fakeLoader{next: []*fakeNet{{faces: face}, {faces: face}}}
+	cfg := DetectorConfig{Interval: 5 * time.Millisecond, ModelPath: "a.caffemodel", Confidence: 0.5, InputW: 300, InputH: 300}
+	d := newFakeDetector(t, cfg, l)
+	models := NewModels(dir)
+	p := &Pipeline{Store: NewFaceStore(), Stats: NewStats(), Models: models}
+	stop := startLoop(d, cfg, p)
+	defer stop()
+
+	before, _ := waitSnapshot(t, p.Store, func(s Snapshot) bool { return s.Meta != nil })
+	if err := models.Switch(context.Background(), "b"); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := waitSnapshot(t, p.Store, func(s Snapshot) bool { return s.Meta.Model != "a.caffemodel" })
+
+	want := SnapshotMeta{Model: "a.caffemodel", Confidence: 0.5, InputW: 300, InputH: 300, Backend: before.Meta.Backend, Target: before.Meta.Target}
+	if *before.Meta != want {
+		t.Errorf("before the switch: meta %+v, want %+v", *before.Meta, want)
+	}
+	want.Model = "b.caffemodel"
+	if *after.Meta != want || after.Frame <= before.Frame {
+		t.Errorf("frame %d after the switch: meta %+v, want %+v", after.Frame, *after.Meta, want)
+	}
+}
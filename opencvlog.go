@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/* ---------------------------- OpenCV log level ----------------------------- */
+
+// openCVLogLevels maps FACE_OPENCV_LOG_LEVEL to the level names of OpenCV's
+// own logger (OPENCV_LOG_LEVEL) and to FFmpeg's AV_LOG_* values, which its
+// FFmpeg backend reads from OPENCV_FFMPEG_LOGLEVEL.
+var openCVLogLevels = map[string]struct {
+	opencv string
+	ffmpeg int
+}{
+	"silent":  {"SILENT", -8}, // AV_LOG_QUIET
+	"fatal":   {"FATAL", 8},
+	"error":   {"ERROR", 16},
+	"warning": {"WARNING", 24},
+	"info":    {"INFO", 32},
+	"verbose": {"VERBOSE", 40},
+	"debug":   {"DEBUG", 48},
+}
+
+// applyOpenCVLogLevel sets the verbosity of OpenCV and of its FFmpeg
+// backend (e.g. the RTSP decode warnings of a flaky stream), independently
+// of the app's log. gocv has no binding for it, but both read their level
+// from the environment the first time they log, so this must run before
+// any capture or net is opened. An empty level keeps OpenCV's defaults,
+// and OPENCV_LOG_LEVEL or OPENCV_FFMPEG_LOGLEVEL set explicitly win.
+func applyOpenCVLogLevel(level string) error {
+	if level == "" {
+		return nil
+	}
+	l, ok := openCVLogLevels[strings.ToLower(level)]
+	if !ok {
+		return fmt.Errorf("unknown level %q (want silent, fatal, error, warning, info, verbose or debug)", level)
+	}
+	for k, v := range map[string]string{"OPENCV_LOG_LEVEL": l.opencv, "OPENCV_FFMPEG_LOGLEVEL": strconv.Itoa(l.ffmpeg)} {
+		if _, set := os.LookupEnv(k); set {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyOpenCVLogLevel(t *testing.T) {
+	// unset clears both variables for the rest of the test.
+	unset := func() {
+		for _, k := range []string{"OPENCV_LOG_LEVEL", "OPENCV_FFMPEG_LOGLEVEL", "FACE_OPENCV_LOG_LEVEL"} {
+			t.Setenv(k, "")
+			os.Unsetenv(k)
+		}
+	}
+	env := func() (string, string) { return os.Getenv("OPENCV_LOG_LEVEL"), os.Getenv("OPENCV_FFMPEG_LOGLEVEL") }
+
+	tests := []struct {
+		level          string
+		opencv, ffmpeg string
+	}{
+		{"silent", "SILENT", "-8"},
+		{"error", "ERROR", "16"},
+		{"Warning", "WARNING", "24"},
+		{"debug", "DEBUG", "48"},
+		{"", "", ""}, // OpenCV's defaults
+	}
+	for _, tt := range tests {
+		unset()
+		if err := applyOpenCVLogLevel(tt.level); err != nil {
+			t.Fatalf("%q: %v", tt.level, err)
+		}
+		if cv, ff := env(); cv != tt.opencv || ff != tt.ffmpeg {
+			t.Errorf("%q: OPENCV_LOG_LEVEL %q, OPENCV_FFMPEG_LOGLEVEL %q, want %q, %q", tt.level, cv, ff, tt.opencv, tt.ffmpeg)
+		}
+	}
+
+	unset()
+	if err := applyOpenCVLogLevel("loud"); err == nil {
+		t.Error("unknown level accepted")
+	}
+	if cv, ff := env(); cv != "" || ff != "" {
+		t.Errorf("unknown level set %q, %q", cv, ff)
+	}
+
+	// An explicit OpenCV variable wins over FACE_OPENCV_LOG_LEVEL.
+	unset()
+	t.Setenv("OPENCV_FFMPEG_LOGLEVEL", "56")
+	if err := applyOpenCVLogLevel("error"); err != nil {
+		t.Fatal(err)
+	}
+	if cv, ff := env(); cv != "ERROR" || ff != "56" {
+		t.Errorf("explicit FFmpeg level: %q, %q, want ERROR, 56", cv, ff)
+	}
+
+	// At startup the level may come from FACE_ENV_FILE, read first; the
+	// config check applies it the same way.
+	unset()
+	envFile := filepath.Join(t.TempDir(), "facetrack.env")
+	if err := os.WriteFile(envFile, []byte("FACE_OPENCV_LOG_LEVEL=silent\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FACE_ENV_FILE", envFile)
+	rep := checkConfig(func(DetectorConfig) (inferenceNet, error) { return &fakeNet{}, nil }, "127.0.0.1:0")
+	for _, c := range rep.Checks {
+		if c.Name == "opencv log level" && !c.OK {
+			t.Errorf("check: %s", c.Error)
+		}
+	}
+	if cv, ff := env(); cv != "SILENT" || ff != "-8" {
+		t.Errorf("from the env file: %q, %q, want SILENT, -8", cv, ff)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+/* ---------------------------- Detection order ------------------------------ */
+
+// detectionOrder is the order of the detections in a snapshot
+// (FACE_DETECTION_ORDER). The detector's own order follows the net's output
+// rows, which can change between runs for the same faces; id and score give
+// byte-identical JSON for equivalent snapshots. Ties fall back to the box,
+// so the order never depends on the detector's.
+type detectionOrder string
+
+const (
+	orderDetector detectionOrder = "detector"
+	orderID       detectionOrder = "id"    // ascending ID
+	orderScore    detectionOrder = "score" // descending score
+)
+
+func parseDetectionOrder(v string) (detectionOrder, error) {
+	switch o := detectionOrder(v); o {
+	case "", orderDetector:
+		return orderDetector, nil
+	case orderID, orderScore:
+		return o, nil
+	}
+	return "", fmt.Errorf("want detector, id or score, got %q", v)
+}
+
+// apply sorts dets in place; the detector order leaves them as they are.
+func (o detectionOrder) apply(dets []Detection) []Detection {
+	if o != orderID && o != orderScore {
+		return dets
+	}
+	slices.SortStableFunc(dets, func(a, b Detection) int {
+		if o == orderScore && a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		if a.ID != b.ID {
+			return a.ID - b.ID
+		}
+		if a.BBox.Y != b.BBox.Y {
+			return a.BBox.Y - b.BBox.Y
+		}
+		if a.BBox.X != b.BBox.X {
+			return a.BBox.X - b.BBox.X
+		}
+		if a.BBox.Width != b.BBox.Width {
+			return a.BBox.Width - b.BBox.Width
+		}
+		return a.BBox.Height - b.BBox.Height
+	})
+	return dets
+}
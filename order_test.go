@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestDetectionOrderByteIdentical(t *testing.T) {
+	attrs := func(reverse bool) map[string]float64 {
+		m := make(map[string]float64)
+		for i := range 20 {
+			k := i
+			if reverse {
+				k = 19 - i
+			}
+			m["attr"+strconv.Itoa(k)] = float64(k) / 20
+		}
+		return m
+	}
+	dets := []Detection{
+		{ID: 3, BBox: Rect{X: 50, Y: 10, Width: 20, Height: 20}, Score: 0.7, Attributes: attrs(false)},
+		{ID: 1, BBox: Rect{X: 10, Y: 10, Width: 20, Height: 20}, Score: 0.9, Attributes: attrs(true)},
+		{ID: 2, BBox: Rect{X: 90, Y: 10, Width: 20, Height: 20}, Score: 0.9, Attributes: attrs(false)},
+	}
+	// The same detections, as the net might return them on another run.
+	encode := func(o detectionOrder, dets []Detection) []byte {
+		data, err := json.Marshal(Snapshot{Frame: 1, Detections: o.apply(cloneDetections(dets))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	shuffled := []Detection{dets[2], dets[0], dets[1]}
+	for i := range shuffled {
+		shuffled[i].Attributes = attrs(i%2 == 0)
+	}
+
+	tests := []struct {
+		order detectionOrder
+		ids   []int
+	}{
+		{orderID, []int{1, 2, 3}},
+		{orderScore, []int{1, 2, 3}}, // 0.9 tie broken by ID
+	}
+	for _, tt := range tests {
+		a, b := encode(tt.order, dets), encode(tt.order, shuffled)
+		if !bytes.Equal(a, b) {
+			t.Errorf("order %s: encodings differ\n%s\n%s", tt.order, a, b)
+		}
+		var got []int
+		for _, d := range tt.order.apply(cloneDetections(shuffled)) {
+			got = append(got, d.ID)
+		}
+		if !slices.Equal(got, tt.ids) {
+			t.Errorf("order %s: IDs %v, want %v", tt.order, got, tt.ids)
+		}
+	}
+	if bytes.Equal(encode(orderDetector, dets), encode(orderDetector, shuffled)) {
+		t.Errorf("the detector order reordered the detections")
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+/* ------------------------------- Live preview ------------------------------ */
+
+// overlayColor is used for boxes without a track color.
+var overlayColor = color.RGBA{G: 255, A: 255}
+
+// Preview keeps a copy of the latest captured frame and its snapshot so the
+// annotated view can be rendered on demand (/snapshot.jpg, /stream.mjpg).
+type Preview struct {
+	mu     sync.Mutex
+	snap   Snapshot
+	img    gocv.Mat
+	has    bool
+	trails *Trails // nil when trails are off
+
+	minScore float64        // default overlay threshold, 0 = every detection
+	label    *labelTemplate // text above each box, nil = "#{id} {score}"
+}
+
+// NewPreview returns a preview; trails and label may be nil. Only boxes
+// scoring at least minScore are drawn unless a request asks otherwise.
+func NewPreview(trails *Trails, minScore float64, label *labelTemplate) *Preview {
+	return &Preview{img: gocv.NewMat(), trails: trails, minScore: minScore, label: label}
+}
+
+// overlayOptions selects what is drawn on top of the frame.
+type overlayOptions struct {
+	Boxes    bool
+	Trails   bool
+	MinScore float64 // boxes scoring lower are not drawn; the data is untouched
+}
+
+// parseOverlayOptions reads ?overlay=0 (no boxes), ?trails=0 (no trails) and
+// ?min_score= (defaults to the preview's threshold).
+func (p *Preview) parseOverlayOptions(r *http.Request) (overlayOptions, error) {
+	q := r.URL.Query()
+	opts := overlayOptions{Boxes: q.Get("overlay") != "0", Trails: q.Get("trails") != "0", MinScore: p.minScore}
+	if v := q.Get("min_score"); v != "" {
+		s, err := strconv.ParseFloat(v, 64)
+		if err != nil || s < 0 {
+			return opts, fmt.Errorf("invalid min_score %q", v)
+		}
+		opts.MinScore = s
+	}
+	return opts, nil
+}
+
+// Update replaces the latest frame with a copy of img.
+func (p *Preview) Update(snap Snapshot, img gocv.Mat) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_ = img.CopyTo(&p.img)
+	p.snap = snap.clone()
+	p.has = true
+	if p.trails != nil {
+		p.trails.Observe(snap.Detections)
+	}
+}
+
+// HasFrame reports whether a frame has been captured yet.
+func (p *Preview) HasFrame() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.has
+}
+
+// Encode encodes the latest frame in format f, with the detections and
+// trails drawn on it as selected by opts. It returns false if no frame has
+// been captured yet.
+func (p *Preview) Encode(opts overlayOptions, f imageFormat) ([]byte, Snapshot, bool) {
+	p.mu.Lock()
+	if !p.has {
+		p.mu.Unlock()
+		return nil, Snapshot{}, false
+	}
+	img := p.img.Clone()
+	snap := p.snap
+	var trails map[int][]image.Point
+	var fade float64
+	if opts.Trails && p.trails != nil {
+		trails, fade = p.trails.clone(), p.trails.fade
+	}
+	p.mu.Unlock()
+	defer img.Close()
+
+	if trails != nil {
+		drawTrails(&img, trails, fade)
+	}
+	if opts.Boxes {
+		drawOverlay(&img, snap.Detections, opts.MinScore, p.label)
+	}
+	data, err := f.Encode(img)
+	if err != nil {
+		return nil, Snapshot{}, false
+	}
+	return data, snap, true
+}
+
+// drawOverlay draws the box and label of each detection scoring at least
+// minScore. Tracked detections use their track color, the same one reported
+// in the JSON "color" field.
+func drawOverlay(img *gocv.Mat, dets []Detection, minScore float64, label *labelTemplate) {
+	for _, d := range dets {
+		if d.Score < minScore {
+			continue
+		}
+		c := overlayColor
+		if d.Color != "" {
+			c = trackColor(d.ID)
+		}
+		box := image.Rect(d.BBox.X, d.BBox.Y, d.BBox.X+d.BBox.Width, d.BBox.Y+d.BBox.Height)
+		_ = gocv.Rectangle(img, box, c, 2)
+		_ = gocv.PutText(img, label.render(d), image.Pt(box.Min.X, max(box.Min.Y-6, 12)), gocv.FontHersheySimplex, 0.5, c, 1)
+	}
+}
+
+// snapshotHandler serves the latest frame as an image (JPEG unless WebP is
+// negotiated); ?overlay=0 disables the boxes, ?trails=0 the trails and
+// ?min_score= hides the less confident boxes.
+func snapshotHandler(preview *Preview, encoders *ImageEncoders) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if preview == nil {
+			http.Error(w, "preview disabled", http.StatusNotFound)
+			return
+		}
+		opts, err := preview.parseOverlayOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format := encoders.Negotiate(r.Header.Get("Accept"))
+		jpg, snap, ok := preview.Encode(opts, format)
+		if !ok {
+			http.Error(w, "no frame captured yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", format.ContentType)
+		w.Header().Set("Vary", "Accept")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Frame", strconv.FormatInt(snap.Frame, 10))
+		w.Header().Set("Content-Length", strconv.Itoa(len(jpg)))
+		_, _ = w.Write(jpg)
+	}
+}
+
+// mjpegHandler streams the annotated frames as multipart/x-mixed-replace, one
+// part per new snapshot, until the client or the server goes away.
+func mjpegHandler(ctx context.Context, store *FaceStore, preview *Preview, encoders *ImageEncoders) http.HandlerFunc {
+	const boundary = "frame"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if preview == nil {
+			http.Error(w, "preview disabled", http.StatusNotFound)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		overlay, err := preview.parseOverlayOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format := encoders.Negotiate(r.Header.Get("Accept"))
+
+		w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+boundary)
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		var last int64 = -1
+		for {
+			changed := store.Changed()
+			if jpg, snap, ok := preview.Encode(overlay, format); ok && snap.Frame != last {
+				last = snap.Frame
+				if _, err := fmt.Fprintf(w, "--%s\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n", boundary, format.ContentType, len(jpg)); err != nil {
+					return
+				}
+				if _, err := w.Write(append(jpg, '\r', '\n')); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
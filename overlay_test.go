@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestOverlayMinScore(t *testing.T) {
+	strong := Detection{ID: 1, BBox: Rect{X: 20, Y: 20, Width: 40, Height: 40}, Score: 0.9}
+	weak := Detection{ID: 2, BBox: Rect{X: 120, Y: 20, Width: 40, Height: 40}, Score: 0.4}
+	snap := Snapshot{Frame: 7, FrameWidth: 200, FrameHeight: 100, Detections: []Detection{strong, weak}}
+	img := gocv.NewMatWithSize(100, 200, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	store, preview := NewFaceStore(), NewPreview(nil, 0.6, nil)
+	store.Set(snap)
+	preview.Update(snap, img)
+	encoders, err := NewImageEncoders("jpeg", 90, "", 75)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// drawn reports which of the two boxes have their top edge drawn.
+	drawn := func(query string) (bool, bool) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		snapshotHandler(preview, encoders)(w, httptest.NewRequest(http.MethodGet, "/snapshot.jpg"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: %d %s", query, w.Code, w.Body)
+		}
+		out, err := jpeg.Decode(bytes.NewReader(w.Body.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		edge := func(d Detection) bool {
+			_, g, _, _ := out.At(d.BBox.X+d.BBox.Width/2, d.BBox.Y).RGBA()
+			return g>>8 > 150
+		}
+		return edge(strong), edge(weak)
+	}
+	tests := []struct {
+		query        string
+		strong, weak bool
+	}{
+		{"", true, false}, // the preview's default threshold
+		{"?min_score=0", true, true},
+		{"?min_score=0.95", false, false},
+		{"?overlay=0&min_score=0", false, false},
+	}
+	for _, tt := range tests {
+		if s, w := drawn(tt.query); s != tt.strong || w != tt.weak {
+			t.Errorf("%q: boxes drawn %v %v, want %v %v", tt.query, s, w, tt.strong, tt.weak)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	snapshotHandler(preview, encoders)(w, httptest.NewRequest(http.MethodGet, "/snapshot.jpg?min_score=-1", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("negative min_score: %d, want 400", w.Code)
+	}
+
+	// The data keeps every detection.
+	if _, got, _ := preview.Encode(overlayOptions{Boxes: true, MinScore: 0.95}, stillJPEG); len(got.Detections) != 2 {
+		t.Errorf("preview snapshot has %d detections after filtering, want 2", len(got.Detections))
+	}
+	w = httptest.NewRecorder()
+	facesHandler(ServerConfig{}, store)(w, httptest.NewRequest(http.MethodGet, "/faces", nil))
+	var stored Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Detections) != 2 || stored.Detections[1].BBox != weak.BBox {
+		t.Errorf("/faces %+v, want both detections", stored.Detections)
+	}
+	if got, _ := store.Get(); len(got.Detections) != 2 || got.Detections[1].Score != weak.Score {
+		t.Errorf("store %+v, want both detections", got.Detections)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+/* ---------------------------------- Pause ---------------------------------- */
+
+// Pause holds the detector while set: its ticks neither capture nor infer,
+// and the last snapshot stays served. It outlives detector restarts. A nil
+// Pause never pauses.
+type Pause struct {
+	paused atomic.Bool
+}
+
+// Set pauses or resumes the detector and reports whether that changed.
+func (p *Pause) Set(paused bool) bool {
+	return p.paused.Swap(paused) != paused
+}
+
+func (p *Pause) Paused() bool {
+	return p != nil && p.paused.Load()
+}
+
+/* -------------------------------- GPIO gate -------------------------------- */
+
+// gpioReader reads a digital input, e.g. a PIR motion sensor.
+type gpioReader interface {
+	Read() (high bool, err error)
+	Close() error
+}
+
+// GPIOGate runs the detector only while an input is active, plus hold after
+// it goes inactive: PIR sensors drop between movements, and a face standing
+// still shouldn't stop being detected right away. A failing read resumes
+// the detector, so a broken sensor never blinds it.
+type GPIOGate struct {
+	in        gpioReader
+	activeLow bool
+	hold      time.Duration
+	poll      time.Duration
+	pause     *Pause
+	stats     *Stats // may be nil
+
+	lastActive time.Time
+	lastErr    string // logged once until it changes
+}
+
+func NewGPIOGate(in gpioReader, activeLow bool, hold, poll time.Duration, pause *Pause, stats *Stats) *GPIOGate {
+	if poll <= 0 {
+		poll = 100 * time.Millisecond
+	}
+	return &GPIOGate{in: in, activeLow: activeLow, hold: hold, poll: poll, pause: pause, stats: stats}
+}
+
+// Run polls the input until ctx is done, then closes it. The detector is
+// paused until the first read says otherwise.
+func (g *GPIOGate) Run(ctx context.Context) {
+	defer g.in.Close()
+	g.setPaused(true)
+	ticker := time.NewTicker(g.poll)
+	defer ticker.Stop()
+	for {
+		g.setPaused(g.step(time.Now()))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// step reads the input at now and returns whether the detector should be
+// paused.
+func (g *GPIOGate) step(now time.Time) bool {
+	high, err := g.in.Read()
+	if err != nil {
+		if err.Error() != g.lastErr {
+			log.Printf("[gpio] %v, detecting regardless", err)
+		}
+		g.lastErr = err.Error()
+		return false
+	}
+	g.lastErr = ""
+	if high != g.activeLow {
+		g.lastActive = now
+		return false
+	}
+	return g.lastActive.IsZero() || now.Sub(g.lastActive) >= g.hold
+}
+
+func (g *GPIOGate) setPaused(paused bool) {
+	if !g.pause.Set(paused) {
+		return
+	}
+	if paused {
+		log.Printf("[gpio] input inactive, detector paused")
+	} else {
+		log.Printf("[gpio] detector running")
+	}
+	if g.stats != nil {
+		g.stats.SetPaused(paused)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGPIO replays reads in order, repeating the last one.
+type fakeGPIO struct {
+	mu     sync.Mutex
+	reads  []gpioRead
+	closed bool
+}
+
+type gpioRead struct {
+	high bool
+	err  error
+}
+
+func (g *fakeGPIO) Read() (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r := g.reads[0]
+	if len(g.reads) > 1 {
+		g.reads = g.reads[1:]
+	}
+	return r.high, r.err
+}
+
+// pending returns how many reads are left before the last one repeats.
+func (g *fakeGPIO) pending() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.reads) - 1
+}
+
+func (g *fakeGPIO) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+	return nil
+}
+
+func TestGPIOGateStep(t *testing.T) {
+	broken := errors.New("read failed")
+	type step struct {
+		at     time.Duration
+		read   gpioRead
+		paused bool
+	}
+	tests := []struct {
+		name      string
+		activeLow bool
+		steps     []step
+	}{
+		{"never active", false, []step{{0, gpioRead{}, true}, {time.Second, gpioRead{}, true}}},
+		{"active then held", false, []step{
+			{0, gpioRead{high: true}, false},
+			{time.Second, gpioRead{}, false},
+			{2 * time.Second, gpioRead{}, true},
+			{3 * time.Second, gpioRead{high: true}, false},
+		}},
+		{"active low", true, []step{
+			{0, gpioRead{high: true}, true},
+			{time.Second, gpioRead{}, false},
+			{4 * time.Second, gpioRead{high: true}, true},
+		}},
+		{"broken sensor", false, []step{
+			{0, gpioRead{err: broken}, false},
+			{time.Second, gpioRead{err: broken}, false},
+			{2 * time.Second, gpioRead{}, true},
+		}},
+	}
+	start := time.Now()
+	for _, tt := range tests {
+		in := &fakeGPIO{}
+		for _, s := range tt.steps {
+			in.reads = append(in.reads, s.read)
+		}
+		g := NewGPIOGate(in, tt.activeLow, 2*time.Second, 0, &Pause{}, nil)
+		for i, s := range tt.steps {
+			if got := g.step(start.Add(s.at)); got != s.paused {
+				t.Errorf("%s: step %d at %v paused = %v, want %v", tt.name, i, s.at, got, s.paused)
+			}
+		}
+	}
+}
+
+func TestGPIOGateRun(t *testing.T) {
+	in := &fakeGPIO{reads: []gpioRead{{high: true}, {high: true}, {}}}
+	pause, stats := &Pause{}, NewStats()
+	g := NewGPIOGate(in, false, 0, time.Millisecond, pause, stats)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		g.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !pause.Paused() || in.pending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("detector not paused once the input went low")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if !in.closed {
+		t.Error("input not closed when the gate stopped")
+	}
+	if !stats.Report().Paused {
+		t.Error("/stats does not report the pause")
+	}
+}
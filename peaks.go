@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/* ------------------------------ Peak occupancy ----------------------------- */
+
+// Peak is the highest simultaneous face count seen and when it happened.
+type Peak struct {
+	Count int       `json:"count"`
+	At    time.Time `json:"at,omitzero"`
+}
+
+// PeakStats is served on /stats/peaks and persisted in the state file.
+type PeakStats struct {
+	Day     string `json:"day"` // local calendar day of Daily, YYYY-MM-DD
+	Daily   Peak   `json:"daily"`
+	AllTime Peak   `json:"all_time"`
+}
+
+// Peaks tracks the daily and all-time maximum face counts. The daily figure
+// resets when the calendar day changes in loc, so DST shifts don't matter.
+type Peaks struct {
+	mu    sync.Mutex
+	loc   *time.Location
+	stats PeakStats
+	dirty bool // changed since the last Snapshot
+}
+
+func NewPeaks(loc *time.Location) *Peaks {
+	return &Peaks{loc: loc}
+}
+
+// SetLocation sets the time zone whose midnight resets the daily peak.
+func (p *Peaks) SetLocation(loc *time.Location) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loc = loc
+}
+
+// Restore seeds the peaks from a previous run.
+func (p *Peaks) Restore(st PeakStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats = st
+}
+
+// Observe records count faces seen at t. Peaks only move when exceeded.
+func (p *Peaks) Observe(count int, t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if day := t.In(p.loc).Format(time.DateOnly); day != p.stats.Day {
+		p.stats.Day, p.stats.Daily = day, Peak{}
+		p.dirty = true
+	}
+	if count > p.stats.Daily.Count {
+		p.stats.Daily = Peak{Count: count, At: t}
+		p.dirty = true
+	}
+	if count > p.stats.AllTime.Count {
+		p.stats.AllTime = Peak{Count: count, At: t}
+		p.dirty = true
+	}
+}
+
+// Report returns the current peaks.
+func (p *Peaks) Report() PeakStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// takeDirty returns the peaks and whether they changed since the last call.
+func (p *Peaks) takeDirty() (PeakStats, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dirty := p.dirty
+	p.dirty = false
+	return p.stats, dirty
+}
+
+// faceCount is the number of faces in snap, counting cluster members.
+// Predicted boxes are tracks the detector missed, not faces seen.
+func faceCount(snap Snapshot) int {
+	if snap.CountOnly {
+		return snap.Count
+	}
+	n := 0
+	for _, d := range snap.Detections {
+		if !d.Predicted {
+			n += max(d.Count, 1)
+		}
+	}
+	return n
+}
+
+func peaksHandler(peaks *Peaks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(peaks.Report())
+	}
+}
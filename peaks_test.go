@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPeaks(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 3, day, hour, minute, 0, 0, paris) }
+	steps := []struct {
+		count   int
+		at      time.Time
+		day     string
+		daily   Peak
+		allTime Peak
+	}{
+		{2, at(1, 9, 0), "2026-03-01", Peak{2, at(1, 9, 0)}, Peak{2, at(1, 9, 0)}},
+		{1, at(1, 10, 0), "2026-03-01", Peak{2, at(1, 9, 0)}, Peak{2, at(1, 9, 0)}},
+		// Equal is not exceeded: the first time stays.
+		{2, at(1, 11, 0), "2026-03-01", Peak{2, at(1, 9, 0)}, Peak{2, at(1, 9, 0)}},
+		{5, at(1, 23, 59), "2026-03-01", Peak{5, at(1, 23, 59)}, Peak{5, at(1, 23, 59)}},
+		// Local midnight: the day starts over, the all-time peak stays.
+		{1, at(2, 0, 1), "2026-03-02", Peak{1, at(2, 0, 1)}, Peak{5, at(1, 23, 59)}},
+		{0, at(3, 0, 0), "2026-03-03", Peak{}, Peak{5, at(1, 23, 59)}},
+		{3, at(3, 12, 0), "2026-03-03", Peak{3, at(3, 12, 0)}, Peak{5, at(1, 23, 59)}},
+		// 23:30 UTC is already the next day in Paris.
+		{1, time.Date(2026, 3, 3, 23, 30, 0, 0, time.UTC), "2026-03-04", Peak{1, time.Date(2026, 3, 3, 23, 30, 0, 0, time.UTC)}, Peak{5, at(1, 23, 59)}},
+	}
+	p := NewPeaks(paris)
+	for i, s := range steps {
+		p.Observe(s.count, s.at)
+		got := p.Report()
+		if got.Day != s.day || !got.Daily.At.Equal(s.daily.At) || got.Daily.Count != s.daily.Count ||
+			!got.AllTime.At.Equal(s.allTime.At) || got.AllTime.Count != s.allTime.Count {
+			t.Errorf("step %d (%d at %v): %+v, want day %s daily %+v all-time %+v", i, s.count, s.at, got, s.day, s.daily, s.allTime)
+		}
+	}
+}
+
+func TestPeaksPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	t0 := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	store := NewFaceStore()
+	store.Peaks().SetLocation(time.UTC)
+	store.Set(Snapshot{Detections: make([]Detection, 4), GeneratedAt: t0})
+	store.Set(Snapshot{Detections: make([]Detection, 1), GeneratedAt: t0.Add(time.Hour)})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := StartStateSaver(ctx, path, store, &FrameCounter{})
+	cancel()
+	<-done
+
+	// Restarted the same day: fewer faces don't lower the restored peaks.
+	st, err := loadState(path)
+	if err != nil || st.Peaks == nil {
+		t.Fatalf("state %+v, %v", st, err)
+	}
+	restarted := NewFaceStore()
+	restarted.Peaks().SetLocation(time.UTC)
+	restarted.Peaks().Restore(*st.Peaks)
+	restarted.Set(Snapshot{Detections: make([]Detection, 2), GeneratedAt: t0.Add(2 * time.Hour)})
+	got := restarted.Peaks().Report()
+	if got.Daily.Count != 4 || got.AllTime.Count != 4 || !got.AllTime.At.Equal(t0) {
+		t.Errorf("after restart: %+v, want the 4 faces of %v", got, t0)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+/* ---------------------------- Pipelined detector --------------------------- */
+
+// With FACE_WORKERS > 0 the detector runs as a pipeline instead of a single
+// loop: a capture goroutine reads frames on the ticker into a bounded queue,
+// each worker pulls from it and runs its own copy of the net (gocv.Net is
+// not safe for concurrent use), and the loop goroutine tracks and publishes
+// the results. The queue keeps the latest frames: when it is full the oldest
+// one is dropped, so capture never waits on inference and workers never
+// process stale frames. Results are published in capture order; one that
+// completes after a newer frame has been published is discarded.
+
+// frameJob is a captured frame waiting for a worker, which takes over img.
+type frameJob struct {
+	seq int64
+	t0  time.Time // capture time
+	img gocv.Mat
+}
+
+// frameResult is the outcome of one captured frame. img, when hasImg is
+// set, is owned by the receiver.
+type frameResult struct {
+	seq    int64
+	t0     time.Time
+	source string
+	faces  []Detection
+	fw, fh int
+	err    error
+	img    gocv.Mat
+	hasImg bool
+}
+
+// frameQueue is a bounded latest-wins queue of captured frames.
+type frameQueue struct {
+	jobs chan frameJob
+}
+
+func newFrameQueue(depth int) *frameQueue {
+	if depth <= 0 {
+		depth = 1
+	}
+	return &frameQueue{jobs: make(chan frameJob, depth)}
+}
+
+// push enqueues j without blocking, dropping (and releasing) the oldest
+// queued frames to make room. It returns how many were dropped.
+func (q *frameQueue) push(j frameJob) (dropped int) {
+	for {
+		select {
+		case q.jobs <- j:
+			return dropped
+		default:
+		}
+		select {
+		case old := <-q.jobs:
+			old.img.Close()
+			dropped++
+		default: // a worker took one meanwhile
+		}
+	}
+}
+
+// drain releases the frames left in the queue once nothing pushes anymore.
+func (q *frameQueue) drain() {
+	for {
+		select {
+		case j := <-q.jobs:
+			j.img.Close()
+		default:
+			return
+		}
+	}
+}
+
+// detectOwned runs detection on img, which the detector takes over, and
+// returns the frame to publish with the result: img itself, or a copy of
+// the ROI crop when coordinates are ROI-relative. The detector keeps no
+// reference to img afterwards.
+func (d *DNNDetector) detectOwned(img gocv.Mat) frameResult {
+	d.frame.Close()
+	d.frame, d.hasFrame = img, true
+	var res frameResult
+	res.source, res.faces, res.fw, res.fh, res.err = d.detectFrame()
+	if d.hasFrame && d.reportsRegion() {
+		res.img, res.hasImg = d.region.Clone(), true
+		img.Close()
+	} else if d.hasFrame {
+		res.img, res.hasImg = img, true
+	} else {
+		img.Close()
+	}
+	d.region.Close()
+	d.region = gocv.NewMat()
+	d.frame, d.hasFrame = gocv.NewMat(), false
+	return res
+}
+
+func runPipelinedLoop(ctx context.Context, det *DNNDetector, workers []*DNNDetector, cfg DetectorConfig, p *Pipeline) {
+	queue := newFrameQueue(cfg.QueueDepth)
+	results := make(chan frameResult, len(workers)+1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runCapture(ctx, det, cfg, p, queue, results)
+	}()
+	for i, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer w.Close()
+			runInferenceWorker(ctx, i, w, cfg, p, queue, results)
+		}()
+	}
+
+	pub := &resultPublisher{cfg: cfg, p: p, meta: det.Meta()}
+	if cfg.FrozenFrames > 0 {
+		pub.frozen = &frozenDetector{threshold: cfg.FrozenFrames}
+	}
+	if cfg.Track {
+		pub.tracker = NewTracker(cfg.TrackIoU, cfg.TrackMaxMissed, cfg.TrackSmooth)
+		pub.tracker.SetStability(cfg.StableAfter, cfg.StableMove)
+		pub.tracker.SetPrediction(cfg.TrackPredict)
+		if cfg.ZoneEvents > 0 {
+			pub.tracker.SetZones(cfg.Zones, cfg.ZoneEvents)
+		}
+	}
+	log.Printf("[detector] started (interval=%v, source=%s, workers=%d, queue=%d)", cfg.Interval, cfg.Source, len(workers), cap(queue.jobs))
+	p.Stats.SetClockOffset(cfg.ClockOffset)
+	p.Stats.SetModel(det.Meta().Model)
+	p.Stats.SetCaptureBuffer(det.capBuffer)
+
+	for {
+		var res frameResult
+		select {
+		case <-ctx.Done():
+			log.Printf("[detector] stopping")
+			wg.Wait()
+			queue.drain()
+			for {
+				select {
+				case res := <-results:
+					res.img.Close()
+				default:
+					return
+				}
+			}
+		case res = <-results:
+		}
+
+		pub.handle(res)
+	}
+}
+
+// resultPublisher tracks and publishes the pipeline results in capture
+// order, dropping the ones that complete after a newer frame.
+type resultPublisher struct {
+	cfg     DetectorConfig
+	p       *Pipeline
+	meta    *SnapshotMeta
+	tracker *Tracker        // nil = tracking off
+	frozen  *frozenDetector // nil = check off
+
+	last        int64 // sequence number of the last published result
+	lastCapture time.Time
+	lastErr     string
+}
+
+// handle publishes res unless it failed inference or is stale, and
+// releases its frame.
+func (r *resultPublisher) handle(res frameResult) {
+	p := r.p
+	interval := p.Playback.Interval(r.cfg.Interval)
+	if errors.Is(res.err, ErrInference) {
+		// Keep the previous snapshot rather than publishing an empty one.
+		p.Stats.ObserveInferenceFailure()
+		p.Stats.ObserveCycle(res.seq, time.Since(res.t0), interval)
+		res.img.Close()
+		return
+	}
+	if res.seq <= r.last {
+		p.Stats.ObserveStaleResult()
+		res.img.Close()
+		return
+	}
+	r.last = res.seq
+	if err := res.err; err != nil && !errors.Is(err, ErrEmptyFrame) {
+		if err.Error() != r.lastErr {
+			log.Printf("[detector] %v", err)
+		}
+		r.lastErr = err.Error()
+	} else {
+		r.lastErr = ""
+	}
+	faces, raw := trackFaces(r.tracker, res.faces)
+	faces = r.cfg.Order.apply(faces)
+	snap := Snapshot{
+		Source:      res.source,
+		Frame:       res.seq,
+		FrameWidth:  res.fw,
+		FrameHeight: res.fh,
+		Detections:  faces,
+		Raw:         raw,
+		GeneratedAt: time.Now().Add(r.cfg.ClockOffset).UTC(),
+		Meta:        r.meta,
+		Zones:       assignZones(faces, r.cfg.Zones),
+		Events:      r.tracker.Events(),
+	}
+	if res.err == nil {
+		if !r.lastCapture.IsZero() {
+			snap.FrameIntervalMs = ms(res.t0.Sub(r.lastCapture))
+		}
+		r.lastCapture = res.t0
+		p.Stats.ObserveFrame(res.t0)
+	}
+	p.publish(snap, res.img, res.hasImg, r.frozen)
+	res.img.Close()
+	p.Stats.ObserveCycle(res.seq, time.Since(res.t0), interval)
+}
+
+// runCapture reads a frame on every tick into the queue. It owns the
+// source, so it also serves the seek and speed requests. A failed read is
+// sent straight to the results as an empty frame.
+func runCapture(ctx context.Context, det *DNNDetector, cfg DetectorConfig, p *Pipeline, queue *frameQueue, results chan<- frameResult) {
+	p.Playback.SetConfigured(cfg.Interval)
+	interval := p.Playback.Interval(cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var seeks <-chan seekRequest
+	var timingChanged <-chan struct{}
+	if p.Playback != nil {
+		seeks, timingChanged = p.Playback.requests, p.Playback.timingChanged
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-seeks:
+			req.reply <- det.Seek(req)
+		case <-timingChanged:
+			interval = p.Playback.Interval(cfg.Interval)
+			ticker.Reset(interval)
+			log.Printf("[detector] interval %v (speed %gx)", interval, p.Playback.Speed())
+		case <-ticker.C:
+			if p.Pause.Paused() {
+				continue
+			}
+			seq := p.Seq.Next()
+			t0 := time.Now()
+			img := gocv.NewMat()
+			if ok := det.cap.Read(&img); !ok || img.Empty() {
+				img.Close()
+				select {
+				case results <- frameResult{seq: seq, t0: t0, source: det.source, err: ErrEmptyFrame}:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			if n := queue.push(frameJob{seq: seq, t0: t0, img: img}); n > 0 {
+				p.Stats.ObserveQueueDrops(n)
+			}
+		}
+	}
+}
+
+// runInferenceWorker detects faces on queued frames until ctx is done,
+// reloading its model after cfg.ReinitAfter consecutive failures.
+func runInferenceWorker(ctx context.Context, id int, w *DNNDetector, cfg DetectorConfig, p *Pipeline, queue *frameQueue, results chan<- frameResult) {
+	var failures int
+	for {
+		var job frameJob
+		select {
+		case <-ctx.Done():
+			return
+		case job = <-queue.jobs:
+		}
+		res := w.detectOwned(job.img)
+		res.seq, res.t0 = job.seq, job.t0
+		if errors.Is(res.err, ErrInference) {
+			if failures++; cfg.ReinitAfter > 0 && failures >= cfg.ReinitAfter {
+				log.Printf("[detector] worker %d: %d consecutive inference failures, reloading the model", id, failures)
+				if err := w.Reinit(); err != nil {
+					log.Printf("[detector] worker %d: model reload failed: %v", id, err)
+				} else {
+					p.Stats.ObserveModelReinit()
+				}
+				failures = 0
+			}
+		} else {
+			failures = 0
+		}
+		select {
+		case results <- res:
+		case <-ctx.Done():
+			res.img.Close()
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+func TestFrameQueueDropsOldest(t *testing.T) {
+	q := newFrameQueue(2)
+	dropped := 0
+	for seq := range int64(5) {
+		done := make(chan int)
+		go func() { done <- q.push(frameJob{seq: seq + 1, img: gocv.NewMat()}) }()
+		select {
+		case n := <-done:
+			dropped += n
+		case <-time.After(time.Second):
+			t.Fatalf("push %d blocked on a full queue", seq+1)
+		}
+	}
+	if dropped != 3 {
+		t.Fatalf("dropped %d frames, want 3", dropped)
+	}
+	var left []int64
+	for len(q.jobs) > 0 {
+		left = append(left, (<-q.jobs).seq)
+	}
+	if fmt.Sprint(left) != "[4 5]" {
+		t.Fatalf("queued frames %v, want the newest [4 5]", left)
+	}
+}
+
+func TestResultPublisherOrder(t *testing.T) {
+	p := &Pipeline{Store: NewFaceStore(), Stats: NewStats()}
+	pub := &resultPublisher{cfg: DetectorConfig{Interval: time.Second}, p: p}
+	t0 := time.Now()
+	result := func(seq int64, faces int, err error) frameResult {
+		return frameResult{seq: seq, t0: t0, faces: make([]Detection, faces), err: err, img: gocv.NewMat()}
+	}
+
+	// Workers complete frames 1 and 3 first; 2 lands after 3 was published.
+	pub.handle(result(1, 1, nil))
+	pub.handle(result(3, 3, nil))
+	pub.handle(result(2, 2, nil))
+	snap, ver := p.Store.Get()
+	if snap.Frame != 3 || len(snap.Detections) != 3 || ver != 2 {
+		t.Fatalf("store has frame %d with %d faces at version %d, want frame 3, 3 faces, version 2", snap.Frame, len(snap.Detections), ver)
+	}
+	if p.Stats.staleResults != 1 {
+		t.Fatalf("%d stale results, want 1", p.Stats.staleResults)
+	}
+
+	// A failed inference keeps the previous snapshot.
+	pub.handle(result(4, 0, fmt.Errorf("%w: no output", ErrInference)))
+	if snap, _ := p.Store.Get(); snap.Frame != 3 {
+		t.Fatalf("store has frame %d after a failed inference, want 3", snap.Frame)
+	}
+	if p.Stats.inferenceFailures != 1 {
+		t.Fatalf("%d inference failures, want 1", p.Stats.inferenceFailures)
+	}
+
+	// The failed frame doesn't hold back the next one.
+	pub.handle(result(5, 1, nil))
+	if snap, _ := p.Store.Get(); snap.Frame != 5 {
+		t.Fatalf("store has frame %d, want 5", snap.Frame)
+	}
+}
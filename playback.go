@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+/* -------------------------------- Playback --------------------------------- */
+
+// maxSpeed bounds the playback speed factor.
+const maxSpeed = 16.0
+
+// errNotSeekable is returned when seeking a live source (camera, stream).
+var errNotSeekable = errors.New("source is not a seekable file")
+
+// Playback carries seek and speed requests from /control, and interval
+// overrides from /sources, to the detector loop, which applies them between
+// frames so the capture is never touched concurrently. It outlives detector
+// restarts, and so do the speed and the interval override.
+type Playback struct {
+	requests      chan seekRequest
+	speed         atomic.Uint64 // math.Float64bits of the speed factor
+	interval      atomic.Int64  // runtime interval override in ns, 0 = configured
+	configured    atomic.Int64  // FACE_INTERVAL of the running detector, in ns
+	timingChanged chan struct{} // speed or interval override changed
+}
+
+type seekRequest struct {
+	msec    float64
+	frame   int64
+	byFrame bool
+	reply   chan error
+}
+
+func NewPlayback() *Playback {
+	p := &Playback{requests: make(chan seekRequest), timingChanged: make(chan struct{}, 1)}
+	p.speed.Store(math.Float64bits(1))
+	return p
+}
+
+// Seek asks the detector loop to move to req and waits for the outcome.
+func (p *Playback) Seek(ctx context.Context, req seekRequest) error {
+	req.reply = make(chan error, 1)
+	select {
+	case p.requests <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Speed returns the playback speed factor (1 = the configured interval).
+func (p *Playback) Speed() float64 {
+	if p == nil {
+		return 1
+	}
+	return math.Float64frombits(p.speed.Load())
+}
+
+func (p *Playback) SetSpeed(speed float64) {
+	p.speed.Store(math.Float64bits(speed))
+	p.notify()
+}
+
+// SetInterval overrides the configured interval until the process exits;
+// 0 goes back to the configured one.
+func (p *Playback) SetInterval(interval time.Duration) {
+	p.interval.Store(int64(interval))
+	p.notify()
+}
+
+// Override returns the interval override, 0 if none.
+func (p *Playback) Override() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return time.Duration(p.interval.Load())
+}
+
+// SetConfigured records the configured interval of the running detector,
+// as reported by /sources.
+func (p *Playback) SetConfigured(interval time.Duration) {
+	if p != nil {
+		p.configured.Store(int64(interval))
+	}
+}
+
+func (p *Playback) Configured() time.Duration {
+	return time.Duration(p.configured.Load())
+}
+
+// Interval is the tick period: the override, or configured, at the current
+// speed.
+func (p *Playback) Interval(configured time.Duration) time.Duration {
+	if o := p.Override(); o > 0 {
+		configured = o
+	}
+	return effectiveInterval(configured, p.Speed())
+}
+
+func (p *Playback) notify() {
+	select {
+	case p.timingChanged <- struct{}{}:
+	default:
+	}
+}
+
+// effectiveInterval is the tick period for interval played at speed.
+func effectiveInterval(interval time.Duration, speed float64) time.Duration {
+	if speed <= 0 {
+		return interval
+	}
+	return max(time.Duration(float64(interval)/speed), time.Millisecond)
+}
+
+// parseSeekQuery reads msec=, frame= and speed= from a /control/seek query.
+func parseSeekQuery(q url.Values) (req *seekRequest, speed float64, err error) {
+	if v := q.Get("msec"); v != "" {
+		ms, err := strconv.ParseFloat(v, 64)
+		if err != nil || ms < 0 {
+			return nil, 0, errors.New("invalid msec")
+		}
+		req = &seekRequest{msec: ms}
+	}
+	if v := q.Get("frame"); v != "" {
+		if req != nil {
+			return nil, 0, errors.New("msec and frame are exclusive")
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, 0, errors.New("invalid frame")
+		}
+		req = &seekRequest{frame: n, byFrame: true}
+	}
+	if v := q.Get("speed"); v != "" {
+		speed, err = strconv.ParseFloat(v, 64)
+		if err != nil || speed <= 0 || speed > maxSpeed {
+			return nil, 0, errors.New("invalid speed (want 0 < speed <= 16)")
+		}
+	}
+	if req == nil && speed == 0 {
+		return nil, 0, errors.New("want msec, frame or speed")
+	}
+	return req, speed, nil
+}
+
+// isFileSource reports whether source names a local file (as opposed to a
+// camera index, a stream URL or a synthetic source).
+func isFileSource(source string) bool {
+	if _, err := strconv.Atoi(source); err == nil {
+		return false
+	}
+	return !strings.Contains(source, "://")
+}
+
+// Seek moves a file source to the requested position.
+func (d *DNNDetector) Seek(req seekRequest) error {
+	cap, ok := d.cap.(captureProperties)
+	if !ok || !isFileSource(d.source) {
+		return errNotSeekable
+	}
+	if req.byFrame {
+		cap.Set(gocv.VideoCapturePosFrames, float64(req.frame))
+	} else {
+		cap.Set(gocv.VideoCapturePosMsec, req.msec)
+	}
+	return nil
+}
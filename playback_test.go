@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// fakeCapture is a frame source whose properties are recorded, and read
+// back as set unless ignored.
+type fakeCapture struct {
+	fakeSource
+	props   map[gocv.VideoCaptureProperties]float64
+	ignored map[gocv.VideoCaptureProperties]bool
+}
+
+func newFakeCapture() *fakeCapture {
+	return &fakeCapture{fakeSource: fakeSource{w: 100, h: 100}, props: map[gocv.VideoCaptureProperties]float64{}}
+}
+
+func (c *fakeCapture) Set(prop gocv.VideoCaptureProperties, v float64) {
+	if !c.ignored[prop] {
+		c.props[prop] = v
+	}
+}
+
+func (c *fakeCapture) Get(prop gocv.VideoCaptureProperties) float64 { return c.props[prop] }
+
+func TestDetectorSeek(t *testing.T) {
+	tests := []struct {
+		source string
+		req    seekRequest
+		prop   gocv.VideoCaptureProperties
+		want   float64
+	}{
+		{"clip.mp4", seekRequest{msec: 1500}, gocv.VideoCapturePosMsec, 1500},
+		{"/videos/clip.mp4", seekRequest{frame: 120, byFrame: true}, gocv.VideoCapturePosFrames, 120},
+		{"clip.mp4", seekRequest{frame: 0, byFrame: true}, gocv.VideoCapturePosFrames, 0},
+	}
+	for _, tt := range tests {
+		cap := newFakeCapture()
+		d := &DNNDetector{source: tt.source, cap: cap}
+		if err := d.Seek(tt.req); err != nil {
+			t.Fatalf("%+v: %v", tt.req, err)
+		}
+		if v, ok := cap.props[tt.prop]; !ok || v != tt.want || len(cap.props) != 1 {
+			t.Errorf("%+v: set %v, want only %v=%v", tt.req, cap.props, tt.prop, tt.want)
+		}
+	}
+
+	for _, source := range []string{"0", "rtsp://cam/stream", "http://cam/mjpeg"} {
+		cap := newFakeCapture()
+		d := &DNNDetector{source: source, cap: cap}
+		if err := d.Seek(seekRequest{msec: 10}); err != errNotSeekable || len(cap.props) != 0 {
+			t.Errorf("seek on %s: %v, set %v, want errNotSeekable and nothing set", source, err, cap.props)
+		}
+	}
+	if err := (&DNNDetector{source: "clip.mp4", cap: fakeSource{}}).Seek(seekRequest{msec: 10}); err != errNotSeekable {
+		t.Errorf("seek on a source without properties: %v, want errNotSeekable", err)
+	}
+}
+
+func TestEffectiveInterval(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		speed    float64
+		want     time.Duration
+	}{
+		{100 * time.Millisecond, 1, 100 * time.Millisecond},
+		{100 * time.Millisecond, 2, 50 * time.Millisecond},
+		{100 * time.Millisecond, 0.5, 200 * time.Millisecond},
+		{100 * time.Millisecond, 16, 6250 * time.Microsecond},
+		{10 * time.Millisecond, 16, time.Millisecond}, // floored
+		{100 * time.Millisecond, 0, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := effectiveInterval(tt.interval, tt.speed); got != tt.want {
+			t.Errorf("effectiveInterval(%v, %v) = %v, want %v", tt.interval, tt.speed, got, tt.want)
+		}
+	}
+
+	p := NewPlayback()
+	p.SetSpeed(4)
+	if got := p.Interval(time.Second); got != 250*time.Millisecond {
+		t.Errorf("Interval at 4x = %v, want 250ms", got)
+	}
+	p.SetInterval(200 * time.Millisecond)
+	if got := p.Interval(time.Second); got != 50*time.Millisecond {
+		t.Errorf("Interval with a 200ms override at 4x = %v, want 50ms", got)
+	}
+}
+
+func TestParseSeekQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		req   *seekRequest
+		speed float64
+		err   bool
+	}{
+		{"msec=1500", &seekRequest{msec: 1500}, 0, false},
+		{"frame=42&speed=2", &seekRequest{frame: 42, byFrame: true}, 2, false},
+		{"speed=0.5", nil, 0.5, false},
+		{"msec=1&frame=2", nil, 0, true},
+		{"msec=-1", nil, 0, true},
+		{"frame=1.5", nil, 0, true},
+		{"speed=0", nil, 0, true},
+		{"speed=17", nil, 0, true},
+		{"", nil, 0, true},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		req, speed, err := parseSeekQuery(q)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: err %v, want error %v", tt.query, err, tt.err)
+			continue
+		}
+		if speed != tt.speed || (req == nil) != (tt.req == nil) || (req != nil && *req != *tt.req) {
+			t.Errorf("%q = %+v, %v, want %+v, %v", tt.query, req, speed, tt.req, tt.speed)
+		}
+	}
+}
+
+func TestControlSeek(t *testing.T) {
+	tests := []struct {
+		source string
+		query  string
+		code   int
+	}{
+		{"clip.mp4", "frame=10&speed=2", http.StatusOK},
+		{"rtsp://cam/stream", "frame=10", http.StatusConflict},
+		{"0", "msec=10", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		cfg := DetectorConfig{Interval: time.Hour}
+		d := newFakeDetector(t, cfg, &fakeLoader{next: []*fakeNet{{}}})
+		cap := newFakeCapture()
+		d.source, d.cap = tt.source, cap
+		p := &Pipeline{Store: NewFaceStore(), Stats: NewStats(), Playback: NewPlayback()}
+		stop := startLoop(d, cfg, p)
+
+		r := httptest.NewRequest(http.MethodPost, "/control/seek?"+tt.query, nil)
+		r.Header.Set("Authorization", "Bearer tok")
+		w := httptest.NewRecorder()
+		controlHandler("tok", &Lifecycle{Playback: p.Playback}).ServeHTTP(w, r)
+		stop()
+		if w.Code != tt.code {
+			t.Errorf("seek %s on %s: %d, want %d", tt.query, tt.source, w.Code, tt.code)
+		}
+		if tt.code == http.StatusOK && (cap.props[gocv.VideoCapturePosFrames] != 10 || p.Playback.Speed() != 2) {
+			t.Errorf("seek %s: props %v, speed %v", tt.query, cap.props, p.Playback.Speed())
+		}
+		if tt.code != http.StatusOK && len(cap.props) != 0 {
+			t.Errorf("seek on %s: set %v, want nothing", tt.source, cap.props)
+		}
+	}
+}
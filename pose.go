@@ -0,0 +1,89 @@
+package main
+
+import "math"
+
+/* ------------------------------ Head pose ---------------------------------- */
+
+// Pose is a coarse head orientation in degrees, derived from 2D landmarks.
+// Yaw > 0 means the face is turned towards the image's right, pitch > 0 means
+// looking up, roll > 0 means the head is tilted clockwise in the image.
+type Pose struct {
+	Yaw   float64 `json:"yaw"`
+	Pitch float64 `json:"pitch"`
+	Roll  float64 `json:"roll"`
+}
+
+// Five-point landmark layout (as emitted by YuNet / RetinaFace style models):
+// eyes, nose tip, mouth corners. Left/right are from the viewer's side.
+const (
+	lmLeftEye = iota
+	lmRightEye
+	lmNose
+	lmLeftMouth
+	lmRightMouth
+	lmCount
+)
+
+// noseDepth is the nose tip's distance in front of the eye/mouth plane,
+// relative to the inter-eye (resp. eye-to-mouth) distance. It turns the 2D
+// nose offset into an angle; the value is a rough anthropometric average.
+const noseDepth = 0.6
+
+// estimatePose returns the head pose for a five-point landmark set, or nil
+// when the landmarks are missing or degenerate. It is a planar approximation
+// good for "facing the camera or not" decisions, not for precise angles.
+func estimatePose(lm []Point) *Pose {
+	if len(lm) != lmCount {
+		return nil
+	}
+	le, re, nose := lm[lmLeftEye], lm[lmRightEye], lm[lmNose]
+	lmo, rmo := lm[lmLeftMouth], lm[lmRightMouth]
+
+	ex, ey := float64(re.X-le.X), float64(re.Y-le.Y)
+	eyeDist := math.Hypot(ex, ey)
+	if eyeDist == 0 {
+		return nil
+	}
+	roll := math.Atan2(ey, ex)
+
+	// Work in a frame aligned with the eye line so roll doesn't leak into
+	// yaw/pitch: u along the eyes, v perpendicular (pointing down the face).
+	cos, sin := math.Cos(roll), math.Sin(roll)
+	toUV := func(x, y float64) (float64, float64) {
+		return x*cos + y*sin, -x*sin + y*cos
+	}
+	eyeU, eyeV := toUV(float64(le.X+re.X)/2, float64(le.Y+re.Y)/2)
+	mouthU, mouthV := toUV(float64(lmo.X+rmo.X)/2, float64(lmo.Y+rmo.Y)/2)
+	noseU, noseV := toUV(float64(nose.X), float64(nose.Y))
+
+	faceHeight := mouthV - eyeV
+	if faceHeight <= 0 {
+		return nil
+	}
+	midU := (eyeU + mouthU) / 2
+	midV := (eyeV + mouthV) / 2
+
+	yaw := math.Atan((noseU - midU) / (noseDepth * eyeDist))
+	pitch := -math.Atan((noseV - midV) / (noseDepth * faceHeight))
+
+	return &Pose{
+		Yaw:   yaw * 180 / math.Pi,
+		Pitch: pitch * 180 / math.Pi,
+		Roll:  roll * 180 / math.Pi,
+	}
+}
+
+// applyPose fills Detection.Pose from landmarks and, when maxYaw > 0, drops
+// faces turned further than maxYaw degrees. Detections without landmarks
+// (e.g. Res10) are kept as-is since their pose is unknown.
+func applyPose(dets []Detection, maxYaw float32) []Detection {
+	out := dets[:0]
+	for _, d := range dets {
+		d.Pose = estimatePose(d.Landmarks)
+		if maxYaw > 0 && d.Pose != nil && math.Abs(d.Pose.Yaw) > float64(maxYaw) {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// faceLandmarks returns a five-point landmark set: eyes 20px apart, mouth
+// 20px below them, and the nose tip at (noseX, noseY), (50, 50) being frontal.
+func faceLandmarks(noseX, noseY int) []Point {
+	return []Point{{40, 40}, {60, 40}, {noseX, noseY}, {42, 60}, {58, 60}}
+}
+
+func TestEstimatePose(t *testing.T) {
+	tests := []struct {
+		name string
+		lm   []Point
+		want *Pose
+	}{
+		{"frontal", faceLandmarks(50, 50), &Pose{}},
+		{"profile right", faceLandmarks(62, 50), &Pose{Yaw: 45}},
+		{"profile left", faceLandmarks(38, 50), &Pose{Yaw: -45}},
+		{"looking down", faceLandmarks(50, 56), &Pose{Pitch: -math.Atan(0.5) * 180 / math.Pi}},
+		// frontal face rotated 90° clockwise around the nose
+		{"tilted", []Point{{60, 40}, {60, 60}, {50, 50}, {40, 42}, {40, 58}}, &Pose{Roll: 90}},
+		{"no landmarks", nil, nil},
+		{"eyes on top of each other", []Point{{50, 40}, {50, 40}, {50, 50}, {42, 60}, {58, 60}}, nil},
+		{"mouth above eyes", []Point{{40, 60}, {60, 60}, {50, 50}, {42, 40}, {58, 40}}, nil},
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	for _, tt := range tests {
+		got := estimatePose(tt.lm)
+		if (got == nil) != (tt.want == nil) {
+			t.Errorf("%s: pose %+v, want %+v", tt.name, got, tt.want)
+			continue
+		}
+		if got != nil && !(near(got.Yaw, tt.want.Yaw) && near(got.Pitch, tt.want.Pitch) && near(got.Roll, tt.want.Roll)) {
+			t.Errorf("%s: pose %+v, want %+v", tt.name, *got, *tt.want)
+		}
+	}
+}
+
+func TestApplyPose(t *testing.T) {
+	dets := func() []Detection {
+		return []Detection{{ID: 1, Landmarks: faceLandmarks(50, 50)}, {ID: 2, Landmarks: faceLandmarks(62, 50)}, {ID: 3}}
+	}
+	tests := []struct {
+		maxYaw float32
+		want   []int
+	}{
+		{0, []int{1, 2, 3}},
+		{30, []int{1, 3}},
+		{45.5, []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		got := applyPose(dets(), tt.maxYaw)
+		var ids []int
+		for _, d := range got {
+			ids = append(ids, d.ID)
+			if (d.Pose == nil) != (d.Landmarks == nil) {
+				t.Errorf("maxYaw %v: face %d pose %+v with landmarks %v", tt.maxYaw, d.ID, d.Pose, d.Landmarks)
+			}
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("maxYaw %v: kept %v, want %v", tt.maxYaw, ids, tt.want)
+		}
+	}
+}
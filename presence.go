@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/* -------------------------------- Presence --------------------------------- */
+
+// Presence is a debounced occupancy flag: it turns on with the first face
+// and stays on until no face has been seen for hold, so brief misses don't
+// flicker a relay.
+type Presence struct {
+	hold time.Duration
+
+	mu      sync.Mutex
+	state   PresenceState
+	changed chan struct{} // signaled (non-blocking) on every transition
+}
+
+// PresenceState is served on /presence and sent to the webhook.
+type PresenceState struct {
+	Present  bool      `json:"present"`
+	Since    time.Time `json:"since,omitzero"`     // last transition
+	LastSeen time.Time `json:"last_seen,omitzero"` // last frame with a face
+	HoldSec  float64   `json:"hold_s"`
+}
+
+func NewPresence(hold time.Duration) *Presence {
+	return &Presence{hold: hold, changed: make(chan struct{}, 1), state: PresenceState{HoldSec: hold.Seconds()}}
+}
+
+// Observe records count faces seen at t and reports whether presence
+// changed.
+func (p *Presence) Observe(count int, t time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if count > 0 {
+		p.state.LastSeen = t
+	}
+	present := count > 0 || p.state.Present && t.Sub(p.state.LastSeen) < p.hold
+	if present == p.state.Present {
+		return false
+	}
+	p.state.Present, p.state.Since = present, t
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (p *Presence) State() PresenceState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+func presenceHandler(p *Presence) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, p.State(), true)
+	}
+}
+
+// StartPresenceWebhook POSTs the presence state as JSON to url on every
+// transition until ctx is done. Transitions that happen while a call is in
+// flight are coalesced into the latest state.
+func StartPresenceWebhook(ctx context.Context, p *Presence, url string) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.changed:
+			}
+			if err := postJSON(ctx, client, url, p.State()); err != nil {
+				log.Printf("[presence] webhook: %v", err)
+			}
+		}
+	}()
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}
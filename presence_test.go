@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPresenceTimeline(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		at      time.Duration
+		count   int
+		changed bool
+		present bool
+	}{
+		{0, 0, false, false},
+		{1 * time.Second, 2, true, true},           // first face
+		{2 * time.Second, 0, false, true},          // brief miss
+		{3 * time.Second, 1, false, true},          // back before hold
+		{5 * time.Second, 0, false, true},          // 2s without a face
+		{7900 * time.Millisecond, 0, false, true},  // just under hold
+		{8 * time.Second, 0, true, false},          // hold elapsed since last seen (3s)
+		{9 * time.Second, 0, false, false},         // stays off
+		{10 * time.Second, 1, true, true},          // on again at once
+		{10500 * time.Millisecond, 3, false, true}, // more faces, no transition
+		{20 * time.Second, 0, true, false},         // a late frame turns it off directly
+	}
+
+	p := NewPresence(5 * time.Second)
+	var since time.Time
+	for _, s := range steps {
+		at := t0.Add(s.at)
+		if got := p.Observe(s.count, at); got != s.changed {
+			t.Fatalf("%v: Observe(%d) changed = %v, want %v", s.at, s.count, got, s.changed)
+		}
+		if s.changed {
+			since = at
+		}
+		st := p.State()
+		if st.Present != s.present || !st.Since.Equal(since) {
+			t.Fatalf("%v: state %+v, want present=%v since %v", s.at, st, s.present, since)
+		}
+	}
+	if st := p.State(); !st.LastSeen.Equal(t0.Add(10500*time.Millisecond)) || st.HoldSec != 5 {
+		t.Fatalf("final state %+v", st)
+	}
+}
+
+func TestPresenceWebhook(t *testing.T) {
+	got := make(chan PresenceState, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var st PresenceState
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s with %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+			t.Error(err)
+		}
+		got <- st
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := NewPresence(time.Second)
+	StartPresenceWebhook(ctx, p, srv.URL)
+
+	p.Observe(1, time.Now())
+	select {
+	case st := <-got:
+		if !st.Present {
+			t.Fatalf("webhook got %+v, want present", st)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
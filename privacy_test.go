@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountOnlyLeaksNoCoordinates(t *testing.T) {
+	store := NewCountOnlyFaceStore()
+	snap := fullSnapshot()
+	snap.Detections[0].Predicted = false // a real cluster of two faces
+	snap.Raw = snap.Detections
+	store.Set(snap)
+
+	if got, _ := store.Get(); got.Detections != nil || got.Raw != nil || got.Events != nil || got.Count != 2 {
+		t.Fatalf("store holds %+v, want only the count", got)
+	}
+
+	// As main wires privacy mode: no retained frames, no preview.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mux, _ := newServeMux(ctx, ServerConfig{Stats: NewStats()}, store)
+	paths := []string{
+		"/faces", "/faces?raw=true", "/faces?filter=score+>+0", "/faces?case=camel", "/faces?v=1",
+		"/faces/stream", "/count", "/count?plain=1", "/zones", "/app-config.json",
+		"/stats", "/stats/peaks", "/metrics",
+		"/snapshot.jpg", "/stream.mjpg", "/face/7/1.jpg", "/export/crops?from=0&to=10", "/diff?from=6&to=7",
+	}
+	for _, path := range paths {
+		reqCtx, stop := context.WithTimeout(ctx, 100*time.Millisecond)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil).WithContext(reqCtx))
+		stop()
+		body, _ := io.ReadAll(rec.Body)
+		for _, field := range []string{`"bbox"`, `"landmarks"`, `"x"`, `"width"`, `"score"`, `"pose"`, `"raw"`, "\xff\xd8"} {
+			if strings.Contains(string(body), field) {
+				t.Errorf("%s (%d) exposes %s: %s", path, rec.Code, field, body)
+			}
+		}
+		if rec.Code == 200 && strings.HasPrefix(rec.Header().Get("Content-Type"), "image/") {
+			t.Errorf("%s serves an image", path)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/faces", nil))
+	if got, want := jsonKeys(t, rec.Body.Bytes()), "count frame generated_at meta schema_version source zones"; got != want {
+		t.Errorf("/faces keys %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/* ------------------------------ Publishers --------------------------------- */
+
+// publishFlushTimeout bounds how long queued snapshots may take to flush on
+// shutdown, and a single Kafka message to be sent.
+const publishFlushTimeout = 5 * time.Second
+
+// Publisher delivers serialized snapshots to an external system.
+// Publish may block; it is always called from a dedicated goroutine.
+type Publisher interface {
+	Publish(ctx context.Context, key, value []byte) error
+	Close() error
+}
+
+// PublisherCounters are the per-sink counters shown in /stats.
+type PublisherCounters struct {
+	Published atomic.Int64
+	Dropped   atomic.Int64 // evicted from a full queue
+	Errors    atomic.Int64
+}
+
+// PublisherStats is the JSON view of PublisherCounters.
+type PublisherStats struct {
+	Published int64 `json:"published"`
+	Dropped   int64 `json:"dropped"`
+	Errors    int64 `json:"errors"`
+}
+
+func (c *PublisherCounters) Report() PublisherStats {
+	return PublisherStats{Published: c.Published.Load(), Dropped: c.Dropped.Load(), Errors: c.Errors.Load()}
+}
+
+// encodeSnapshotMessage returns the message key (the snapshot source, so all
+// frames of a camera land in the same partition) and the JSON payload.
+func encodeSnapshotMessage(snap Snapshot) (key, value []byte, err error) {
+	value, err = json.Marshal(snap)
+	return []byte(snap.Source), value, err
+}
+
+// dropQueue is a bounded FIFO that drops its oldest entry when full, so a
+// slow sink falls behind on history but never on the latest data.
+type dropQueue[T any] struct {
+	mu      sync.Mutex
+	items   []T
+	max     int
+	ready   chan struct{} // signaled (non-blocking) on push
+	dropped *atomic.Int64
+}
+
+func newDropQueue[T any](max int, dropped *atomic.Int64) *dropQueue[T] {
+	return &dropQueue[T]{max: max, ready: make(chan struct{}, 1), dropped: dropped}
+}
+
+func (q *dropQueue[T]) push(v T) {
+	var zero T
+	q.mu.Lock()
+	if len(q.items) >= q.max {
+		q.items[0] = zero
+		q.items = q.items[1:]
+		q.dropped.Add(1)
+	}
+	q.items = append(q.items, v)
+	q.mu.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *dropQueue[T]) pop() (T, bool) {
+	var zero T
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return zero, false
+	}
+	v := q.items[0]
+	q.items[0] = zero
+	q.items = q.items[1:]
+	return v, true
+}
+
+// publisherSink adapts a Publisher (Kafka) to the Sink interface: one
+// message per snapshot, keyed by source.
+type publisherSink struct {
+	pub     Publisher
+	timeout time.Duration // per message
+}
+
+func (p *publisherSink) Publish(snap Snapshot) error {
+	key, value, err := encodeSnapshotMessage(snap)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	return p.pub.Publish(ctx, key, value)
+}
+
+func (p *publisherSink) Close() error {
+	return p.pub.Close()
+}
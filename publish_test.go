@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakePublisher records the messages it is given.
+type fakePublisher struct {
+	keys, values [][]byte
+	deadline     time.Duration
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, key, value []byte) error {
+	p.keys, p.values = append(p.keys, key), append(p.values, value)
+	if dl, ok := ctx.Deadline(); ok {
+		p.deadline = time.Until(dl)
+	}
+	return nil
+}
+
+func (p *fakePublisher) Close() error { return nil }
+
+func TestEncodeSnapshotMessage(t *testing.T) {
+	for _, source := range []string{"0", "rtsp://cam/1", ""} {
+		snap := fullSnapshot()
+		snap.Source = source
+		key, value, err := encodeSnapshotMessage(snap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(key) != source {
+			t.Errorf("key %q, want the source %q", key, source)
+		}
+		var got Snapshot
+		if err := json.Unmarshal(value, &got); err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		if want, _ := json.Marshal(snap); !bytes.Equal(value, want) {
+			t.Errorf("%s: payload %s, want the /faces JSON %s", source, value, want)
+		}
+		if !reflect.DeepEqual(got.Detections, snap.Detections) || got.Frame != snap.Frame {
+			t.Errorf("%s: payload decodes to %+v", source, got)
+		}
+	}
+}
+
+func TestPublisherSink(t *testing.T) {
+	pub := &fakePublisher{}
+	sink := &publisherSink{pub: pub, timeout: time.Second}
+	for _, source := range []string{"cam-a", "cam-b"} {
+		snap := fullSnapshot()
+		snap.Source = source
+		if err := sink.Publish(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(pub.keys) != 2 || string(pub.keys[0]) != "cam-a" || string(pub.keys[1]) != "cam-b" {
+		t.Errorf("keys %q", pub.keys)
+	}
+	if pub.deadline <= 0 || pub.deadline > time.Second {
+		t.Errorf("message deadline %v, want within the %v timeout", pub.deadline, sink.timeout)
+	}
+}
+
+func TestDropQueue(t *testing.T) {
+	var dropped atomic.Int64
+	q := newDropQueue[int](2, &dropped)
+	for i := 1; i <= 4; i++ {
+		q.push(i)
+	}
+	if dropped.Load() != 2 {
+		t.Errorf("dropped %d, want 2", dropped.Load())
+	}
+	var got []int
+	for v, ok := q.pop(); ok; v, ok = q.pop() {
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, []int{3, 4}) {
+		t.Errorf("queue kept %v, want the newest [3 4]", got)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+/* ------------------------------ Face quality ------------------------------- */
+
+// qualityConfig weighs the components of the per-detection quality, used to
+// pick the best view of a face (crops, thumbnails). Each component is in
+// [0, 1]:
+//
+//	score      the detection confidence
+//	size       sqrt(box area) / SizeRef, capped at 1
+//	sharpness  v / (v + SharpRef), v the Laplacian variance of the crop
+//
+// and the quality is their weighted mean.
+type qualityConfig struct {
+	WScore, WSize, WSharp float64
+	SizeRef               float64 // px, side of a box large enough for recognition
+	SharpRef              float64 // Laplacian variance scoring 0.5
+}
+
+// parseQualityWeights reads "score,size,sharpness" weights; at least one
+// must be positive.
+func parseQualityWeights(s string) ([3]float64, error) {
+	var w [3]float64
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return w, fmt.Errorf("want score,size,sharpness weights, got %q", s)
+	}
+	var sum float64
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			return w, fmt.Errorf("want non-negative score,size,sharpness weights, got %q", s)
+		}
+		w[i] = v
+		sum += v
+	}
+	if sum == 0 {
+		return w, fmt.Errorf("at least one weight must be positive, got %q", s)
+	}
+	return w, nil
+}
+
+// combine returns the weighted mean of the components.
+func (q qualityConfig) combine(score, size, sharp float64) float64 {
+	sum := q.WScore + q.WSize + q.WSharp
+	v := (q.WScore*score + q.WSize*size + q.WSharp*sharp) / sum
+	return math.Round(v*1000) / 1000
+}
+
+func (q qualityConfig) sizeComponent(box Rect) float64 {
+	if q.SizeRef <= 0 {
+		return 1
+	}
+	return math.Min(math.Sqrt(float64(box.Width*box.Height))/q.SizeRef, 1)
+}
+
+func (q qualityConfig) sharpComponent(v float64) float64 {
+	if q.SharpRef <= 0 || v <= 0 {
+		return 0
+	}
+	return v / (v + q.SharpRef)
+}
+
+// Annotate sets Quality on every detection; img is the frame the boxes are
+// in. The sharpness is only measured when it is weighted.
+func (q qualityConfig) Annotate(img gocv.Mat, dets []Detection) {
+	bounds := image.Rect(0, 0, img.Cols(), img.Rows())
+	for i := range dets {
+		var sharp float64
+		if q.WSharp > 0 {
+			b := dets[i].BBox
+			box := image.Rect(b.X, b.Y, b.X+b.Width, b.Y+b.Height).Intersect(bounds)
+			if !box.Empty() {
+				crop := img.Region(box)
+				sharp = q.sharpComponent(laplacianVariance(crop))
+				crop.Close()
+			}
+		}
+		dets[i].Quality = q.combine(dets[i].Score, q.sizeComponent(dets[i].BBox), sharp)
+	}
+}
+
+// laplacianVariance is the variance of the Laplacian of img in grayscale, a
+// common focus measure: blur removes the edges the Laplacian responds to.
+func laplacianVariance(img gocv.Mat) float64 {
+	gray := img
+	if img.Channels() > 1 {
+		gray = gocv.NewMat()
+		defer gray.Close()
+		code := gocv.ColorBGRToGray
+		if img.Channels() == 4 {
+			code = gocv.ColorBGRAToGray
+		}
+		if err := gocv.CvtColor(img, &gray, code); err != nil {
+			return 0
+		}
+	}
+	lap := gocv.NewMat()
+	defer lap.Close()
+	if err := gocv.Laplacian(gray, &lap, gocv.MatTypeCV64F, 1, 1, 0, gocv.BorderDefault); err != nil {
+		return 0
+	}
+	mean, std := gocv.NewMat(), gocv.NewMat()
+	defer mean.Close()
+	defer std.Close()
+	if err := gocv.MeanStdDev(lap, &mean, &std); err != nil || std.Empty() {
+		return 0
+	}
+	s := std.GetDoubleAt(0, 0)
+	return s * s
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"image"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// checkerboard returns a size x size BGR frame of 8 px black and white
+// squares.
+func checkerboard(t *testing.T, size int) gocv.Mat {
+	t.Helper()
+	data := make([]byte, size*size*3)
+	for y := range size {
+		for x := range size {
+			if (x/8+y/8)%2 == 0 {
+				copy(data[(y*size+x)*3:], []byte{255, 255, 255})
+			}
+		}
+	}
+	m, err := gocv.NewMatFromBytes(size, size, gocv.MatTypeCV8UC3, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestLaplacianVariance(t *testing.T) {
+	sharp := checkerboard(t, 64)
+	defer sharp.Close()
+	blurred := gocv.NewMat()
+	defer blurred.Close()
+	if err := gocv.GaussianBlur(sharp, &blurred, image.Pt(9, 9), 0, 0, gocv.BorderDefault); err != nil {
+		t.Fatal(err)
+	}
+	flat := gocv.NewMatWithSize(64, 64, gocv.MatTypeCV8UC3)
+	defer flat.Close()
+
+	vs, vb, vf := laplacianVariance(sharp), laplacianVariance(blurred), laplacianVariance(flat)
+	if vs <= 4*vb || vb <= vf || vf != 0 {
+		t.Errorf("variance sharp %v, blurred %v, flat %v: want sharp >> blurred > flat = 0", vs, vb, vf)
+	}
+}
+
+func TestQualityWeighting(t *testing.T) {
+	tests := []struct {
+		q                  qualityConfig
+		score, size, sharp float64
+		want               float64
+	}{
+		{qualityConfig{WScore: 1}, 0.8, 0.2, 0.4, 0.8},
+		{qualityConfig{WSize: 1}, 0.8, 0.2, 0.4, 0.2},
+		{qualityConfig{WScore: 1, WSize: 1}, 0.8, 0.2, 0.4, 0.5},
+		{qualityConfig{WScore: 2, WSize: 1, WSharp: 1}, 0.8, 0.2, 0.4, 0.55},
+		{qualityConfig{WScore: 1, WSize: 1, WSharp: 1}, 0.9, 0.5, 0.25, 0.55},
+	}
+	for _, tt := range tests {
+		if got := tt.q.combine(tt.score, tt.size, tt.sharp); got != tt.want {
+			t.Errorf("%+v.combine(%v, %v, %v) = %v, want %v", tt.q, tt.score, tt.size, tt.sharp, got, tt.want)
+		}
+	}
+
+	q := qualityConfig{SizeRef: 100, SharpRef: 50}
+	for _, c := range []struct {
+		box  Rect
+		want float64
+	}{{Rect{Width: 50, Height: 50}, 0.5}, {Rect{Width: 100, Height: 100}, 1}, {Rect{Width: 400, Height: 100}, 1}} {
+		if got := q.sizeComponent(c.box); got != c.want {
+			t.Errorf("sizeComponent(%+v) = %v, want %v", c.box, got, c.want)
+		}
+	}
+	if got := q.sharpComponent(50); got != 0.5 {
+		t.Errorf("sharpComponent(SharpRef) = %v, want 0.5", got)
+	}
+
+	// Two boxes of equal score and size: the one over the sharp half wins.
+	sharp := checkerboard(t, 128)
+	defer sharp.Close()
+	blurred := gocv.NewMat()
+	defer blurred.Close()
+	if err := gocv.GaussianBlur(sharp, &blurred, image.Pt(9, 9), 0, 0, gocv.BorderDefault); err != nil {
+		t.Fatal(err)
+	}
+	data, half := sharp.ToBytes(), blurred.ToBytes()
+	for y := range 128 { // right half blurred
+		copy(data[(y*128+64)*3:(y+1)*128*3], half[(y*128+64)*3:])
+	}
+	frame, err := gocv.NewMatFromBytes(128, 128, gocv.MatTypeCV8UC3, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer frame.Close()
+	dets := []Detection{
+		{BBox: Rect{X: 8, Y: 8, Width: 48, Height: 48}, Score: 0.9},
+		{BBox: Rect{X: 72, Y: 8, Width: 48, Height: 48}, Score: 0.9},
+	}
+	qualityConfig{WScore: 1, WSize: 1, WSharp: 1, SizeRef: 96, SharpRef: 1000}.Annotate(frame, dets)
+	if dets[0].Quality <= dets[1].Quality {
+		t.Errorf("quality sharp %v, blurred %v: want sharp higher", dets[0].Quality, dets[1].Quality)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable clock for the rate limiter.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newRateLimiter(2, 3)
+	l.now = clock.now
+	h := l.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	get := func(path, addr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := range 3 { // the burst
+		if w := get("/faces", "10.0.0.1:1000"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: %d, want 200", i, w.Code)
+		}
+	}
+	w := get("/faces", "10.0.0.1:1001") // same IP, other port
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("over budget: %d, want 429", w.Code)
+	}
+	if ra := w.Header().Get("Retry-After"); ra != "1" {
+		t.Fatalf("Retry-After = %q, want 1", ra)
+	}
+
+	if w := get("/faces", "10.0.0.2:1000"); w.Code != http.StatusOK {
+		t.Fatalf("other client: %d, want 200", w.Code)
+	}
+	if w := get("/healthz", "10.0.0.1:1000"); w.Code != http.StatusOK {
+		t.Fatalf("exempt path: %d, want 200", w.Code)
+	}
+
+	clock.advance(500 * time.Millisecond) // one token at 2/s
+	if w := get("/faces", "10.0.0.1:1000"); w.Code != http.StatusOK {
+		t.Fatalf("after refill: %d, want 200", w.Code)
+	}
+	if w := get("/faces", "10.0.0.1:1000"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("after spending the refill: %d, want 429", w.Code)
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newRateLimiter(1, 2) // refilled after 2s, swept at most once a minute
+	l.now = clock.now
+
+	l.allow("idle")
+	l.allow("busy")
+	clock.advance(30 * time.Second)
+	l.allow("busy")
+	if len(l.buckets) != 2 {
+		t.Fatalf("%d buckets before the first sweep, want 2", len(l.buckets))
+	}
+
+	clock.advance(31 * time.Second) // "busy" was used 31s ago, both are full
+	l.allow("new")
+	if _, ok := l.buckets["idle"]; ok || len(l.buckets) != 1 {
+		t.Fatalf("buckets after sweep = %v, want only the new one", slices.Collect(maps.Keys(l.buckets)))
+	}
+
+	// A bucket still refilling survives the sweep.
+	l.allow("new")
+	l.allow("new")
+	clock.advance(time.Minute - time.Second)
+	l.allow("recent")
+	clock.advance(time.Second)
+	l.allow("other")
+	if _, ok := l.buckets["recent"]; !ok {
+		t.Fatal("a bucket used 1s ago was swept")
+	}
+	if _, ok := l.buckets["new"]; ok {
+		t.Fatal("a bucket idle for a minute survived the sweep")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"gocv.io/x/gocv"
+)
+
+/* ------------------------------ Region scoring ----------------------------- */
+
+// RegionScorer scores a user-supplied box of a retained frame, for labeling
+// tools: against the frame's detections (the best overlap), and with the
+// second-stage classifier when one is configured. Classification runs in
+// the detector loop, which owns the classifier net.
+type RegionScorer struct {
+	frames   *FrameRing
+	requests chan regionRequest // nil without a classifier
+}
+
+type regionRequest struct {
+	img   gocv.Mat // the region, owned by the requester
+	reply chan map[string]float64
+}
+
+// RegionScore is the /score-region response.
+type RegionScore struct {
+	Frame int64 `json:"frame"`
+	BBox  Rect  `json:"bbox"`
+	// The detection of the frame overlapping the box most, if any, with its
+	// score, the overlap (IoU) and the score weighted by the overlap.
+	Detection     *Detection `json:"detection,omitempty"`
+	IoU           float64    `json:"iou"`
+	Score         float64    `json:"score"`
+	WeightedScore float64    `json:"weighted_score"`
+	// Classifier output for the box itself, by label (FACE_CLASSIFIER_*).
+	Attributes map[string]float64 `json:"attributes,omitempty"`
+}
+
+// NewRegionScorer scores boxes of frames' frames; with classify, the
+// detector loop classifies them too.
+func NewRegionScorer(frames *FrameRing, classify bool) *RegionScorer {
+	s := &RegionScorer{frames: frames}
+	if classify {
+		s.requests = make(chan regionRequest)
+	}
+	return s
+}
+
+// Score scores box in a retained frame, errNotRetained once it is evicted.
+func (s *RegionScorer) Score(ctx context.Context, frame int64, box Rect) (RegionScore, error) {
+	snap, ok := s.frames.Snapshot(frame)
+	if !ok {
+		return RegionScore{}, errNotRetained
+	}
+	res := RegionScore{Frame: frame, BBox: box}
+	for i, d := range snap.Detections {
+		if iou := rectIoU(box, d.BBox); iou > res.IoU {
+			res.Detection, res.IoU = &snap.Detections[i], iou
+		}
+	}
+	if res.Detection != nil {
+		res.Score = res.Detection.Score
+		res.WeightedScore = math.Round(res.Score*res.IoU*1000) / 1000
+		res.IoU = math.Round(res.IoU*1000) / 1000
+	}
+	if s.requests == nil {
+		return res, nil
+	}
+
+	img, ok := s.frames.Region(frame, box)
+	if !ok {
+		return res, nil // outside the frame: nothing to classify
+	}
+	defer img.Close()
+	req := regionRequest{img: img, reply: make(chan map[string]float64, 1)}
+	select {
+	case s.requests <- req:
+	case <-ctx.Done():
+		return res, ctx.Err()
+	}
+	select {
+	case res.Attributes = <-req.reply:
+	case <-ctx.Done():
+		return res, ctx.Err()
+	}
+	return res, nil
+}
+
+// classifyRegion runs the classifier on a whole image; nil without one.
+func (d *DNNDetector) classifyRegion(img gocv.Mat) map[string]float64 {
+	if d.classifier == nil {
+		return nil
+	}
+	dets := []Detection{{BBox: Rect{Width: img.Cols(), Height: img.Rows()}}}
+	d.classifier.Annotate(img, dets)
+	return dets[0].Attributes
+}
+
+// scoreRegionHandler serves POST /score-region?frame=<frame>&box=x,y,w,h:
+// the detector's confidence for a box of a retained frame, 404 once the
+// frame is evicted.
+func scoreRegionHandler(s *RegionScorer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		frame, err := strconv.ParseInt(q.Get("frame"), 10, 64)
+		if err != nil {
+			http.Error(w, "frame is required", http.StatusBadRequest)
+			return
+		}
+		box, err := parseRect(q.Get("box"))
+		if err != nil {
+			http.Error(w, "box: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := s.Score(r.Context(), frame, box)
+		switch {
+		case errors.Is(err, errNotRetained):
+			http.Error(w, "frame no longer retained", http.StatusNotFound)
+			return
+		case err != nil:
+			log.Printf("[score-region] %v", err)
+			http.Error(w, "cannot score the region", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, res, true)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestScoreRegion(t *testing.T) {
+	ring := NewFrameRing(4, 1<<20, 0)
+	img := gocv.NewMatWithSize(200, 200, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	ring.Add(Snapshot{Frame: 1, Detections: []Detection{
+		{ID: 1, BBox: Rect{X: 10, Y: 10, Width: 40, Height: 40}, Score: 0.9},
+		{ID: 2, BBox: Rect{X: 100, Y: 100, Width: 40, Height: 40}, Score: 0.6},
+	}}, img)
+
+	tests := []struct {
+		query         string
+		code          int
+		id            int // 0: no overlapping detection
+		iou, weighted float64
+	}{
+		{"frame=1&box=10,10,40,40", http.StatusOK, 1, 1, 0.9},
+		{"frame=1&box=110,100,40,40", http.StatusOK, 2, 0.6, 0.36}, // IoU 1200/2000
+		{"frame=1&box=20,10,40,40", http.StatusOK, 1, 0.6, 0.54},
+		{"frame=1&box=60,60,30,30", http.StatusOK, 0, 0, 0},
+		{"frame=2&box=10,10,40,40", http.StatusNotFound, 0, 0, 0},
+		{"frame=1&box=10,10", http.StatusBadRequest, 0, 0, 0},
+		{"box=10,10,40,40", http.StatusBadRequest, 0, 0, 0},
+	}
+	h := scoreRegionHandler(NewRegionScorer(ring, false))
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodPost, "/score-region?"+tt.query, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: %d, want %d", tt.query, w.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var res RegionScore
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		id := 0
+		if res.Detection != nil {
+			id = res.Detection.ID
+		}
+		if id != tt.id || res.IoU != tt.iou || res.WeightedScore != tt.weighted || res.Attributes != nil {
+			t.Errorf("%s: detection %d, iou %v, weighted %v, attributes %v; want %d, %v, %v", tt.query, id, res.IoU, res.WeightedScore, res.Attributes, tt.id, tt.iou, tt.weighted)
+		}
+	}
+
+	// With a classifier, the detector loop scores the region itself.
+	s := NewRegionScorer(ring, true)
+	go func() {
+		req := <-s.requests
+		req.reply <- map[string]float64{"mask": float64(req.img.Cols()*1000 + req.img.Rows())}
+	}()
+	w := httptest.NewRecorder()
+	scoreRegionHandler(s)(w, httptest.NewRequest(http.MethodPost, "/score-region?frame=1&box=60,60,30,20", nil))
+	var res RegionScore
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Attributes["mask"] != 30020 {
+		t.Errorf("classified region: %v %s", err, w.Body)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/* ------------------------------ Remote models ------------------------------ */
+
+// modelDownloadTimeout bounds the download of one model file.
+const modelDownloadTimeout = 5 * time.Minute
+
+// isRemotePath reports whether a model path is an http(s) URL.
+func isRemotePath(p string) bool {
+	return strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+}
+
+// resolveModelPath returns a local path for a model file. An http(s) URL is
+// downloaded into cacheDir once and reused on later starts and reloads; a
+// local path is returned as is. With sum (hex SHA256) set, the file is
+// verified, whether downloaded, cached or local, and a cached copy that
+// doesn't match is downloaded again.
+func resolveModelPath(p, sum, cacheDir string) (string, error) {
+	sum = strings.ToLower(strings.TrimSpace(sum))
+	if sum != "" && len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid SHA256 %q", sum)
+	}
+	if !isRemotePath(p) {
+		if sum != "" {
+			if err := verifySHA256(p, sum); err != nil {
+				return "", err
+			}
+		}
+		return p, nil
+	}
+
+	u, err := url.Parse(p)
+	if err != nil {
+		return "", fmt.Errorf("parse %s: %w", p, err)
+	}
+	// One directory per URL keeps the file name, which is reported as the
+	// model name.
+	key := sha256.Sum256([]byte(p))
+	dst := filepath.Join(cacheDir, hex.EncodeToString(key[:8]), path.Base(u.Path))
+	if _, err := os.Stat(dst); err == nil {
+		if sum == "" {
+			return dst, nil
+		}
+		if err := verifySHA256(dst, sum); err == nil {
+			return dst, nil
+		}
+		log.Printf("[model] cached %s doesn't match its SHA256, downloading it again", dst)
+	}
+	if err := downloadFile(p, dst, sum); err != nil {
+		return "", err
+	}
+	log.Printf("[model] downloaded %s to %s", u.Redacted(), dst)
+	return dst, nil
+}
+
+// downloadFile fetches rawURL into dst through a temporary file, so an
+// interrupted or corrupt download never replaces a good copy.
+func downloadFile(rawURL, dst, sum string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: modelDownloadTimeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s: %s", redactURL(rawURL), resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("download %s: %w", redactURL(rawURL), err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); sum != "" && got != sum {
+		return fmt.Errorf("%w: %s has SHA256 %s, want %s", errChecksum, redactURL(rawURL), got, sum)
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// errChecksum is a model file whose SHA256 isn't the configured one.
+var errChecksum = errors.New("checksum mismatch")
+
+func verifySHA256(p, sum string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return fmt.Errorf("%w: %s has SHA256 %s, want %s", errChecksum, p, got, sum)
+	}
+	return nil
+}
+
+// redactURL hides the password of a URL for logs and errors.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
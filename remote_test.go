@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestResolveModelPath(t *testing.T) {
+	model := []byte("fixture caffemodel weights")
+	sum := sha256.Sum256(model)
+	good := hex.EncodeToString(sum[:])
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/good.caffemodel":
+			_, _ = w.Write(model)
+		case "/corrupt.caffemodel":
+			_, _ = w.Write(append([]byte("truncated "), model[:8]...))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	cache := t.TempDir()
+
+	// Downloaded and verified, then served from the cache.
+	p, err := resolveModelPath(srv.URL+"/good.caffemodel", good, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(p); err != nil || string(data) != string(model) || filepath.Base(p) != "good.caffemodel" {
+		t.Fatalf("downloaded %s: %q, %v", p, data, err)
+	}
+	if again, err := resolveModelPath(srv.URL+"/good.caffemodel", good, cache); err != nil || again != p || hits.Load() != 1 {
+		t.Errorf("second resolve: %s %v after %d downloads, want the cached %s", again, err, hits.Load(), p)
+	}
+
+	// A corrupted cached copy is downloaded again.
+	if err := os.WriteFile(p, []byte("bit rot"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolveModelPath(srv.URL+"/good.caffemodel", good, cache); err != nil || hits.Load() != 2 {
+		t.Errorf("resolve over a corrupt cache: %v after %d downloads", err, hits.Load())
+	}
+
+	// A corrupt download fails and leaves nothing behind.
+	_, err = resolveModelPath(srv.URL+"/corrupt.caffemodel", good, cache)
+	if !errors.Is(err, errChecksum) {
+		t.Errorf("corrupt download: %v, want errChecksum", err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(cache, "*", "*corrupt*"))
+	temps, _ := filepath.Glob(filepath.Join(cache, "*", ".download-*"))
+	if len(matches)+len(temps) != 0 {
+		t.Errorf("corrupt download left %v %v", matches, temps)
+	}
+
+	if _, err := resolveModelPath(srv.URL+"/missing.caffemodel", "", cache); err == nil {
+		t.Errorf("404 download succeeded")
+	}
+	if _, err := resolveModelPath(srv.URL+"/good.caffemodel", "abc", cache); err == nil {
+		t.Errorf("short SHA256 accepted")
+	}
+
+	// Local paths are returned as is, verified when a sum is set.
+	local := filepath.Join(t.TempDir(), "local.caffemodel")
+	if err := os.WriteFile(local, model, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := resolveModelPath(local, good, cache); err != nil || got != local {
+		t.Errorf("local path: %s %v", got, err)
+	}
+	bad := hex.EncodeToString(make([]byte, sha256.Size))
+	if _, err := resolveModelPath(local, bad, cache); !errors.Is(err, errChecksum) {
+		t.Errorf("local path with a wrong sum: %v, want errChecksum", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+/* ------------------------------ JSON responses ----------------------------- */
+
+// maxPooledJSONBuffer keeps an occasional huge snapshot from pinning its
+// buffer in the pool forever.
+const maxPooledJSONBuffer = 1 << 20
+
+var jsonBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// writeJSON encodes v (indented) as the response body with status 200,
+// as application/json unless a Content-Type is already set.
+// When buffered, the whole body is encoded first, so an encoding error still
+// turns into a clean 500 and the response carries a Content-Length; otherwise
+// it is streamed, which saves the copy but may leave a truncated 200 behind.
+func writeJSON(w http.ResponseWriter, v any, buffered bool) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	if !buffered {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			log.Printf("[http] encode: %v", err)
+		}
+		return
+	}
+
+	buf := jsonBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledJSONBuffer {
+			jsonBuffers.Put(buf)
+		}
+	}()
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("[http] encode: %v", err)
+		w.Header().Del("ETag")
+		http.Error(w, "cannot encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
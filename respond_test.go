@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// failingJSON fails to marshal, after the fields before it were encoded.
+type failingJSON struct{}
+
+func (failingJSON) MarshalJSON() ([]byte, error) { return nil, errors.New("client went away") }
+
+func TestWriteJSON(t *testing.T) {
+	partial := struct {
+		Source string      `json:"source"`
+		Broken failingJSON `json:"broken"`
+	}{Source: "cam"}
+
+	// Buffered: nothing was sent yet, so the failure is a clean 500.
+	w := httptest.NewRecorder()
+	w.Header().Set("ETag", `"v1"`)
+	writeJSON(w, partial, true)
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "cam") || w.Header().Get("ETag") != "" {
+		t.Errorf("buffered failure: %d %q, ETag %q; want a clean 500", w.Code, w.Body, w.Header().Get("ETag"))
+	}
+
+	// Streamed: the client gets the 200 with a useless body that buffering
+	// avoids.
+	w = httptest.NewRecorder()
+	writeJSON(w, partial, false)
+	if w.Code != http.StatusOK || json.Valid(w.Body.Bytes()) {
+		t.Errorf("streamed failure: %d %q, want a 200 without a valid body", w.Code, w.Body)
+	}
+
+	for _, buffered := range []bool{true, false} {
+		w = httptest.NewRecorder()
+		writeJSON(w, map[string]int{"count": 2}, buffered)
+		body := w.Body.String()
+		if w.Code != http.StatusOK || body != "{\n  \"count\": 2\n}\n" || w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("buffered %v: %d %q %q", buffered, w.Code, body, w.Header().Get("Content-Type"))
+		}
+		if cl := w.Header().Get("Content-Length"); buffered != (cl == strconv.Itoa(len(body))) {
+			t.Errorf("buffered %v: Content-Length %q for %d bytes", buffered, cl, len(body))
+		}
+	}
+
+	w = httptest.NewRecorder()
+	w.Header().Set("Content-Type", "application/geo+json")
+	writeJSON(w, struct{}{}, true)
+	if ct := w.Header().Get("Content-Type"); ct != "application/geo+json" {
+		t.Errorf("preset Content-Type replaced by %q", ct)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+/* ------------------------------ Rotated ROI -------------------------------- */
+
+// RotatedROI is a W x H rectangle of the frame centered on (CX, CY) and
+// turned by Angle degrees, counter-clockwise as in OpenCV, e.g. a doorway
+// seen by an angled camera. The region is warped upright for inference and
+// the boxes are mapped back to the frame.
+type RotatedROI struct {
+	CX, CY int
+	W, H   int
+	Angle  float64
+}
+
+// parseRotatedROI reads "cx,cy,w,h,angle".
+func parseRotatedROI(s string) (*RotatedROI, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("want cx,cy,w,h,angle, got %q", s)
+	}
+	var v [4]int
+	for i, p := range parts[:4] {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("want cx,cy,w,h,angle, got %q", s)
+		}
+		v[i] = n
+	}
+	angle, err := strconv.ParseFloat(strings.TrimSpace(parts[4]), 64)
+	if err != nil || math.IsNaN(angle) || math.IsInf(angle, 0) {
+		return nil, fmt.Errorf("want cx,cy,w,h,angle, got %q", s)
+	}
+	if v[2] <= 0 || v[3] <= 0 {
+		return nil, fmt.Errorf("width and height must be positive, got %q", s)
+	}
+	return &RotatedROI{CX: v[0], CY: v[1], W: v[2], H: v[3], Angle: angle}, nil
+}
+
+// warpMatrix returns the 2x3 matrix taking frame coordinates to the upright
+// region: GetRotationMatrix2D around the center, shifted so the center lands
+// in the middle of the W x H output.
+func (r *RotatedROI) warpMatrix() gocv.Mat {
+	m := gocv.GetRotationMatrix2D(image.Pt(r.CX, r.CY), r.Angle, 1)
+	m.SetDoubleAt(0, 2, m.GetDoubleAt(0, 2)+float64(r.W)/2-float64(r.CX))
+	m.SetDoubleAt(1, 2, m.GetDoubleAt(1, 2)+float64(r.H)/2-float64(r.CY))
+	return m
+}
+
+// warp extracts the upright region of img into dst and returns the affine
+// transform mapping region coordinates back to the frame.
+func (r *RotatedROI) warp(img gocv.Mat, dst *gocv.Mat) (affine, error) {
+	m := r.warpMatrix()
+	defer m.Close()
+	if err := gocv.WarpAffine(img, dst, m, image.Pt(r.W, r.H)); err != nil {
+		return affine{}, err
+	}
+	fwd := affineFromMat(m)
+	inv, ok := fwd.invert()
+	if !ok {
+		return affine{}, fmt.Errorf("rotated ROI: singular transform %v", fwd)
+	}
+	return inv, nil
+}
+
+// affine maps (x, y) to (m[0][0]*x + m[0][1]*y + m[0][2],
+// m[1][0]*x + m[1][1]*y + m[1][2]).
+type affine [2][3]float64
+
+func affineFromMat(m gocv.Mat) affine {
+	var a affine
+	for i := range 2 {
+		for j := range 3 {
+			a[i][j] = m.GetDoubleAt(i, j)
+		}
+	}
+	return a
+}
+
+func (m affine) apply(x, y float64) (float64, float64) {
+	return m[0][0]*x + m[0][1]*y + m[0][2], m[1][0]*x + m[1][1]*y + m[1][2]
+}
+
+// invert returns the inverse transform; false if m is singular.
+func (m affine) invert() (affine, bool) {
+	a, b, c := m[0][0], m[0][1], m[0][2]
+	d, e, f := m[1][0], m[1][1], m[1][2]
+	det := a*e - b*d
+	if math.Abs(det) < 1e-12 {
+		return affine{}, false
+	}
+	ia, ib := e/det, -b/det
+	id, ie := -d/det, a/det
+	return affine{
+		{ia, ib, -(ia*c + ib*f)},
+		{id, ie, -(id*c + ie*f)},
+	}, true
+}
+
+// mapBox transforms the corners of r and returns their bounding box,
+// clamped to bounds. A rotated box isn't axis-aligned in the frame, so the
+// result is somewhat larger than the face.
+func (m affine) mapBox(r Rect, bounds image.Rectangle) Rect {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range [4][2]int{{r.X, r.Y}, {r.X + r.Width, r.Y}, {r.X, r.Y + r.Height}, {r.X + r.Width, r.Y + r.Height}} {
+		x, y := m.apply(float64(p[0]), float64(p[1]))
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	box := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(bounds)
+	return Rect{X: box.Min.X, Y: box.Min.Y, Width: box.Dx(), Height: box.Dy()}
+}
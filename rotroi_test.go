@@ -0,0 +1,87 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestRotatedROITransform(t *testing.T) {
+	near := func(x, y, wx, wy float64) bool { return math.Abs(x-wx) < 1e-9 && math.Abs(y-wy) < 1e-9 }
+
+	// At 90°, a point right of the center ends up above the middle of the
+	// upright region.
+	r := &RotatedROI{CX: 100, CY: 80, W: 60, H: 40, Angle: 90}
+	m := r.warpMatrix()
+	fwd := affineFromMat(m)
+	m.Close()
+	if x, y := fwd.apply(100, 80); !near(x, y, 30, 20) {
+		t.Errorf("center maps to (%g, %g), want the middle (30, 20)", x, y)
+	}
+	if x, y := fwd.apply(110, 80); !near(x, y, 30, 10) {
+		t.Errorf("(110, 80) maps to (%g, %g), want (30, 10)", x, y)
+	}
+
+	for _, angle := range []float64{0, 30, -45, 90, 180, 271.5} {
+		r := &RotatedROI{CX: 100, CY: 80, W: 60, H: 40, Angle: angle}
+		m := r.warpMatrix()
+		fwd := affineFromMat(m)
+		m.Close()
+		inv, ok := fwd.invert()
+		if !ok {
+			t.Fatalf("%g°: singular", angle)
+		}
+		for _, p := range [][2]float64{{100, 80}, {0, 0}, {123.5, 67.25}, {-40, 300}} {
+			x, y := inv.apply(fwd.apply(p[0], p[1]))
+			if !near(x, y, p[0], p[1]) {
+				t.Errorf("%g°: (%g, %g) round-trips to (%g, %g)", angle, p[0], p[1], x, y)
+			}
+		}
+	}
+}
+
+func TestRotatedROIWarp(t *testing.T) {
+	// A bright dot in the frame is found in the upright region where the
+	// forward transform puts it, and mapped back next to where it was.
+	dot := image.Pt(112, 95)
+	img := gocv.NewMatWithSize(200, 200, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	gocv.Rectangle(&img, image.Rect(dot.X, dot.Y, dot.X+1, dot.Y+1), color.RGBA{R: 255, G: 255, B: 255}, -1)
+
+	r := &RotatedROI{CX: 100, CY: 80, W: 60, H: 50, Angle: 30}
+	region := gocv.NewMat()
+	defer region.Close()
+	inv, err := r.warp(img, &region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region.Cols() != 60 || region.Rows() != 50 {
+		t.Fatalf("region is %dx%d, want 60x50", region.Cols(), region.Rows())
+	}
+	var found []image.Point
+	for y := range region.Rows() {
+		for x := range region.Cols() {
+			if region.GetVecbAt(y, x)[0] > 128 {
+				found = append(found, image.Pt(x, y))
+			}
+		}
+	}
+	if len(found) == 0 {
+		t.Fatal("the dot is not in the region")
+	}
+	for _, p := range found {
+		if x, y := inv.apply(float64(p.X), float64(p.Y)); math.Hypot(x-float64(dot.X), y-float64(dot.Y)) > 1.5 {
+			t.Errorf("region pixel %v maps back to (%.1f, %.1f), want next to %v", p, x, y, dot)
+		}
+	}
+
+	// A box around the dot maps to a frame box containing it.
+	p := found[0]
+	box := inv.mapBox(Rect{X: p.X - 5, Y: p.Y - 5, Width: 10, Height: 10}, image.Rect(0, 0, 200, 200))
+	if !dot.In(image.Rect(box.X, box.Y, box.X+box.Width, box.Y+box.Height)) {
+		t.Errorf("box %+v doesn't contain %v", box, dot)
+	}
+}
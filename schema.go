@@ -0,0 +1,71 @@
+package main
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ------------------------------ Schema versions ---------------------------- */
+
+// snapshotSchemaVersion is the version of the Snapshot JSON, reported in
+// its schema_version field. Bump it when fields are added or changed.
+const snapshotSchemaVersion = 2
+
+// mediaTypeV1 selects the v1 projection of snapshots, for clients written
+// against the original schema.
+const mediaTypeV1 = "application/vnd.facetrack.v1+json"
+
+// snapshotV1 and detectionV1 are the original schema. They are frozen: new
+// fields go to Snapshot/Detection only, never here.
+type snapshotV1 struct {
+	Source      string        `json:"source"`
+	Frame       int64         `json:"frame"`
+	FrameWidth  int           `json:"frame_width"`
+	FrameHeight int           `json:"frame_height"`
+	Detections  []detectionV1 `json:"detections"`
+	GeneratedAt time.Time     `json:"generated_at"`
+}
+
+type detectionV1 struct {
+	ID        int       `json:"id"`
+	BBox      Rect      `json:"bbox"`
+	Landmarks []Point   `json:"landmarks,omitempty"`
+	Score     float64   `json:"score"`
+	Timestamp time.Time `json:"ts"`
+}
+
+// projectV1 returns s in the v1 schema. Count-only snapshots have no
+// detections in v1.
+func projectV1(s Snapshot) snapshotV1 {
+	v := snapshotV1{
+		Source:      s.Source,
+		Frame:       s.Frame,
+		FrameWidth:  s.FrameWidth,
+		FrameHeight: s.FrameHeight,
+		Detections:  make([]detectionV1, len(s.Detections)),
+		GeneratedAt: s.GeneratedAt,
+	}
+	for i, d := range s.Detections {
+		v.Detections[i] = detectionV1{ID: d.ID, BBox: d.BBox, Landmarks: d.Landmarks, Score: d.Score, Timestamp: d.Timestamp}
+	}
+	return v
+}
+
+// wantsV1 reports whether an Accept header asks for the v1 projection.
+func wantsV1(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mt != mediaTypeV1 {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if w, err := strconv.ParseFloat(q, 64); err == nil && w == 0 {
+				continue // explicitly refused
+			}
+		}
+		return true
+	}
+	return false
+}
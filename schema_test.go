@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fullSnapshot has every field set, so a field leaking into v1 shows up.
+func fullSnapshot() Snapshot {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := Detection{
+		ID: 1, UUID: "u", BBox: Rect{X: 1, Y: 2, Width: 3, Height: 4}, Landmarks: []Point{{X: 1, Y: 1}},
+		Pose: &Pose{Yaw: 1}, Count: 2, Color: "#ffffff", Zone: "door", Attributes: map[string]float64{"mask": 1},
+		Score: 0.9, RawScore: 0.8, Timestamp: now, ApproxRangeM: 1, Quality: 1, DwellS: 1, Edge: true,
+		TrackConfidence: 1, StableMs: 1, Predicted: true, Velocity: &Velocity{VX: 1},
+	}
+	return Snapshot{
+		SchemaVersion: snapshotSchemaVersion, Source: "0", Frame: 7, FrameWidth: 640, FrameHeight: 480,
+		Detections: []Detection{d}, GeneratedAt: now, FrameIntervalMs: 100, Frozen: true,
+		Zones:  []ZoneCount{{Name: "door", Count: 1}},
+		Events: []TrackEvent{{Type: "stable", ID: 1}},
+		Meta:   &SnapshotMeta{Model: "m"},
+	}
+}
+
+func jsonKeys(t *testing.T, raw json.RawMessage) string {
+	t.Helper()
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(slices.Sorted(maps.Keys(m)), " ")
+}
+
+func TestProjectV1Fields(t *testing.T) {
+	const (
+		wantSnap = "detections frame frame_height frame_width generated_at source"
+		wantDet  = "bbox id landmarks score ts"
+	)
+	check := func(name string, body []byte) {
+		t.Helper()
+		var v struct {
+			Detections []json.RawMessage `json:"detections"`
+		}
+		if err := json.Unmarshal(body, &v); err != nil || len(v.Detections) != 1 {
+			t.Fatalf("%s: body %s: %v", name, body, err)
+		}
+		if got := jsonKeys(t, body); got != wantSnap {
+			t.Errorf("%s: snapshot keys %q, want %q", name, got, wantSnap)
+		}
+		if got := jsonKeys(t, v.Detections[0]); got != wantDet {
+			t.Errorf("%s: detection keys %q, want %q", name, got, wantDet)
+		}
+	}
+
+	body, err := json.Marshal(projectV1(fullSnapshot()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("projectV1", body)
+
+	store := NewFaceStore()
+	store.Set(fullSnapshot())
+	scores, _ := parseScoreFormat("percent", 0)
+	r := httptest.NewRequest(http.MethodGet, "/faces", nil)
+	r.Header.Set("Accept", mediaTypeV1)
+	w := httptest.NewRecorder()
+	facesHandler(ServerConfig{Scores: scores}, store)(w, r)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, mediaTypeV1) {
+		t.Fatalf("Content-Type %q, want %s", ct, mediaTypeV1)
+	}
+	check("/faces", w.Body.Bytes())
+}
+
+func TestWantsV1(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"*/*", false},
+		{mediaTypeV1, true},
+		{"application/json, " + mediaTypeV1, true},
+		{mediaTypeV1 + "; q=0.5, application/json", true},
+		{"APPLICATION/VND.FACETRACK.V1+JSON", true},
+		{mediaTypeV1 + ";q=0", false},
+		{mediaTypeV1 + "; q=0.000", false},
+		{mediaTypeV1 + "; q=0.001", true},
+		{mediaTypeV1 + "; q=0, application/json", false},
+		{"application/vnd.facetrack.v2+json", false},
+		{"not a media type;;", false},
+	}
+	for _, tt := range tests {
+		if got := wantsV1(tt.accept); got != tt.want {
+			t.Errorf("wantsV1(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+/* ------------------------------- Score format ------------------------------ */
+
+// scoreFormat rounds the scores of the JSON served by /faces and
+// /faces/stream (FACE_SCORE_UNITS, FACE_SCORE_DECIMALS). It is applied to a
+// copy when encoding: the store, filters, tracking and sinks keep the full
+// precision, fractions from 0 to 1. The v1 projection is never rounded, and
+// the ETag follows the snapshot version, so rounding doesn't make it any
+// more stable.
+type scoreFormat struct {
+	Percent  bool // 0-100 instead of 0-1
+	Decimals int  // kept after the decimal point
+}
+
+// parseScoreFormat reads the units ("fraction" or "percent") and the number
+// of decimals, < 0 for full precision. Percentages default to integers. A
+// nil format leaves scores untouched.
+func parseScoreFormat(units string, decimals int) (*scoreFormat, error) {
+	switch units {
+	case "fraction":
+		if decimals < 0 {
+			return nil, nil
+		}
+		return &scoreFormat{Decimals: decimals}, nil
+	case "percent":
+		return &scoreFormat{Percent: true, Decimals: max(decimals, 0)}, nil
+	}
+	return nil, fmt.Errorf("want fraction or percent, got %q", units)
+}
+
+func (f scoreFormat) format(v float64) float64 {
+	if f.Percent {
+		v *= 100
+	}
+	p := math.Pow10(f.Decimals)
+	return math.Round(v*p) / p
+}
+
+// apply returns snap with its scores formatted. The shared snapshot (and its
+// Detections backing array) is never modified; a nil format returns snap.
+func (f *scoreFormat) apply(snap Snapshot) Snapshot {
+	if f == nil || len(snap.Detections) == 0 {
+		return snap
+	}
+	dets := make([]Detection, len(snap.Detections))
+	for i, d := range snap.Detections {
+		d.Score = f.format(d.Score)
+		if d.RawScore != 0 {
+			d.RawScore = f.format(d.RawScore)
+		}
+		dets[i] = d
+	}
+	snap.Detections = dets
+	return snap
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScoreFormatAtTheEdge(t *testing.T) {
+	const exact = 0.873241234
+	store := NewFaceStore()
+	store.Set(Snapshot{Frame: 1, Detections: []Detection{{ID: 1, Score: exact, RawScore: 0.5}}})
+
+	served := func(scores *scoreFormat, accept string) float64 {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/faces", nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		facesHandler(ServerConfig{Scores: scores}, store)(w, r)
+		var body struct {
+			Detections []struct {
+				Score float64 `json:"score"`
+			} `json:"detections"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Detections) != 1 {
+			t.Fatalf("body %s: %v", w.Body, err)
+		}
+		return body.Detections[0].Score
+	}
+
+	tests := []struct {
+		name   string
+		units  string
+		dec    int
+		accept string
+		want   float64
+	}{
+		{"full precision", "fraction", -1, "", exact},
+		{"two decimals", "fraction", 2, "", 0.87},
+		{"percent", "percent", -1, "", 87},
+		{"percent, one decimal", "percent", 1, "", 87.3},
+		{"v1 is never rounded", "percent", 0, mediaTypeV1, exact},
+	}
+	for _, tt := range tests {
+		f, err := parseScoreFormat(tt.units, tt.dec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := served(f, tt.accept); got != tt.want {
+			t.Errorf("%s: served %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// The store keeps the exact score for the filters, tracking and sinks.
+	if snap, _ := store.Get(); snap.Detections[0].Score != exact || snap.Detections[0].RawScore != 0.5 {
+		t.Fatalf("stored detection %+v, want the exact scores", snap.Detections[0])
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+/* ---------------------------- Frame sequence ------------------------------- */
+
+// FrameCounter numbers the frames of the whole process. It outlives detector
+// restarts, reloads and source reconnects, so Snapshot.Frame never goes back
+// within a process; seeded from the state file it keeps increasing across
+// process restarts too. Numbers may skip (failed cycles, restarts) but are
+// never reused.
+type FrameCounter struct {
+	n atomic.Int64
+}
+
+// Next returns the number of a new frame.
+func (c *FrameCounter) Next() int64 {
+	return c.n.Add(1)
+}
+
+// Last returns the last number handed out, 0 if none.
+func (c *FrameCounter) Last() int64 {
+	return c.n.Load()
+}
+
+// Seed makes the next frame number follow n; it never moves the counter
+// back.
+func (c *FrameCounter) Seed(n int64) {
+	for {
+		cur := c.n.Load()
+		if n <= cur || c.n.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
+
+// frameRestartGap is how far a restart skips past the saved frame number:
+// the frames a crash may have numbered after the last periodic save, at the
+// given detection interval, with room to spare.
+func frameRestartGap(interval time.Duration) int64 {
+	return 2 * int64(stateSaveInterval/max(interval, time.Millisecond))
+}
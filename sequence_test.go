@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFrameCounterSeed(t *testing.T) {
+	var c FrameCounter
+	c.Next()
+	c.Seed(10)
+	c.Seed(5) // never back
+	if n := c.Next(); n != 11 {
+		t.Errorf("next frame %d, want 11", n)
+	}
+}
+
+func TestFrameCounterReconnect(t *testing.T) {
+	cfg := DetectorConfig{Interval: 5 * time.Millisecond}
+	p := &Pipeline{Store: NewFaceStore(), Stats: NewStats(), Seq: &FrameCounter{}}
+	face := [][4]float32{{0.1, 0.1, 0.5, 0.5}}
+
+	// The supervisor restarts the loop on a fresh detector after the source
+	// dropped, with the same pipeline.
+	var last int64
+	for i := range 3 {
+		d := newFakeDetector(t, cfg, &fakeLoader{next: []*fakeNet{{faces: face}}})
+		changed := p.Store.Changed()
+		stop := startLoop(d, cfg, p)
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("loop %d published nothing", i)
+		}
+		stop()
+		snap, _ := p.Store.Get()
+		if snap.Frame <= last {
+			t.Fatalf("loop %d: frame %d after %d", i, snap.Frame, last)
+		}
+		last = p.Seq.Last()
+	}
+}
+
+func TestFrameCounterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	interval := 100 * time.Millisecond
+
+	// A fresh install has no state file: numbering starts at 1.
+	seq := &FrameCounter{}
+	if err := restoreState(path, NewFaceStore(), seq, interval); err != nil || seq.Next() != 1 {
+		t.Fatalf("no state file: %v, frame %d", err, seq.Last())
+	}
+	for range 41 {
+		seq.Next()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := StartStateSaver(ctx, path, NewFaceStore(), seq)
+	cancel()
+	<-done
+
+	restarted := &FrameCounter{}
+	if err := restoreState(path, NewFaceStore(), restarted, interval); err != nil {
+		t.Fatal(err)
+	}
+	if want := 42 + frameRestartGap(interval) + 1; restarted.Next() != want {
+		t.Errorf("first frame after restart %d, want %d", restarted.Last(), want)
+	}
+}
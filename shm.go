@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"gocv.io/x/gocv"
+)
+
+/* --------------------------- Shared-memory source -------------------------- */
+
+// shmScheme selects a frame buffer written by a co-located process:
+// "shm://name" maps /dev/shm/name, "shm:///path/file" any file (e.g. on a
+// tmpfs, or on macOS where POSIX shared memory has no path).
+const shmScheme = "shm://"
+
+// The buffer is a 32-byte little-endian header followed by the pixels of
+// the latest frame:
+//
+//	offset  size  field
+//	0       4     magic "FSHM"
+//	4       4     version, 1
+//	8       8     sequence: odd while the writer updates the frame, even
+//	              when it is complete; bumped twice per frame
+//	16      4     width (px)
+//	20      4     height (px)
+//	24      4     format: 0 = BGR (3 bytes/px), 1 = gray (1), 2 = BGRA (4)
+//	28      4     stride (bytes per row); 0 = width * bytes per pixel
+//	32            rows of pixels, top first
+//
+// The writer sets the sequence to odd, writes the header fields and the
+// pixels, then sets it to the next even value (atomic 64-bit stores). The
+// reader copies the frame out and keeps it only if the sequence was the
+// same even value before and after, so a frame is never torn.
+const (
+	shmMagic      = "FSHM"
+	shmVersion    = 1
+	shmHeaderSize = 32
+
+	// shmMaxSide bounds the width and height of the header, and the stride
+	// to a row of as many BGRA pixels, so a malformed header can't make the
+	// size arithmetic overflow.
+	shmMaxSide = 1 << 16
+
+	shmFormatBGR  = 0
+	shmFormatGray = 1
+	shmFormatBGRA = 2
+)
+
+// shmBytesPerPixel is indexed by format.
+var shmBytesPerPixel = [...]int{shmFormatBGR: 3, shmFormatGray: 1, shmFormatBGRA: 4}
+
+// ShmSource reads the latest frame of a shared-memory buffer. Read waits
+// (up to timeout) for a frame newer than the previous one, so a stalled
+// writer surfaces as empty frames rather than a repeated image.
+type ShmSource struct {
+	path    string
+	data    []byte // the mapping, read-only
+	seq     *uint64
+	last    uint64 // sequence of the frame last returned
+	timeout time.Duration
+	poll    time.Duration
+	buf     []byte // frame copied out of the mapping
+}
+
+// NewShmSource maps the buffer of a shm:// source URL. The writer must have
+// created it, at its final size. Supported query params: timeout (wait for
+// a new frame, default 1s).
+func NewShmSource(source string) (*ShmSource, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("parse shm source: %w", err)
+	}
+	path := u.Path
+	if u.Host != "" {
+		path = filepath.Join("/dev/shm", u.Host+u.Path)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("shm source: missing buffer name in %q", source)
+	}
+	s := &ShmSource{path: path, timeout: time.Second, poll: time.Millisecond}
+	if v := u.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("shm source: invalid timeout=%q", v)
+		}
+		s.timeout = d
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSourceUnavailable, err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSourceUnavailable, err)
+	}
+	if fi.Size() < shmHeaderSize {
+		return nil, fmt.Errorf("%w: %s is %d bytes, smaller than the header", ErrSourceUnavailable, path, fi.Size())
+	}
+	s.data, err = syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("%w: mmap %s: %w", ErrSourceUnavailable, path, err)
+	}
+	// The mapping is page aligned, so the sequence is 8-byte aligned.
+	s.seq = (*uint64)(unsafe.Pointer(&s.data[8]))
+	return s, nil
+}
+
+// Read copies the next complete frame into m, converted to BGR. It returns
+// false when no new frame arrived within the timeout, or when the header
+// describes a frame that doesn't fit the buffer.
+func (s *ShmSource) Read(m *gocv.Mat) bool {
+	deadline := time.Now().Add(s.timeout)
+	for {
+		w, h, format, seq, ok := s.copyFrame()
+		if ok {
+			s.last = seq
+			return s.toMat(m, w, h, format)
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(s.poll)
+	}
+}
+
+// copyFrame copies the frame into s.buf (tightly packed rows) if one newer
+// than s.last is complete and unchanged while copying.
+func (s *ShmSource) copyFrame() (w, h, format int, seq uint64, ok bool) {
+	seq = atomic.LoadUint64(s.seq)
+	if seq == s.last || seq%2 == 1 || string(s.data[0:4]) != shmMagic {
+		return 0, 0, 0, 0, false
+	}
+	le := binary.LittleEndian
+	if le.Uint32(s.data[4:]) != shmVersion {
+		return 0, 0, 0, 0, false
+	}
+	uw, uh := le.Uint32(s.data[16:]), le.Uint32(s.data[20:])
+	uformat, ustride := le.Uint32(s.data[24:]), le.Uint32(s.data[28:])
+	if uformat >= uint32(len(shmBytesPerPixel)) || uw == 0 || uh == 0 || uw > shmMaxSide || uh > shmMaxSide || ustride > shmMaxSide*4 {
+		return 0, 0, 0, 0, false
+	}
+	w, h, format = int(uw), int(uh), int(uformat)
+	row := w * shmBytesPerPixel[format]
+	stride := int(ustride)
+	if stride == 0 {
+		stride = row
+	}
+	if stride < row || uint64(shmHeaderSize)+uint64(h-1)*uint64(stride)+uint64(row) > uint64(len(s.data)) {
+		return 0, 0, 0, 0, false
+	}
+	if cap(s.buf) < row*h {
+		s.buf = make([]byte, row*h)
+	}
+	s.buf = s.buf[:row*h]
+	for y := 0; y < h; y++ {
+		off := shmHeaderSize + y*stride
+		copy(s.buf[y*row:(y+1)*row], s.data[off:off+row])
+	}
+	if atomic.LoadUint64(s.seq) != seq {
+		return 0, 0, 0, 0, false // overwritten while copying
+	}
+	return w, h, format, seq, true
+}
+
+// toMat converts the copied frame into m as BGR.
+func (s *ShmSource) toMat(m *gocv.Mat, w, h, format int) bool {
+	typ := [...]gocv.MatType{shmFormatBGR: gocv.MatTypeCV8UC3, shmFormatGray: gocv.MatTypeCV8UC1, shmFormatBGRA: gocv.MatTypeCV8UC4}[format]
+	src, err := gocv.NewMatFromBytes(h, w, typ, s.buf)
+	if err != nil {
+		return false
+	}
+	defer src.Close()
+	switch format {
+	case shmFormatGray:
+		return gocv.CvtColor(src, m, gocv.ColorGrayToBGR) == nil
+	case shmFormatBGRA:
+		return gocv.CvtColor(src, m, gocv.ColorBGRAToBGR) == nil
+	}
+	return src.CopyTo(m) == nil
+}
+
+func (s *ShmSource) Close() error {
+	if s.data == nil {
+		return nil
+	}
+	err := syscall.Munmap(s.data)
+	s.data, s.seq = nil, nil
+	return err
+}
+
+func isShmSource(source string) bool {
+	return strings.HasPrefix(source, shmScheme)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"gocv.io/x/gocv"
+)
+
+// writeShmBuffer creates a buffer file holding one complete BGR frame.
+func writeShmBuffer(t *testing.T, w, h int) string {
+	t.Helper()
+	buf := make([]byte, shmHeaderSize+w*h*3)
+	copy(buf, shmMagic)
+	buf[4] = shmVersion
+	buf[8] = 2 // sequence: complete
+	buf[16], buf[20] = byte(w), byte(h)
+	path := filepath.Join(t.TempDir(), "frames")
+	if err := os.WriteFile(path, buf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProbeSourceShm(t *testing.T) {
+	path := writeShmBuffer(t, 4, 2)
+
+	// The startup probe must not hand shm:// to OpenCV.
+	if _, err := ProbeSource("shm://"+path, gocv.VideoCaptureAny); err != nil {
+		t.Fatalf("ProbeSource(existing buffer) = %v, want nil", err)
+	}
+	_, err := ProbeSource("shm://"+filepath.Join(t.TempDir(), "missing"), gocv.VideoCaptureAny)
+	if !errors.Is(err, ErrSourceUnavailable) {
+		t.Fatalf("ProbeSource(missing buffer) = %v, want ErrSourceUnavailable", err)
+	}
+}
+
+func TestCheckConfigShmSource(t *testing.T) {
+	path := writeShmBuffer(t, 4, 2)
+	t.Setenv("FACE_SOURCE", "shm://"+path)
+	rep := checkConfig(openNet, "127.0.0.1:0")
+	for _, c := range rep.Checks {
+		if c.Name == "source" && !c.OK {
+			t.Fatalf("source check failed: %s", c.Error)
+		}
+	}
+}
+
+func TestShmMalformedHeader(t *testing.T) {
+	tests := []struct {
+		name                 string
+		w, h, format, stride uint32
+	}{
+		{"overflowing stride", 1, 0xFFFFFFFF, shmFormatGray, 0xFFFFFFFF},
+		{"huge width", 0xFFFFFFFF, 1, shmFormatBGR, 0},
+		{"huge height", 4, 0xFFFFFFFF, shmFormatBGR, 0},
+		{"larger than the buffer", 4, 3, shmFormatBGR, 0},
+		{"stride shorter than a row", 4, 2, shmFormatBGR, 4},
+		{"unknown format", 4, 2, 3, 0},
+		{"empty", 0, 2, shmFormatBGR, 0},
+	}
+	for _, tt := range tests {
+		path := writeShmBuffer(t, 4, 2)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		le := binary.LittleEndian
+		le.PutUint32(data[16:], tt.w)
+		le.PutUint32(data[20:], tt.h)
+		le.PutUint32(data[24:], tt.format)
+		le.PutUint32(data[28:], tt.stride)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		src, err := NewShmSource("shm://" + path + "?timeout=10ms")
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := gocv.NewMat()
+		if src.Read(&m) {
+			t.Errorf("%s: read a %dx%d frame", tt.name, m.Cols(), m.Rows())
+		}
+		m.Close()
+		src.Close()
+	}
+}
+
+// shmWriter publishes frames into a mapped buffer the way a producer
+// process does.
+type shmWriter struct {
+	data []byte
+	seq  *uint64
+}
+
+func newShmWriter(t *testing.T, size int) (*shmWriter, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "frames")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Truncate(int64(shmHeaderSize + size)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, shmHeaderSize+size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Munmap(data) })
+	copy(data, shmMagic)
+	binary.LittleEndian.PutUint32(data[4:], shmVersion)
+	return &shmWriter{data: data, seq: (*uint64)(unsafe.Pointer(&data[8]))}, path
+}
+
+// write publishes a w x h frame whose rows are stride bytes apart, each
+// pixel set to px.
+func (sw *shmWriter) write(w, h, format, stride int, px []byte) {
+	atomic.AddUint64(sw.seq, 1) // odd: updating
+	le := binary.LittleEndian
+	le.PutUint32(sw.data[16:], uint32(w))
+	le.PutUint32(sw.data[20:], uint32(h))
+	le.PutUint32(sw.data[24:], uint32(format))
+	le.PutUint32(sw.data[28:], uint32(stride))
+	if stride == 0 {
+		stride = w * len(px)
+	}
+	for y := range h {
+		row := sw.data[shmHeaderSize+y*stride:]
+		for x := range w {
+			copy(row[x*len(px):], px)
+		}
+		for i := w * len(px); i < stride; i++ {
+			row[i] = 0xEE // padding, never read
+		}
+	}
+	atomic.AddUint64(sw.seq, 1) // even: complete
+}
+
+func TestShmConcurrentWriter(t *testing.T) {
+	const w, h, stride, frames = 320, 240, 320*3 + 8, 100
+	sw, path := newShmWriter(t, h*stride)
+	src, err := NewShmSource("shm://" + path + "?timeout=100ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	// Frame n is filled with n: a torn frame would mix two values.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := 1; n <= frames; n++ {
+			start := time.Now()
+			sw.write(w, h, shmFormatBGR, stride, []byte{byte(n), byte(n), byte(n)})
+			for busy := time.Since(start); time.Since(start) < 4*busy; {
+				// spin: a sleep would wake in step with the reader's polls
+			}
+		}
+	}()
+	defer func() { <-done }() // before the buffer is unmapped
+
+	m := gocv.NewMat()
+	defer m.Close()
+	last, read := 0, 0
+	for src.Read(&m) {
+		if m.Cols() != w || m.Rows() != h || m.Channels() != 3 {
+			t.Fatalf("frame %dx%dx%d, want %dx%dx3", m.Cols(), m.Rows(), m.Channels(), w, h)
+		}
+		px := m.ToBytes()
+		n := int(px[0])
+		if i := slices.IndexFunc(px, func(v byte) bool { return int(v) != n }); i >= 0 {
+			t.Fatalf("torn frame: %d at (%d, %d) in frame %d", px[i], i%(w*3)/3, i/(w*3), n)
+		}
+		if n <= last {
+			t.Fatalf("frame %d after %d", n, last)
+		}
+		last = n
+		read++
+	}
+	if last != frames || read == 0 {
+		t.Errorf("read %d frames, the last %d, want up to %d", read, last, frames)
+	}
+
+	// The writer stalled: no new frame, no repeat of the last one.
+	start := time.Now()
+	if src.Read(&m) {
+		t.Error("read a frame from a stalled writer")
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("gave up after %v, want the 100ms timeout", d)
+	}
+}
+
+func TestShmFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		format int
+		stride int
+		px     []byte
+	}{
+		{"bgr", shmFormatBGR, 0, []byte{10, 20, 30}},
+		{"gray", shmFormatGray, 0, []byte{40}},
+		{"gray with padding", shmFormatGray, 12, []byte{50}},
+		{"bgra", shmFormatBGRA, 0, []byte{60, 70, 80, 255}},
+		{"bgra with padding", shmFormatBGRA, 40, []byte{90, 100, 110, 0}},
+	}
+	for _, tt := range tests {
+		sw, path := newShmWriter(t, 6*40)
+		sw.write(8, 6, tt.format, tt.stride, tt.px)
+		src, err := NewShmSource("shm://" + path + "?timeout=10ms")
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := gocv.NewMat()
+		if !src.Read(&m) {
+			t.Fatalf("%s: no frame", tt.name)
+		}
+		want := gocv.Vecb{tt.px[0], tt.px[0], tt.px[0]}
+		if len(tt.px) >= 3 {
+			want = gocv.Vecb{tt.px[0], tt.px[1], tt.px[2]}
+		}
+		if m.Cols() != 8 || m.Rows() != 6 || m.Channels() != 3 {
+			t.Errorf("%s: frame %dx%dx%d, want 8x6 BGR", tt.name, m.Cols(), m.Rows(), m.Channels())
+		}
+		for _, p := range [][2]int{{0, 0}, {5, 7}} {
+			if got := m.GetVecbAt(p[0], p[1]); !slices.Equal(got, want) {
+				t.Errorf("%s: pixel %v is %v, want %v", tt.name, p, got, want)
+			}
+		}
+		m.Close()
+		src.Close()
+	}
+}
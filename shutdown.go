@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/* ---------------------------- Graceful shutdown ---------------------------- */
+
+// connTracker follows the connections of a server through its ConnState
+// hook, to tell how many requests a shutdown had to wait for. A connection
+// is in flight while it is serving a request (StateActive); idle keep-alive
+// connections are closed right away by Shutdown and don't count.
+type connTracker struct {
+	mu     sync.Mutex
+	active map[net.Conn]struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{active: make(map[net.Conn]struct{})}
+}
+
+// ConnState is the http.Server hook.
+func (t *connTracker) ConnState(c net.Conn, s http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch s {
+	case http.StateActive:
+		t.active[c] = struct{}{}
+	case http.StateIdle, http.StateHijacked, http.StateClosed:
+		delete(t.active, c)
+	}
+}
+
+// Active returns the number of connections serving a request.
+func (t *connTracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.active)
+}
+
+// shutdownResult is the drain accounting of one shutdown.
+type shutdownResult struct {
+	InFlight int // requests in flight when the shutdown started
+	Drained  int // of those, completed within the drain window
+	Forced   int // connections still busy when the window elapsed, closed
+}
+
+// shutdownServer stops srv from accepting connections and waits up to drain
+// for the in-flight requests, then closes whatever is left. Streaming
+// handlers are expected to return on their own when the server context is
+// canceled, which happens before this is called.
+func shutdownServer(srv *http.Server, t *connTracker, drain time.Duration) shutdownResult {
+	res := shutdownResult{InFlight: t.Active()}
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		res.Forced = t.Active()
+		_ = srv.Close()
+	}
+	res.Drained = max(res.InFlight-res.Forced, 0)
+	return res
+}
+
+// logShutdown reports the drain accounting, so the drain window can be tuned
+// and clients holding connections spotted.
+func logShutdown(res shutdownResult, drain time.Duration) {
+	if res.Forced > 0 {
+		log.Printf("[http] drain window (%v) elapsed: %d of %d in-flight requests drained, %d connections closed forcibly",
+			drain, res.Drained, res.InFlight, res.Forced)
+		return
+	}
+	log.Printf("[http] shut down: %d in-flight requests drained", res.Drained)
+}
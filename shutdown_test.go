@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShutdownServerDrain(t *testing.T) {
+	tests := []struct {
+		name       string
+		fast, slow int
+		want       shutdownResult
+	}{
+		{"all drained", 3, 0, shutdownResult{InFlight: 3, Drained: 3}},
+		{"slow ones forced", 2, 1, shutdownResult{InFlight: 3, Drained: 2, Forced: 1}},
+		{"idle", 0, 0, shutdownResult{}},
+	}
+	for _, tt := range tests {
+		release := make(chan struct{})
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				<-release // e.g. a stream that ignores the shutdown
+			} else {
+				time.Sleep(100 * time.Millisecond)
+			}
+			w.Write([]byte("ok"))
+		}))
+		conns := newConnTracker()
+		ts.Config.ConnState = conns.ConnState
+		ts.Start()
+
+		// An idle keep-alive connection doesn't count.
+		if resp, err := ts.Client().Get(ts.URL + "/"); err == nil {
+			resp.Body.Close()
+		}
+		done := make(chan struct{}, tt.fast+tt.slow)
+		get := func(path string) {
+			if resp, err := http.Get(ts.URL + path); err == nil {
+				resp.Body.Close()
+			}
+			done <- struct{}{}
+		}
+		for range tt.fast {
+			go get("/fast")
+		}
+		for range tt.slow {
+			go get("/slow")
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for conns.Active() != tt.fast+tt.slow && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+
+		if got := shutdownServer(ts.Config, conns, 500*time.Millisecond); got != tt.want {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+		close(release)
+		for range tt.fast + tt.slow {
+			<-done
+		}
+		ts.Close()
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ---------------------------------- Sinks ---------------------------------- */
+
+// Sink receives every published snapshot (already redacted in count-only
+// mode). Publish may block; each sink is called from its own goroutine, in
+// order. Close flushes what the sink buffers.
+type Sink interface {
+	Publish(snap Snapshot) error
+	Close() error
+}
+
+// SinkConfig is one entry of FACE_SINKS, a JSON list such as
+//
+//	[{"type": "kafka", "brokers": ["k1:9092"], "topic": "faces"},
+//	 {"type": "sqlite", "path": "faces.db", "sample": 5},
+//	 {"type": "frigate", "url": "http://bridge/events", "camera": "door"}]
+//
+// Settings that don't apply to the type are rejected.
+type SinkConfig struct {
+	Type  string `json:"type"`            // kafka, sqlite or frigate
+	Name  string `json:"name,omitempty"`  // in logs and /stats; default: the type
+	Queue int    `json:"queue,omitempty"` // snapshots kept when the sink is slow, oldest dropped first (default 64)
+
+	// kafka
+	Brokers []string `json:"brokers,omitempty"`
+	Topic   string   `json:"topic,omitempty"` // default "faces"
+
+	// sqlite
+	Path   string `json:"path,omitempty"`
+	Sample int    `json:"sample,omitempty"` // log every Nth frame only (default 1)
+
+	// frigate
+	URL    string `json:"url,omitempty"`    // receives a POST per event
+	Camera string `json:"camera,omitempty"` // default "facetrack"
+	Label  string `json:"label,omitempty"`  // default "face"
+}
+
+// sinkTypes opens a sink of each type from its config.
+var sinkTypes = map[string]func(c SinkConfig) (Sink, error){
+	"kafka": func(c SinkConfig) (Sink, error) {
+		if len(c.Brokers) == 0 || c.Path != "" || c.Sample != 0 || c.URL != "" || c.Camera != "" || c.Label != "" {
+			return nil, errors.New("kafka takes brokers and an optional topic")
+		}
+		topic := c.Topic
+		if topic == "" {
+			topic = "faces"
+		}
+		pub, err := newKafkaPublisher(c.Brokers, topic)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[%s] publishing to %s on %s", c.Name, topic, strings.Join(c.Brokers, ","))
+		return &publisherSink{pub: pub, timeout: publishFlushTimeout}, nil
+	},
+	"sqlite": func(c SinkConfig) (Sink, error) {
+		if c.Path == "" || len(c.Brokers) != 0 || c.Topic != "" || c.URL != "" || c.Camera != "" || c.Label != "" {
+			return nil, errors.New("sqlite takes a path and an optional sample")
+		}
+		db, err := newSQLiteSink(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[%s] logging detections to %s", c.Name, c.Path)
+		return newDetectionLogSink(db, c.Sample), nil
+	},
+	"frigate": func(c SinkConfig) (Sink, error) {
+		if c.URL == "" || len(c.Brokers) != 0 || c.Topic != "" || c.Path != "" || c.Sample != 0 {
+			return nil, errors.New("frigate takes a url and an optional camera and label")
+		}
+		log.Printf("[%s] posting Frigate events to %s", c.Name, c.URL)
+		return newFrigateSink(c.URL, c.Camera, c.Label), nil
+	},
+}
+
+// loadSinkConfigs reads FACE_SINKS, plus the single-sink variables kept for
+// compatibility (FACE_KAFKA_*, FACE_DB*), and checks that names are unique.
+func loadSinkConfigs() ([]SinkConfig, error) {
+	var configs []SinkConfig
+	if v := os.Getenv("FACE_SINKS"); v != "" {
+		dec := json.NewDecoder(strings.NewReader(v))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&configs); err != nil {
+			return nil, fmt.Errorf("FACE_SINKS: %w", err)
+		}
+	}
+	if brokers := os.Getenv("FACE_KAFKA_BROKERS"); brokers != "" {
+		configs = append(configs, SinkConfig{Type: "kafka", Brokers: strings.Split(brokers, ","),
+			Topic: getenvDefault("FACE_KAFKA_TOPIC", "faces"), Queue: getenvIntDefault("FACE_KAFKA_QUEUE", 64)})
+	}
+	if path := os.Getenv("FACE_DB"); path != "" {
+		configs = append(configs, SinkConfig{Type: "sqlite", Path: path, Sample: getenvIntDefault("FACE_DB_SAMPLE", 1)})
+	}
+
+	seen := make(map[string]bool, len(configs))
+	for i := range configs {
+		c := &configs[i]
+		if _, ok := sinkTypes[c.Type]; !ok {
+			return nil, fmt.Errorf("FACE_SINKS: unknown sink type %q", c.Type)
+		}
+		if c.Name == "" {
+			c.Name = c.Type
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("FACE_SINKS: duplicate sink name %q, set distinct names", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return configs, nil
+}
+
+// openSinks opens every configured sink; on error the ones already opened
+// are closed.
+func openSinks(configs []SinkConfig) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(configs))
+	for _, c := range configs {
+		s, err := sinkTypes[c.Type](c)
+		if err != nil {
+			for _, s := range sinks {
+				_ = s.Close()
+			}
+			return nil, fmt.Errorf("[%s] %w", c.Name, err)
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// SinkFanout hands every published snapshot to all the sinks. Each sink has
+// a bounded drop-oldest queue drained by its own goroutine, so the detector
+// loop never waits on a slow sink and sinks don't wait on each other.
+type SinkFanout struct {
+	queues []*dropQueue[Snapshot]
+}
+
+// Publish queues snap for every sink without blocking. snap is shared and
+// must not be modified afterwards.
+func (f *SinkFanout) Publish(snap Snapshot) {
+	for _, q := range f.queues {
+		q.push(snap)
+	}
+}
+
+// StartSinks starts one sender per sink, named as in configs. When ctx is
+// done, each queue is flushed (up to publishFlushTimeout) and its sink
+// closed; the returned channel is closed after all of them.
+func StartSinks(ctx context.Context, configs []SinkConfig, sinks []Sink, stats *Stats) (*SinkFanout, <-chan struct{}) {
+	f := &SinkFanout{}
+	var wg sync.WaitGroup
+	for i, sink := range sinks {
+		c := configs[i]
+		queueSize := c.Queue
+		if queueSize <= 0 {
+			queueSize = 64
+		}
+		counters := stats.Publisher(c.Name)
+		q := newDropQueue[Snapshot](queueSize, &counters.Dropped)
+		f.queues = append(f.queues, q)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runSink(ctx, c.Name, sink, q, counters)
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return f, done
+}
+
+// runSink drains q into sink until ctx is done, then flushes what's left.
+func runSink(ctx context.Context, name string, sink Sink, q *dropQueue[Snapshot], counters *PublisherCounters) {
+	send := func(deadline time.Time) {
+		for deadline.IsZero() || time.Now().Before(deadline) {
+			snap, ok := q.pop()
+			if !ok {
+				return
+			}
+			if err := sink.Publish(snap); err != nil {
+				if n := counters.Errors.Add(1); n == 1 || n%100 == 0 {
+					log.Printf("[%s] publish failed (%d errors so far): %v", name, n, err)
+				}
+				continue
+			}
+			counters.Published.Add(1)
+		}
+	}
+	for {
+		select {
+		case <-q.ready:
+			send(time.Time{})
+		case <-ctx.Done():
+			send(time.Now().Add(publishFlushTimeout))
+			if err := sink.Close(); err != nil {
+				log.Printf("[%s] close: %v", name, err)
+			}
+			log.Printf("[%s] stopped", name)
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordSink records the frames published to it. With a gate, each Publish
+// signals started, then waits for the gate.
+type recordSink struct {
+	mu      sync.Mutex
+	frames  []int64
+	closed  bool
+	fail    int64 // frame whose Publish fails
+	gate    chan struct{}
+	started chan struct{}
+}
+
+func (s *recordSink) Publish(snap Snapshot) error {
+	if s.gate != nil {
+		s.started <- struct{}{}
+		<-s.gate
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if snap.Frame == s.fail {
+		return errors.New("boom")
+	}
+	s.frames = append(s.frames, snap.Frame)
+	return nil
+}
+
+func (s *recordSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestSinkFanout(t *testing.T) {
+	fast := &recordSink{fail: 3}
+	slow := &recordSink{gate: make(chan struct{}), started: make(chan struct{}, 10)}
+	stats := NewStats()
+	ctx, cancel := context.WithCancel(context.Background())
+	fanout, done := StartSinks(ctx, []SinkConfig{{Name: "fast"}, {Name: "slow", Queue: 2}}, []Sink{fast, slow}, stats)
+
+	fanout.Publish(Snapshot{Frame: 1})
+	select {
+	case <-slow.started: // the slow sink holds frame 1
+	case <-time.After(5 * time.Second):
+		t.Fatal("frame 1 never reached the slow sink")
+	}
+	for f := range int64(4) {
+		fanout.Publish(Snapshot{Frame: f + 2}) // never waits on the slow sink
+	}
+	close(slow.gate)
+	cancel() // flushes what's queued
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sinks not stopped")
+	}
+
+	if got := fmt.Sprint(fast.frames); got != "[1 2 4 5]" || !fast.closed {
+		t.Fatalf("fast sink got %s (closed %v), want [1 2 4 5] and closed", got, fast.closed)
+	}
+	// Its queue of 2 kept the newest frames while it was busy with frame 1.
+	if got := fmt.Sprint(slow.frames); got != "[1 4 5]" || !slow.closed {
+		t.Fatalf("slow sink got %s (closed %v), want [1 4 5] and closed", got, slow.closed)
+	}
+
+	for name, want := range map[string][3]int64{"fast": {4, 1, 0}, "slow": {3, 0, 2}} {
+		c := stats.Publisher(name)
+		if got := [3]int64{c.Published.Load(), c.Errors.Load(), c.Dropped.Load()}; got != want {
+			t.Errorf("%s: published, errors, dropped = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/* --------------------------------- Sources --------------------------------- */
+
+// defaultSourceName names the detector's single source under /sources. The
+// per-source routes leave room for several sources.
+const defaultSourceName = "default"
+
+// Bounds of a runtime interval override.
+const (
+	minSourceInterval = 10 * time.Millisecond
+	maxSourceInterval = time.Hour
+)
+
+// SourceStatus is one entry of GET /sources.
+type SourceStatus struct {
+	Name                 string  `json:"name"`
+	Source               string  `json:"source"`
+	IntervalMs           float64 `json:"interval_ms"`            // in effect: the override or FACE_INTERVAL, at the playback speed
+	ConfiguredIntervalMs float64 `json:"configured_interval_ms"` // FACE_INTERVAL
+	OverrideMs           float64 `json:"override_ms,omitempty"`  // set through POST /sources/<name>/interval
+	Speed                float64 `json:"speed"`
+}
+
+// sourcesHandler serves GET /sources and POST
+// /sources/<name>/interval?value=250ms, which changes the detection interval
+// of that source live (its ticker is reset between frames) until the process
+// exits; value=0 restores FACE_INTERVAL. Changing it needs the control token.
+func sourcesHandler(store *FaceStore, playback *Playback, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sources" {
+			if r.Method != http.MethodGet {
+				w.Header().Set("Allow", http.MethodGet)
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			snap, _ := store.Get()
+			configured := playback.Configured()
+			st := SourceStatus{
+				Name:                 defaultSourceName,
+				Source:               snap.Source,
+				IntervalMs:           ms(playback.Interval(configured)),
+				ConfiguredIntervalMs: ms(configured),
+				OverrideMs:           ms(playback.Override()),
+				Speed:                playback.Speed(),
+			}
+			w.Header().Set("Cache-Control", "no-store")
+			writeJSON(w, []SourceStatus{st}, true)
+			return
+		}
+
+		name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/sources/"), "/interval")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token == "" || !authorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if name != defaultSourceName {
+			http.Error(w, "unknown source "+name, http.StatusNotFound)
+			return
+		}
+		d, err := time.ParseDuration(r.URL.Query().Get("value"))
+		if err != nil || d != 0 && (d < minSourceInterval || d > maxSourceInterval) {
+			http.Error(w, "invalid value (want 0 or a duration from "+minSourceInterval.String()+" to "+maxSourceInterval.String()+")", http.StatusBadRequest)
+			return
+		}
+		playback.SetInterval(d)
+		if d == 0 {
+			log.Printf("[sources] %s restored the configured interval of %s", r.RemoteAddr, name)
+		} else {
+			log.Printf("[sources] %s set the interval of %s to %v", r.RemoteAddr, name, d)
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	}
+}
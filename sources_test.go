@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSourcesHandler(t *testing.T) {
+	cfg := DetectorConfig{Interval: time.Hour}
+	d := newFakeDetector(t, cfg, &fakeLoader{next: []*fakeNet{{faces: [][4]float32{{0.1, 0.1, 0.3, 0.3}}}}})
+	p := &Pipeline{Store: NewFaceStore(), Stats: NewStats(), Playback: NewPlayback()}
+	stop := startLoop(d, cfg, p)
+	defer stop()
+	h := sourcesHandler(p.Store, p.Playback, "tok")
+	for deadline := time.Now().Add(5 * time.Second); p.Playback.Configured() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("detector loop not started after 5s")
+		}
+	}
+
+	post := func(path, token string) int {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w.Code
+	}
+	status := func() SourceStatus {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, "/sources", nil))
+		var st []SourceStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil || len(st) != 1 {
+			t.Fatalf("GET /sources: %v %s", err, w.Body)
+		}
+		return st[0]
+	}
+
+	tests := []struct {
+		path  string
+		token string
+		code  int
+	}{
+		{"/sources/default/interval?value=20ms", "", http.StatusUnauthorized},
+		{"/sources/default/interval?value=20ms", "nope", http.StatusUnauthorized},
+		{"/sources/door/interval?value=20ms", "tok", http.StatusNotFound},
+		{"/sources/default/interval?value=5ms", "tok", http.StatusBadRequest},
+		{"/sources/default/interval?value=2h", "tok", http.StatusBadRequest},
+		{"/sources/default/interval?value=-1s", "tok", http.StatusBadRequest},
+		{"/sources/default/interval?value=fast", "tok", http.StatusBadRequest},
+		{"/sources/default/interval", "tok", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := post(tt.path, tt.token); code != tt.code {
+			t.Errorf("POST %s (token %q): %d, want %d", tt.path, tt.token, code, tt.code)
+		}
+	}
+	if st := status(); st.IntervalMs != 3_600_000 || st.OverrideMs != 0 {
+		t.Fatalf("rejected overrides changed the interval: %+v", st)
+	}
+
+	// The hourly ticker is reset: frames follow within the new interval.
+	if code := post("/sources/default/interval?value=20ms", "tok"); code != http.StatusOK {
+		t.Fatalf("POST 20ms: %d", code)
+	}
+	waitSnapshot(t, p.Store, func(s Snapshot) bool { return s.Frame >= 3 })
+	if st := status(); st.IntervalMs != 20 || st.OverrideMs != 20 || st.ConfiguredIntervalMs != 3_600_000 {
+		t.Errorf("with a 20ms override: %+v", st)
+	}
+
+	if code := post("/sources/default/interval?value=0", "tok"); code != http.StatusOK {
+		t.Fatalf("POST 0: %d", code)
+	}
+	if st := status(); st.IntervalMs != 3_600_000 || st.OverrideMs != 0 {
+		t.Errorf("after restoring the configured interval: %+v", st)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+/* ------------------------------- Spatial IDs ------------------------------- */
+
+// ID strategies (FACE_ID_STRATEGY):
+//
+//	none     IDs are the detector's row order, which changes from frame to
+//	         frame
+//	spatial  IDs follow reading order, top-to-bottom then left-to-right, so
+//	         the Nth face keeps ID N while the layout is stable; no state
+//	track    the IoU tracker (FACE_TRACK=1)
+//
+// Spatial IDs belong to places, not people: when two faces swap positions
+// they swap IDs, and a face appearing earlier in reading order shifts the
+// IDs of every face after it.
+const (
+	idStrategyNone    = "none"
+	idStrategySpatial = "spatial"
+	idStrategyTrack   = "track"
+)
+
+// parseIDStrategy reads FACE_ID_STRATEGY, defaulting to track when the
+// legacy FACE_TRACK=1 is set and none otherwise.
+func parseIDStrategy(s string, track bool) (string, error) {
+	switch s {
+	case "":
+		if track {
+			return idStrategyTrack, nil
+		}
+		return idStrategyNone, nil
+	case idStrategyNone, idStrategySpatial, idStrategyTrack:
+		if track && s != idStrategyTrack {
+			return "", fmt.Errorf("%s contradicts FACE_TRACK=1", s)
+		}
+		return s, nil
+	}
+	return "", fmt.Errorf("want none, spatial or track, got %q", s)
+}
+
+// assignSpatialIDs sorts dets in reading order and numbers them from 0.
+// Faces whose centers are within half a box height of the first face of a
+// row belong to that row, so small vertical jitter doesn't reorder a row.
+// Ties on the center fall back to the box size, then the score, so the
+// order never depends on the detector's.
+func assignSpatialIDs(dets []Detection) []Detection {
+	center := func(d Detection) (int, int) {
+		return 2*d.BBox.X + d.BBox.Width, 2*d.BBox.Y + d.BBox.Height // doubled, to stay in ints
+	}
+	less := func(a, b Detection) bool {
+		ax, ay := center(a)
+		bx, by := center(b)
+		switch {
+		case ay != by:
+			return ay < by
+		case ax != bx:
+			return ax < bx
+		case a.BBox.Width != b.BBox.Width:
+			return a.BBox.Width > b.BBox.Width // same center: larger box first
+		case a.BBox.Height != b.BBox.Height:
+			return a.BBox.Height > b.BBox.Height
+		}
+		return a.Score > b.Score
+	}
+	sort.SliceStable(dets, func(i, j int) bool { return less(dets[i], dets[j]) })
+
+	// Rows in top-to-bottom order, each sorted left-to-right.
+	for start := 0; start < len(dets); {
+		_, top := center(dets[start])
+		end := start + 1
+		for end < len(dets) {
+			if _, cy := center(dets[end]); cy-top >= dets[start].BBox.Height {
+				break
+			}
+			end++
+		}
+		row := dets[start:end]
+		sort.SliceStable(row, func(i, j int) bool {
+			ix, _ := center(row[i])
+			jx, _ := center(row[j])
+			if ix != jx {
+				return ix < jx
+			}
+			return less(row[i], row[j])
+		})
+		start = end
+	}
+	for i := range dets {
+		dets[i].ID = i
+	}
+	return dets
+}
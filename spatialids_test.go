@@ -0,0 +1,54 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// permutations calls f with every ordering of dets.
+func permutations(dets []Detection, f func([]Detection)) {
+	if len(dets) <= 1 {
+		f(slices.Clone(dets))
+		return
+	}
+	for i := range dets {
+		rest := append(slices.Clone(dets[:i]), dets[i+1:]...)
+		permutations(rest, func(p []Detection) { f(append([]Detection{dets[i]}, p...)) })
+	}
+}
+
+func TestAssignSpatialIDs(t *testing.T) {
+	face := func(name string, x, y, w int, score float64) Detection {
+		return Detection{Zone: name, BBox: Rect{X: x, Y: y, Width: w, Height: w}, Score: score}
+	}
+	tests := []struct {
+		name string
+		dets []Detection
+		want []string // names by ID
+	}{
+		{"two rows", []Detection{
+			face("a", 10, 10, 40, 0.9), face("b", 100, 14, 40, 0.9), face("c", 50, 6, 40, 0.9), // row jitter < half a box
+			face("d", 10, 100, 40, 0.9), face("e", 100, 92, 40, 0.9),
+		}, []string{"a", "c", "b", "d", "e"}},
+		{"same center, larger first", []Detection{
+			face("small", 20, 20, 20, 0.9), face("large", 10, 10, 40, 0.9), face("mid", 15, 15, 30, 0.9),
+		}, []string{"large", "mid", "small"}},
+		{"same box, higher score first", []Detection{
+			face("low", 10, 10, 40, 0.5), face("high", 10, 10, 40, 0.9), face("right", 60, 10, 40, 0.1),
+		}, []string{"high", "low", "right"}},
+	}
+	for _, tt := range tests {
+		permutations(tt.dets, func(in []Detection) {
+			var got []string
+			for i, d := range assignSpatialIDs(in) {
+				if d.ID != i {
+					t.Fatalf("%s: detection %d has ID %d", tt.name, i, d.ID)
+				}
+				got = append(got, d.Zone)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+/* -------------------------------- SQLite ----------------------------------- */
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS detections (
+	frame  INTEGER NOT NULL,
+	id     INTEGER NOT NULL,
+	x      INTEGER NOT NULL,
+	y      INTEGER NOT NULL,
+	w      INTEGER NOT NULL,
+	h      INTEGER NOT NULL,
+	score  REAL    NOT NULL,
+	ts     TEXT    NOT NULL,
+	source TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS detections_ts ON detections (ts);
+CREATE INDEX IF NOT EXISTS detections_source_frame ON detections (source, frame);
+`
+
+// sqliteSink logs detections to a SQLite database. Only built with
+// `-tags sqlite` so default builds don't pull the driver in.
+type sqliteSink struct {
+	db *sql.DB
+}
+
+func newSQLiteSink(path string) (detectionSink, error) {
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteSink{db: db}, nil
+}
+
+// Insert writes rows in a single transaction.
+func (s *sqliteSink) Insert(rows []detectionRow) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT INTO detections (frame, id, x, y, w, h, score, ts, source) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range rows {
+		if _, err := stmt.Exec(r.Frame, r.ID, r.X, r.Y, r.W, r.H, r.Score, r.TS.UTC().Format(time.RFC3339Nano), r.Source); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteSink) Close() error {
+	return s.db.Close()
+}
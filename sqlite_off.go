@@ -0,0 +1,9 @@
+//go:build !sqlite
+
+package main
+
+import "errors"
+
+func newSQLiteSink(path string) (detectionSink, error) {
+	return nil, errors.New("built without SQLite support, rebuild with -tags sqlite")
+}
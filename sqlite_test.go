@@ -0,0 +1,70 @@
+//go:build sqlite
+
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSQLiteSink(t *testing.T) {
+	sink, err := newSQLiteSink(":memory:") // one connection, so one database
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	db := sink.(*sqliteSink).db
+
+	var indices []string
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'detections' ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		indices = append(indices, name)
+	}
+	rows.Close()
+	if want := []string{"detections_source_frame", "detections_ts"}; !slices.Equal(indices, want) {
+		t.Errorf("indices %v, want %v", indices, want)
+	}
+
+	ts := time.Date(2026, 1, 1, 12, 0, 0, 500, time.FixedZone("CET", 3600))
+	batch := []detectionRow{
+		{Frame: 1, ID: 1, X: 10, Y: 20, W: 30, H: 40, Score: 0.9, TS: ts, Source: "cam"},
+		{Frame: 1, ID: 2, X: 50, Y: 60, W: 70, H: 80, Score: 0.75, TS: ts, Source: "cam"},
+	}
+	if err := sink.Insert(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Insert(batch[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	var got detectionRow
+	var stamp string
+	if err := db.QueryRow(`SELECT COUNT(*) FROM detections`).Scan(&count); err != nil || count != 3 {
+		t.Errorf("%d rows (%v), want 3", count, err)
+	}
+	err = db.QueryRow(`SELECT frame, id, x, y, w, h, score, ts, source FROM detections WHERE id = 2`).
+		Scan(&got.Frame, &got.ID, &got.X, &got.Y, &got.W, &got.H, &got.Score, &stamp, &got.Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.TS, _ = time.Parse(time.RFC3339Nano, stamp)
+	if want := batch[1]; got.Frame != want.Frame || got.X != want.X || got.H != want.H || got.Score != want.Score ||
+		!got.TS.Equal(ts) || got.Source != want.Source {
+		t.Errorf("row %+v (ts %s), want %+v", got, stamp, want)
+	}
+
+	// Opening an existing database keeps its schema and rows.
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		t.Fatalf("schema on an existing database: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM detections`).Scan(&count); err != nil || count != 3 {
+		t.Errorf("after reapplying the schema: %d rows (%v), want 3", count, err)
+	}
+}
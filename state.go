@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/* ------------------------------- State file -------------------------------- */
+
+// stateSaveInterval bounds how often the state file is rewritten.
+const stateSaveInterval = 10 * time.Second
+
+// persistentState is what survives a restart (FACE_STATE_FILE).
+type persistentState struct {
+	Peaks *PeakStats `json:"peaks,omitempty"`
+	Frame int64      `json:"frame,omitempty"` // last frame number handed out
+}
+
+// loadState reads the state file; a missing file is an empty state.
+func loadState(path string) (persistentState, error) {
+	var st persistentState
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	return st, json.Unmarshal(b, &st)
+}
+
+// restoreState applies the state file at path: the peaks are restored and
+// frame numbers continue past the saved one, skipping those a crash may have
+// handed out since the last save at the given detection interval.
+func restoreState(path string, store *FaceStore, seq *FrameCounter, interval time.Duration) error {
+	st, err := loadState(path)
+	if err != nil {
+		return err
+	}
+	if st.Peaks != nil {
+		store.Peaks().Restore(*st.Peaks)
+	}
+	if st.Frame > 0 {
+		seq.Seed(st.Frame + frameRestartGap(interval))
+	}
+	return nil
+}
+
+// saveState writes the state file atomically (temp file + rename).
+func saveState(path string, st persistentState) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// StartStateSaver rewrites the state file when it changed, at most every
+// stateSaveInterval, and once more when ctx is done. The returned channel is
+// closed after the final save.
+func StartStateSaver(ctx context.Context, path string, store *FaceStore, frames *FrameCounter) <-chan struct{} {
+	done := make(chan struct{})
+	var savedFrame int64
+	save := func() {
+		peaks, dirty := store.Peaks().takeDirty()
+		frame := frames.Last()
+		if !dirty && frame == savedFrame {
+			return
+		}
+		if err := saveState(path, persistentState{Peaks: &peaks, Frame: frame}); err != nil {
+			log.Printf("[state] save %s: %v", path, err)
+			return
+		}
+		savedFrame = frame
+	}
+	go func() {
+		defer close(done)
+		tick := time.NewTicker(stateSaveInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				save()
+			case <-ctx.Done():
+				save()
+				return
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/* ------------------------------ Static files ------------------------------- */
+
+// dashboardFS is the default dashboard, served when the static directory
+// doesn't exist.
+//
+//go:embed dashboard
+var dashboardFS embed.FS
+
+// dashboardPreloads are the assets of the embedded index.html, announced
+// with 103 Early Hints so the browser fetches them while the page is sent.
+var dashboardPreloads = []string{
+	"</dashboard.css>; rel=preload; as=style",
+	"</dashboard.js>; rel=preload; as=script",
+}
+
+// staticRoot returns the files to serve on "/": dir when it exists, the
+// embedded dashboard otherwise. embedded reports which one was picked.
+func staticRoot(dir string) (root http.FileSystem, embedded bool) {
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return http.Dir(dir), false
+	}
+	sub, _ := fs.Sub(dashboardFS, "dashboard") // can't fail, the path is static
+	return http.FS(sub), true
+}
+
+// staticHandler serves root like http.FileServer, but answers with a
+// precompressed "<file>.gz" sibling when one exists and the client accepts
+// gzip. Paths are resolved through the http.FileSystem, which rejects
+// traversal. preloads, if any, are sent with the index on "/" as Link
+// headers, first in a 103 Early Hints response; clients that don't know
+// 103 skip it and still get them on the final response.
+func staticHandler(root http.FileSystem, preloads []string) http.Handler {
+	files := http.FileServer(root)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") {
+			name = path.Join(name, "index.html")
+		}
+		if r.URL.Path == "/" && len(preloads) > 0 && r.Method == http.MethodGet {
+			for _, link := range preloads {
+				w.Header().Add("Link", link)
+			}
+			w.WriteHeader(http.StatusEarlyHints)
+		}
+
+		gz, err := root.Open(name + ".gz")
+		if err != nil {
+			files.ServeHTTP(w, r)
+			return
+		}
+		defer gz.Close()
+		info, err := gz.Stat()
+		if err != nil || info.IsDir() {
+			files.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			files.ServeHTTP(w, r)
+			return
+		}
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", ctype)
+		w.Header().Set("Content-Encoding", "gzip")
+		http.ServeContent(w, r, name, info.ModTime(), gz)
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.TrimSpace(coding)
+		if !strings.EqualFold(coding, "gzip") && coding != "*" {
+			continue
+		}
+		if v, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			q, err := strconv.ParseFloat(v, 64)
+			return err == nil && q > 0
+		}
+		return true
+	}
+	return false
+}
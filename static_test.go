@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStaticRoot(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>mine</h1>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	index, err := dashboardFS.ReadFile("dashboard/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir      string
+		embedded bool
+		body     string
+	}{
+		{dir, false, "<h1>mine</h1>"},
+		{filepath.Join(dir, "missing"), true, string(index)},
+		{filepath.Join(dir, "index.html"), true, string(index)}, // a file, not a dir
+	}
+	for _, tt := range tests {
+		root, embedded := staticRoot(tt.dir)
+		if embedded != tt.embedded {
+			t.Errorf("%s: embedded %v, want %v", tt.dir, embedded, tt.embedded)
+		}
+		w := httptest.NewRecorder()
+		staticHandler(root, nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK || w.Body.String() != tt.body {
+			t.Errorf("%s: GET / = %d %.40q, want %.40q", tt.dir, w.Code, w.Body, tt.body)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("the missing static dir was created: %v", err)
+	}
+
+	root, _ := staticRoot("")
+	w := httptest.NewRecorder()
+	staticHandler(root, nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dashboard.js", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/faces") {
+		t.Errorf("GET /dashboard.js = %d, want the embedded script polling /faces", w.Code)
+	}
+}
+
+func TestStaticHandlerEarlyHints(t *testing.T) {
+	root, _ := staticRoot("")
+	srv := httptest.NewServer(staticHandler(root, dashboardPreloads))
+	defer srv.Close()
+
+	get := func(path string) (hints, final []string) {
+		trace := &httptrace.ClientTrace{Got1xxResponse: func(code int, h textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, h.Values("Link")...)
+			}
+			return nil
+		}}
+		req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, srv.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: %d", path, resp.StatusCode)
+		}
+		return hints, resp.Header.Values("Link")
+	}
+
+	hints, final := get("/")
+	if !slices.Equal(hints, dashboardPreloads) || !slices.Equal(final, dashboardPreloads) {
+		t.Errorf("GET /: 103 Link %q, 200 Link %q, want %q on both", hints, final, dashboardPreloads)
+	}
+	for _, path := range []string{"/dashboard.js", "/dashboard.css"} {
+		if hints, final := get(path); len(hints) != 0 || len(final) != 0 {
+			t.Errorf("GET %s: 103 Link %q, 200 Link %q, want none", path, hints, final)
+		}
+	}
+
+	// Without preloads (external dir, or disabled), the index has none.
+	w := httptest.NewRecorder()
+	staticHandler(root, nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if link := w.Header().Values("Link"); len(link) != 0 {
+		t.Errorf("GET / without preloads: Link %q", link)
+	}
+}
+
+func TestStaticHandlerGzip(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "www")
+	files := map[string]string{
+		"www/app.js":            "plain app",
+		"www/app.js.gz":         "gzipped app",
+		"www/only.js":           "only plain",
+		"secret.txt":            "secret",
+		"secret.txt.gz":         "gzipped secret",
+		"www/sub/index.html":    "sub index",
+		"www/sub/index.html.gz": "gzipped sub index",
+	}
+	for name, body := range files {
+		p := filepath.Join(parent, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := staticHandler(http.Dir(dir), nil)
+
+	tests := []struct {
+		path, accept string
+		body         string
+		gzip         bool
+		ctype        string
+	}{
+		{"/app.js", "gzip, deflate", "gzipped app", true, "text/javascript"},
+		{"/app.js", "br;q=1.0, GZIP;q=0.5", "gzipped app", true, "text/javascript"},
+		{"/app.js", "*", "gzipped app", true, "text/javascript"},
+		{"/app.js", "", "plain app", false, "text/javascript"},
+		{"/app.js", "deflate", "plain app", false, "text/javascript"},
+		{"/app.js", "gzip;q=0", "plain app", false, "text/javascript"},
+		{"/app.js", "gzip; q=0.000", "plain app", false, "text/javascript"},
+		{"/only.js", "gzip", "only plain", false, "text/javascript"},
+		{"/sub/", "gzip", "gzipped sub index", true, "text/html"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept-Encoding", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != tt.body {
+			t.Errorf("%s (%q): %d %q, want %q", tt.path, tt.accept, w.Code, w.Body, tt.body)
+		}
+		if gz := w.Header().Get("Content-Encoding") == "gzip"; gz != tt.gzip {
+			t.Errorf("%s (%q): Content-Encoding %q, want gzip %v", tt.path, tt.accept, w.Header().Get("Content-Encoding"), tt.gzip)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.ctype) {
+			t.Errorf("%s (%q): Content-Type %q, want %s", tt.path, tt.accept, ct, tt.ctype)
+		}
+	}
+
+	for _, p := range []string{"/../secret.txt", "/..%2fsecret.txt", "/sub/../../secret.txt"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.URL.Path = p
+		if u, err := url.PathUnescape(p); err == nil {
+			r.URL.Path = u
+		}
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("GET %s escaped the root: %d %q", p, w.Code, w.Body)
+		}
+	}
+}
+
+func TestStaticRouting(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>mine</h1>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), "public")
+	store := NewFaceStore()
+	store.Set(Snapshot{Source: "cam", Detections: []Detection{{ID: 1, Score: 0.9}}})
+
+	tests := []struct {
+		name, dir, path string
+		code            int
+		body            string
+	}{
+		{"static", dir, "/", http.StatusOK, "<h1>mine</h1>"},
+		{"static", dir, "/faces", http.StatusOK, `"source": "cam"`},
+		{"static", dir, "/count?plain=1", http.StatusOK, "1"},
+		{"static", dir, "/nope.html", http.StatusNotFound, ""},
+		{"embedded", missing, "/", http.StatusOK, "<html"},
+		{"disabled", "", "/", http.StatusNotFound, ""},
+		{"disabled", "", "/index.html", http.StatusNotFound, ""},
+		{"disabled", "", "/faces", http.StatusOK, `"source": "cam"`},
+		{"disabled", "", "/count?plain=1", http.StatusOK, "1"},
+		{"disabled", "", "/healthz", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		mux, _ := newServeMux(context.Background(), ServerConfig{StaticDir: tt.dir, Stats: NewStats()}, store)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code || !strings.Contains(strings.ToLower(w.Body.String()), tt.body) {
+			t.Errorf("%s: GET %s = %d %.60q, want %d with %q", tt.name, tt.path, w.Code, w.Body, tt.code, tt.body)
+		}
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("the missing static dir was created: %v", err)
+	}
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* -------------------------------- Stats ------------------------------------ */
+
+// sustainedOverrun is how many consecutive slow cycles trigger a warning.
+const sustainedOverrun = 10
+
+// Stats collects runtime counters of the detector loop, served on /stats
+// (JSON) and /metrics (Prometheus text format).
+type Stats struct {
+	mu        sync.Mutex
+	startedAt time.Time
+	interval  time.Duration
+
+	frames        int64
+	overrunCycles int64         // cycles that took longer than interval
+	droppedFrames int64         // ticks that couldn't be served on time
+	overrunTotal  time.Duration // sum of (cycle - interval) over slow cycles
+	lastCycle     time.Duration
+	consecutive   int // current run of slow cycles
+	lastWarn      time.Time
+	clockOffset   time.Duration
+	model         string // file name of the active model
+	capBuffer     *CaptureBufferStatus
+
+	inferenceFailures int64 // cycles whose Forward failed after retries
+	modelReinits      int64
+	acceleratorOOM    int64     // Forward failures for lack of device memory
+	acceleratorFrom   string    // target the net fell back from to the CPU, "" if none
+	frozen            bool      // the feed currently repeats an identical frame
+	frozenEvents      int64     // times the feed became frozen
+	paused            bool      // the detector is held, e.g. by the GPIO gate
+	sourceOpen        bool      // the detector holds an open source
+	modelLoaded       bool      // and a loaded net
+	lastFrameAt       time.Time // last successful capture
+	queueDropped      int64     // pipeline: captured frames evicted from a full queue
+	staleResults      int64     // pipeline: results completed after a newer one
+
+	publishers map[string]*PublisherCounters
+	cycles     cycleHistogram
+}
+
+func NewStats() *Stats {
+	return &Stats{startedAt: time.Now(), cycles: newCycleHistogram()}
+}
+
+// ObserveCycle records detector cycle number frame, of duration d for the
+// given interval. time.Ticker silently coalesces ticks when a cycle overruns,
+// so every full interval spent beyond the first counts as a dropped frame.
+func (s *Stats) ObserveCycle(frame int64, d, interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.interval = interval
+	s.frames++
+	s.lastCycle = d
+	s.cycles.observe(frame, d)
+	if interval <= 0 || d <= interval {
+		s.consecutive = 0
+		return
+	}
+	s.overrunCycles++
+	s.droppedFrames += int64(d / interval)
+	s.overrunTotal += d - interval
+	s.consecutive++
+	if s.consecutive >= sustainedOverrun && time.Since(s.lastWarn) > time.Minute {
+		s.lastWarn = time.Now()
+		log.Printf("[detector] inference can't keep up: last %d cycles exceeded %v (last %v); "+
+			"raise FACE_INTERVAL or use a faster backend/target", s.consecutive, interval, d.Round(time.Millisecond))
+	}
+}
+
+// ObserveInferenceFailure counts a cycle whose inference failed.
+func (s *Stats) ObserveInferenceFailure() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inferenceFailures++
+}
+
+// ObserveAcceleratorOOM counts a Forward that ran out of device memory.
+func (s *Stats) ObserveAcceleratorOOM() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acceleratorOOM++
+}
+
+// SetAcceleratorFallback records that the net left target for the CPU.
+func (s *Stats) SetAcceleratorFallback(target string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acceleratorFrom = target
+}
+
+// ObserveModelReinit counts a model reload triggered by repeated failures.
+func (s *Stats) ObserveModelReinit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modelReinits++
+}
+
+// SetDetectorUp records whether the detector holds an open source and a
+// loaded model.
+func (s *Stats) SetDetectorUp(sourceOpen, modelLoaded bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sourceOpen, s.modelLoaded = sourceOpen, modelLoaded
+}
+
+// ObserveFrame records a successful capture at t.
+func (s *Stats) ObserveFrame(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastFrameAt = t
+}
+
+// Health returns the detector state /healthz?verbose=1 is assessed from.
+func (s *Stats) Health() detectorHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return detectorHealth{SourceOpen: s.sourceOpen, ModelLoaded: s.modelLoaded, LastFrame: s.lastFrameAt, Frozen: s.frozen, Paused: s.paused}
+}
+
+// ObserveQueueDrops counts captured frames the pipeline dropped unprocessed.
+func (s *Stats) ObserveQueueDrops(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queueDropped += int64(n)
+}
+
+// ObserveStaleResult counts a pipeline result discarded because a newer
+// frame had already been published.
+func (s *Stats) ObserveStaleResult() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.staleResults++
+}
+
+// SetPaused records whether the detector is paused, so a paused detector
+// isn't reported as stalled.
+func (s *Stats) SetPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+}
+
+// SetFrozen records whether the feed is frozen, logging transitions.
+func (s *Stats) SetFrozen(frozen bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if frozen == s.frozen {
+		return
+	}
+	s.frozen = frozen
+	if frozen {
+		s.frozenEvents++
+		log.Printf("[detector] source looks frozen: identical frames")
+	} else {
+		log.Printf("[detector] source is live again")
+	}
+}
+
+// SetModel records the active model.
+func (s *Stats) SetModel(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.model = name
+}
+
+// SetCaptureBuffer records the outcome of the capture buffer request; nil
+// when none was made.
+func (s *Stats) SetCaptureBuffer(st *CaptureBufferStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.capBuffer = st
+}
+
+// SetClockOffset records the offset applied to emitted timestamps.
+func (s *Stats) SetClockOffset(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clockOffset = d
+}
+
+// ClockOffset returns the offset applied to emitted timestamps.
+func (s *Stats) ClockOffset() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clockOffset
+}
+
+// StatsReport is the JSON payload returned by /stats.
+type StatsReport struct {
+	UptimeSec     float64 `json:"uptime_s"`
+	Model         string  `json:"model"`
+	IntervalMs    float64 `json:"interval_ms"`
+	Frames        int64   `json:"frames"`
+	DroppedFrames int64   `json:"dropped_frames"`
+	OverrunCycles int64   `json:"overrun_cycles"`
+	OverrunMs     float64 `json:"overrun_ms"`
+	LastCycleMs   float64 `json:"last_cycle_ms"`
+	ClockOffsetMs float64 `json:"clock_offset_ms"` // added to emitted timestamps
+
+	InferenceFailures int64 `json:"inference_failures"`
+	ModelReinits      int64 `json:"model_reinits"`
+	AcceleratorOOM    int64 `json:"accelerator_oom"`
+	// Target the net fell back from after out-of-memory failures; the
+	// detector runs on the CPU until restarted.
+	AcceleratorFallback string `json:"accelerator_fallback,omitempty"`
+	Frozen              bool   `json:"frozen"`
+	Paused              bool   `json:"paused,omitempty"` // held by the GPIO gate
+	FrozenEvents        int64  `json:"frozen_events"`
+	QueueDropped        int64  `json:"queue_dropped"` // FACE_WORKERS only
+	StaleResults        int64  `json:"stale_results"` // FACE_WORKERS only
+
+	CaptureBuffer *CaptureBufferStatus      `json:"capture_buffer,omitempty"`
+	Retention     *RetentionStats           `json:"retention,omitempty"` // when frame retention is on
+	Memory        *MemoryStats              `json:"memory,omitempty"`    // when FACE_MEMORY_MB is set
+	Publishers    map[string]PublisherStats `json:"publishers,omitempty"`
+}
+
+// Publisher returns the counters for the named publisher, creating them on
+// first use.
+func (s *Stats) Publisher(name string) *PublisherCounters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.publishers == nil {
+		s.publishers = make(map[string]*PublisherCounters)
+	}
+	c, ok := s.publishers[name]
+	if !ok {
+		c = &PublisherCounters{}
+		s.publishers[name] = c
+	}
+	return c
+}
+
+func (s *Stats) Report() StatsReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rep := StatsReport{
+		UptimeSec:     time.Since(s.startedAt).Seconds(),
+		Model:         s.model,
+		CaptureBuffer: s.capBuffer,
+		IntervalMs:    ms(s.interval),
+		Frames:        s.frames,
+		DroppedFrames: s.droppedFrames,
+		OverrunCycles: s.overrunCycles,
+		OverrunMs:     ms(s.overrunTotal),
+		LastCycleMs:   ms(s.lastCycle),
+		ClockOffsetMs: ms(s.clockOffset),
+
+		InferenceFailures:   s.inferenceFailures,
+		ModelReinits:        s.modelReinits,
+		AcceleratorOOM:      s.acceleratorOOM,
+		AcceleratorFallback: s.acceleratorFrom,
+		Frozen:              s.frozen,
+		Paused:              s.paused,
+		FrozenEvents:        s.frozenEvents,
+		QueueDropped:        s.queueDropped,
+		StaleResults:        s.staleResults,
+	}
+	if len(s.publishers) > 0 {
+		rep.Publishers = make(map[string]PublisherStats, len(s.publishers))
+		for name, c := range s.publishers {
+			rep.Publishers[name] = c.Report()
+		}
+	}
+	return rep
+}
+
+/* ---------------------------- Latency histogram ---------------------------- */
+
+// cycleBuckets are the upper bounds (seconds) of the cycle duration histogram.
+var cycleBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// cycleHistogram counts cycle durations per bucket and remembers, for each
+// bucket, the last frame that landed in it (its exemplar).
+type cycleHistogram struct {
+	counts    []int64 // per bucket, not cumulative; the last one is +Inf
+	exemplars []exemplar
+	sum       float64
+	count     int64
+}
+
+// exemplar ties a histogram observation to the frame it measured.
+type exemplar struct {
+	frame int64
+	value float64 // seconds
+	at    time.Time
+}
+
+func newCycleHistogram() cycleHistogram {
+	return cycleHistogram{
+		counts:    make([]int64, len(cycleBuckets)+1),
+		exemplars: make([]exemplar, len(cycleBuckets)+1),
+	}
+}
+
+func (h *cycleHistogram) observe(frame int64, d time.Duration) {
+	v := d.Seconds()
+	i := sort.SearchFloat64s(cycleBuckets, v) // first bound >= v
+	h.counts[i]++
+	h.exemplars[i] = exemplar{frame: frame, value: v, at: time.Now()}
+	h.sum += v
+	h.count++
+}
+
+func (h *cycleHistogram) clone() cycleHistogram {
+	return cycleHistogram{
+		counts:    append([]int64(nil), h.counts...),
+		exemplars: append([]exemplar(nil), h.exemplars...),
+		sum:       h.sum,
+		count:     h.count,
+	}
+}
+
+// CycleHistogram returns a copy of the cycle duration histogram.
+func (s *Stats) CycleHistogram() cycleHistogram {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cycles.clone()
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func statsHandler(cfg ServerConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rep := cfg.Stats.Report()
+		if cfg.Frames != nil {
+			ret := cfg.Frames.Stats()
+			rep.Retention = &ret
+		}
+		if cfg.Memory != nil {
+			mem := cfg.Memory.Stats()
+			rep.Memory = &mem
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+	}
+}
+
+// metricsHandler exposes the stats in the Prometheus text format. With
+// exemplars set, scrapers asking for OpenMetrics get that format instead,
+// with the frame number of the last observation of each latency bucket
+// attached as its trace_id exemplar.
+func metricsHandler(stats *Stats, exemplars bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rep := stats.Report()
+		om := exemplars && acceptsType(r.Header.Get("Accept"), "application/openmetrics-text", false)
+		if om {
+			w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		}
+		// family is the name of the HELP/TYPE lines: OpenMetrics names a
+		// counter without the _total suffix of its sample.
+		family := func(name, typ string) string {
+			if om && typ == "counter" {
+				return strings.TrimSuffix(name, "_total")
+			}
+			return name
+		}
+		metric := func(name, typ, help string, value float64) {
+			f := family(name, typ)
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", f, help, f, typ, name, value)
+		}
+		metric("facetrack_frames_total", "counter", "Detector cycles run.", float64(rep.Frames))
+		metric("facetrack_dropped_frames_total", "counter", "Ticks skipped because a cycle overran the interval.", float64(rep.DroppedFrames))
+		metric("facetrack_overrun_cycles_total", "counter", "Cycles that took longer than the interval.", float64(rep.OverrunCycles))
+		metric("facetrack_overrun_seconds_total", "counter", "Cumulative time spent beyond the interval.", rep.OverrunMs/1000)
+		metric("facetrack_last_cycle_seconds", "gauge", "Duration of the last detector cycle.", rep.LastCycleMs/1000)
+		metric("facetrack_interval_seconds", "gauge", "Configured detection interval.", rep.IntervalMs/1000)
+		metric("facetrack_inference_failures_total", "counter", "Cycles whose inference failed after retries.", float64(rep.InferenceFailures))
+		metric("facetrack_model_reinits_total", "counter", "Model reloads after repeated inference failures.", float64(rep.ModelReinits))
+		metric("facetrack_accelerator_oom_total", "counter", "Inferences that ran out of accelerator memory.", float64(rep.AcceleratorOOM))
+		metric("facetrack_accelerator_fallback", "gauge", "1 once the net fell back to the CPU after out-of-memory failures.", boolMetric(rep.AcceleratorFallback != ""))
+		metric("facetrack_queue_dropped_total", "counter", "Captured frames dropped from the full pipeline queue.", float64(rep.QueueDropped))
+		metric("facetrack_stale_results_total", "counter", "Pipeline results discarded because a newer frame was published.", float64(rep.StaleResults))
+		metric("facetrack_frozen", "gauge", "1 while the source repeats an identical frame.", boolMetric(rep.Frozen))
+		metric("facetrack_clock_offset_seconds", "gauge", "Offset added to emitted timestamps.", rep.ClockOffsetMs/1000)
+
+		if len(rep.Publishers) > 0 {
+			names := make([]string, 0, len(rep.Publishers))
+			for name := range rep.Publishers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			labeled := func(name, help string, value func(PublisherStats) int64) {
+				f := family(name, "counter")
+				fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", f, help, f)
+				for _, p := range names {
+					fmt.Fprintf(w, "%s{publisher=%q} %d\n", name, p, value(rep.Publishers[p]))
+				}
+			}
+			labeled("facetrack_published_total", "Snapshots delivered by a publisher.", func(p PublisherStats) int64 { return p.Published })
+			labeled("facetrack_publish_dropped_total", "Snapshots dropped from a full publisher queue.", func(p PublisherStats) int64 { return p.Dropped })
+			labeled("facetrack_publish_errors_total", "Failed publish attempts.", func(p PublisherStats) int64 { return p.Errors })
+		}
+
+		writeCycleHistogram(w, stats.CycleHistogram(), om)
+		if om {
+			fmt.Fprint(w, "# EOF\n")
+		}
+	}
+}
+
+// writeCycleHistogram writes the cycle duration histogram, with exemplars
+// when om is set.
+func writeCycleHistogram(w io.Writer, h cycleHistogram, om bool) {
+	const name = "facetrack_cycle_seconds"
+	fmt.Fprintf(w, "# HELP %s Detector cycle duration (capture and inference).\n# TYPE %s histogram\n", name, name)
+	var cum int64
+	for i, n := range h.counts {
+		cum += n
+		le := "+Inf"
+		if i < len(cycleBuckets) {
+			// OpenMetrics wants canonical floats: 1.0, not 1
+			le = strconv.FormatFloat(cycleBuckets[i], 'g', -1, 64)
+			if !strings.ContainsAny(le, ".e") {
+				le += ".0"
+			}
+		}
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d", name, le, cum)
+		if e := h.exemplars[i]; om && n > 0 {
+			fmt.Fprintf(w, " # {trace_id=\"%d\"} %g %.3f", e.frame, e.value, float64(e.at.UnixMilli())/1000)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", name, h.sum, name, h.count)
+}
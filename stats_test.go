@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	metricName  = `[a-zA-Z_:][a-zA-Z0-9_:]*`
+	labelSet    = `\{(?:[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\]|\\.)*"(?:,[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\]|\\.)*")*)?\}`
+	metaLine    = regexp.MustCompile(`^# (HELP|TYPE) (` + metricName + `) (.+)$`)
+	sampleLine  = regexp.MustCompile(`^(` + metricName + `)(` + labelSet + `)? (\S+)(?: # (` + labelSet + `) (\S+) (\S+))?$`)
+	canonicalLE = regexp.MustCompile(`le="(\d+\.\d+|\d(?:\.\d+)?e[+-]\d+|\+Inf)"`)
+	metricTypes = map[string]bool{"counter": true, "gauge": true, "histogram": true}
+)
+
+// checkExposition parses a Prometheus text or OpenMetrics exposition and
+// returns its exemplars.
+func checkExposition(t *testing.T, body string, om bool) (exemplars []string) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if om {
+		if lines[len(lines)-1] != "# EOF" {
+			t.Fatalf("OpenMetrics exposition doesn't end with # EOF")
+		}
+		lines = lines[:len(lines)-1]
+	}
+	types := map[string]string{}
+	for i, line := range lines {
+		if m := metaLine.FindStringSubmatch(line); m != nil {
+			if m[1] == "TYPE" {
+				if !metricTypes[m[3]] {
+					t.Fatalf("line %d: unknown type %q", i+1, m[3])
+				}
+				types[m[2]] = m[3]
+			}
+			continue
+		}
+		m := sampleLine.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("line %d: malformed %q", i+1, line)
+		}
+		if _, err := strconv.ParseFloat(m[3], 64); err != nil {
+			t.Fatalf("line %d: value %q: %v", i+1, m[3], err)
+		}
+		typ := sampleType(types, m[1], om)
+		if typ == "" {
+			t.Fatalf("line %d: sample %s without a TYPE", i+1, m[1])
+		}
+		if typ == "histogram" && strings.HasSuffix(m[1], "_bucket") && !canonicalLE.MatchString(m[2]) {
+			t.Fatalf("line %d: bucket label %s is not a canonical float", i+1, m[2])
+		}
+		if m[4] != "" {
+			exemplars = append(exemplars, m[4]+" "+m[5])
+		}
+	}
+	return exemplars
+}
+
+// sampleType is the type of the family a sample belongs to, "" if none.
+// OpenMetrics counter families drop the _total suffix of their sample.
+func sampleType(types map[string]string, name string, om bool) string {
+	if typ := types[name]; typ == "gauge" || typ == "counter" && !om {
+		return typ
+	}
+	if f, ok := strings.CutSuffix(name, "_total"); ok && om && types[f] == "counter" {
+		return "counter"
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if f, ok := strings.CutSuffix(name, suffix); ok && types[f] == "histogram" {
+			return "histogram"
+		}
+	}
+	return ""
+}
+
+func TestMetricsExposition(t *testing.T) {
+	stats := NewStats()
+	stats.ObserveCycle(41, 30*time.Millisecond, 100*time.Millisecond)
+	stats.ObserveCycle(42, 700*time.Millisecond, 100*time.Millisecond)
+
+	tests := []struct {
+		exemplars bool
+		accept    string
+		om        bool
+	}{
+		{false, "", false},
+		{false, "application/openmetrics-text; version=1.0.0", false},
+		{true, "text/plain", false},
+		{true, "application/openmetrics-text; version=1.0.0,text/plain;q=0.5", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		metricsHandler(stats, tt.exemplars)(w, r)
+		if om := strings.HasPrefix(w.Header().Get("Content-Type"), "application/openmetrics-text"); om != tt.om {
+			t.Errorf("exemplars %v, Accept %q: Content-Type %s", tt.exemplars, tt.accept, w.Header().Get("Content-Type"))
+			continue
+		}
+		body := w.Body.String()
+		exemplars := checkExposition(t, body, tt.om)
+		if !strings.Contains(body, `facetrack_cycle_seconds_bucket{le="1.0"} 2`) {
+			t.Errorf("exemplars %v, Accept %q: no le=\"1.0\" bucket:\n%s", tt.exemplars, tt.accept, body)
+		}
+		if !tt.om {
+			if len(exemplars) != 0 {
+				t.Errorf("exemplars %v, Accept %q: exemplars %v in the Prometheus format", tt.exemplars, tt.accept, exemplars)
+			}
+			continue
+		}
+		if len(exemplars) != 2 || !strings.HasPrefix(exemplars[0], `{trace_id="41"} 0.03`) || !strings.HasPrefix(exemplars[1], `{trace_id="42"} 0.7`) {
+			t.Errorf("exemplars %v, want frames 41 and 42", exemplars)
+		}
+	}
+}
+
+func TestStatsOverrun(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	tests := []struct {
+		cycle             time.Duration
+		overruns, dropped int64
+		overrunMs         float64
+	}{
+		{50 * time.Millisecond, 0, 0, 0},
+		{100 * time.Millisecond, 0, 0, 0},
+		{150 * time.Millisecond, 1, 1, 50},
+		{350 * time.Millisecond, 2, 4, 300}, // 3 ticks coalesced
+		{80 * time.Millisecond, 2, 4, 300},
+	}
+	s := NewStats()
+	for i, tt := range tests {
+		s.ObserveCycle(int64(i+1), tt.cycle, interval)
+		rep := s.Report()
+		if rep.OverrunCycles != tt.overruns || rep.DroppedFrames != tt.dropped || rep.OverrunMs != tt.overrunMs {
+			t.Errorf("after a %v cycle: %d overruns, %d dropped, %vms; want %d, %d, %vms", tt.cycle,
+				rep.OverrunCycles, rep.DroppedFrames, rep.OverrunMs, tt.overruns, tt.dropped, tt.overrunMs)
+		}
+	}
+}
+
+// TestDetectorLoopOverrun runs the loop with an inferer three times slower
+// than the interval: every cycle overruns and drops the ticks it covers.
+func TestDetectorLoopOverrun(t *testing.T) {
+	cfg := DetectorConfig{Interval: 20 * time.Millisecond}
+	slow := &fakeNet{faces: [][4]float32{{0.1, 0.1, 0.3, 0.3}}, delay: 60 * time.Millisecond}
+	d := newFakeDetector(t, cfg, &fakeLoader{next: []*fakeNet{slow}})
+	p := &Pipeline{Store: NewFaceStore(), Stats: NewStats()}
+	stop := startLoop(d, cfg, p)
+	waitSnapshot(t, p.Store, func(s Snapshot) bool { return s.Frame >= 3 })
+	stop()
+
+	rep := p.Stats.Report()
+	if rep.Frames < 3 || rep.OverrunCycles != rep.Frames || rep.DroppedFrames < 3*rep.Frames {
+		t.Errorf("%d cycles: %d overruns, %d dropped; want every cycle overrun by 3 ticks or more", rep.Frames, rep.OverrunCycles, rep.DroppedFrames)
+	}
+	if rep.OverrunMs < float64(rep.Frames)*40 {
+		t.Errorf("%d cycles overran by %vms in total, want at least 40ms each", rep.Frames, rep.OverrunMs)
+	}
+}
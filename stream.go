@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ------------------------------ SSE stream --------------------------------- */
+
+// sseHeartbeat keeps idle connections alive through proxies.
+const sseHeartbeat = 15 * time.Second
+
+// snapshotFilter is a per-subscriber view of the snapshot stream.
+// The zero value lets everything through.
+type snapshotFilter struct {
+	minScore float64
+	region   *Rect // keep detections whose center lies inside
+	top      int   // keep the N best-scored detections; 0 = all
+	raw      bool  // detections before tracking and smoothing
+}
+
+// parseSnapshotFilter reads min_score, region=x,y,w,h, top and raw from q.
+func parseSnapshotFilter(q url.Values) (snapshotFilter, error) {
+	var f snapshotFilter
+	raw, err := parseRawParam(q)
+	if err != nil {
+		return f, err
+	}
+	f.raw = raw
+	if v := q.Get("min_score"); v != "" {
+		score, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return f, fmt.Errorf("invalid min_score %q", v)
+		}
+		f.minScore = score
+	}
+	if v := q.Get("region"); v != "" {
+		rect, err := parseRect(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid region: %w", err)
+		}
+		f.region = &rect
+	}
+	if v := q.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, fmt.Errorf("invalid top %q", v)
+		}
+		f.top = n
+	}
+	return f, nil
+}
+
+// parseRect parses "x,y,w,h" with a positive width and height.
+func parseRect(s string) (Rect, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return Rect{}, fmt.Errorf("want x,y,w,h, got %q", s)
+	}
+	var v [4]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return Rect{}, fmt.Errorf("want x,y,w,h, got %q", s)
+		}
+		v[i] = n
+	}
+	if v[2] <= 0 || v[3] <= 0 {
+		return Rect{}, fmt.Errorf("width and height must be positive, got %q", s)
+	}
+	return Rect{X: v[0], Y: v[1], Width: v[2], Height: v[3]}, nil
+}
+
+// parseRawParam reads ?raw=true.
+func parseRawParam(q url.Values) (bool, error) {
+	v := q.Get("raw")
+	if v == "" {
+		return false, nil
+	}
+	raw, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid raw %q", v)
+	}
+	return raw, nil
+}
+
+// rawView returns snap with its detections as detected, before tracking and
+// smoothing; snap itself when nothing was applied to them.
+func rawView(snap Snapshot) Snapshot {
+	if snap.Raw != nil {
+		snap.Detections = snap.Raw
+	}
+	return snap
+}
+
+func (f snapshotFilter) isZero() bool {
+	return f.minScore == 0 && f.region == nil && f.top == 0 && !f.raw
+}
+
+// apply returns snap with the filter applied. The shared snapshot (and its
+// Detections backing array) is never modified.
+func (f snapshotFilter) apply(snap Snapshot) Snapshot {
+	if f.raw {
+		snap = rawView(snap)
+	}
+	if f.isZero() || len(snap.Detections) == 0 {
+		return snap
+	}
+	kept := make([]Detection, 0, len(snap.Detections))
+	for _, d := range snap.Detections {
+		if d.Score < f.minScore {
+			continue
+		}
+		if f.region != nil {
+			cx, cy := d.BBox.X+d.BBox.Width/2, d.BBox.Y+d.BBox.Height/2
+			rg := f.region
+			if cx < rg.X || cy < rg.Y || cx >= rg.X+rg.Width || cy >= rg.Y+rg.Height {
+				continue
+			}
+		}
+		kept = append(kept, d)
+	}
+	if f.top > 0 && len(kept) > f.top {
+		sort.SliceStable(kept, func(i, j int) bool { return kept[i].Score > kept[j].Score })
+		kept = kept[:f.top]
+	}
+	snap.Detections = kept
+	return snap
+}
+
+// events returns the track events of snap whose track the filter keeps,
+// judged on the tracked detections even for a raw view.
+func (f snapshotFilter) events(snap Snapshot) []TrackEvent {
+	f.raw = false
+	if f.isZero() || len(snap.Events) == 0 {
+		return snap.Events
+	}
+	kept := make(map[int]bool)
+	for _, d := range f.apply(snap).Detections {
+		kept[d.ID] = true
+	}
+	var out []TrackEvent
+	for _, ev := range snap.Events {
+		if kept[ev.ID] {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// streamHandler serves snapshots as server-sent events. Each subscriber may
+// narrow the stream with ?min_score=, ?region=x,y,w,h and ?top=, which also
+// narrow the track events sent before each snapshot; v1 subscribers get no
+// events. The stream ends cleanly when the client leaves or when ctx (server
+// lifetime) is done. Scores are filtered at full precision, then formatted;
+// ?case= picks the field names as on /faces.
+func streamHandler(ctx context.Context, store *FaceStore, scores *scoreFormat, jsonCase jsonCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseSnapshotFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jc, err := jsonCase.forRequest(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		// EventSource can't set headers, so ?accept= works too
+		v1 := wantsV1(r.Header.Get("Accept")) || wantsV1(r.URL.Query().Get("accept"))
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+
+		var sent uint64
+		for {
+			changed := store.Changed()
+			if snap, ver := store.Get(); ver != sent {
+				filtered := filter.apply(snap)
+				var body any = scores.apply(filtered)
+				events := filter.events(snap)
+				if v1 {
+					body = projectV1(filtered) // frozen: scores unrounded
+					events = nil               // and no event types
+				}
+				// Track events of the frame first, under their own names
+				for _, ev := range events {
+					data, err := json.Marshal(jc.wrap(ev))
+					if err != nil {
+						return
+					}
+					if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+						return
+					}
+				}
+				payload, err := json.Marshal(jc.wrap(body))
+				if err != nil {
+					return
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: snapshot\ndata: %s\n\n", ver, payload); err != nil {
+					return
+				}
+				flusher.Flush()
+				sent = ver
+			}
+
+			select {
+			case <-changed:
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
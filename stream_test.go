@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is one server-sent event: its name and its data.
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readStream subscribes to srv with query and accept, and returns the events
+// up to and including the first snapshot.
+func readStream(t *testing.T, srv *httptest.Server, query, accept string) []sseEvent {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s: %s", query, resp.Status)
+	}
+	var events []sseEvent
+	var ev sseEvent
+	for sc := bufio.NewScanner(resp.Body); sc.Scan(); {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && ev.name != "":
+			events = append(events, ev)
+			if ev.name == "snapshot" {
+				return events
+			}
+			ev = sseEvent{}
+		}
+	}
+	t.Fatalf("%s: stream ended before a snapshot", query)
+	return nil
+}
+
+// streamIDs returns the detection IDs of a snapshot event.
+func streamIDs(t *testing.T, ev sseEvent) []int {
+	t.Helper()
+	var snap struct{ Detections []Detection }
+	if err := json.Unmarshal([]byte(ev.data), &snap); err != nil {
+		t.Fatalf("%s: %v", ev.data, err)
+	}
+	ids := []int{}
+	for _, d := range snap.Detections {
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
+
+func TestStreamFilters(t *testing.T) {
+	// Best score last, so that top has something to reorder.
+	dets := []Detection{
+		{ID: 1, BBox: Rect{X: 30, Y: 30, Width: 20, Height: 20}, Score: 0.6},
+		{ID: 2, BBox: Rect{X: 300, Y: 300, Width: 20, Height: 20}, Score: 0.85},
+		{ID: 3, BBox: Rect{X: 10, Y: 10, Width: 20, Height: 20}, Score: 0.95},
+	}
+	store := NewFaceStore()
+	store.Set(Snapshot{Frame: 1, Detections: dets})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := httptest.NewServer(streamHandler(ctx, store, nil, caseSnake))
+	defer srv.Close()
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{1, 2, 3}},
+		{"?min_score=0.8", []int{2, 3}},
+		{"?region=0,0,100,100&top=1", []int{3}},
+	}
+	for _, tt := range tests {
+		events := readStream(t, srv, tt.query, "")
+		if got := streamIDs(t, events[len(events)-1]); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: detections %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// Both subscribers were served from the same snapshot, left as it was.
+	snap, _ := store.Get()
+	if !reflect.DeepEqual(snap.Detections, dets) {
+		t.Errorf("store holds %+v, want %+v", snap.Detections, dets)
+	}
+}
+
+func TestRawView(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	frames := []Rect{{X: 100, Y: 100, Width: 40, Height: 40}, {X: 104, Y: 102, Width: 42, Height: 42}}
+	boxes := func(dets []Detection) []Rect {
+		var out []Rect
+		for _, d := range dets {
+			out = append(out, d.BBox)
+		}
+		return out
+	}
+	// served returns the boxes of /faces and of the stream filter for the
+	// last snapshot built by tracker.
+	served := func(tracker *Tracker, raw bool) (faces, stream []Rect) {
+		store := NewFaceStore()
+		for i, box := range frames {
+			dets, rawDets := trackFaces(tracker, []Detection{{BBox: box, Score: 0.9, Timestamp: t0.Add(time.Duration(i) * 100 * time.Millisecond)}})
+			store.Set(Snapshot{Frame: int64(i + 1), Detections: dets, Raw: rawDets})
+		}
+		url := "/faces"
+		if raw {
+			url += "?raw=true"
+		}
+		w := httptest.NewRecorder()
+		facesHandler(ServerConfig{}, store)(w, httptest.NewRequest(http.MethodGet, url, nil))
+		var body struct{ Detections []Detection }
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v", w.Body, err)
+		}
+		snap, _ := store.Get()
+		return boxes(body.Detections), boxes(snapshotFilter{raw: raw}.apply(snap).Detections)
+	}
+
+	tests := []struct {
+		name    string
+		tracker *Tracker
+		differ  bool
+	}{
+		{"smoothing", NewTracker(0.3, 5, 0.5), true},
+		{"no tracker", nil, false},
+	}
+	for _, tt := range tests {
+		rawFaces, rawStream := served(tt.tracker, true)
+		procFaces, procStream := served(tt.tracker, false)
+		if len(rawFaces) != 1 || rawFaces[0] != frames[1] {
+			t.Errorf("%s: raw /faces %v, want the last detection %v", tt.name, rawFaces, frames[1])
+		}
+		if (procFaces[0] != rawFaces[0]) != tt.differ {
+			t.Errorf("%s: processed %v, raw %v, want differing %v", tt.name, procFaces, rawFaces, tt.differ)
+		}
+		if rawStream[0] != rawFaces[0] || procStream[0] != procFaces[0] {
+			t.Errorf("%s: stream raw %v processed %v, /faces raw %v processed %v", tt.name, rawStream, procStream, rawFaces, procFaces)
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	in := Detection{ID: 1, BBox: Rect{X: 10, Y: 10, Width: 20, Height: 20}, Score: 0.9}
+	out := Detection{ID: 2, BBox: Rect{X: 300, Y: 300, Width: 20, Height: 20}, Score: 0.9}
+	store := NewFaceStore()
+	store.Set(Snapshot{Frame: 1, Detections: []Detection{in, out}, Events: []TrackEvent{
+		{Type: "stable", ID: 1, BBox: in.BBox},
+		{Type: "zone_enter", ID: 2, BBox: out.BBox, Zone: "door"},
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := httptest.NewServer(streamHandler(ctx, store, nil, caseSnake))
+	defer srv.Close()
+
+	tests := []struct {
+		query, accept string
+		want          []string
+	}{
+		{"", "", []string{"stable", "zone_enter", "snapshot"}},
+		{"?region=0,0,100,100", "", []string{"stable", "snapshot"}},
+		{"?raw=true&region=0,0,100,100", "", []string{"stable", "snapshot"}},
+		{"?min_score=0.95", "", []string{"snapshot"}},
+		{"", mediaTypeV1, []string{"snapshot"}},
+		{"?accept=" + url.QueryEscape(mediaTypeV1), "", []string{"snapshot"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, ev := range readStream(t, srv, tt.query, tt.accept) {
+			got = append(got, ev.name)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q %q: events %v, want %v", tt.query, tt.accept, got, tt.want)
+		}
+	}
+}
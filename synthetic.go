@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+/* ---------------------------- Synthetic source ----------------------------- */
+
+// syntheticScheme selects the built-in test-pattern source, e.g.
+// "synthetic://?w=640&h=480&faces=2".
+const syntheticScheme = "synthetic://"
+
+// syntheticMaxFaces bounds faces, drawn (and returned by BoxesAt) on every
+// frame.
+const syntheticMaxFaces = 64
+
+// SyntheticSource generates frames with moving filled rectangles standing in
+// for faces. Positions depend only on the frame index, so a run is fully
+// reproducible and BoxesAt gives the ground truth for any frame.
+type SyntheticSource struct {
+	width, height int
+	faces         int
+	size          int // side of each synthetic face in pixels
+	speed         int // pixels per frame
+	frame         int64
+	canvas        gocv.Mat
+}
+
+// NewSyntheticSource parses a synthetic:// source URL. Supported query params:
+// w, h (frame size, default 640x480), faces (default 1, at most 64), size
+// (box side, default a quarter of the shorter side) and speed (pixels per
+// frame, default 8).
+func NewSyntheticSource(source string) (*SyntheticSource, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("parse synthetic source: %w", err)
+	}
+	q := u.Query()
+
+	s := &SyntheticSource{width: 640, height: 480, faces: 1, speed: 8}
+	for _, p := range []struct {
+		key      string
+		dst      *int
+		min, max int // max 0 = unbounded
+	}{
+		{"w", &s.width, 16, 0},
+		{"h", &s.height, 16, 0},
+		{"faces", &s.faces, 0, syntheticMaxFaces},
+		{"size", &s.size, 1, 0},
+		{"speed", &s.speed, 0, 0},
+	} {
+		v := q.Get(p.key)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < p.min || p.max > 0 && n > p.max {
+			return nil, fmt.Errorf("synthetic source: invalid %s=%q", p.key, v)
+		}
+		*p.dst = n
+	}
+	if s.size == 0 {
+		s.size = min(s.width, s.height) / 4
+	}
+	if s.size > s.width || s.size > s.height {
+		return nil, fmt.Errorf("synthetic source: size %d exceeds frame %dx%d", s.size, s.width, s.height)
+	}
+
+	s.canvas = gocv.NewMatWithSize(s.height, s.width, gocv.MatTypeCV8UC3)
+	return s, nil
+}
+
+// BoxesAt returns the synthetic face rectangles drawn on the given frame
+// (1-based, matching Snapshot.Frame). Face i bounces horizontally on its own
+// lane with a phase offset, so boxes never overlap when lanes fit the frame.
+func (s *SyntheticSource) BoxesAt(frame int64) []image.Rectangle {
+	out := make([]image.Rectangle, 0, s.faces)
+	if s.faces == 0 {
+		return out
+	}
+	travel := s.width - s.size
+	lane := s.height / s.faces
+	for i := 0; i < s.faces; i++ {
+		x := 0
+		if travel > 0 {
+			// Triangle wave over [0, travel].
+			pos := (int(frame)*s.speed + i*travel/s.faces) % (2 * travel)
+			if pos > travel {
+				pos = 2*travel - pos
+			}
+			x = pos
+		}
+		y := i*lane + (lane-s.size)/2
+		if y < 0 {
+			y = 0
+		}
+		if y+s.size > s.height {
+			y = s.height - s.size
+		}
+		out = append(out, image.Rect(x, y, x+s.size, y+s.size))
+	}
+	return out
+}
+
+// Read renders the next frame into m. It never fails.
+func (s *SyntheticSource) Read(m *gocv.Mat) bool {
+	s.frame++
+	s.canvas.SetTo(gocv.NewScalar(64, 64, 64, 0))
+	skin := color.RGBA{R: 224, G: 172, B: 140}
+	dark := color.RGBA{R: 40, G: 30, B: 30}
+	for _, r := range s.BoxesAt(s.frame) {
+		_ = gocv.Rectangle(&s.canvas, r, skin, -1)
+		// Eyes and mouth, so the pattern is at least vaguely face-like.
+		w, h := r.Dx(), r.Dy()
+		eye := max(w/10, 1)
+		_ = gocv.Circle(&s.canvas, image.Pt(r.Min.X+w*3/10, r.Min.Y+h*4/10), eye, dark, -1)
+		_ = gocv.Circle(&s.canvas, image.Pt(r.Min.X+w*7/10, r.Min.Y+h*4/10), eye, dark, -1)
+		_ = gocv.Line(&s.canvas, image.Pt(r.Min.X+w*3/10, r.Min.Y+h*3/4), image.Pt(r.Min.X+w*7/10, r.Min.Y+h*3/4), dark, max(h/20, 1))
+	}
+	return s.canvas.CopyTo(m) == nil
+}
+
+func (s *SyntheticSource) Close() error {
+	return s.canvas.Close()
+}
+
+func isSyntheticSource(source string) bool {
+	return strings.HasPrefix(source, syntheticScheme)
+}
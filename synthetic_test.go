@@ -0,0 +1,95 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestNewSyntheticSource(t *testing.T) {
+	tests := []struct {
+		source                         string
+		width, height, faces, size, sp int
+		ok                             bool
+	}{
+		{"synthetic://", 640, 480, 1, 120, 8, true},
+		{"synthetic://?w=320&h=240&faces=3&size=40&speed=2", 320, 240, 3, 40, 2, true},
+		{"synthetic://?w=16&h=480", 16, 480, 1, 4, 8, true}, // default size fits the width
+		{"synthetic://?w=640&h=16", 640, 16, 1, 4, 8, true},
+		{"synthetic://?faces=0&speed=0", 640, 480, 0, 120, 0, true},
+		{"synthetic://?faces=64", 640, 480, 64, 120, 8, true},
+		{"synthetic://?faces=65", 0, 0, 0, 0, 0, false},
+		{"synthetic://?faces=1000000000", 0, 0, 0, 0, 0, false},
+		{"synthetic://?faces=-1", 0, 0, 0, 0, 0, false},
+		{"synthetic://?w=8", 0, 0, 0, 0, 0, false},
+		{"synthetic://?h=abc", 0, 0, 0, 0, 0, false},
+		{"synthetic://?size=0", 0, 0, 0, 0, 0, false},
+		{"synthetic://?size=481", 0, 0, 0, 0, 0, false},
+		{"synthetic://?w=100&size=101", 0, 0, 0, 0, 0, false},
+		{"synthetic://?speed=-1", 0, 0, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		s, err := NewSyntheticSource(tt.source)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: %v", tt.source, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if s.width != tt.width || s.height != tt.height || s.faces != tt.faces || s.size != tt.size || s.speed != tt.sp {
+			t.Errorf("%s: %dx%d, %d faces of %d, speed %d", tt.source, s.width, s.height, s.faces, s.size, s.speed)
+		}
+		s.Close()
+	}
+}
+
+func TestSyntheticBoxesAt(t *testing.T) {
+	for _, source := range []string{
+		"synthetic://",
+		"synthetic://?faces=3&speed=13",
+		"synthetic://?w=320&h=240&faces=4&size=50",
+		"synthetic://?w=16&h=480&faces=2",
+	} {
+		s, err := NewSyntheticSource(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := NewSyntheticSource(source)
+		frame := gocv.NewMat()
+		for f := range int64(200) {
+			boxes := s.BoxesAt(f + 1)
+			if !slices.Equal(boxes, again.BoxesAt(f+1)) {
+				t.Fatalf("%s: frame %d not reproducible", source, f+1)
+			}
+			if len(boxes) != s.faces {
+				t.Fatalf("%s: %d boxes, want %d", source, len(boxes), s.faces)
+			}
+			for i, b := range boxes {
+				if b.Dx() != s.size || b.Dy() != s.size || b.Min.X < 0 || b.Min.Y < 0 || b.Max.X > s.width || b.Max.Y > s.height {
+					t.Fatalf("%s: frame %d box %v out of the %dx%d frame", source, f+1, b, s.width, s.height)
+				}
+				for _, o := range boxes[i+1:] {
+					if b.Overlaps(o) {
+						t.Fatalf("%s: frame %d boxes %v and %v overlap", source, f+1, b, o)
+					}
+				}
+			}
+
+			// Read draws the boxes of the same frame.
+			if f >= 10 {
+				continue
+			}
+			s.Read(&frame)
+			for _, b := range boxes {
+				if v := frame.GetVecbAt(b.Min.Y, b.Min.X); v[2] != 224 {
+					t.Fatalf("%s: frame %d has no face at %v", source, f+1, b.Min)
+				}
+			}
+		}
+		frame.Close()
+		s.Close()
+		again.Close()
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* --------------------------------- Tiling ---------------------------------- */
+
+// Tiling splits wide frames into a grid of overlapping tiles inferred as one
+// batch, so faces keep enough pixels once each tile is scaled to the network
+// input. Faces in the overlaps are seen twice and merged back.
+type Tiling struct {
+	Cols, Rows int
+	Overlap    float64 // fraction of a tile shared with its neighbour, [0, 0.9]
+	Merge      float64 // overlap (intersection over the smaller box) above which boxes from different tiles are one face
+}
+
+// parseTiling reads "COLSxROWS", e.g. "3x1". An empty spec disables tiling.
+func parseTiling(spec string, overlap, merge float64) (*Tiling, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	c, r, ok := strings.Cut(strings.ToLower(spec), "x")
+	cols, err1 := strconv.Atoi(strings.TrimSpace(c))
+	rows, err2 := strconv.Atoi(strings.TrimSpace(r))
+	if !ok || err1 != nil || err2 != nil || cols < 1 || rows < 1 || cols*rows > 64 {
+		return nil, fmt.Errorf("want COLSxROWS with at most 64 tiles, got %q", spec)
+	}
+	if overlap < 0 || overlap > 0.9 {
+		return nil, fmt.Errorf("overlap must be within [0, 0.9], got %g", overlap)
+	}
+	if merge <= 0 || merge > 1 {
+		merge = 0.5
+	}
+	return &Tiling{Cols: cols, Rows: rows, Overlap: overlap, Merge: merge}, nil
+}
+
+// Rects returns the tiles covering bounds, row by row. Tiles have the same
+// size and neighbours share Overlap of it; the last tile of a row or column
+// ends exactly on the frame edge.
+func (t *Tiling) Rects(bounds image.Rectangle) []image.Rectangle {
+	xs, tw := tileSpans(bounds.Dx(), t.Cols, t.Overlap)
+	ys, th := tileSpans(bounds.Dy(), t.Rows, t.Overlap)
+	out := make([]image.Rectangle, 0, len(xs)*len(ys))
+	for _, y := range ys {
+		for _, x := range xs {
+			out = append(out, image.Rect(x, y, x+tw, y+th).Add(bounds.Min))
+		}
+	}
+	return out
+}
+
+// tileSpans splits length into n spans overlapping by the given fraction
+// and returns their starts and common size.
+func tileSpans(length, n int, overlap float64) ([]int, int) {
+	if n <= 1 || length <= 0 {
+		return []int{0}, length
+	}
+	size := min(int(math.Ceil(float64(length)/(float64(n)-float64(n-1)*overlap))), length)
+	starts := make([]int, n)
+	for i := range starts {
+		starts[i] = (length - size) * i / (n - 1)
+	}
+	return starts, size
+}
+
+// mergeTileDetections removes the duplicates that tiling produces along the
+// seams. A face in an overlap is detected by both tiles, and a face cut by a
+// tile edge yields a partial box inside the larger one, so boxes from
+// different tiles are compared by intersection over the smaller box rather
+// than IoU. Boxes cut by an inner tile edge are considered last, so the
+// complete box of a straddling face wins over its cut half regardless of
+// score. tiles and bounds are in inference coordinates, offset maps them to
+// the boxes' coordinates. IDs are renumbered.
+func mergeTileDetections(dets []Detection, tileOf []int, tiles []image.Rectangle, bounds image.Rectangle, offset image.Point, thresh float64) []Detection {
+	if len(dets) < 2 {
+		return dets
+	}
+	cut := make([]bool, len(dets))
+	for i, d := range dets {
+		cut[i] = touchesSeam(d.BBox, tiles[tileOf[i]].Add(offset), bounds.Add(offset))
+	}
+	order := make([]int, len(dets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		i, j := order[a], order[b]
+		if cut[i] != cut[j] {
+			return !cut[i]
+		}
+		return dets[i].Score > dets[j].Score
+	})
+
+	var kept []int
+	for _, i := range order {
+		dup := false
+		for _, k := range kept {
+			if tileOf[i] != tileOf[k] && overlapSmaller(dets[i].BBox, dets[k].BBox) >= thresh {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			kept = append(kept, i)
+		}
+	}
+	sort.Ints(kept) // back to inference order
+	out := make([]Detection, len(kept))
+	for n, i := range kept {
+		out[n] = dets[i]
+		out[n].ID = n
+	}
+	return out
+}
+
+// touchesSeam reports whether box reaches an edge of tile that isn't also
+// an edge of the frame, i.e. whether the tile may have cut it.
+func touchesSeam(box Rect, tile, frame image.Rectangle) bool {
+	const margin = 2 // px
+	return box.X <= tile.Min.X+margin && tile.Min.X > frame.Min.X ||
+		box.Y <= tile.Min.Y+margin && tile.Min.Y > frame.Min.Y ||
+		box.X+box.Width >= tile.Max.X-margin && tile.Max.X < frame.Max.X ||
+		box.Y+box.Height >= tile.Max.Y-margin && tile.Max.Y < frame.Max.Y
+}
+
+// overlapSmaller is the intersection of a and b over the area of the
+// smaller one.
+func overlapSmaller(a, b Rect) float64 {
+	x1, y1 := max(a.X, b.X), max(a.Y, b.Y)
+	x2, y2 := min(a.X+a.Width, b.X+b.Width), min(a.Y+a.Height, b.Y+b.Height)
+	if x2 <= x1 || y2 <= y1 {
+		return 0
+	}
+	smaller := min(a.Width*a.Height, b.Width*b.Height)
+	if smaller <= 0 {
+		return 0
+	}
+	return float64((x2-x1)*(y2-y1)) / float64(smaller)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestTilingRects(t *testing.T) {
+	tl := &Tiling{Cols: 2, Rows: 1, Overlap: 0.2}
+	want := []image.Rectangle{image.Rect(0, 0, 556, 200), image.Rect(444, 0, 1000, 200)}
+	if got := tl.Rects(image.Rect(0, 0, 1000, 200)); !reflect.DeepEqual(got, want) {
+		t.Errorf("tiles %v, want %v", got, want)
+	}
+}
+
+func TestMergeTileDetections(t *testing.T) {
+	// Two tiles of a 1000x200 frame sharing x 444..556.
+	bounds := image.Rect(0, 0, 1000, 200)
+	tiles := (&Tiling{Cols: 2, Rows: 1, Overlap: 0.2}).Rects(bounds)
+	face := func(x, w int, score float64) Detection {
+		return Detection{BBox: Rect{X: x, Y: 50, Width: w, Height: 60}, Score: score}
+	}
+	tests := []struct {
+		name   string
+		dets   []Detection
+		tileOf []int
+		want   []Detection
+	}{
+		{"inside the overlap, seen twice",
+			[]Detection{face(470, 60, 0.8), face(471, 60, 0.9)}, []int{0, 1},
+			[]Detection{face(471, 60, 0.9)}},
+		{"straddling the seam, cut half scores higher",
+			[]Detection{face(520, 36, 0.95), face(520, 80, 0.8)}, []int{0, 1},
+			[]Detection{face(520, 80, 0.8)}},
+		{"straddling the seam, complete box first",
+			[]Detection{face(520, 80, 0.8), face(520, 36, 0.95)}, []int{1, 0},
+			[]Detection{face(520, 80, 0.8)}},
+		{"neighbours in different tiles",
+			[]Detection{face(380, 60, 0.9), face(460, 60, 0.9)}, []int{0, 1},
+			[]Detection{face(380, 60, 0.9), face(460, 60, 0.9)}},
+		{"overlapping boxes of one tile are left to NMS",
+			[]Detection{face(100, 60, 0.9), face(110, 60, 0.7)}, []int{0, 0},
+			[]Detection{face(100, 60, 0.9), face(110, 60, 0.7)}},
+		{"below the merge threshold",
+			[]Detection{face(470, 60, 0.9), face(505, 60, 0.9)}, []int{0, 1},
+			[]Detection{face(470, 60, 0.9), face(505, 60, 0.9)}},
+	}
+	for _, tt := range tests {
+		got := mergeTileDetections(tt.dets, tt.tileOf, tiles, bounds, image.Point{}, 0.5)
+		for i := range tt.want {
+			tt.want[i].ID = i
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// Same straddling face with the tiles inside an ROI at (100, 30).
+	off := image.Pt(100, 30)
+	cut, whole := face(620, 36, 0.95), face(620, 80, 0.8)
+	cut.BBox.Y += off.Y
+	whole.BBox.Y += off.Y
+	got := mergeTileDetections([]Detection{cut, whole}, []int{0, 1}, tiles, bounds, off, 0.5)
+	if len(got) != 1 || got[0].BBox != whole.BBox {
+		t.Errorf("offset tiles: %+v, want the complete box %v", got, whole.BBox)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+/* ---------------------------------- TLS ------------------------------------ */
+
+// listenAndServe listens on srv.Addr and serves srv there, see serve.
+func listenAndServe(srv *http.Server, cfg ServerConfig) error {
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":http"
+		if cfg.TLSCert != "" || cfg.TLSKey != "" || cfg.TLSSelfSigned {
+			addr = ":https"
+		}
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return serve(srv, ln, cfg)
+}
+
+// serve serves srv on ln over HTTPS when a certificate is configured (or
+// self-signed mode is on) and over plain HTTP otherwise. HTTP/2 is negotiated
+// automatically over TLS. ln is closed on return.
+func serve(srv *http.Server, ln net.Listener, cfg ServerConfig) error {
+	defer ln.Close() // ServeTLS leaves it open when the certificate fails to load
+	switch {
+	case cfg.TLSCert != "" || cfg.TLSKey != "":
+		if cfg.TLSCert == "" || cfg.TLSKey == "" {
+			return errors.New("FACE_TLS_CERT and FACE_TLS_KEY must be set together")
+		}
+		log.Printf("[http] TLS enabled (%s)", cfg.TLSCert)
+		return srv.ServeTLS(ln, cfg.TLSCert, cfg.TLSKey)
+	case cfg.TLSSelfSigned:
+		cert, err := selfSignedCert(time.Now())
+		if err != nil {
+			return err
+		}
+		log.Printf("[http] TLS enabled with a self-signed certificate (testing only)")
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		return srv.ServeTLS(ln, "", "")
+	}
+	return srv.Serve(ln)
+}
+
+// selfSignedCert generates a throwaway certificate for localhost and this
+// host's name, valid for a year from now.
+func selfSignedCert(now time.Time) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "tracking-go self-signed"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if host, err := os.Hostname(); err == nil && host != "localhost" {
+		tmpl.DNSNames = append(tmpl.DNSNames, host)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
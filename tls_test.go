@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a freshly generated certificate and its key as PEM
+// files, and returns them with a pool trusting the certificate.
+func writeCert(t *testing.T) (certFile, keyFile string, roots *x509.CertPool) {
+	t.Helper()
+	cert, err := selfSignedCert(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = x509.NewCertPool()
+	roots.AddCert(leaf)
+	return certFile, keyFile, roots
+}
+
+func TestServeTLSSelection(t *testing.T) {
+	certFile, keyFile, roots := writeCert(t)
+	tests := []struct {
+		name   string
+		cfg    ServerConfig
+		scheme string
+		tls    *tls.Config // client side
+		proto  string
+	}{
+		{"plain", ServerConfig{}, "http", nil, "HTTP/1.1"},
+		{"certificate", ServerConfig{TLSCert: certFile, TLSKey: keyFile}, "https", &tls.Config{RootCAs: roots}, "HTTP/2.0"},
+		{"self-signed", ServerConfig{TLSSelfSigned: true}, "https", &tls.Config{InsecureSkipVerify: true}, "HTTP/2.0"},
+	}
+	for _, tt := range tests {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "ok")
+		})}
+		served := make(chan error, 1)
+		go func() { served <- serve(srv, ln, tt.cfg) }()
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tt.tls, ForceAttemptHTTP2: true}}
+		resp, err := client.Get(tt.scheme + "://" + ln.Addr().String() + "/")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "ok" || resp.Proto != tt.proto || (resp.TLS != nil) != (tt.scheme == "https") {
+			t.Errorf("%s: %q over %s (TLS %v), want ok over %s", tt.name, body, resp.Proto, resp.TLS != nil, tt.proto)
+		}
+		client.CloseIdleConnections()
+
+		// Shutdown ends serve the same way with or without TLS.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Errorf("%s: shutdown: %v", tt.name, err)
+		}
+		cancel()
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("%s: serve returned %v, want ErrServerClosed", tt.name, err)
+		}
+	}
+
+	for _, cfg := range []ServerConfig{{TLSCert: certFile}, {TLSKey: keyFile}, {TLSCert: certFile, TLSKey: certFile}} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := serve(&http.Server{}, ln, cfg); err == nil || errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("cert %q, key %q: %v, want a configuration error", cfg.TLSCert, cfg.TLSKey, err)
+		}
+		if _, err := ln.Accept(); err == nil {
+			t.Errorf("cert %q, key %q: listener left open", cfg.TLSCert, cfg.TLSKey)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+/* --------------------------- Relative thresholds --------------------------- */
+
+// scoreSelection replaces the absolute confidence cutoff with a per-frame
+// one: keep the K best detections, or the best Percent of them. Candidates
+// still have to reach a low floor so the net's background rows don't count.
+type scoreSelection struct {
+	K       int     // top_k mode
+	Percent float64 // top_percent mode, (0, 100]
+	Floor   float32
+}
+
+// parseScoreSelection reads FACE_THRESHOLD_MODE and its parameter. The
+// absolute mode (default) returns nil.
+func parseScoreSelection(mode string, param, floor float64) (*scoreSelection, error) {
+	switch mode {
+	case "", "absolute":
+		return nil, nil
+	case "top_k":
+		if param < 1 || param != math.Trunc(param) {
+			return nil, fmt.Errorf("top_k wants a whole number of detections >= 1, got %g", param)
+		}
+		return &scoreSelection{K: int(param), Floor: float32(floor)}, nil
+	case "top_percent":
+		if param <= 0 || param > 100 {
+			return nil, fmt.Errorf("top_percent wants a percentage in (0, 100], got %g", param)
+		}
+		return &scoreSelection{Percent: param, Floor: float32(floor)}, nil
+	}
+	return nil, fmt.Errorf("unknown mode %q (want absolute, top_k or top_percent)", mode)
+}
+
+// apply keeps the best-scored detections of one frame, in their original
+// order. Ties at the cut are broken by order of appearance.
+func (s *scoreSelection) apply(dets []Detection) []Detection {
+	if s == nil || len(dets) == 0 {
+		return dets
+	}
+	n := s.K
+	if s.Percent > 0 {
+		n = int(math.Ceil(float64(len(dets)) * s.Percent / 100))
+	}
+	if n >= len(dets) {
+		return dets
+	}
+	idx := make([]int, len(dets))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return dets[idx[a]].Score > dets[idx[b]].Score })
+	idx = idx[:n]
+	sort.Ints(idx)
+	out := make([]Detection, n)
+	for i, j := range idx {
+		out[i] = dets[j]
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScoreSelectionApply(t *testing.T) {
+	dets := func(scores ...float64) []Detection {
+		out := make([]Detection, len(scores))
+		for i, s := range scores {
+			out[i] = Detection{ID: i + 1, Score: s}
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		sel  *scoreSelection
+		in   []Detection
+		want string // IDs kept, in order
+	}{
+		{"absolute mode", nil, dets(0.9, 0.1), "[1 2]"},
+		{"empty frame", &scoreSelection{K: 2}, nil, "[]"},
+		{"top 2, original order kept", &scoreSelection{K: 2}, dets(0.5, 0.9, 0.3, 0.7), "[2 4]"},
+		{"top 1", &scoreSelection{K: 1}, dets(0.5, 0.9, 0.3), "[2]"},
+		{"K above count", &scoreSelection{K: 5}, dets(0.5, 0.9), "[1 2]"},
+		{"K equals count", &scoreSelection{K: 2}, dets(0.5, 0.9), "[1 2]"},
+		{"tie at the cut, first wins", &scoreSelection{K: 2}, dets(0.6, 0.8, 0.6, 0.6), "[1 2]"},
+		{"all tied", &scoreSelection{K: 2}, dets(0.5, 0.5, 0.5), "[1 2]"},
+		{"50 percent of 4", &scoreSelection{Percent: 50}, dets(0.1, 0.4, 0.3, 0.2), "[2 3]"},
+		{"percent rounds up", &scoreSelection{Percent: 30}, dets(0.1, 0.4, 0.3, 0.2), "[2 3]"},
+		{"tiny percent keeps one", &scoreSelection{Percent: 1}, dets(0.1, 0.4, 0.3), "[2]"},
+		{"100 percent", &scoreSelection{Percent: 100}, dets(0.1, 0.4), "[1 2]"},
+	}
+	for _, tt := range tests {
+		var ids []int
+		for _, d := range tt.sel.apply(tt.in) {
+			ids = append(ids, d.ID)
+		}
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("%s: kept %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseScoreSelection(t *testing.T) {
+	tests := []struct {
+		mode  string
+		param float64
+		want  *scoreSelection // nil with ok = absolute
+		ok    bool
+	}{
+		{"", 0, nil, true},
+		{"absolute", 3, nil, true},
+		{"top_k", 3, &scoreSelection{K: 3, Floor: 0.1}, true},
+		{"top_k", 0, nil, false},
+		{"top_k", 2.5, nil, false},
+		{"top_percent", 25, &scoreSelection{Percent: 25, Floor: 0.1}, true},
+		{"top_percent", 100, &scoreSelection{Percent: 100, Floor: 0.1}, true},
+		{"top_percent", 0, nil, false},
+		{"top_percent", 101, nil, false},
+		{"best", 1, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := parseScoreSelection(tt.mode, tt.param, 0.1)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseScoreSelection(%q, %g) error = %v, want ok=%v", tt.mode, tt.param, err, tt.ok)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("parseScoreSelection(%q, %g) = %+v, want %+v", tt.mode, tt.param, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+/* ------------------------------- Tracking ---------------------------------- */
+
+// Tracker gives detections stable IDs across frames by greedily matching
+// each new box to the live track it overlaps most (IoU). A track survives up
+// to maxMissed consecutive frames without a match before it is retired.
+// With smoothing, the reported boxes are an exponential moving average of
+// the matched ones, which steadies the jitter of the detector. With
+// prediction, a track missed for a few frames is still reported, its box
+// extrapolated from its last velocity and flagged Predicted.
+type Tracker struct {
+	minIoU    float64
+	maxMissed int
+	smooth    float64 // EMA weight of the new box, 0 = off
+	predict   int     // missed frames reported as predicted boxes, 0 = off
+	nextID    int
+	tracks    []*track
+
+	stableAfter time.Duration // still for this long makes a track stable, 0 = off
+	stableMove  float64       // movement resetting stability, in box sizes
+	zones       []Zone        // zone_enter/zone_exit events, nil = off
+	zoneFrames  int           // frames a zone change must hold before it counts
+	events      []TrackEvent  // of the last Update
+}
+
+type track struct {
+	id       int
+	uuid     string // globally unique, new for every appearance
+	box      Rect   // last matched box, as detected
+	smoothed [4]float64
+	since    time.Time // timestamp of the first detection
+	missed   int
+	hits     int     // consecutive frames matched
+	iou      float64 // moving average of the match IoU
+
+	last     Detection     // last matched, as reported
+	vel      [4]float64    // box change per frame between the last two matches
+	frameDur time.Duration // time per frame between the last two matches
+	moving   bool          // vel is known (matched at least twice)
+	speed    [2]float64    // smoothed velocity of the box center, px/s
+
+	anchor      Rect      // box where the track last came to rest
+	stableSince time.Time // zero until the next match sets the anchor
+	stableSent  bool      // the stable event was emitted
+
+	inZone    map[string]bool // zones the track is in, as last reported; nil until first seen
+	zoneFlips map[string]int  // consecutive frames seen on the other side of a zone's border
+}
+
+// TrackEvent is a change of a track worth acting upon, carried by the
+// snapshot of the frame it happened in.
+type TrackEvent struct {
+	Type      string    `json:"type"` // "stable", "zone_enter" or "zone_exit"
+	ID        int       `json:"id"`
+	UUID      string    `json:"uuid"`
+	BBox      Rect      `json:"bbox"`
+	Timestamp time.Time `json:"ts"`
+	StableMs  int64     `json:"stable_ms,omitempty"` // stable only
+	Zone      string    `json:"zone,omitempty"`      // zone_enter and zone_exit only
+}
+
+// velocitySmooth is the EMA weight of each new measure in the reported
+// velocity, which starts from the first measure: light, so a change of
+// motion shows within a few frames while the detector's jitter is damped.
+const velocitySmooth = 0.5
+
+// NewTracker returns a tracker; minIoU defaults to 0.3 and maxMissed to 5.
+// smooth in (0, 1] is the weight of each new box in the reported one; 0
+// reports boxes as detected.
+func NewTracker(minIoU float64, maxMissed int, smooth float64) *Tracker {
+	if minIoU <= 0 {
+		minIoU = 0.3
+	}
+	if maxMissed < 0 {
+		maxMissed = 5
+	}
+	return &Tracker{minIoU: minIoU, maxMissed: maxMissed, smooth: min(max(smooth, 0), 1), nextID: 1}
+}
+
+// SetStability enables StableMs and the stable events: a track is stable
+// once its box stayed within move box sizes (0.1 by default) of where it
+// came to rest for after. Moving further or being re-acquired after a miss
+// restarts the count.
+func (t *Tracker) SetStability(after time.Duration, move float64) {
+	if move <= 0 {
+		move = 0.1
+	}
+	t.stableAfter, t.stableMove = after, move
+}
+
+// SetPrediction reports each track missed for up to frames consecutive
+// frames (at most the tracker's maxMissed, after which it is retired) with
+// a constant-velocity prediction of its box. Missed tracks are matched at
+// their predicted position too, so a face moving across a gap keeps its ID.
+func (t *Tracker) SetPrediction(frames int) {
+	t.predict = max(frames, 0)
+}
+
+// SetZones enables the zone_enter and zone_exit events: a track crossing
+// the border of a zone (by its box center) is reported once it has stayed
+// on the other side for frames consecutive frames (1 by default), so
+// jitter along the border doesn't flap. A track is in its zones from its
+// first frame, and leaves them all when it is retired.
+func (t *Tracker) SetZones(zones []Zone, frames int) {
+	t.zones, t.zoneFrames = zones, max(frames, 1)
+}
+
+// Events returns the events of the last Update; a nil tracker has none.
+func (t *Tracker) Events() []TrackEvent {
+	if t == nil {
+		return nil
+	}
+	return t.events
+}
+
+// Update matches dets against the live tracks and returns them with ID set
+// to the stable track ID, UUID to the track's unique token, Color to the
+// track's color, DwellS to the time since the track appeared and
+// TrackConfidence to how sure the match is, Velocity to the smoothed speed
+// of the box center (zero on a new track), and the box smoothed if
+// enabled. Matching uses the boxes as detected. dets is modified in place,
+// then followed by the predicted boxes of the missed tracks if enabled.
+//
+// The confidence is the average IoU of the matches weighted by the run of
+// consecutive matches, hits/(hits+2): 0 for a new track, a third of the
+// overlap when it is first continued or re-acquired after a miss, and
+// toward the typical overlap (~0.9) on a stable, well-matched track.
+func (t *Tracker) Update(dets []Detection) []Detection {
+	type pair struct {
+		ti, di int
+		iou    float64
+	}
+	t.events = nil
+	var pairs []pair
+	for ti, tr := range t.tracks {
+		for di := range dets {
+			if iou := rectIoU(t.expected(tr), dets[di].BBox); iou >= t.minIoU {
+				pairs = append(pairs, pair{ti, di, iou})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].iou > pairs[j].iou })
+
+	trackUsed := make([]bool, len(t.tracks))
+	detTrack := make([]*track, len(dets))
+	for _, p := range pairs {
+		if trackUsed[p.ti] || detTrack[p.di] != nil {
+			continue
+		}
+		trackUsed[p.ti] = true
+		detTrack[p.di] = t.tracks[p.ti]
+		tr := t.tracks[p.ti]
+		if tr.missed > 0 || tr.hits == 0 {
+			tr.hits, tr.iou = 0, p.iou
+		}
+		if tr.missed > 0 {
+			tr.stableSince = time.Time{}
+		}
+		tr.hits++
+		tr.iou += 0.5 * (p.iou - tr.iou)
+	}
+
+	// Age unmatched tracks, retire stale ones.
+	live := t.tracks[:0]
+	for ti, tr := range t.tracks {
+		if !trackUsed[ti] {
+			tr.missed++
+			if tr.missed > t.maxMissed {
+				t.exitZones(tr)
+				continue
+			}
+		}
+		live = append(live, tr)
+	}
+	t.tracks = live
+
+	for di := range dets {
+		tr := detTrack[di]
+		b := dets[di].BBox
+		raw := [4]float64{float64(b.X), float64(b.Y), float64(b.Width), float64(b.Height)}
+		continued := tr != nil
+		if tr == nil {
+			tr = &track{id: t.nextID, uuid: newUUID(), smoothed: raw, since: dets[di].Timestamp}
+			t.nextID++
+			t.tracks = append(t.tracks, tr)
+		}
+		if continued { // the velocity is over the frames since the last match
+			gap := tr.missed + 1
+			for i, v := range [4]int{b.X - tr.box.X, b.Y - tr.box.Y, b.Width - tr.box.Width, b.Height - tr.box.Height} {
+				tr.vel[i] = float64(v) / float64(gap)
+			}
+			dt := dets[di].Timestamp.Sub(tr.last.Timestamp)
+			tr.frameDur = dt / time.Duration(gap)
+			tr.moving = true
+			if dt > 0 {
+				// Center shift over the time between the two detections
+				vx := float64(2*(b.X-tr.box.X)+b.Width-tr.box.Width) / 2 / dt.Seconds()
+				vy := float64(2*(b.Y-tr.box.Y)+b.Height-tr.box.Height) / 2 / dt.Seconds()
+				if tr.hits == 1 { // first measure since the track (re)appeared
+					tr.speed = [2]float64{vx, vy}
+				} else {
+					tr.speed[0] += velocitySmooth * (vx - tr.speed[0])
+					tr.speed[1] += velocitySmooth * (vy - tr.speed[1])
+				}
+			}
+		}
+		tr.box, tr.missed = b, 0
+		if t.smooth > 0 {
+			for i := range raw {
+				tr.smoothed[i] += t.smooth * (raw[i] - tr.smoothed[i])
+			}
+			dets[di].BBox = Rect{
+				X: int(math.Round(tr.smoothed[0])), Y: int(math.Round(tr.smoothed[1])),
+				Width: int(math.Round(tr.smoothed[2])), Height: int(math.Round(tr.smoothed[3])),
+			}
+		}
+		dets[di].ID = tr.id
+		dets[di].UUID = tr.uuid
+		dets[di].Color = colorHex(trackColor(tr.id))
+		dets[di].DwellS = math.Round(dets[di].Timestamp.Sub(tr.since).Seconds()*10) / 10
+		dets[di].TrackConfidence = math.Round(tr.iou*float64(tr.hits)/float64(tr.hits+2)*1000) / 1000
+		dets[di].Velocity = &Velocity{VX: math.Round(tr.speed[0]*10) / 10, VY: math.Round(tr.speed[1]*10) / 10}
+		if t.stableAfter > 0 {
+			t.observeStability(tr, &dets[di], b)
+		}
+		tr.last = dets[di]
+		if t.zones != nil {
+			t.observeZones(tr, dets[di])
+		}
+	}
+
+	for _, tr := range t.tracks {
+		if tr.missed > 0 && tr.missed <= t.predict && tr.moving {
+			d := tr.predicted()
+			if t.zones != nil {
+				t.observeZones(tr, d)
+			}
+			dets = append(dets, d)
+		}
+	}
+	return dets
+}
+
+// observeZones updates the zones tr is in from its reported detection d,
+// and records the enter and exit events. A missed track, unless predicted,
+// keeps its zones until it is retired.
+func (t *Tracker) observeZones(tr *track, d Detection) {
+	center := Point{X: d.BBox.X + d.BBox.Width/2, Y: d.BBox.Y + d.BBox.Height/2}
+	first := tr.inZone == nil
+	if first {
+		tr.inZone, tr.zoneFlips = make(map[string]bool), make(map[string]int)
+	}
+	for _, z := range t.zones {
+		in := z.Contains(center)
+		if in == tr.inZone[z.Name] {
+			tr.zoneFlips[z.Name] = 0
+			continue
+		}
+		if tr.zoneFlips[z.Name]++; !first && tr.zoneFlips[z.Name] < t.zoneFrames {
+			continue
+		}
+		tr.inZone[z.Name], tr.zoneFlips[z.Name] = in, 0
+		typ := "zone_exit"
+		if in {
+			typ = "zone_enter"
+		}
+		t.events = append(t.events, TrackEvent{Type: typ, ID: tr.id, UUID: tr.uuid, BBox: d.BBox, Timestamp: d.Timestamp, Zone: z.Name})
+	}
+}
+
+// exitZones records the exit of a retired track from the zones it was in,
+// as of its last detection.
+func (t *Tracker) exitZones(tr *track) {
+	for _, z := range t.zones {
+		if tr.inZone[z.Name] {
+			t.events = append(t.events, TrackEvent{Type: "zone_exit", ID: tr.id, UUID: tr.uuid, BBox: tr.last.BBox, Timestamp: tr.last.Timestamp, Zone: z.Name})
+		}
+	}
+}
+
+// expected is where tr should be in the current frame: its last box, or the
+// predicted one while it is missed and prediction is on.
+func (t *Tracker) expected(tr *track) Rect {
+	if t.predict == 0 || tr.missed == 0 || !tr.moving {
+		return tr.box
+	}
+	return tr.extrapolate(tr.missed)
+}
+
+// extrapolate moves the last detected box n frames ahead at tr's velocity.
+func (tr *track) extrapolate(n int) Rect {
+	b, f := tr.box, float64(n)
+	return Rect{
+		X:      int(math.Round(float64(b.X) + tr.vel[0]*f)),
+		Y:      int(math.Round(float64(b.Y) + tr.vel[1]*f)),
+		Width:  max(int(math.Round(float64(b.Width)+tr.vel[2]*f)), 1),
+		Height: max(int(math.Round(float64(b.Height)+tr.vel[3]*f)), 1),
+	}
+}
+
+// predicted is the detection reported for tr after tr.missed misses: the
+// last one, moved at constant velocity and dated a frame duration later per
+// miss. Predictions don't move the smoothed box.
+func (tr *track) predicted() Detection {
+	d := tr.last
+	d.BBox = tr.extrapolate(tr.missed)
+	d.Predicted = true
+	d.Timestamp = d.Timestamp.Add(time.Duration(tr.missed) * tr.frameDur)
+	d.DwellS = math.Round(d.Timestamp.Sub(tr.since).Seconds()*10) / 10
+	d.TrackConfidence = 0
+	return d
+}
+
+// observeStability sets d.StableMs from the detected box b, and records the
+// stable event the first time the track crosses the threshold.
+func (t *Tracker) observeStability(tr *track, d *Detection, b Rect) {
+	limit := t.stableMove * float64(max(tr.anchor.Width, tr.anchor.Height))
+	if tr.stableSince.IsZero() || float64(boxShift(tr.anchor, b)) > limit {
+		tr.anchor, tr.stableSince = b, d.Timestamp
+	}
+	still := d.Timestamp.Sub(tr.stableSince)
+	d.StableMs = still.Milliseconds()
+	if still >= t.stableAfter && !tr.stableSent {
+		tr.stableSent = true
+		t.events = append(t.events, TrackEvent{Type: "stable", ID: tr.id, UUID: tr.uuid, BBox: d.BBox, Timestamp: d.Timestamp, StableMs: d.StableMs})
+	}
+}
+
+// trackFaces runs dets through tracker, if any. raw is a copy of dets as
+// detected, before tracking and smoothing; nil without a tracker, since
+// nothing changes them then.
+func trackFaces(tracker *Tracker, dets []Detection) (faces, raw []Detection) {
+	if tracker == nil {
+		return dets, nil
+	}
+	raw = slices.Clone(dets)
+	return tracker.Update(dets), raw
+}
+
+// rectIoU is the intersection-over-union of two boxes.
+func rectIoU(a, b Rect) float64 {
+	x1, y1 := max(a.X, b.X), max(a.Y, b.Y)
+	x2, y2 := min(a.X+a.Width, b.X+b.Width), min(a.Y+a.Height, b.Y+b.Height)
+	if x2 <= x1 || y2 <= y1 {
+		return 0
+	}
+	inter := float64((x2 - x1) * (y2 - y1))
+	union := float64(a.Width*a.Height+b.Width*b.Height) - inter
+	if union <= 0 {
+		return 0
+	}
+	return inter / union
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+/* ------------------------------- ID colors --------------------------------- */
+
+// trackColor derives a deterministic, well-saturated color from a track ID:
+// the ID is hashed (splitmix64) to a hue plus small saturation/value jitter,
+// then converted from HSV. Neighbouring IDs get unrelated hues.
+func trackColor(id int) color.RGBA {
+	h := uint64(id) + 0x9e3779b97f4a7c15
+	h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+	h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+	h ^= h >> 31
+
+	hue := float64(h%3600) / 10           // [0, 360)
+	sat := 0.65 + float64((h>>16)%30)/100 // [0.65, 0.95)
+	val := 0.80 + float64((h>>32)%20)/100 // [0.80, 1.00)
+	return hsvToRGBA(hue, sat, val)
+}
+
+func hsvToRGBA(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	to8 := func(f float64) uint8 { return uint8(math.Round((f + m) * 255)) }
+	return color.RGBA{R: to8(r), G: to8(g), B: to8(b), A: 255}
+}
+
+func colorHex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
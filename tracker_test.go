@@ -0,0 +1,336 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrackerPredictionAccuracy(t *testing.T) {
+	// A face moving 8 px right and 2 px down per frame, 100 ms apart.
+	truth := func(i int) Rect { return Rect{X: 10 + 8*i, Y: 50 + 2*i, Width: 40, Height: 40} }
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	missed := map[int]bool{3: true, 4: true, 8: true, 12: true, 13: true, 14: true}
+
+	tr := NewTracker(0.3, 5, 0)
+	tr.SetPrediction(2)
+	for i := range 16 {
+		var dets []Detection
+		if !missed[i] {
+			dets = []Detection{{BBox: truth(i), Timestamp: t0.Add(time.Duration(i) * 100 * time.Millisecond)}}
+		}
+		out := tr.Update(dets)
+
+		wantPredicted := missed[i] && i != 14 // the third miss in a row isn't predicted
+		if !missed[i] || wantPredicted {
+			if len(out) != 1 {
+				t.Fatalf("frame %d: %d detections, want 1", i, len(out))
+			}
+			d := out[0]
+			if d.ID != 1 {
+				t.Fatalf("frame %d: ID %d, want 1 across the gaps", i, d.ID)
+			}
+			if d.Predicted != wantPredicted {
+				t.Fatalf("frame %d: predicted = %v, want %v", i, d.Predicted, wantPredicted)
+			}
+			if d.BBox != truth(i) {
+				t.Fatalf("frame %d: box %+v, want %+v", i, d.BBox, truth(i))
+			}
+			if want := t0.Add(time.Duration(i) * 100 * time.Millisecond); !d.Timestamp.Equal(want) {
+				t.Fatalf("frame %d: ts %v, want %v", i, d.Timestamp, want)
+			}
+		} else if len(out) != 0 {
+			t.Fatalf("frame %d: %+v, want nothing past the prediction window", i, out)
+		}
+	}
+}
+
+func TestFaceCountSkipsPredicted(t *testing.T) {
+	snap := Snapshot{Detections: []Detection{
+		{ID: 1},
+		{ID: 2, Count: 3},
+		{ID: 4, Predicted: true},
+	}}
+	if n := faceCount(snap); n != 4 {
+		t.Fatalf("faceCount = %d, want 4", n)
+	}
+
+	zones := []Zone{{Name: "all", Points: []Point{{0, 0}, {100, 0}, {100, 100}, {0, 100}}}}
+	dets := []Detection{{BBox: Rect{10, 10, 10, 10}}, {BBox: Rect{20, 20, 10, 10}, Predicted: true}}
+	counts := assignZones(dets, zones)
+	if counts[0].Count != 1 || dets[1].Zone != "all" {
+		t.Fatalf("zone count %d, predicted zone %q; want 1 and \"all\"", counts[0].Count, dets[1].Zone)
+	}
+}
+
+func TestTrackerVelocity(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(box func(i int) Rect) []Velocity {
+		tr := NewTracker(0.3, 5, 0)
+		var out []Velocity
+		for i := range 6 {
+			dets := tr.Update([]Detection{{BBox: box(i), Timestamp: t0.Add(time.Duration(i) * 100 * time.Millisecond)}})
+			out = append(out, *dets[0].Velocity)
+		}
+		return out
+	}
+
+	// 8 px right and 2 px down every 100 ms, growing 2 px: the center moves
+	// 9 px and 3 px.
+	moving := run(func(i int) Rect { return Rect{X: 10 + 8*i, Y: 50 + 2*i, Width: 40 + 2*i, Height: 40 + 2*i} })
+	if moving[0] != (Velocity{}) {
+		t.Fatalf("new track velocity %+v, want zero", moving[0])
+	}
+	for i, v := range moving[1:] {
+		if v != (Velocity{VX: 90, VY: 30}) {
+			t.Fatalf("frame %d: velocity %+v, want {90 30} from the first measure", i+1, v)
+		}
+	}
+
+	still := run(func(int) Rect { return Rect{X: 100, Y: 100, Width: 40, Height: 40} })
+	for i, v := range still {
+		if v != (Velocity{}) {
+			t.Fatalf("frame %d: stationary track velocity %+v, want zero", i, v)
+		}
+	}
+}
+
+func TestTrackerZoneEvents(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	door := Zone{Name: "door", Points: []Point{{X: 100, Y: 0}, {X: 200, Y: 0}, {X: 200, Y: 200}, {X: 100, Y: 200}}}
+	// run moves a 40x40 face so its center is at x = centers[i] (-1: not
+	// detected), and returns the zone events as "frame:enter|exit".
+	run := func(debounce int, centers ...int) []string {
+		tr := NewTracker(0.3, 1, 0)
+		tr.SetZones([]Zone{door}, debounce)
+		var got []string
+		for i, c := range centers {
+			var dets []Detection
+			if c >= 0 {
+				dets = []Detection{{BBox: Rect{X: c - 20, Y: 80, Width: 40, Height: 40}, Timestamp: t0.Add(time.Duration(i) * 100 * time.Millisecond)}}
+			}
+			tr.Update(dets)
+			for _, ev := range tr.Events() {
+				if ev.Zone == "door" {
+					got = append(got, strconv.Itoa(i)+":"+strings.TrimPrefix(ev.Type, "zone_"))
+				}
+			}
+		}
+		return got
+	}
+	tests := []struct {
+		name     string
+		debounce int
+		centers  []int
+		want     []string
+	}{
+		{"outside", 2, []int{50, 60, 70, 60}, nil},
+		{"enter", 1, []int{80, 90, 100, 110, 120}, []string{"2:enter"}},
+		{"enter debounced", 2, []int{80, 90, 100, 110, 120}, []string{"3:enter"}},
+		{"enter and exit", 2, []int{90, 100, 110, 100, 90, 80, 70}, []string{"2:enter", "5:exit"}},
+		{"jitter", 2, []int{90, 100, 95, 105, 95, 105, 95}, nil},
+		{"jitter without debounce", 1, []int{95, 105, 95}, []string{"1:enter", "2:exit"}},
+		{"in from the first frame", 3, []int{150, 150, 150}, []string{"0:enter"}},
+		{"retired inside", 2, []int{150, 150, -1, -1, -1}, []string{"0:enter", "3:exit"}},
+	}
+	for _, tt := range tests {
+		if got := run(tt.debounce, tt.centers...); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: events %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrackerStability(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// run feeds a 40x40 face at x = xs[i] (-1: not detected), 100 ms apart,
+	// and returns its StableMs per detected frame and the frames of its
+	// stable events.
+	run := func(xs ...int) (stableMs []int64, events []int) {
+		tr := NewTracker(0.3, 5, 0)
+		tr.SetStability(500*time.Millisecond, 0.1) // within 4 px
+		for i, x := range xs {
+			var dets []Detection
+			if x >= 0 {
+				dets = []Detection{{BBox: Rect{X: x, Y: 100, Width: 40, Height: 40}, Timestamp: t0.Add(time.Duration(i) * 100 * time.Millisecond)}}
+			}
+			for _, d := range tr.Update(dets) {
+				if d.Timestamp.Equal(dets[0].Timestamp) {
+					stableMs = append(stableMs, d.StableMs)
+				}
+			}
+			for _, ev := range tr.Events() {
+				if ev.Type == "stable" {
+					events = append(events, i)
+				}
+			}
+		}
+		return stableMs, events
+	}
+	tests := []struct {
+		name     string
+		xs       []int
+		stableMs []int64
+		events   []int
+	}{
+		{"still", []int{100, 102, 99, 101, 98, 100, 102, 100}, []int64{0, 100, 200, 300, 400, 500, 600, 700}, []int{5}},
+		{"jittery", []int{100, 108, 100, 108, 100, 108, 100, 108}, []int64{0, 0, 0, 0, 0, 0, 0, 0}, nil},
+		{"moved then still", []int{100, 101, 120, 121, 120, 119, 120, 121, 120}, []int64{0, 100, 0, 100, 200, 300, 400, 500, 600}, []int{7}},
+		{"re-acquired", []int{100, 100, 100, -1, 100, 100, 100, 100, 100, 100}, []int64{0, 100, 200, 0, 100, 200, 300, 400, 500}, []int{9}},
+	}
+	for _, tt := range tests {
+		stableMs, events := run(tt.xs...)
+		if !slices.Equal(stableMs, tt.stableMs) || !slices.Equal(events, tt.events) {
+			t.Errorf("%s: stable_ms %v, events at %v; want %v, %v", tt.name, stableMs, events, tt.stableMs, tt.events)
+		}
+	}
+}
+
+func TestTrackerConfidence(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	box := Rect{X: 100, Y: 100, Width: 40, Height: 40}
+	tr := NewTracker(0.3, 5, 0)
+	conf := func(i int, b Rect) float64 {
+		dets := tr.Update([]Detection{{BBox: b, Timestamp: t0.Add(time.Duration(i) * 100 * time.Millisecond)}})
+		return dets[0].TrackConfidence
+	}
+
+	// A clean continuous track: from 0 when new toward the overlap.
+	var continuous []float64
+	for i := range 10 {
+		continuous = append(continuous, conf(i, box))
+	}
+	want := []float64{0, 0.333, 0.5, 0.6, 0.667, 0.714, 0.75, 0.778, 0.8, 0.818}
+	if !slices.Equal(continuous, want) {
+		t.Errorf("continuous track confidence %v, want %v", continuous, want)
+	}
+
+	// Missed once, then re-acquired: low again.
+	tr.Update(nil)
+	if c := conf(11, box); c != 0.333 {
+		t.Errorf("re-acquired track confidence %v, want 0.333", c)
+	}
+
+	// A loose match (IoU 0.6) climbs slower than a clean one.
+	loose := NewTracker(0.3, 5, 0)
+	var c float64
+	for i := range 10 {
+		b := box
+		b.X += 10 * (i % 2)
+		c = loose.Update([]Detection{{BBox: b, Timestamp: t0.Add(time.Duration(i) * 100 * time.Millisecond)}})[0].TrackConfidence
+	}
+	if c >= continuous[9] || c < 0.4 {
+		t.Errorf("loosely matched track confidence %v, want between 0.4 and %v", c, continuous[9])
+	}
+}
+
+func TestTrackerUUID(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	left, right := Rect{X: 10, Y: 10, Width: 40, Height: 40}, Rect{X: 200, Y: 10, Width: 40, Height: 40}
+	v4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	tr := NewTracker(0.3, 1, 0)
+	uuids := map[int]string{} // by track ID
+	frame := func(i int, boxes ...Rect) []Detection {
+		var dets []Detection
+		for _, b := range boxes {
+			dets = append(dets, Detection{BBox: b, Timestamp: t0.Add(time.Duration(i) * 100 * time.Millisecond)})
+		}
+		return tr.Update(dets)
+	}
+
+	for i := range 5 {
+		for _, d := range frame(i, left, right) {
+			if !v4.MatchString(d.UUID) {
+				t.Fatalf("UUID %q is not a version 4 UUID", d.UUID)
+			}
+			if prev, ok := uuids[d.ID]; ok && prev != d.UUID {
+				t.Fatalf("frame %d: track %d UUID changed from %s to %s", i, d.ID, prev, d.UUID)
+			}
+			uuids[d.ID] = d.UUID
+		}
+	}
+	if len(uuids) != 2 || uuids[1] == uuids[2] {
+		t.Fatalf("UUIDs %v, want two distinct", uuids)
+	}
+
+	// The left face leaves until its track is retired, then comes back: a
+	// new appearance, a new UUID.
+	frame(5, right)
+	frame(6, right)
+	back := frame(7, left, right)
+	if back[0].ID == 1 || back[0].UUID == uuids[1] || back[0].UUID == uuids[2] || back[1].UUID != uuids[2] {
+		t.Errorf("after re-entry: %d %s and %d %s, before %v", back[0].ID, back[0].UUID, back[1].ID, back[1].UUID, uuids)
+	}
+	if name := cropName(7, back[1], defaultJPEG); name != "7-"+uuids[2]+".jpg" {
+		t.Errorf("crop name %q, want the track UUID", name)
+	}
+}
+
+func TestTrackColor(t *testing.T) {
+	primaries := []struct {
+		h    float64
+		want color.RGBA
+	}{
+		{0, color.RGBA{R: 255, A: 255}},
+		{120, color.RGBA{G: 255, A: 255}},
+		{240, color.RGBA{B: 255, A: 255}},
+		{60, color.RGBA{R: 255, G: 255, A: 255}},
+	}
+	for _, p := range primaries {
+		if got := hsvToRGBA(p.h, 1, 1); got != p.want {
+			t.Errorf("hue %v: %v, want %v", p.h, got, p.want)
+		}
+	}
+
+	// Colors far apart enough to tell two boxes apart on screen.
+	dist := func(a, b color.RGBA) float64 {
+		dr, dg, db := float64(a.R)-float64(b.R), float64(a.G)-float64(b.G), float64(a.B)-float64(b.B)
+		return math.Sqrt(dr*dr + dg*dg + db*db)
+	}
+	const ids, close = 1000, 40.0
+	seen := map[string]int{}
+	var collisions, neighbours int
+	for id := 1; id <= ids; id++ {
+		c := trackColor(id)
+		if trackColor(id) != c {
+			t.Fatalf("track %d: color not deterministic", id)
+		}
+		if c.A != 255 || max(c.R, c.G, c.B)-min(c.R, c.G, c.B) < 100 {
+			t.Errorf("track %d: %v is not a well-saturated opaque color", id, c)
+		}
+		if _, ok := seen[colorHex(c)]; ok {
+			collisions++
+		}
+		seen[colorHex(c)] = id
+		if dist(c, trackColor(id+1)) < close {
+			neighbours++
+		}
+	}
+	if collisions > ids/100 || neighbours > ids/10 {
+		t.Errorf("%d identical colors and %d close consecutive IDs out of %d", collisions, neighbours, ids)
+	}
+
+	// The tracker tags each box with its track's color, which the overlay
+	// draws from the same ID.
+	tr := NewTracker(0.3, 1, 0)
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		ts := t0.Add(time.Duration(i) * 100 * time.Millisecond)
+		dets := tr.Update([]Detection{
+			{BBox: Rect{X: 10, Y: 10, Width: 40, Height: 40}, Timestamp: ts},
+			{BBox: Rect{X: 200, Y: 10, Width: 40, Height: 40}, Timestamp: ts},
+		})
+		for _, d := range dets {
+			if d.Color != colorHex(trackColor(d.ID)) {
+				t.Errorf("frame %d: track %d color %q, want %q", i, d.ID, d.Color, colorHex(trackColor(d.ID)))
+			}
+		}
+		if dets[0].Color == dets[1].Color {
+			t.Errorf("frame %d: both tracks are %s", i, dets[0].Color)
+		}
+	}
+}
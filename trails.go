@@ -0,0 +1,101 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"gocv.io/x/gocv"
+)
+
+/* -------------------------------- Trails ----------------------------------- */
+
+// Trails keeps the recent box centers of each tracked face so the preview can
+// draw where it has been. A trail is dropped as soon as its track is absent
+// from a snapshot, so retired tracks never linger on screen.
+type Trails struct {
+	length int     // centers kept per track
+	fade   float64 // brightness of the oldest segment, 0..1 (1 = no fade)
+	points map[int][]image.Point
+}
+
+// NewTrails returns trails of up to length centers; length < 2 disables them.
+func NewTrails(length int, fade float64) *Trails {
+	if length < 2 {
+		return nil
+	}
+	return &Trails{length: length, fade: min(max(fade, 0), 1), points: make(map[int][]image.Point)}
+}
+
+// Observe appends the center of each tracked detection to its trail.
+// Untracked detections (no color) have no stable ID and are ignored.
+func (t *Trails) Observe(dets []Detection) {
+	seen := make(map[int]bool, len(dets))
+	for _, d := range dets {
+		if d.Color == "" {
+			continue
+		}
+		seen[d.ID] = true
+		pts := t.points[d.ID]
+		if len(pts) == t.length {
+			copy(pts, pts[1:])
+			pts = pts[:len(pts)-1]
+		}
+		t.points[d.ID] = append(pts, image.Pt(d.BBox.X+d.BBox.Width/2, d.BBox.Y+d.BBox.Height/2))
+	}
+	for id := range t.points {
+		if !seen[id] {
+			delete(t.points, id)
+		}
+	}
+}
+
+// clone returns a copy safe to draw from outside the preview lock.
+func (t *Trails) clone() map[int][]image.Point {
+	out := make(map[int][]image.Point, len(t.points))
+	for id, pts := range t.points {
+		out[id] = append([]image.Point(nil), pts...)
+	}
+	return out
+}
+
+// trailSegment is one line of a trail polyline.
+type trailSegment struct {
+	From, To  image.Point
+	Color     color.RGBA
+	Thickness int
+}
+
+// trailSegments converts a trail (oldest center first) into line segments
+// that fade from c scaled by fade at the tail to c at the head, getting
+// thicker towards the head. Fading darkens rather than blends: real alpha
+// would need a full-frame blend per segment.
+func trailSegments(pts []image.Point, c color.RGBA, fade float64) []trailSegment {
+	if len(pts) < 2 {
+		return nil
+	}
+	segs := make([]trailSegment, 0, len(pts)-1)
+	last := float64(len(pts) - 2)
+	for i := 0; i < len(pts)-1; i++ {
+		w := 1.0
+		if last > 0 {
+			w = fade + (1-fade)*float64(i)/last
+		}
+		scale := func(v uint8) uint8 { return uint8(float64(v)*w + 0.5) }
+		segs = append(segs, trailSegment{
+			From:      pts[i],
+			To:        pts[i+1],
+			Color:     color.RGBA{R: scale(c.R), G: scale(c.G), B: scale(c.B), A: 255},
+			Thickness: 1 + int(w*2+0.5),
+		})
+	}
+	return segs
+}
+
+// drawTrails draws every trail in its track color.
+func drawTrails(img *gocv.Mat, trails map[int][]image.Point, fade float64) {
+	for id, pts := range trails {
+		for _, s := range trailSegments(pts, trackColor(id), fade) {
+			_ = gocv.Line(img, s.From, s.To, s.Color, s.Thickness)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestTrailSegments(t *testing.T) {
+	c := color.RGBA{R: 200, G: 100, A: 255}
+	pts := []image.Point{{0, 0}, {10, 0}, {20, 10}, {30, 30}}
+	tests := []struct {
+		name string
+		pts  []image.Point
+		fade float64
+		want []trailSegment
+	}{
+		{"fading", pts, 0.5, []trailSegment{
+			{image.Pt(0, 0), image.Pt(10, 0), color.RGBA{R: 100, G: 50, A: 255}, 2},
+			{image.Pt(10, 0), image.Pt(20, 10), color.RGBA{R: 150, G: 75, A: 255}, 3},
+			{image.Pt(20, 10), image.Pt(30, 30), color.RGBA{R: 200, G: 100, A: 255}, 3},
+		}},
+		{"no fade", pts[:3], 1, []trailSegment{
+			{image.Pt(0, 0), image.Pt(10, 0), c, 3},
+			{image.Pt(10, 0), image.Pt(20, 10), c, 3},
+		}},
+		{"single segment", pts[:2], 0, []trailSegment{{image.Pt(0, 0), image.Pt(10, 0), c, 3}}},
+		{"single center", pts[:1], 0.5, nil},
+	}
+	for _, tt := range tests {
+		if got := trailSegments(tt.pts, c, tt.fade); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrailsObserve(t *testing.T) {
+	if NewTrails(1, 0.5) != nil {
+		t.Fatal("a one-center trail is not disabled")
+	}
+	tracked := func(id, x int) Detection {
+		return Detection{ID: id, Color: colorHex(trackColor(id)), BBox: Rect{X: x, Y: 0, Width: 10, Height: 20}}
+	}
+	tr := NewTrails(3, 0.5)
+	tr.Observe([]Detection{tracked(1, 0), tracked(2, 100), {ID: 0, BBox: Rect{X: 50, Width: 10, Height: 10}}})
+	tr.Observe([]Detection{tracked(1, 10), tracked(2, 110)})
+	tr.Observe([]Detection{tracked(1, 20), tracked(2, 120)})
+	tr.Observe([]Detection{tracked(1, 30)}) // track 2 retired
+
+	want := map[int][]image.Point{1: {{15, 10}, {25, 10}, {35, 10}}}
+	if got := tr.clone(); !reflect.DeepEqual(got, want) {
+		t.Errorf("trails %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+/* ------------------------------ Still capture ------------------------------ */
+
+// stillJPEG is the encoding of triggered captures: full resolution, high
+// quality, regardless of FACE_IMAGE_FORMAT.
+var stillJPEG = imageFormat{Name: "jpeg", ContentType: "image/jpeg", ext: gocv.JPEGFileExt,
+	params: []int{gocv.IMWriteJpegQuality, 95}}
+
+// Trigger carries on-demand capture requests to the detector loop, which
+// serves them between ticks so the trigger never races the loop's read.
+type Trigger struct {
+	dir      string
+	label    *labelTemplate // text above each box, nil = default
+	requests chan triggerRequest
+}
+
+type triggerRequest struct {
+	reply chan triggerResult
+}
+
+// StillCapture is the result of a triggered capture.
+type StillCapture struct {
+	Path     string   `json:"path"`
+	Snapshot Snapshot `json:"snapshot"`
+}
+
+type triggerResult struct {
+	still StillCapture
+	err   error
+}
+
+// NewTrigger saves captures into dir, creating it if needed; label may be
+// nil.
+func NewTrigger(dir string, label *labelTemplate) (*Trigger, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Trigger{dir: dir, label: label, requests: make(chan triggerRequest)}, nil
+}
+
+// Capture asks the detector loop for an annotated still.
+func (t *Trigger) Capture(ctx context.Context) (StillCapture, error) {
+	req := triggerRequest{reply: make(chan triggerResult, 1)}
+	select {
+	case t.requests <- req:
+	case <-ctx.Done():
+		return StillCapture{}, ctx.Err()
+	}
+	select {
+	case res := <-req.reply:
+		return res.still, res.err
+	case <-ctx.Done():
+		return StillCapture{}, ctx.Err()
+	}
+}
+
+// captureStill reads the next frame, detects faces and saves the frame with
+// the boxes drawn as a JPEG in t's dir. The frame is not published to the
+// store.
+func captureStill(det *DNNDetector, cfg DetectorConfig, t *Trigger) (StillCapture, error) {
+	source, faces, fw, fh, err := det.Detect()
+	if err != nil {
+		return StillCapture{}, err
+	}
+	frame, ok := det.LastFrame()
+	if !ok {
+		return StillCapture{}, ErrEmptyFrame
+	}
+	img := frame.Clone()
+	defer img.Close()
+	drawOverlay(&img, faces, 0, t.label)
+	data, err := stillJPEG.Encode(img)
+	if err != nil {
+		return StillCapture{}, err
+	}
+
+	now := time.Now().Add(cfg.ClockOffset).UTC()
+	path := filepath.Join(t.dir, "capture-"+now.Format("20060102-150405.000")+".jpg")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return StillCapture{}, fmt.Errorf("save capture: %w", err)
+	}
+	return StillCapture{
+		Path: path,
+		Snapshot: Snapshot{
+			Source:      source,
+			FrameWidth:  fw,
+			FrameHeight: fh,
+			Detections:  faces,
+			GeneratedAt: now,
+			Meta:        det.Meta(),
+			Zones:       assignZones(faces, cfg.Zones),
+		},
+	}, nil
+}
+
+// triggerHandler serves POST /trigger/capture.
+func triggerHandler(t *Trigger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		still, err := t.Capture(ctx)
+		switch {
+		case errors.Is(err, ErrEmptyFrame):
+			http.Error(w, "no frame available", http.StatusServiceUnavailable)
+			return
+		case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+			http.Error(w, "detector not running", http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			http.Error(w, "capture failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, still, true)
+	}
+}
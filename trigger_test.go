@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTriggerCapture(t *testing.T) {
+	post := func(trig *Trigger, ctx context.Context) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/trigger/capture", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		triggerHandler(trig)(w, r)
+		return w
+	}
+	cfg := DetectorConfig{Interval: time.Hour}
+	net := &fakeNet{faces: [][4]float32{{0.25, 0.25, 0.5, 0.5}}}
+	d := newFakeDetector(t, cfg, &fakeLoader{next: []*fakeNet{net}})
+	d.cap = fakeSource{w: 320, h: 240}
+	trig, err := NewTrigger(filepath.Join(t.TempDir(), "stills"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Pipeline{Store: NewFaceStore(), Stats: NewStats(), Trigger: trig}
+	stop := startLoop(d, cfg, p)
+	defer stop()
+
+	w := post(trig, context.Background())
+	if w.Code != http.StatusOK {
+		t.Fatalf("capture: %d %s", w.Code, w.Body)
+	}
+	var still StillCapture
+	if err := json.Unmarshal(w.Body.Bytes(), &still); err != nil {
+		t.Fatal(err)
+	}
+	want := Rect{X: 80, Y: 60, Width: 80, Height: 60}
+	if s := still.Snapshot; s.FrameWidth != 320 || s.FrameHeight != 240 || len(s.Detections) != 1 || s.Detections[0].BBox != want {
+		t.Errorf("snapshot %+v, want one face at %v in the 320x240 frame", s, want)
+	}
+	if filepath.Dir(still.Path) != trig.dir || !strings.HasPrefix(filepath.Base(still.Path), "capture-") {
+		t.Errorf("saved to %q, want a capture in %q", still.Path, trig.dir)
+	}
+
+	// Full resolution, with the box drawn.
+	data, err := os.ReadFile(still.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 320 || b.Dy() != 240 {
+		t.Errorf("still is %v, want 320x240", b)
+	}
+	if r, g, _, _ := img.At(want.X+want.Width/2, want.Y).RGBA(); g>>8 < 150 || r>>8 > 100 {
+		t.Errorf("no box edge on the still: rgb %d,%d at the top of the box", r>>8, g>>8)
+	}
+
+	// Captures are not published.
+	_, ver := p.Store.Get()
+	if w := post(trig, context.Background()); w.Code != http.StatusOK {
+		t.Fatalf("second capture: %d %s", w.Code, w.Body)
+	}
+	if _, v := p.Store.Get(); v != ver {
+		t.Errorf("store moved to version %d on a capture", v)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/trigger/capture", nil)
+	w = httptest.NewRecorder()
+	triggerHandler(trig)(w, r)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("GET: %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	// A source without frames.
+	blank := newFakeDetector(t, cfg, &fakeLoader{next: []*fakeNet{{faces: net.faces}}})
+	blank.cap = fakeSource{}
+	blankTrig, _ := NewTrigger(t.TempDir(), nil)
+	stopBlank := startLoop(blank, cfg, &Pipeline{Store: NewFaceStore(), Stats: NewStats(), Trigger: blankTrig})
+	defer stopBlank()
+	if w := post(blankTrig, context.Background()); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "no frame available") {
+		t.Errorf("empty source: %d %s, want 503 no frame available", w.Code, w.Body)
+	}
+
+	// Nobody serving the requests: the client isn't left hanging.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	idle, _ := NewTrigger(t.TempDir(), nil)
+	if w := post(idle, ctx); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "detector not running") {
+		t.Errorf("detector not running: %d %s, want 503", w.Code, w.Body)
+	}
+}
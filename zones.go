@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* --------------------------------- Zones ----------------------------------- */
+
+// Zone is a named polygon in frame coordinates.
+type Zone struct {
+	Name   string  `json:"name"`
+	Points []Point `json:"points"`
+}
+
+// ZoneCount is the number of faces whose center lies in a zone.
+type ZoneCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// parseZones parses "name=x,y x,y x,y;name2=..." (at least three vertices
+// per zone, unique names).
+func parseZones(spec string) ([]Zone, error) {
+	var zones []Zone
+	seen := map[string]bool{}
+	for _, item := range strings.Split(spec, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		name, verts, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("want name=x,y x,y x,y, got %q", item)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate zone %q", name)
+		}
+		seen[name] = true
+		z := Zone{Name: name}
+		for _, v := range strings.Fields(verts) {
+			xs, ys, ok := strings.Cut(v, ",")
+			x, errX := strconv.Atoi(xs)
+			y, errY := strconv.Atoi(ys)
+			if !ok || errX != nil || errY != nil {
+				return nil, fmt.Errorf("zone %q: invalid vertex %q", name, v)
+			}
+			z.Points = append(z.Points, Point{X: x, Y: y})
+		}
+		if len(z.Points) < 3 {
+			return nil, fmt.Errorf("zone %q: need at least 3 vertices", name)
+		}
+		zones = append(zones, z)
+	}
+	return zones, nil
+}
+
+// Contains reports whether p lies inside the polygon (ray casting). Points
+// exactly on an edge or vertex count as inside, so a face centered on a
+// shared border belongs to the first zone listed.
+func (z Zone) Contains(p Point) bool {
+	inside := false
+	n := len(z.Points)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		a, b := z.Points[i], z.Points[j]
+		if onSegment(p, a, b) {
+			return true
+		}
+		if (a.Y > p.Y) != (b.Y > p.Y) {
+			// x of the edge at height p.Y, compared without division
+			lhs := int64(p.X-a.X) * int64(b.Y-a.Y)
+			rhs := int64(b.X-a.X) * int64(p.Y-a.Y)
+			if b.Y > a.Y && lhs < rhs || b.Y < a.Y && lhs > rhs {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// onSegment reports whether p lies on the segment ab.
+func onSegment(p, a, b Point) bool {
+	cross := int64(b.X-a.X)*int64(p.Y-a.Y) - int64(b.Y-a.Y)*int64(p.X-a.X)
+	return cross == 0 &&
+		min(a.X, b.X) <= p.X && p.X <= max(a.X, b.X) &&
+		min(a.Y, b.Y) <= p.Y && p.Y <= max(a.Y, b.Y)
+}
+
+// assignZones sets each detection's Zone to the first zone containing its
+// center and returns the per-zone face counts in configuration order;
+// predicted boxes get their zone but aren't counted.
+func assignZones(dets []Detection, zones []Zone) []ZoneCount {
+	if len(zones) == 0 {
+		return nil
+	}
+	counts := make([]ZoneCount, len(zones))
+	for i, z := range zones {
+		counts[i].Name = z.Name
+	}
+	for i := range dets {
+		d := &dets[i]
+		center := Point{X: d.BBox.X + d.BBox.Width/2, Y: d.BBox.Y + d.BBox.Height/2}
+		for zi, z := range zones {
+			if z.Contains(center) {
+				d.Zone = z.Name
+				if !d.Predicted {
+					counts[zi].Count += max(d.Count, 1)
+				}
+				break
+			}
+		}
+	}
+	return counts
+}
+
+// zonesHandler serves the per-zone counts of the latest snapshot.
+func zonesHandler(store *FaceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snap, _ := store.Get()
+		zones := snap.Zones
+		if zones == nil {
+			zones = []ZoneCount{}
+		}
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(struct {
+			Frame       int64       `json:"frame"`
+			GeneratedAt time.Time   `json:"generated_at"`
+			Zones       []ZoneCount `json:"zones"`
+		}{snap.Frame, snap.GeneratedAt, zones})
+	}
+}
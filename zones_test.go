@@ -0,0 +1,87 @@
+package main
+
+import "testing"
+
+func TestZoneContains(t *testing.T) {
+	// A U open at the top: two arms x 0-30 and 70-100 down to y 100, joined
+	// by a base down to y 130.
+	u := Zone{Name: "u", Points: []Point{
+		{X: 0, Y: 0}, {X: 30, Y: 0}, {X: 30, Y: 100}, {X: 70, Y: 100},
+		{X: 70, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 130}, {X: 0, Y: 130},
+	}}
+	tests := []struct {
+		name string
+		p    Point
+		want bool
+	}{
+		{"left arm", Point{X: 15, Y: 50}, true},
+		{"right arm", Point{X: 85, Y: 50}, true},
+		{"base", Point{X: 50, Y: 120}, true},
+		{"notch", Point{X: 50, Y: 50}, false},
+		{"notch opening", Point{X: 50, Y: 0}, false},
+		{"left of the U", Point{X: -10, Y: 50}, false},
+		{"right of the U", Point{X: 110, Y: 50}, false},
+		{"below", Point{X: 50, Y: 131}, false},
+		{"above", Point{X: 15, Y: -1}, false},
+
+		// The ray from these crosses vertices or runs along a horizontal edge.
+		{"level with the notch bottom, outside", Point{X: -10, Y: 100}, false},
+		{"level with the notch bottom, in an arm", Point{X: 85, Y: 100}, true},
+		{"level with the top, outside", Point{X: 150, Y: 0}, false},
+		{"level with the base, outside", Point{X: -5, Y: 130}, false},
+
+		{"on the top edge", Point{X: 10, Y: 0}, true},
+		{"on the notch bottom", Point{X: 50, Y: 100}, true},
+		{"on a notch side", Point{X: 70, Y: 40}, true},
+		{"on the base edge", Point{X: 40, Y: 130}, true},
+		{"reflex vertex", Point{X: 30, Y: 100}, true},
+		{"convex vertex", Point{X: 100, Y: 130}, true},
+		{"top-left vertex", Point{X: 0, Y: 0}, true},
+	}
+	for _, tt := range tests {
+		if got := u.Contains(tt.p); got != tt.want {
+			t.Errorf("%s %+v: Contains = %v, want %v", tt.name, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestZoneContainsSlantedEdge(t *testing.T) {
+	tri := Zone{Name: "tri", Points: []Point{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 0, Y: 100}}}
+	tests := []struct {
+		p    Point
+		want bool
+	}{
+		{Point{X: 50, Y: 50}, true}, // on the hypotenuse
+		{Point{X: 49, Y: 50}, true},
+		{Point{X: 51, Y: 50}, false},
+		{Point{X: 10, Y: 10}, true},
+		{Point{X: 0, Y: 100}, true},
+		{Point{X: 1, Y: 100}, false},
+	}
+	for _, tt := range tests {
+		if got := tri.Contains(tt.p); got != tt.want {
+			t.Errorf("%+v: Contains = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestParseZones(t *testing.T) {
+	zones, err := parseZones("door=0,0 10,0 10,10; desk = 20,20 30,20 30,30 20,30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 2 || zones[1].Name != "desk" || len(zones[1].Points) != 4 {
+		t.Fatalf("zones = %+v", zones)
+	}
+
+	for _, spec := range []string{
+		"door=0,0 10,0",               // too few vertices
+		"door=0,0 10,0 10,x",          // invalid vertex
+		"=0,0 10,0 10,10",             // no name
+		"a=0,0 1,0 1,1;a=0,0 1,0 1,1", // duplicate
+	} {
+		if _, err := parseZones(spec); err == nil {
+			t.Errorf("parseZones(%q) accepted", spec)
+		}
+	}
+}